@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -19,12 +20,38 @@ type Cache[T any] interface {
 }
 
 type redisCache[T any] struct {
-	client *redis.Client
+	client    *redis.Client
+	keyPrefix string
+}
+
+// Option configures caches created by NewRedisCache
+type Option func(opts *cacheOptions)
+
+type cacheOptions struct {
+	namespace string
+	version   int
+}
+
+// WithNamespace prefixes all keys by the namespace, e.g. "invoices:key", to share a DB between different caches
+func WithNamespace(namespace string) Option {
+	return func(opts *cacheOptions) {
+		opts.namespace = namespace
+	}
+}
+
+// WithVersion prefixes all keys by the version after namespace, e.g. "invoices:v3:key"
+//
+// The version should be bumped on incompatible changes of T, so that entries written by old releases are ignored.
+func WithVersion(version int) Option {
+	return func(opts *cacheOptions) {
+		opts.version = version
+	}
 }
 
 var ctx = context.Background()
 
-func NewRedisCache[T any](addr string, pwd string, db int, useTls bool) Cache[T] {
+// NewRedisCache creates a Cache[T] storing values as JSON in Redis, with keys prefixed by namespace and version if set
+func NewRedisCache[T any](addr string, pwd string, db int, useTls bool, options ...Option) Cache[T] {
 	var client *redis.Client
 	if useTls {
 		client = redis.NewClient(&redis.Options{
@@ -41,12 +68,28 @@ func NewRedisCache[T any](addr string, pwd string, db int, useTls bool) Cache[T]
 		})
 	}
 	return redisCache[T]{
-		client: client,
+		client:    client,
+		keyPrefix: getKeyPrefix(options),
+	}
+}
+
+func getKeyPrefix(options []Option) string {
+	opts := cacheOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	prefix := ""
+	if opts.namespace != "" {
+		prefix += opts.namespace + ":"
+	}
+	if opts.version != 0 {
+		prefix += "v" + strconv.Itoa(opts.version) + ":"
 	}
+	return prefix
 }
 
 func (cache redisCache[T]) Get(key string) (*T, error) {
-	val, err := cache.client.Get(ctx, key).Result()
+	val, err := cache.client.Get(ctx, cache.keyPrefix+key).Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -66,7 +109,7 @@ func (cache redisCache[T]) Set(key string, value T, expiration time.Duration) er
 	if err != nil {
 		return err
 	}
-	err = cache.client.Set(ctx, key, bytes, expiration).Err()
+	err = cache.client.Set(ctx, cache.keyPrefix+key, bytes, expiration).Err()
 	if err != nil {
 		return err
 	}
@@ -79,11 +122,11 @@ func (cache redisCache[T]) SetNX(key string, value T, expiration time.Duration)
 	if err != nil {
 		return false, err
 	}
-	return cache.client.SetNX(ctx, key, bytes, expiration).Result()
+	return cache.client.SetNX(ctx, cache.keyPrefix+key, bytes, expiration).Result()
 }
 
 func (cache redisCache[T]) Del(key string) error {
-	err := cache.client.Del(ctx, key).Err()
+	err := cache.client.Del(ctx, cache.keyPrefix+key).Err()
 	if err != nil {
 		return err
 	}
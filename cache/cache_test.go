@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	assert.Equal(t, "", getKeyPrefix(nil))
+	assert.Equal(t, "invoices:", getKeyPrefix([]Option{WithNamespace("invoices")}))
+	assert.Equal(t, "v2:", getKeyPrefix([]Option{WithVersion(2)}))
+	assert.Equal(t, "invoices:v3:", getKeyPrefix([]Option{WithVersion(3), WithNamespace("invoices")}))
+
+	c := NewRedisCache[int]("localhost:6379", "", 0, false, WithNamespace("invoices"), WithVersion(3))
+	assert.Equal(t, "invoices:v3:", c.(redisCache[int]).keyPrefix)
+}
@@ -16,16 +16,28 @@ package cacher
 import (
 	"fmt"
 	"hash/fnv"
-	"io/ioutil"
 	"net/http"
-	"os"
-	"path"
 	"strings"
+	"time"
 
+	"github.com/relex/gotils/httpclient"
 	"github.com/relex/gotils/logger"
 )
 
-// getFileNameFromURL computes FNV-1a hash of the URL as filename to avoid name collisions
+// httpClient downloads files for all functions in this package
+//
+// It has no overall timeout like the default http.Client, so that large or slow downloads are not cut off and replaced
+// by stale cache. Only connections and TLS handshakes are limited by the defaults of httpclient.
+var httpClient = &http.Client{Transport: httpclient.NewTransport(httpclient.Options{Name: "Cacher"})}
+
+// SetHTTPClient replaces the client to download files, e.g. with retry and custom timeout by httpclient.New
+//
+// It should be called before any download starts.
+func SetHTTPClient(client *http.Client) {
+	httpClient = client
+}
+
+// getFileNameFromURL computes FNV-1a hash of the URL as filename or cache key to avoid name collisions
 func getFileNameFromURL(url string) string {
 	hash := fnv.New32a()
 	hash.Write([]byte(url))
@@ -36,6 +48,9 @@ func getFileNameFromURL(url string) string {
 //
 // If the URL is not available, attempt to read the previous response from cache
 //
+// Responses are decompressed by Content-Encoding (gzip or deflate), and converted to UTF-8 if they're text with
+// another charset in Content-Type.
+//
 // The function only returns remote error if both downloading from the URL and reading from existing cache fail,
 // cache-related error is only logged, not reported.
 func GetFromURLOrDefaultCache(req *http.Request, cacheDir string) (string, error) {
@@ -56,61 +71,104 @@ func GetFromURLOrDefaultCache(req *http.Request, cacheDir string) (string, error
 //
 // The function only returns remote error if both downloading from the URL and reading from existing cache fail,
 // cache-related error is only logged, not reported.
+//
+// Cache can be read first or only by WithReadOptions.
 func GetFromURLOrDefaultCacheWithCallback(req *http.Request, cacheDir string, onData func([]byte) error) error {
+	return GetFromURLOrStoreWithCallback(req, NewFileStore(cacheDir), onData)
+}
+
+// GetFromURLOrStore downloads file into the store and returns its content, like GetFromURLOrDefaultCache
+func GetFromURLOrStore(req *http.Request, store CacheStore) (string, error) {
+	var result string
+	err := GetFromURLOrStoreWithCallback(req, store, func(data []byte) error {
+		result = string(data)
+		return nil
+	})
+	return result, err
+}
+
+// GetFromURLOrStoreWithCallback downloads file into the store and passes the content to the onData callback, like
+// GetFromURLOrDefaultCacheWithCallback
+func GetFromURLOrStoreWithCallback(req *http.Request, store CacheStore, onData func([]byte) error) error {
+	_, err := GetFromURLOrStoreWithResult(req, store, onData)
+	return err
+}
+
+// GetFromURLOrStoreWithResult downloads file into the store and passes the content to the onData callback like
+// GetFromURLOrStoreWithCallback, and returns the metadata of remote response and cache used, e.g. to warn about old
+// cache:
+//
+//	result, err := cacher.GetFromURLOrStoreWithResult(req, store, onData)
+//	if err == nil && result.FromCache && result.CacheAge() > 24*time.Hour {
+//		logger.Warnf("using cache modified at %s", result.CacheModTime)
+//	}
+func GetFromURLOrStoreWithResult(req *http.Request, store CacheStore, onData func([]byte) error) (Result, error) {
+	result := Result{}
 
 	clogger := logger.WithFields(logger.Fields{
 		"component": "Cacher",
 		"url":       req.URL.String(),
 	})
 
-	filename := getFileNameFromURL(req.URL.String())
-	filepath := path.Join(cacheDir, filename)
+	key := getFileNameFromURL(req.URL.String())
+
+	switch opts := getReadOptions(req); opts.Mode {
+	case CacheOnly:
+		return result, getCacheOnly(store, key, onData, &result)
+	case CacheFirst:
+		if getFreshCache(clogger, store, key, opts.MaxAge, onData, &result) {
+			return result, nil
+		}
+	}
 
-	httpClient := &http.Client{}
 	resp, reqErr := httpClient.Do(req)
 
 	if reqErr != nil {
-		return getCache(clogger, filepath, onData, fmt.Errorf("failed to open URL: %w", reqErr))
+		return result, getCache(clogger, store, key, onData, fmt.Errorf("failed to open URL: %w", reqErr), &result)
+	}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		result.Header = resp.Header
 	}
 
 	// Resp could be nil in some cases
 	// Unauthorized 401 or Forbidden 403 don't return err, this is written in request
 	switch {
 	case resp == nil:
-		return getCache(clogger, filepath, onData, fmt.Errorf("failed to open URL: no response"))
+		return result, getCache(clogger, store, key, onData, fmt.Errorf("failed to open URL: no response"), &result)
 	case resp.StatusCode >= 300:
-		return getCache(clogger, filepath, onData, fmt.Errorf("failed to open URL: %s", resp.Status))
+		return result, getCache(clogger, store, key, onData, fmt.Errorf("failed to open URL: %s", resp.Status), &result)
 	}
 	defer resp.Body.Close()
 
 	// Read from HTTP request
-	body, respErr := ioutil.ReadAll(resp.Body)
+	body, contentType, respErr := readResponseBody(resp)
 	if respErr != nil {
-		return getCache(clogger, filepath, onData, fmt.Errorf("failed to read request body from URL: %w", respErr))
+		return result, getCache(clogger, store, key, onData, fmt.Errorf("failed to read request body from URL: %w", respErr), &result)
 	}
 
 	if dataErr := onData(body); dataErr != nil {
-		return getCache(clogger, filepath, onData, fmt.Errorf("failed to process request body from URL: %w", dataErr))
+		return result, getCache(clogger, store, key, onData, fmt.Errorf("failed to process request body from URL: %w", dataErr), &result)
 	}
 
-	// Create cache Folder
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		clogger.Error("failed to create cache dir: ", err)
+	var saveErr error
+	if metaStore, ok := store.(MetadataStore); ok {
+		saveErr = metaStore.PutWithMetadata(key, body, CacheMetadata{URL: req.URL.String(), ContentType: contentType})
+	} else {
+		saveErr = store.Put(key, body)
 	}
-
-	// Create file to write data
-	if err := ioutil.WriteFile(filepath, body, 0644); err != nil {
-		clogger.Error("failed to save cache: ", err)
+	if saveErr != nil {
+		clogger.Error("failed to save cache: ", saveErr)
 	}
 
-	return nil
+	return result, nil
 }
 
-func getCache(clogger logger.Logger, filepath string, onData func([]byte) error, remoteErr error) error {
-	// Read from file if request fails
-	data, fileErr := ioutil.ReadFile(filepath)
-	if fileErr != nil {
-		clogger.Errorf("failed to read cache (remote URL is unavailable): %s", fileErr)
+func getCache(clogger logger.Logger, store CacheStore, key string, onData func([]byte) error, remoteErr error, result *Result) error {
+	// Read from cache if request fails
+	data, cacheErr := store.Get(key)
+	if cacheErr != nil {
+		clogger.Errorf("failed to read cache (remote URL is unavailable): %s", cacheErr)
 		return remoteErr
 	}
 
@@ -118,6 +176,7 @@ func getCache(clogger logger.Logger, filepath string, onData func([]byte) error,
 		clogger.Errorf("failed to process cache (remote URL is unavailable): %s", dataErr)
 		return remoteErr
 	}
+	result.setFromCache(store, key, remoteErr)
 
 	// cache is good, log remote error as warning
 	if remoteErr != nil {
@@ -131,3 +190,37 @@ func getCache(clogger logger.Logger, filepath string, onData func([]byte) error,
 
 	return nil
 }
+
+// getCacheOnly reads cache without falling back to remote URL
+func getCacheOnly(store CacheStore, key string, onData func([]byte) error, result *Result) error {
+	data, cacheErr := store.Get(key)
+	if cacheErr != nil {
+		return fmt.Errorf("failed to read cache in cache-only mode: %w", cacheErr)
+	}
+	if dataErr := onData(data); dataErr != nil {
+		return fmt.Errorf("failed to process cache in cache-only mode: %w", dataErr)
+	}
+	result.setFromCache(store, key, nil)
+	return nil
+}
+
+// getFreshCache reads cache if it's not older than maxAge, returning false if it's not used
+func getFreshCache(clogger logger.Logger, store CacheStore, key string, maxAge time.Duration, onData func([]byte) error, result *Result) bool {
+	info, statErr := store.Stat(key)
+	if statErr != nil || time.Since(info.ModTime) > maxAge {
+		return false
+	}
+	data, cacheErr := store.Get(key)
+	if cacheErr != nil {
+		clogger.Warnf("failed to read fresh cache: %s", cacheErr)
+		return false
+	}
+	if dataErr := onData(data); dataErr != nil {
+		clogger.Warnf("failed to process fresh cache: %s", dataErr)
+		return false
+	}
+	clogger.Debugf("used cache modified at %s", info.ModTime.Format(time.RFC3339))
+	result.FromCache = true
+	result.CacheModTime = info.ModTime
+	return true
+}
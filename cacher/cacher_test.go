@@ -17,9 +17,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/relex/gotils/logger"
 	"github.com/stretchr/testify/assert"
@@ -121,6 +123,36 @@ func TestCacherGetFromCacheForBadServer(t *testing.T) {
 	})
 }
 
+func TestGetJSONOrDefaultCache(t *testing.T) {
+	serveAndCache()
+
+	type targetGroup struct {
+		Targets []string
+		Labels  map[string]string
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s", Addr), nil)
+
+	shutdownServer := StartHTTPServer("../test_data/not-json.json")
+	groups, err := GetJSONOrDefaultCache(req, cacheDir, JSONOptions[[]targetGroup]{})
+	shutdownServer()
+	assert.Nil(t, err)
+	if assert.Len(t, groups, 2) { // should be the cache
+		assert.Equal(t, []string{"baz.domain.com"}, groups[1].Targets)
+	}
+
+	shutdownServer = StartHTTPServer("../test_data/cacher-response-cache.json")
+	defer shutdownServer()
+	_, err = GetJSONOrDefaultCache(req, cacheDir, JSONOptions[[]targetGroup]{
+		Validate: func(value []targetGroup) error {
+			return fmt.Errorf("bad groups: %d", len(value))
+		},
+	})
+	assert.EqualError(t, err, "failed to process request body from URL: failed to validate JSON: bad groups: 2")
+
+	_, err = GetJSONOrDefaultCache(req, cacheDir, JSONOptions[[]struct{ Targets []string }]{DisallowUnknownFields: true})
+	assert.ErrorContains(t, err, `failed to parse JSON: json: unknown field "labels"`)
+}
+
 func TestCacherGetWithoutCacheFileAndConnection(t *testing.T) {
 	removeCache()
 
@@ -144,3 +176,39 @@ func removeCache() {
 	filePath := path.Join(cacheDir, getFileNameFromURL(fmt.Sprintf("http://%s", Addr)))
 	os.Remove(filePath)
 }
+
+func TestGetWithResult(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", "42")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"name":"foo"}`))
+	}))
+	defer server.Close()
+	store := NewMemoryStore()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	value, result, err := GetJSONOrStoreWithResult(req, store, JSONOptions[map[string]string]{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "foo"}, value)
+	assert.False(t, result.FromCache)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "42", result.Header.Get("X-Version"))
+	assert.Zero(t, result.CacheAge())
+
+	status = http.StatusServiceUnavailable
+	value, result, err = GetJSONOrStoreWithResult(req, store, JSONOptions[map[string]string]{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "foo"}, value)
+	assert.True(t, result.FromCache)
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	assert.EqualError(t, result.RemoteErr, "failed to open URL: 503 Service Unavailable")
+	assert.False(t, result.CacheModTime.IsZero())
+	assert.Less(t, result.CacheAge(), time.Minute)
+
+	result, err = GetFromURLOrStoreWithResult(WithReadOptions(req, ReadOptions{Mode: CacheOnly}), store, func([]byte) error { return nil })
+	assert.NoError(t, err)
+	assert.True(t, result.FromCache)
+	assert.Zero(t, result.StatusCode)
+	assert.Nil(t, result.RemoteErr)
+}
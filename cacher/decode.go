@@ -0,0 +1,99 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cacher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// readResponseBody reads the body decompressed by Content-Encoding and converted to UTF-8 by charset in Content-Type
+// of text responses, and returns the content type with charset updated
+func readResponseBody(resp *http.Response) ([]byte, string, error) {
+	reader, decErr := newDecompressReader(resp.Header.Get("Content-Encoding"), resp.Body)
+	if decErr != nil {
+		return nil, "", decErr
+	}
+	body, readErr := io.ReadAll(reader)
+	if readErr != nil {
+		return nil, "", readErr
+	}
+	return decodeCharset(body, resp.Header.Get("Content-Type"))
+}
+
+// newDecompressReader creates a reader to decompress data by the value of Content-Encoding, e.g. "gzip"
+//
+// Responses decompressed by http.Transport have the header removed.
+func newDecompressReader(encoding string, reader io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return reader, nil
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip: %w", err)
+		}
+		return gzipReader, nil
+	case "deflate":
+		// "deflate" should be zlib format but some servers send raw deflate
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		if zlibReader, zerr := zlib.NewReader(bytes.NewReader(data)); zerr == nil {
+			return zlibReader, nil
+		}
+		return flate.NewReader(bytes.NewReader(data)), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding: '%s'", encoding)
+	}
+}
+
+// decodeCharset converts text data to UTF-8 by the charset in contentType, e.g. "text/csv; charset=ISO-8859-1"
+func decodeCharset(data []byte, contentType string) ([]byte, string, error) {
+	if contentType == "" {
+		return data, contentType, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return data, contentType, nil // ignore malformed header as before
+	}
+	charset := strings.ToLower(params["charset"])
+	if !isTextMediaType(mediaType) || charset == "" || charset == "utf-8" || charset == "utf8" || charset == "us-ascii" {
+		return data, contentType, nil
+	}
+	encoding, encErr := htmlindex.Get(charset)
+	if encErr != nil {
+		return nil, "", fmt.Errorf("unsupported charset: '%s'", params["charset"])
+	}
+	decoded, decErr := encoding.NewDecoder().Bytes(data)
+	if decErr != nil {
+		return nil, "", fmt.Errorf("failed to decode charset '%s': %w", params["charset"], decErr)
+	}
+	params["charset"] = "utf-8"
+	return decoded, mime.FormatMediaType(mediaType, params), nil
+}
+
+func isTextMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "/json") ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml")
+}
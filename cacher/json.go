@@ -0,0 +1,71 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cacher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// JSONOptions configures the parsing and validation of GetJSONOrDefaultCache
+type JSONOptions[T any] struct {
+	// DisallowUnknownFields rejects JSON objects containing fields not in the destination type
+	DisallowUnknownFields bool
+
+	// Validate checks the parsed value as the schema of data, optional
+	Validate func(value T) error
+}
+
+// GetJSONOrDefaultCache downloads JSON into cacheDir and returns the parsed and validated value
+//
+// If the URL is not available or its content cannot be parsed or validated, attempt to use the previous response from
+// cache. Only valid responses are saved in cache.
+//
+// See GetFromURLOrDefaultCacheWithCallback for error handling.
+func GetJSONOrDefaultCache[T any](req *http.Request, cacheDir string, opts JSONOptions[T]) (T, error) {
+	return GetJSONOrStore(req, NewFileStore(cacheDir), opts)
+}
+
+// GetJSONOrStore downloads JSON into the store and returns the parsed and validated value, like
+// GetJSONOrDefaultCache
+func GetJSONOrStore[T any](req *http.Request, store CacheStore, opts JSONOptions[T]) (T, error) {
+	value, _, err := GetJSONOrStoreWithResult(req, store, opts)
+	return value, err
+}
+
+// GetJSONOrStoreWithResult downloads JSON into the store and returns the parsed and validated value like
+// GetJSONOrStore, along with the metadata of remote response and cache used, see GetFromURLOrStoreWithResult
+func GetJSONOrStoreWithResult[T any](req *http.Request, store CacheStore, opts JSONOptions[T]) (T, Result, error) {
+	var value T
+	result, err := GetFromURLOrStoreWithResult(req, store, func(data []byte) error {
+		var parsed T
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if opts.DisallowUnknownFields {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(&parsed); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		if opts.Validate != nil {
+			if err := opts.Validate(parsed); err != nil {
+				return fmt.Errorf("failed to validate JSON: %w", err)
+			}
+		}
+		value = parsed
+		return nil
+	})
+	return value, result, err
+}
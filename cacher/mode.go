@@ -0,0 +1,62 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cacher
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ReadMode decides whether cache or remote URL is read first
+type ReadMode int
+
+// Read modes
+const (
+	RemoteFirst ReadMode = iota // download from the URL and fall back to cache if failed, by default
+	CacheFirst                  // use cache without downloading if fresher than MaxAge, otherwise same as RemoteFirst
+	CacheOnly                   // use cache only and never download, e.g. for air-gapped test runs
+)
+
+// ReadOptions configures how cache and remote URL are read
+type ReadOptions struct {
+	Mode   ReadMode
+	MaxAge time.Duration // max age of cache to be used without downloading in CacheFirst mode
+}
+
+type readOptionsKey struct{}
+
+var defaultReadOptions atomic.Pointer[ReadOptions]
+
+// SetDefaultReadOptions sets the options for requests without options from WithReadOptions
+func SetDefaultReadOptions(opts ReadOptions) {
+	defaultReadOptions.Store(&opts)
+}
+
+// WithReadOptions returns a shallow copy of the request with options for all functions in this package, e.g.:
+//
+//	body, err := cacher.GetFromURLOrStore(cacher.WithReadOptions(req, cacher.ReadOptions{Mode: cacher.CacheFirst, MaxAge: time.Hour}), store)
+func WithReadOptions(req *http.Request, opts ReadOptions) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), readOptionsKey{}, opts))
+}
+
+func getReadOptions(req *http.Request) ReadOptions {
+	if opts, found := req.Context().Value(readOptionsKey{}).(ReadOptions); found {
+		return opts
+	}
+	if opts := defaultReadOptions.Load(); opts != nil {
+		return *opts
+	}
+	return ReadOptions{Mode: RemoteFirst}
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cacher
+
+import (
+	"net/http"
+	"time"
+)
+
+// Result is the metadata of remote response and cache used by GetFromURLOrStoreWithResult
+type Result struct {
+	FromCache    bool        // whether the data came from cache instead of the remote URL
+	StatusCode   int         // status code of remote response, zero if not downloaded or without response
+	Header       http.Header // header of remote response, nil if not downloaded or without response
+	CacheModTime time.Time   // modification time of the cache used, zero if not from cache or unknown
+	RemoteErr    error       // error of the remote URL if the cache is used as fallback
+}
+
+// CacheAge returns the time since the cache used was modified, or zero if not from cache or unknown
+func (r Result) CacheAge() time.Duration {
+	if r.CacheModTime.IsZero() {
+		return 0
+	}
+	return time.Since(r.CacheModTime)
+}
+
+// setFromCache marks the result as from the cache by key, with the modification time from the store if possible
+func (r *Result) setFromCache(store CacheStore, key string, remoteErr error) {
+	r.FromCache = true
+	r.RemoteErr = remoteErr
+	if info, err := store.Stat(key); err == nil {
+		r.CacheModTime = info.ModTime
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cacher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/relex/gotils/cache"
+	"github.com/relex/gotils/fsutil"
+)
+
+// ErrCacheMiss is returned (wrapped) by CacheStore if the key doesn't exist
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheStore stores the latest responses by keys, as fallback when remote URLs are unavailable
+type CacheStore interface {
+	// Get returns the data by key, or ErrCacheMiss if not found
+	Get(key string) ([]byte, error)
+
+	// Put stores the data by key, replacing any previous data
+	Put(key string, data []byte) error
+
+	// Stat returns information of the data by key without reading it, or ErrCacheMiss if not found
+	Stat(key string) (CacheInfo, error)
+}
+
+// MetadataStore is a CacheStore which also stores metadata of responses, used instead of Put if implemented
+type MetadataStore interface {
+	CacheStore
+
+	// PutWithMetadata stores the data with metadata by key, replacing any previous data
+	PutWithMetadata(key string, data []byte, metadata CacheMetadata) error
+}
+
+// CacheInfo is information of stored data
+type CacheInfo struct {
+	Size     int64 // size in store, e.g. compressed size
+	ModTime  time.Time
+	Metadata CacheMetadata // empty unless the store is MetadataStore
+}
+
+// CacheMetadata is information of the response of stored data
+type CacheMetadata struct {
+	URL         string
+	ContentType string // with charset changed to "utf-8" if converted
+}
+
+// CacheEntry is the data stored in Redis by RedisStore
+type CacheEntry struct {
+	Data    []byte    `json:"data"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// fileStore stores data in files of a local directory
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore creates a CacheStore which stores data in files under the directory, created if not existing
+func NewFileStore(dir string) CacheStore {
+	return fileStore{dir}
+}
+
+func (s fileStore) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(path.Join(s.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", ErrCacheMiss, err)
+	}
+	return data, err
+}
+
+func (s fileStore) Put(key string, data []byte) error {
+	return fsutil.AtomicWriteFile(path.Join(s.dir, key), data, fsutil.AtomicWriteOptions{CreateDir: true})
+}
+
+func (s fileStore) Stat(key string) (CacheInfo, error) {
+	info, err := os.Stat(path.Join(s.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return CacheInfo{}, fmt.Errorf("%w: %v", ErrCacheMiss, err)
+	}
+	if err != nil {
+		return CacheInfo{}, err
+	}
+	return CacheInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// compressedFileStore stores data with metadata in gzip files of a local directory
+type compressedFileStore struct {
+	fileStore
+}
+
+// NewCompressedFileStore creates a MetadataStore which stores data in gzip files under the directory, created if not
+// existing, e.g. for large JSON responses
+//
+// Metadata are stored in gzip headers: URL as the name and content type as the comment, e.g. to be shown by
+// "gzip -lN". Files are named by keys with ".gz" suffix.
+func NewCompressedFileStore(dir string) MetadataStore {
+	return compressedFileStore{fileStore{dir}}
+}
+
+func (s compressedFileStore) Get(key string) ([]byte, error) {
+	compressed, err := s.fileStore.Get(key + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress cache: %w", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress cache: %w", err)
+	}
+	return data, nil
+}
+
+func (s compressedFileStore) Put(key string, data []byte) error {
+	return s.PutWithMetadata(key, data, CacheMetadata{})
+}
+
+func (s compressedFileStore) PutWithMetadata(key string, data []byte, metadata CacheMetadata) error {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	writer.Name = metadata.URL
+	writer.Comment = metadata.ContentType
+	writer.ModTime = time.Now()
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to compress cache: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress cache: %w", err)
+	}
+	return s.fileStore.Put(key+".gz", buffer.Bytes())
+}
+
+func (s compressedFileStore) Stat(key string) (CacheInfo, error) {
+	info, err := s.fileStore.Stat(key + ".gz")
+	if err != nil {
+		return info, err
+	}
+	file, err := os.Open(path.Join(s.dir, key+".gz"))
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file) // only reads header
+	if err != nil {
+		return info, fmt.Errorf("failed to read cache header: %w", err)
+	}
+	info.Metadata = CacheMetadata{URL: reader.Name, ContentType: reader.Comment}
+	return info, nil
+}
+
+// memoryStore stores data in memory of the current process
+type memoryStore struct {
+	lock    sync.RWMutex
+	entries map[string]CacheEntry
+}
+
+// NewMemoryStore creates a CacheStore which keeps data in memory, e.g. for short-running processes or tests
+func NewMemoryStore() CacheStore {
+	return &memoryStore{entries: make(map[string]CacheEntry)}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	entry, found := s.entries[key]
+	if !found {
+		return nil, ErrCacheMiss
+	}
+	return append([]byte{}, entry.Data...), nil
+}
+
+func (s *memoryStore) Put(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries[key] = CacheEntry{Data: append([]byte{}, data...), ModTime: time.Now()}
+	return nil
+}
+
+func (s *memoryStore) Stat(key string) (CacheInfo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	entry, found := s.entries[key]
+	if !found {
+		return CacheInfo{}, ErrCacheMiss
+	}
+	return CacheInfo{Size: int64(len(entry.Data)), ModTime: entry.ModTime}, nil
+}
+
+// redisStore stores data in Redis by the cache package, to be shared between replicas
+type redisStore struct {
+	cache      cache.Cache[CacheEntry]
+	keyPrefix  string
+	expiration time.Duration
+}
+
+// NewRedisStore creates a CacheStore which stores data in Redis, to share fallback caches between replicas, e.g.:
+//
+//	cacher.NewRedisStore(cache.NewRedisCache[cacher.CacheEntry]("redis:6379", password, 0, true), "cacher:", 7*24*time.Hour)
+//
+// Keys are prefixed by keyPrefix. Zero expiration means data never expire.
+func NewRedisStore(redisCache cache.Cache[CacheEntry], keyPrefix string, expiration time.Duration) CacheStore {
+	return redisStore{
+		cache:      redisCache,
+		keyPrefix:  keyPrefix,
+		expiration: expiration,
+	}
+}
+
+func (s redisStore) Get(key string) ([]byte, error) {
+	entry, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Data, nil
+}
+
+func (s redisStore) Put(key string, data []byte) error {
+	if err := s.cache.Set(s.keyPrefix+key, CacheEntry{Data: data, ModTime: time.Now()}, s.expiration); err != nil {
+		return fmt.Errorf("failed to set '%s' in Redis: %w", s.keyPrefix+key, err)
+	}
+	return nil
+}
+
+func (s redisStore) Stat(key string) (CacheInfo, error) {
+	entry, err := s.get(key)
+	if err != nil {
+		return CacheInfo{}, err
+	}
+	return CacheInfo{Size: int64(len(entry.Data)), ModTime: entry.ModTime}, nil
+}
+
+func (s redisStore) get(key string) (*CacheEntry, error) {
+	entry, err := s.cache.Get(s.keyPrefix + key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get '%s' from Redis: %w", s.keyPrefix+key, err)
+	}
+	if entry == nil {
+		return nil, ErrCacheMiss
+	}
+	return entry, nil
+}
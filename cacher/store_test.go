@@ -0,0 +1,182 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cacher
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedisCache implements cache.Cache in memory, with JSON serialization like the Redis implementation
+type fakeRedisCache map[string]string
+
+func (c fakeRedisCache) Get(key string) (*CacheEntry, error) {
+	val, found := c[key]
+	if !found {
+		return nil, nil
+	}
+	result := &CacheEntry{}
+	return result, json.Unmarshal([]byte(val), result)
+}
+
+func (c fakeRedisCache) Set(key string, value CacheEntry, expiration time.Duration) error {
+	bytes, err := json.Marshal(value)
+	c[key] = string(bytes)
+	return err
+}
+
+func (c fakeRedisCache) SetNX(key string, value CacheEntry, expiration time.Duration) (bool, error) {
+	if _, found := c[key]; found {
+		return false, nil
+	}
+	return true, c.Set(key, value, expiration)
+}
+
+func (c fakeRedisCache) Del(key string) error {
+	delete(c, key)
+	return nil
+}
+
+func (c fakeRedisCache) HealthCheck() error {
+	return nil
+}
+
+func TestCacheStores(t *testing.T) {
+	redisCache := fakeRedisCache{}
+	stores := map[string]CacheStore{
+		"file":       NewFileStore(t.TempDir() + "/sub"),
+		"compressed": NewCompressedFileStore(t.TempDir() + "/sub"),
+		"memory":     NewMemoryStore(),
+		"redis":      NewRedisStore(redisCache, "cacher:", time.Hour),
+	}
+	for name, store := range stores {
+		_, err := store.Get("key1")
+		assert.ErrorIs(t, err, ErrCacheMiss, name)
+		_, err = store.Stat("key1")
+		assert.ErrorIs(t, err, ErrCacheMiss, name)
+
+		assert.NoError(t, store.Put("key1", []byte("hello")), name)
+		data, err := store.Get("key1")
+		assert.NoError(t, err, name)
+		assert.Equal(t, "hello", string(data), name)
+		info, err := store.Stat("key1")
+		assert.NoError(t, err, name)
+		if name != "compressed" {
+			assert.EqualValues(t, 5, info.Size, name)
+		}
+		assert.WithinDuration(t, time.Now(), info.ModTime, time.Minute, name)
+	}
+	assert.Contains(t, redisCache, "cacher:key1")
+}
+
+func TestDecodeResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			writer.Write([]byte(`{"name":"inventory"}`))
+			writer.Close()
+		case "/deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Header().Set("Content-Type", "text/csv; charset=ISO-8859-1")
+			writer := zlib.NewWriter(w)
+			writer.Write([]byte("name\nM\xfcnchen\n"))
+			writer.Close()
+		case "/unknown":
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte("?"))
+		}
+	}))
+	defer server.Close()
+	store := NewCompressedFileStore(t.TempDir())
+
+	req, _ := http.NewRequest("GET", server.URL+"/gzip", nil)
+	req.Header.Set("Accept-Encoding", "gzip") // otherwise decompressed by http.Transport
+	body, err := GetFromURLOrStore(req, store)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"inventory"}`, body)
+
+	req, _ = http.NewRequest("GET", server.URL+"/deflate", nil)
+	body, err = GetFromURLOrStore(req, store)
+	assert.NoError(t, err)
+	assert.Equal(t, "name\nMünchen\n", body)
+	info, err := store.Stat(getFileNameFromURL(req.URL.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMetadata{URL: server.URL + "/deflate", ContentType: "text/csv; charset=utf-8"}, info.Metadata)
+
+	req, _ = http.NewRequest("GET", server.URL+"/unknown", nil)
+	_, err = GetFromURLOrStore(req, store)
+	assert.ErrorContains(t, err, "failed to read request body from URL: unsupported Content-Encoding: 'br'")
+}
+
+func TestGetFromURLOrStore(t *testing.T) {
+	store := NewMemoryStore()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s/store", Addr), nil)
+
+	shutdownServer := StartHTTPServer("../test_data/cacher-response-cache.json")
+	body, err := GetFromURLOrStore(req, store)
+	shutdownServer()
+	assert.NoError(t, err)
+	assert.Contains(t, body, "foo.domain.com")
+
+	cached, err := GetFromURLOrStore(req, store)
+	assert.NoError(t, err)
+	assert.Equal(t, body, cached, "should be the cache")
+}
+
+func TestReadModes(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte("remote"))
+	}))
+	defer server.Close()
+	store := NewMemoryStore()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	_, err := GetFromURLOrStore(WithReadOptions(req, ReadOptions{Mode: CacheOnly}), store)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	assert.ErrorContains(t, err, "failed to read cache in cache-only mode")
+
+	assert.NoError(t, store.Put(getFileNameFromURL(server.URL), []byte("cached")))
+	body, err := GetFromURLOrStore(WithReadOptions(req, ReadOptions{Mode: CacheOnly}), store)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", body)
+
+	body, err = GetFromURLOrStore(WithReadOptions(req, ReadOptions{Mode: CacheFirst, MaxAge: time.Hour}), store)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", body, "fresh cache should be used")
+	assert.EqualValues(t, 0, calls.Load())
+
+	body, err = GetFromURLOrStore(WithReadOptions(req, ReadOptions{Mode: CacheFirst, MaxAge: 0}), store)
+	assert.NoError(t, err)
+	assert.Equal(t, "remote", body, "stale cache should not be used")
+	assert.EqualValues(t, 1, calls.Load())
+
+	SetDefaultReadOptions(ReadOptions{Mode: CacheOnly})
+	defer SetDefaultReadOptions(ReadOptions{})
+	server.Close()
+	body, err = GetFromURLOrStore(req, store)
+	assert.NoError(t, err)
+	assert.Equal(t, "remote", body, "cache should be updated from the last download")
+}
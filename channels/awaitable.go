@@ -14,9 +14,12 @@
 package channels
 
 import (
+	"context"
 	"reflect"
 	"sync"
 	"time"
+
+	"github.com/relex/gotils/logger"
 )
 
 // Awaitable is a signal that can waited on.
@@ -30,17 +33,55 @@ type Awaitable interface {
 	WaitTimer(timerC <-chan time.Time) bool
 }
 
+// CauseAwaitable is an Awaitable which can be signaled with a cause, implemented by all awaitables in this package
+type CauseAwaitable interface {
+	Awaitable
+	Cause() error
+}
+
+// CauseOf returns the cause of the awaitable if it's a CauseAwaitable, or nil otherwise
+func CauseOf(awaitable Awaitable) error {
+	if ca, ok := awaitable.(CauseAwaitable); ok {
+		return ca.Cause()
+	}
+	return nil
+}
+
+var (
+	doneAwaitable  = &AwaitableBase{channel: newClosedChannel()}
+	neverAwaitable = &AwaitableBase{channel: make(chan Void)}
+)
+
 // AwaitableBase provides waiting methods by a channel (to be closed)
 type AwaitableBase struct {
 	channel chan Void
+	cause   *error // set before the channel is closed, nil for shared awaitables without cause
 }
 
 func newAwaitableBase() AwaitableBase {
 	return AwaitableBase{
 		channel: make(chan Void),
+		cause:   new(error),
 	}
 }
 
+// Cause returns the error the awaitable is signaled with, e.g. context.DeadlineExceeded for DeadlineAwaitable, or nil
+// if it's not signaled yet or signaled normally
+//
+// Chained awaitables created by After and Next carry the cause of their origin.
+func (awaitable *AwaitableBase) Cause() error {
+	if awaitable.cause == nil || !awaitable.Peek() {
+		return nil
+	}
+	return *awaitable.cause
+}
+
+// signalWithCause sets the cause and closes the channel, which can be only called once or panic
+func (awaitable *AwaitableBase) signalWithCause(cause error) {
+	*awaitable.cause = cause
+	close(awaitable.channel)
+}
+
 // Channel returns the internal channel that can be used in more complex situations.
 // The returned channel is meant to be read-only and select should either be pending or not-ok,
 // because the signal is done by closing the channel, not by sending any message.
@@ -55,7 +96,7 @@ func (awaitable AwaitableBase) After(timeout time.Duration) Awaitable {
 	go func() {
 		awaitable.WaitForever()
 		time.Sleep(timeout)
-		nextSignal.Signal()
+		nextSignal.SignalWithCause(awaitable.Cause())
 	}()
 	return nextSignal
 }
@@ -67,7 +108,7 @@ func (awaitable AwaitableBase) Next(action func()) Awaitable {
 	go func() {
 		awaitable.WaitForever()
 		action()
-		nextSignal.Signal()
+		nextSignal.SignalWithCause(awaitable.Cause())
 	}()
 	return nextSignal
 }
@@ -125,11 +166,114 @@ func NewSignalAwaitable() *SignalAwaitable {
 // Signal marks the Awaitable to notify the awaiter(s)
 // It can be only called once or panic
 func (awaitable *SignalAwaitable) Signal() {
-	close(awaitable.channel)
+	awaitable.signalWithCause(nil)
+}
+
+// SignalWithCause marks the Awaitable to notify the awaiter(s) with the cause, e.g. an error which aborts the work
+// It can be only called once or panic, same as Signal
+func (awaitable *SignalAwaitable) SignalWithCause(cause error) {
+	awaitable.signalWithCause(cause)
+}
+
+// DeadlineAwaitable is a one-time signal at a deadline with cause context.DeadlineExceeded, or earlier by Signal
+type DeadlineAwaitable struct {
+	AwaitableBase
+	deadline time.Time
+	timer    *time.Timer
+	once     sync.Once
+}
+
+// NewDeadlineAwaitable creates a DeadlineAwaitable signaled at the deadline, or immediately if it's passed
+func NewDeadlineAwaitable(deadline time.Time) *DeadlineAwaitable {
+	awaitable := &DeadlineAwaitable{
+		AwaitableBase: newAwaitableBase(),
+		deadline:      deadline,
+	}
+	awaitable.timer = time.AfterFunc(time.Until(deadline), func() {
+		awaitable.once.Do(func() { awaitable.signalWithCause(context.DeadlineExceeded) })
+	})
+	return awaitable
+}
+
+// Deadline returns the deadline of the Awaitable
+func (awaitable *DeadlineAwaitable) Deadline() time.Time {
+	return awaitable.deadline
+}
+
+// Signal marks the Awaitable before the deadline without cause, or does nothing if it's already signaled
+func (awaitable *DeadlineAwaitable) Signal() {
+	awaitable.SignalWithCause(nil)
+}
+
+// SignalWithCause marks the Awaitable before the deadline with the cause, or does nothing if it's already signaled
+func (awaitable *DeadlineAwaitable) SignalWithCause(cause error) {
+	awaitable.timer.Stop()
+	awaitable.once.Do(func() { awaitable.signalWithCause(cause) })
+}
+
+// WaitForeverWithHeartbeat waits for the signal and logs "still waiting for <description>" periodically
+//
+// It's meant for startup and shutdown, so that operators can tell which dependency a stuck service is waiting on.
+// The interval must be positive.
+func WaitForeverWithHeartbeat(awaitable Awaitable, lg logger.Logger, interval time.Duration, description string) {
+	if interval <= 0 {
+		logger.Panicf("failed to wait for %s: invalid heartbeat interval %s", description, interval)
+	}
+	startTime := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-awaitable.Channel():
+			return
+		case <-ticker.C:
+			lg.Infof("still waiting for %s (elapsed %s)", description, time.Since(startTime).Round(time.Millisecond))
+		}
+	}
+}
+
+// Done returns a shared Awaitable which is already signaled
+func Done() Awaitable {
+	return doneAwaitable
+}
+
+// Never returns a shared Awaitable which is never signaled
+func Never() Awaitable {
+	return neverAwaitable
+}
+
+// OrDone returns the given Awaitable, or Done() if it's nil, e.g. for optional dependencies to wait for
+func OrDone(awaitable Awaitable) Awaitable {
+	if awaitable == nil {
+		return doneAwaitable
+	}
+	return awaitable
+}
+
+// OrNever returns the given Awaitable, or Never() if it's nil, e.g. for optional stop signals
+func OrNever(awaitable Awaitable) Awaitable {
+	if awaitable == nil {
+		return neverAwaitable
+	}
+	return awaitable
+}
+
+func newClosedChannel() chan Void {
+	channel := make(chan Void)
+	close(channel)
+	return channel
 }
 
 // AllAwaitables creates an aggregated Awaitable waiting for all of the given Awaitable(s)
+//
+// The cause is the first non-nil cause of the given Awaitable(s) by their order.
+//
+// Nil Awaitable(s) are ignored. If there is none left, Done() is returned.
 func AllAwaitables(awaitables ...Awaitable) Awaitable {
+	awaitables = removeNilAwaitables(awaitables)
+	if len(awaitables) == 0 {
+		return doneAwaitable
+	}
 	aggregated := NewSignalAwaitable()
 	caseList := make([]reflect.SelectCase, len(awaitables))
 	for index, a := range awaitables {
@@ -144,13 +288,27 @@ func AllAwaitables(awaitables ...Awaitable) Awaitable {
 			index, _, _ := reflect.Select(remainingCases)
 			remainingCases = removeSelectCaseByIndex(remainingCases, index)
 		}
+		for _, a := range awaitables {
+			if cause := CauseOf(a); cause != nil {
+				aggregated.SignalWithCause(cause)
+				return
+			}
+		}
 		aggregated.Signal()
 	}()
 	return aggregated
 }
 
 // AnyAwaitables creates an aggregated Awaitable waiting for any of the given Awaitable(s)
+//
+// The cause is the cause of the first signaled Awaitable, e.g. to tell whether a DeadlineAwaitable is reached.
+//
+// Nil Awaitable(s) are ignored. If there is none left, Never() is returned.
 func AnyAwaitables(awaitables ...Awaitable) Awaitable {
+	awaitables = removeNilAwaitables(awaitables)
+	if len(awaitables) == 0 {
+		return neverAwaitable
+	}
 	aggregated := NewSignalAwaitable()
 	caseList := make([]reflect.SelectCase, len(awaitables))
 	for index, a := range awaitables {
@@ -160,8 +318,8 @@ func AnyAwaitables(awaitables ...Awaitable) Awaitable {
 		}
 	}
 	go func() {
-		reflect.Select(caseList)
-		aggregated.Signal()
+		index, _, _ := reflect.Select(caseList)
+		aggregated.SignalWithCause(CauseOf(awaitables[index]))
 	}()
 	return aggregated
 }
@@ -186,6 +344,16 @@ func NewWaitGroupAwaitable(waitGroup *sync.WaitGroup) Awaitable {
 	return awaitable
 }
 
+func removeNilAwaitables(awaitables []Awaitable) []Awaitable {
+	result := make([]Awaitable, 0, len(awaitables))
+	for _, a := range awaitables {
+		if a != nil {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
 func removeSelectCaseByIndex(slice []reflect.SelectCase, index int) []reflect.SelectCase {
 	if index == 0 {
 		return slice[1:]
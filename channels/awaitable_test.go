@@ -14,11 +14,15 @@
 package channels
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"reflect"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/relex/gotils/logger"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -64,6 +68,55 @@ func TestAwaitableNext(t *testing.T) {
 	assert.True(t, states[2], "chain action #3 should be triggered after signaling")
 }
 
+// TestAwaitableCause tests causes of SignalAwaitable, DeadlineAwaitable and chained awaitables
+func TestAwaitableCause(t *testing.T) {
+	errAborted := errors.New("aborted")
+	s := NewSignalAwaitable()
+	s1 := s.Next(func() {})
+	assert.Nil(t, s.Cause(), ".Cause() should be nil before signaling")
+	s.SignalWithCause(errAborted)
+	assert.Equal(t, errAborted, s.Cause())
+	assert.True(t, s1.Wait(waitDuration), ".Wait() of chain signal should succeed after signaling")
+	assert.Equal(t, errAborted, CauseOf(s1), ".Cause() should be carried to chained signal")
+	assert.Nil(t, CauseOf(struct{ Awaitable }{s}), "CauseOf() should be nil for awaitables without cause")
+
+	d := NewDeadlineAwaitable(time.Now().Add(waitDuration))
+	stop := NewSignalAwaitable()
+	any := AnyAwaitables(stop, d)
+	assert.Equal(t, time.Now().Add(waitDuration).Round(time.Second), d.Deadline().Round(time.Second))
+	assert.False(t, d.Peek(), ".Peek() should fail before deadline")
+	assert.True(t, any.Wait(2*waitDuration), ".Wait() of any should succeed after deadline")
+	assert.Equal(t, context.DeadlineExceeded, d.Cause())
+	assert.Equal(t, context.DeadlineExceeded, CauseOf(any), ".Cause() of any should be from the deadline")
+	d.Signal() // no-op after deadline
+
+	d = NewDeadlineAwaitable(time.Now().Add(time.Hour))
+	d.Signal()
+	assert.True(t, d.Peek(), ".Peek() should succeed after early signaling")
+	assert.Nil(t, d.Cause(), ".Cause() should be nil after early signaling")
+	assert.Nil(t, CauseOf(AllAwaitables(d, Done()).After(0)))
+}
+
+// TestWaitForeverWithHeartbeat tests WaitForeverWithHeartbeat
+func TestWaitForeverWithHeartbeat(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger.AddNamedOutput(t.Name(), output, logger.TextFormat, logger.InfoLevel)
+	defer logger.AddNamedOutput(t.Name(), nil, logger.TextFormat, logger.InfoLevel)
+
+	s := NewSignalAwaitable()
+	waiter := NewSignalAwaitable()
+	go func() {
+		WaitForeverWithHeartbeat(s, logger.WithField("test", t.Name()).ToOutput(t.Name()), waitDuration/2, "test signal")
+		waiter.Signal()
+	}()
+	assert.False(t, waiter.Wait(3*waitDuration), "WaitForeverWithHeartbeat() should block before signaling")
+	s.Signal()
+	assert.True(t, waiter.Wait(waitDuration), "WaitForeverWithHeartbeat() should return after signaling")
+	assert.Regexp(t, `level=info msg="still waiting for test signal \(elapsed [^)]+\)" test=TestWaitForeverWithHeartbeat`, output.String())
+
+	assert.Panics(t, func() { WaitForeverWithHeartbeat(s, logger.Root(), 0, "test signal") })
+}
+
 // TestAllAwaitables tests AllAwaitables
 func TestAllAwaitables(t *testing.T) {
 	s1 := NewSignalAwaitable()
@@ -90,6 +143,28 @@ func TestAnyAwaitables(t *testing.T) {
 	assert.True(t, sany.Wait(waitDuration), ".Wait() should succeed after one of awaitables are signaled")
 }
 
+// TestDoneAndNeverAwaitables tests Done, Never and nil awaitables in aggregations
+func TestDoneAndNeverAwaitables(t *testing.T) {
+	assert.True(t, Done().Peek())
+	assert.True(t, Done().Wait(waitDuration))
+	assert.False(t, Never().Wait(waitDuration))
+	assert.True(t, OrDone(nil).Peek())
+	assert.False(t, OrNever(nil).Peek())
+
+	s := NewSignalAwaitable()
+	assert.Same(t, s, OrNever(s))
+	assert.True(t, AllAwaitables(nil, nil).Peek())
+	assert.False(t, AnyAwaitables(nil).Wait(waitDuration))
+
+	all := AllAwaitables(nil, s, Done())
+	first := AnyAwaitables(nil, s, Never())
+	assert.False(t, all.Wait(waitDuration))
+	assert.False(t, first.Peek())
+	s.Signal()
+	assert.True(t, all.Wait(time.Second))
+	assert.True(t, first.Wait(time.Second))
+}
+
 // TestRemoveItemFromSlice tests removeSelectCaseByIndex
 func TestRemoveItemFromSlice(t *testing.T) {
 	c0 := reflect.SelectCase{Dir: reflect.SelectDir(0)}
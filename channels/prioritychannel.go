@@ -0,0 +1,248 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package channels
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/relex/gotils/logger"
+)
+
+// ErrChannelClosed is returned from sending to a closed channel or receiving from a closed and drained one
+var ErrChannelClosed = errors.New("channel closed")
+
+// PriorityChannel is a bounded queue which delivers items of higher priorities first, and items of the same priority
+// in FIFO order
+//
+// After Close, sending fails and receivers get the remaining items until drained, like Go channels.
+type PriorityChannel[T any] struct {
+	mutex    sync.Mutex
+	items    priorityItems[T]
+	capacity int
+	sequence uint64
+	closed   bool
+	changed  chan Void // closed and replaced on every change, to wake up blocked senders and receivers
+	drained  *SignalAwaitable
+}
+
+type priorityItem[T any] struct {
+	value    T
+	priority int
+	sequence uint64
+}
+
+// priorityItems implements heap.Interface with the highest priority and then the earliest item at top
+type priorityItems[T any] []priorityItem[T]
+
+// NewPriorityChannel creates a PriorityChannel which holds up to capacity items
+func NewPriorityChannel[T any](capacity int) *PriorityChannel[T] {
+	if capacity <= 0 {
+		logger.Panicf("invalid priority channel capacity: %d", capacity)
+	}
+	return &PriorityChannel[T]{
+		items:    make(priorityItems[T], 0, capacity),
+		capacity: capacity,
+		changed:  make(chan Void),
+		drained:  NewSignalAwaitable(),
+	}
+}
+
+// Send sends an item with the priority, blocking while the channel is full
+//
+// Returns ErrChannelClosed if closed, or the context error if done before sending
+func (pc *PriorityChannel[T]) Send(ctx context.Context, value T, priority int) error {
+	for {
+		pc.mutex.Lock()
+		if pc.closed {
+			pc.mutex.Unlock()
+			return ErrChannelClosed
+		}
+		if len(pc.items) < pc.capacity {
+			pc.push(value, priority)
+			pc.mutex.Unlock()
+			return nil
+		}
+		changed := pc.changed
+		pc.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// TrySend sends an item with the priority without blocking, returning false if the channel is full or closed
+func (pc *PriorityChannel[T]) TrySend(value T, priority int) bool {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if pc.closed || len(pc.items) >= pc.capacity {
+		return false
+	}
+	pc.push(value, priority)
+	return true
+}
+
+// SendOrDropLowest sends an item with the priority without blocking, dropping the latest item of the lowest priority
+// if the channel is full, e.g. to drop debug logs in favor of errors when the queue backs up
+//
+// Returns the dropped item if any, which is the given item itself if it has the lowest priority or the channel is
+// closed
+func (pc *PriorityChannel[T]) SendOrDropLowest(value T, priority int) (dropped T, hasDropped bool) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if pc.closed {
+		return value, true
+	}
+	if len(pc.items) < pc.capacity {
+		pc.push(value, priority)
+		return dropped, false
+	}
+
+	lowest := 0
+	for i, item := range pc.items {
+		if item.priority < pc.items[lowest].priority ||
+			(item.priority == pc.items[lowest].priority && item.sequence > pc.items[lowest].sequence) {
+			lowest = i
+		}
+	}
+	if pc.items[lowest].priority >= priority {
+		return value, true
+	}
+	dropped = heap.Remove(&pc.items, lowest).(priorityItem[T]).value
+	pc.push(value, priority)
+	return dropped, true
+}
+
+// Receive receives the item of the highest priority, blocking while the channel is empty
+//
+// Returns ErrChannelClosed if closed and drained, or the context error if done before receiving
+func (pc *PriorityChannel[T]) Receive(ctx context.Context) (T, error) {
+	for {
+		value, ok, changed := pc.tryReceive()
+		if ok {
+			return value, nil
+		}
+		if changed == nil {
+			return value, ErrChannelClosed
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return value, ctx.Err()
+		}
+	}
+}
+
+// TryReceive receives the item of the highest priority without blocking, returning false if the channel is empty
+func (pc *PriorityChannel[T]) TryReceive() (T, bool) {
+	value, ok, _ := pc.tryReceive()
+	return value, ok
+}
+
+// Close closes the channel for sending. Remaining items can still be received.
+//
+// Calling it more than once has no effect.
+func (pc *PriorityChannel[T]) Close() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if pc.closed {
+		return
+	}
+	pc.closed = true
+	pc.notify()
+}
+
+// Drained returns an Awaitable which is signaled when the channel is closed and all items are received
+func (pc *PriorityChannel[T]) Drained() Awaitable {
+	return pc.drained
+}
+
+// Len returns the number of items in the channel
+func (pc *PriorityChannel[T]) Len() int {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	return len(pc.items)
+}
+
+// Cap returns the capacity of the channel
+func (pc *PriorityChannel[T]) Cap() int {
+	return pc.capacity
+}
+
+// tryReceive pops the top item if any, or returns the channel to wait for changes, or nil if closed and drained
+func (pc *PriorityChannel[T]) tryReceive() (value T, ok bool, changed chan Void) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	if len(pc.items) == 0 {
+		if pc.closed {
+			return value, false, nil
+		}
+		return value, false, pc.changed
+	}
+	value = heap.Pop(&pc.items).(priorityItem[T]).value
+	pc.notify()
+	return value, true, nil
+}
+
+// push adds an item and notifies receivers, must be called under lock
+func (pc *PriorityChannel[T]) push(value T, priority int) {
+	pc.sequence++
+	heap.Push(&pc.items, priorityItem[T]{value, priority, pc.sequence})
+	pc.notify()
+}
+
+// notify wakes up all blocked senders and receivers, must be called under lock
+func (pc *PriorityChannel[T]) notify() {
+	close(pc.changed)
+	pc.changed = make(chan Void)
+	if pc.closed && len(pc.items) == 0 && !pc.drained.Peek() {
+		pc.drained.Signal()
+	}
+}
+
+func (items priorityItems[T]) Len() int {
+	return len(items)
+}
+
+func (items priorityItems[T]) Less(i, j int) bool {
+	if items[i].priority != items[j].priority {
+		return items[i].priority > items[j].priority
+	}
+	return items[i].sequence < items[j].sequence
+}
+
+func (items priorityItems[T]) Swap(i, j int) {
+	items[i], items[j] = items[j], items[i]
+}
+
+func (items *priorityItems[T]) Push(x interface{}) {
+	*items = append(*items, x.(priorityItem[T]))
+}
+
+func (items *priorityItems[T]) Pop() interface{} {
+	old := *items
+	item := old[len(old)-1]
+	old[len(old)-1] = priorityItem[T]{} // release the reference to value
+	*items = old[:len(old)-1]
+	return item
+}
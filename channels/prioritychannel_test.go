@@ -0,0 +1,90 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityChannel(t *testing.T) {
+	pc := NewPriorityChannel[string](3)
+	assert.True(t, pc.TrySend("debug1", 0))
+	assert.True(t, pc.TrySend("error1", 2))
+	assert.True(t, pc.TrySend("debug2", 0))
+	assert.False(t, pc.TrySend("info1", 1), ".TrySend() should fail when full")
+
+	dropped, hasDropped := pc.SendOrDropLowest("info1", 1)
+	assert.True(t, hasDropped)
+	assert.Equal(t, "debug2", dropped, "the latest item of the lowest priority should be dropped")
+	dropped, _ = pc.SendOrDropLowest("debug3", 0)
+	assert.Equal(t, "debug3", dropped, "the new item should be dropped if it has the lowest priority")
+	assert.Equal(t, 3, pc.Len())
+
+	sent := NewSignalAwaitable()
+	go func() {
+		if pc.Send(context.Background(), "error2", 2) == nil {
+			sent.Signal()
+		}
+	}()
+	assert.False(t, sent.Wait(waitDuration), ".Send() should block when full")
+
+	value, err := pc.Receive(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "error1", value)
+	assert.True(t, sent.Wait(waitDuration), ".Send() should succeed after receiving")
+
+	pc.Close()
+	assert.ErrorIs(t, pc.Send(context.Background(), "error3", 2), ErrChannelClosed)
+	assert.False(t, pc.Drained().Peek())
+
+	var received []string
+	for {
+		value, err := pc.Receive(context.Background())
+		if err != nil {
+			assert.ErrorIs(t, err, ErrChannelClosed)
+			break
+		}
+		received = append(received, value)
+	}
+	assert.Equal(t, []string{"error2", "info1", "debug1"}, received)
+	assert.True(t, pc.Drained().Peek())
+}
+
+func TestPriorityChannelBlockingReceive(t *testing.T) {
+	pc := NewPriorityChannel[int](1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitDuration)
+	defer cancel()
+	_, err := pc.Receive(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	received := make(chan int, 1)
+	go func() {
+		value, _ := pc.Receive(context.Background())
+		received <- value
+	}()
+	assert.NoError(t, pc.Send(context.Background(), 42, 0))
+	assert.Equal(t, 42, <-received)
+
+	closedErr := make(chan error, 1)
+	go func() {
+		_, err := pc.Receive(context.Background())
+		closedErr <- err
+	}()
+	pc.Close()
+	assert.ErrorIs(t, <-closedErr, ErrChannelClosed, "blocked receivers should be woken up on close")
+	assert.True(t, pc.Drained().Peek())
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package channels
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/relex/gotils/logger"
+)
+
+// ProgressGroup is an Awaitable signaled when the given total number of tasks are done, with progress reporting
+//
+// It replaces the combination of sync.WaitGroup, atomic counter and ticker in batch jobs.
+type ProgressGroup struct {
+	AwaitableBase
+	total     int64
+	current   atomic.Int64
+	startTime time.Time
+	doneOnce  sync.Once
+}
+
+// Progress is a snapshot of ProgressGroup
+type Progress struct {
+	Current int
+	Total   int
+	Elapsed time.Duration
+}
+
+// NewProgressGroup creates a ProgressGroup for the total number of tasks
+//
+// The group is signaled immediately if the total is zero.
+func NewProgressGroup(total int) *ProgressGroup {
+	if total < 0 {
+		logger.Panicf("invalid progress total: %d", total)
+	}
+	group := &ProgressGroup{
+		AwaitableBase: newAwaitableBase(),
+		total:         int64(total),
+		startTime:     time.Now(),
+	}
+	if total == 0 {
+		group.signal()
+	}
+	return group
+}
+
+// Done marks one task as done
+func (group *ProgressGroup) Done() {
+	group.Add(1)
+}
+
+// Add marks n tasks as done
+//
+// It panics if the number of done tasks would exceed the total, like sync.WaitGroup on negative counter.
+func (group *ProgressGroup) Add(n int) {
+	current := group.current.Add(int64(n))
+	if current > group.total {
+		logger.Panicf("progress exceeds total: %d > %d", current, group.total)
+	}
+	if current == group.total {
+		group.signal()
+	}
+}
+
+// Snapshot returns the current progress
+func (group *ProgressGroup) Snapshot() Progress {
+	return Progress{
+		Current: int(group.current.Load()),
+		Total:   int(group.total),
+		Elapsed: time.Since(group.startTime),
+	}
+}
+
+// WaitContext waits for all tasks to be done or the context to be done
+//
+// Returns the context error if the context is done first
+func (group *ProgressGroup) WaitContext(ctx context.Context) error {
+	select {
+	case <-group.channel:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogPeriodically starts logging "<description>: <progress>" at the given interval in background, until all tasks are
+// done, and once more at the end
+func (group *ProgressGroup) LogPeriodically(lg logger.Logger, interval time.Duration, description string) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-group.channel:
+				lg.Infof("%s: %s", description, group.Snapshot())
+				return
+			case <-ticker.C:
+				lg.Infof("%s: %s", description, group.Snapshot())
+			}
+		}
+	}()
+}
+
+func (group *ProgressGroup) signal() {
+	group.doneOnce.Do(func() {
+		close(group.channel)
+	})
+}
+
+// Percent returns the percentage of done tasks, or 100 if the total is zero
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Current) * 100 / float64(p.Total)
+}
+
+// Remaining estimates the remaining time by the average duration of done tasks, or returns zero if none is done
+func (p Progress) Remaining() time.Duration {
+	if p.Current == 0 {
+		return 0
+	}
+	return p.Elapsed * time.Duration(p.Total-p.Current) / time.Duration(p.Current)
+}
+
+// String formats the progress as "42/100 (42.0%), elapsed 1m0s, remaining 1m22s"
+func (p Progress) String() string {
+	text := fmt.Sprintf("%d/%d (%.1f%%), elapsed %s", p.Current, p.Total, p.Percent(), p.Elapsed.Round(time.Second))
+	if p.Current > 0 && p.Current < p.Total {
+		text += fmt.Sprintf(", remaining %s", p.Remaining().Round(time.Second))
+	}
+	return text
+}
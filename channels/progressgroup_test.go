@@ -0,0 +1,49 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressGroup(t *testing.T) {
+	group := NewProgressGroup(3)
+	assert.False(t, group.Peek())
+	group.Done()
+	assert.Equal(t, 1, group.Snapshot().Current)
+	assert.Equal(t, 3, group.Snapshot().Total)
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitDuration)
+	defer cancel()
+	assert.ErrorIs(t, group.WaitContext(ctx), context.DeadlineExceeded)
+
+	go group.Add(2)
+	assert.NoError(t, group.WaitContext(context.Background()))
+	assert.True(t, group.Peek())
+	assert.Panics(t, group.Done)
+
+	assert.True(t, NewProgressGroup(0).Peek())
+}
+
+func TestProgressString(t *testing.T) {
+	p := Progress{Current: 25, Total: 100, Elapsed: time.Minute}
+	assert.Equal(t, 25.0, p.Percent())
+	assert.Equal(t, 3*time.Minute, p.Remaining())
+	assert.Equal(t, "25/100 (25.0%), elapsed 1m0s, remaining 3m0s", p.String())
+	assert.Equal(t, "100/100 (100.0%), elapsed 1m0s", Progress{Current: 100, Total: 100, Elapsed: time.Minute}.String())
+	assert.Equal(t, "0/0 (100.0%), elapsed 0s", Progress{}.String())
+}
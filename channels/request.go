@@ -0,0 +1,160 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package channels
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/relex/gotils/logger"
+)
+
+// RequestChannel implements the request-reply pattern: callers send requests and wait for replies, which are made
+// by handlers receiving from Requests()
+//
+// On shutdown, Close stops accepting requests and returns an Awaitable for pending requests to be replied.
+type RequestChannel[Req any, Resp any] struct {
+	requests  chan *Request[Req, Resp]
+	lock      sync.RWMutex // held by callers while sending, to close requests channel safely
+	closing   *SignalAwaitable
+	closeOnce sync.Once
+	pending   sync.WaitGroup
+	replied   *SignalAwaitable
+}
+
+// Request is a request received from RequestChannel, to be replied exactly once by Reply or Fail
+type Request[Req any, Resp any] struct {
+	Payload Req
+
+	ctx       context.Context
+	replyCh   chan requestReply[Resp] // buffered, so that replies never block after callers give up
+	replied   atomic.Bool
+	onReplied func()
+}
+
+type requestReply[Resp any] struct {
+	resp Resp
+	err  error
+}
+
+// NewRequestChannel creates a RequestChannel, which can buffer the given number of requests not received yet
+func NewRequestChannel[Req any, Resp any](bufferSize int) *RequestChannel[Req, Resp] {
+	return &RequestChannel[Req, Resp]{
+		requests: make(chan *Request[Req, Resp], bufferSize),
+		closing:  NewSignalAwaitable(),
+		replied:  NewSignalAwaitable(),
+	}
+}
+
+// Call sends a request and waits for its reply
+//
+// Returns ErrChannelClosed if closed, the error from Request.Fail, or the context error if done before replied. The
+// context is passed to handlers by Request.Context.
+func (rc *RequestChannel[Req, Resp]) Call(ctx context.Context, payload Req) (Resp, error) {
+	var empty Resp
+	req := &Request[Req, Resp]{
+		Payload:   payload,
+		ctx:       ctx,
+		replyCh:   make(chan requestReply[Resp], 1),
+		onReplied: rc.pending.Done,
+	}
+	if err := rc.send(ctx, req); err != nil {
+		return empty, err
+	}
+
+	select {
+	case reply := <-req.replyCh:
+		return reply.resp, reply.err
+	case <-ctx.Done():
+		return empty, ctx.Err()
+	}
+}
+
+// CallWithTimeout sends a request and waits for its reply like Call, with the timeout for both sending and waiting
+func (rc *RequestChannel[Req, Resp]) CallWithTimeout(payload Req, timeout time.Duration) (Resp, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return rc.Call(ctx, payload)
+}
+
+// Requests returns the channel for handlers to receive requests, which is closed after Close
+func (rc *RequestChannel[Req, Resp]) Requests() <-chan *Request[Req, Resp] {
+	return rc.requests
+}
+
+// Close stops accepting new requests and closes the channel of Requests() after buffered requests are received
+//
+// Returns an Awaitable signaled when all accepted requests have been replied. Calling it again returns the same.
+func (rc *RequestChannel[Req, Resp]) Close() Awaitable {
+	rc.closeOnce.Do(func() {
+		rc.closing.Signal() // wake up blocked callers before waiting for the lock
+		rc.lock.Lock()
+		close(rc.requests)
+		rc.lock.Unlock()
+
+		go func() {
+			rc.pending.Wait()
+			rc.replied.Signal()
+		}()
+	})
+	return rc.replied
+}
+
+func (rc *RequestChannel[Req, Resp]) send(ctx context.Context, req *Request[Req, Resp]) error {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+
+	if rc.closing.Peek() {
+		return ErrChannelClosed
+	}
+	rc.pending.Add(1)
+	select {
+	case rc.requests <- req:
+		return nil
+	case <-rc.closing.Channel():
+		rc.pending.Done()
+		return ErrChannelClosed
+	case <-ctx.Done():
+		rc.pending.Done()
+		return ctx.Err()
+	}
+}
+
+// Context returns the context of caller, to stop processing early if the caller has given up
+func (req *Request[Req, Resp]) Context() context.Context {
+	return req.ctx
+}
+
+// Reply replies to the caller with the response
+//
+// Replying more than once causes panic.
+func (req *Request[Req, Resp]) Reply(resp Resp) {
+	req.reply(requestReply[Resp]{resp: resp})
+}
+
+// Fail replies to the caller with the error
+//
+// Replying more than once causes panic.
+func (req *Request[Req, Resp]) Fail(err error) {
+	req.reply(requestReply[Resp]{err: err})
+}
+
+func (req *Request[Req, Resp]) reply(reply requestReply[Resp]) {
+	if req.replied.Swap(true) {
+		logger.Panicf("request replied more than once: %+v", req.Payload)
+	}
+	req.replyCh <- reply
+	req.onReplied()
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package channels
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestChannel(t *testing.T) {
+	rc := NewRequestChannel[string, string](1)
+	go func() {
+		for req := range rc.Requests() {
+			if req.Payload == "" {
+				req.Fail(errors.New("empty"))
+			} else {
+				req.Reply(strings.ToUpper(req.Payload))
+			}
+		}
+	}()
+
+	resp, err := rc.Call(context.Background(), "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", resp)
+
+	_, err = rc.CallWithTimeout("", waitDuration)
+	assert.EqualError(t, err, "empty")
+
+	assert.True(t, rc.Close().Wait(waitDuration))
+	_, err = rc.Call(context.Background(), "hello")
+	assert.ErrorIs(t, err, ErrChannelClosed)
+}
+
+func TestRequestChannelPendingReplies(t *testing.T) {
+	rc := NewRequestChannel[int, int](1)
+
+	_, err := rc.CallWithTimeout(1, waitDuration)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "should time out without handler")
+
+	req := <-rc.Requests()
+	assert.Equal(t, 1, req.Payload)
+	assert.ErrorIs(t, req.Context().Err(), context.DeadlineExceeded)
+
+	replied := rc.Close()
+	assert.False(t, replied.Wait(waitDuration), "should wait for pending requests")
+	req.Reply(2) // should not block after the caller gave up
+	assert.True(t, replied.Wait(waitDuration))
+	assert.Panics(t, func() { req.Reply(3) })
+
+	_, open := <-rc.Requests()
+	assert.False(t, open)
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package channels
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// Semaphore is a weighted semaphore with FIFO ordering of waiters
+//
+// A large acquisition at the head of queue blocks smaller ones behind it, to prevent starvation.
+type Semaphore struct {
+	mutex   sync.Mutex
+	size    int64
+	used    int64
+	waiters list.List // of *semaphoreWaiter
+	metrics *semaphoreMetrics
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan Void // closed when the permits are acquired
+}
+
+type semaphoreMetrics struct {
+	waiters          promext.RWGauge
+	inUse            promext.RWGauge
+	acquisitions     promext.RWCounter
+	holdMilliseconds promext.RWCounter // integral of in-use permits over time
+	lastChange       time.Time
+	holdRemainder    time.Duration // sub-millisecond remainder of the integral
+}
+
+// NewSemaphore creates a Semaphore with the given number of permits
+//
+// Metrics are created from the given creator with the prefix "semaphore_" and the label "name", unless the creator is
+// nil. Semaphores sharing a creator need different names.
+func NewSemaphore(name string, size int64, creator promreg.MetricCreator) *Semaphore {
+	if size <= 0 {
+		logger.Panicf("invalid size of semaphore '%s': %d", name, size)
+	}
+	sem := &Semaphore{size: size}
+	if creator != nil {
+		metricCreator := creator.AddOrGetPrefix("semaphore_", []string{"name"}, []string{name})
+		sem.metrics = &semaphoreMetrics{
+			waiters:          metricCreator.AddOrGetGauge("waiters", "Numbers of waiters blocked in acquisition", nil, nil),
+			inUse:            metricCreator.AddOrGetGauge("in_use", "Numbers of permits in use", nil, nil),
+			acquisitions:     metricCreator.AddOrGetCounter("acquisitions_total", "Numbers of successful acquisitions", nil, nil),
+			holdMilliseconds: metricCreator.AddOrGetCounter("hold_milliseconds_total", "Total permit-milliseconds held", nil, nil),
+			lastChange:       time.Now(),
+		}
+	}
+	return sem
+}
+
+// Acquire acquires n permits, blocking until they're available or the context is done
+//
+// Returns the context error if done before acquisition, in which case no permits are held
+func (sem *Semaphore) Acquire(ctx context.Context, n int64) error {
+	sem.mutex.Lock()
+	if n > sem.size {
+		sem.mutex.Unlock()
+		return fmt.Errorf("failed to acquire %d permits: exceeding semaphore size %d", n, sem.size)
+	}
+	if sem.size-sem.used >= n && sem.waiters.Len() == 0 {
+		sem.take(n)
+		sem.mutex.Unlock()
+		return nil
+	}
+
+	waiter := &semaphoreWaiter{n: n, ready: make(chan Void)}
+	elem := sem.waiters.PushBack(waiter)
+	sem.updateWaiters()
+	sem.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		sem.mutex.Lock()
+		defer sem.mutex.Unlock()
+		select {
+		case <-waiter.ready:
+			// acquired right after cancellation, give it back
+			sem.give(n)
+		default:
+			isFront := sem.waiters.Front() == elem
+			sem.waiters.Remove(elem)
+			sem.updateWaiters()
+			if isFront && sem.size > sem.used {
+				sem.notifyWaiters()
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// TryAcquire acquires n permits without blocking, returning false if they're not available
+func (sem *Semaphore) TryAcquire(n int64) bool {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	if sem.size-sem.used >= n && sem.waiters.Len() == 0 {
+		sem.take(n)
+		return true
+	}
+	return false
+}
+
+// Release releases n permits
+func (sem *Semaphore) Release(n int64) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	if n > sem.used {
+		logger.Panicf("failed to release %d permits: only %d in use", n, sem.used)
+	}
+	sem.give(n)
+}
+
+// InUse returns the number of permits in use
+func (sem *Semaphore) InUse() int64 {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+	return sem.used
+}
+
+// take takes permits and updates metrics, must be called under lock
+func (sem *Semaphore) take(n int64) {
+	sem.accumulateHoldTime()
+	sem.used += n
+	if sem.metrics != nil {
+		sem.metrics.inUse.Set(sem.used)
+		sem.metrics.acquisitions.Inc()
+	}
+}
+
+// give returns permits and wakes up waiters, must be called under lock
+func (sem *Semaphore) give(n int64) {
+	sem.accumulateHoldTime()
+	sem.used -= n
+	if sem.metrics != nil {
+		sem.metrics.inUse.Set(sem.used)
+	}
+	sem.notifyWaiters()
+}
+
+// notifyWaiters grants permits to waiters from the front of queue as long as available, must be called under lock
+func (sem *Semaphore) notifyWaiters() {
+	for {
+		front := sem.waiters.Front()
+		if front == nil {
+			break
+		}
+		waiter := front.Value.(*semaphoreWaiter)
+		if sem.size-sem.used < waiter.n {
+			break
+		}
+		sem.take(waiter.n)
+		sem.waiters.Remove(front)
+		close(waiter.ready)
+	}
+	sem.updateWaiters()
+}
+
+func (sem *Semaphore) updateWaiters() {
+	if sem.metrics != nil {
+		sem.metrics.waiters.Set(int64(sem.waiters.Len()))
+	}
+}
+
+// accumulateHoldTime adds the permit-time since last change to the hold counter, must be called under lock
+func (sem *Semaphore) accumulateHoldTime() {
+	if sem.metrics == nil {
+		return
+	}
+	now := time.Now()
+	sem.metrics.holdRemainder += now.Sub(sem.metrics.lastChange) * time.Duration(sem.used)
+	sem.metrics.lastChange = now
+	if ms := sem.metrics.holdRemainder / time.Millisecond; ms > 0 {
+		sem.metrics.holdMilliseconds.Add(uint64(ms))
+		sem.metrics.holdRemainder -= ms * time.Millisecond
+	}
+}
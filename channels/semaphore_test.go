@@ -0,0 +1,79 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promext/promexttest"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	factory := promreg.NewMetricFactory("test_", nil, nil)
+	sem := NewSemaphore("upload", 3, factory)
+	other := NewSemaphore("download", 1, factory)
+	assert.True(t, other.TryAcquire(1))
+
+	assert.NoError(t, sem.Acquire(context.Background(), 2))
+	assert.True(t, sem.TryAcquire(1))
+	assert.False(t, sem.TryAcquire(1), ".TryAcquire() should fail when all permits are in use")
+
+	acquired := NewSignalAwaitable()
+	go func() {
+		if sem.Acquire(context.Background(), 2) == nil {
+			acquired.Signal()
+		}
+	}()
+	assert.False(t, acquired.Wait(waitDuration), ".Acquire() should block when permits are not enough")
+	assert.EqualValues(t, 1, promext.SumMetricValues(factory.LookupMetricFamily("semaphore_waiters")))
+
+	sem.Release(1)
+	assert.False(t, acquired.Wait(waitDuration), ".Acquire() should block when permits are still not enough")
+	assert.False(t, sem.TryAcquire(1), ".TryAcquire() should not jump the queue")
+
+	sem.Release(1)
+	assert.True(t, acquired.Wait(waitDuration), ".Acquire() should succeed after enough permits are released")
+	assert.EqualValues(t, 3, sem.InUse())
+
+	assert.EqualValues(t, 0, promext.SumMetricValues(factory.LookupMetricFamily("semaphore_waiters")))
+	assert.EqualValues(t, 4, promext.SumMetricValues(factory.LookupMetricFamily("semaphore_in_use")))
+	assert.EqualValues(t, 4, promext.SumMetricValues(factory.LookupMetricFamily("semaphore_acquisitions_total")))
+	assert.Contains(t, promexttest.CollectAsMap(factory), `test_semaphore_in_use{name="upload"}`)
+	assert.Contains(t, promexttest.CollectAsMap(factory), `test_semaphore_in_use{name="download"}`)
+
+	sem.Release(3)
+	assert.Greater(t, promext.SumMetricValues(factory.LookupMetricFamily("semaphore_hold_milliseconds_total")), 0.0)
+}
+
+func TestSemaphoreCancellation(t *testing.T) {
+	sem := NewSemaphore("test", 2, nil)
+	assert.NoError(t, sem.Acquire(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitDuration)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, sem.Acquire(ctx, 2), context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, time.Since(start), waitDuration)
+
+	// the cancelled waiter at front should not block others
+	assert.True(t, sem.TryAcquire(1))
+	assert.EqualValues(t, 2, sem.InUse())
+
+	assert.Error(t, sem.Acquire(context.Background(), 3), ".Acquire() should fail if exceeding the size")
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"strings"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+)
+
+// CmdOptions defines the command added by AddCmdWithOptions. All fields are optional.
+type CmdOptions struct {
+	Short       string            // short description in help of parent command
+	Long        string            // long description in help of this command
+	Aliases     []string          // alternative names, e.g. "rm" for "remove"
+	Group       string            // ID of group in help of parent command, see AddCmdGroup
+	Annotations map[string]string // key/value annotations for applications, e.g. to generate docs
+	Hidden      bool              // hide from help of parent command
+	FlagStruct  interface{}       // pointer to struct for auto flags, see AddCmdWithArgs
+	Run         func(args []string)
+	RunE        func(args []string) error
+}
+
+// AddCmdWithOptions creates and adds a new command to its parent like AddCmd, with aliases, group and other options
+//
+// See AddCmd for the "use" parameter. Groups must be added to the parent command by AddCmdGroup beforehand.
+func AddCmdWithOptions(use string, opts CmdOptions) {
+	cmd := &cobra.Command{
+		Use:         use,
+		Short:       opts.Short,
+		Long:        opts.Long,
+		Aliases:     opts.Aliases,
+		GroupID:     opts.Group,
+		Annotations: opts.Annotations,
+		Hidden:      opts.Hidden,
+	}
+	if opts.FlagStruct != nil {
+		AddStructFlagsToFlags(logger.WithField("cmd", use), cmd.PersistentFlags(), opts.FlagStruct)
+	}
+	if opts.Run != nil {
+		cmd.Run = func(cmd *cobra.Command, args []string) { opts.Run(args) }
+	}
+	if opts.RunE != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error { return opts.RunE(args) }
+	}
+
+	if opts.Group != "" {
+		parentPath := ""
+		if pathMatch := commandPathPattern.FindStringSubmatch(use); pathMatch != nil {
+			parentPath = strings.TrimRight(pathMatch[commandPathPattern.SubexpIndex("parent")], " ")
+		}
+		if !getCommand(parentPath).ContainsGroup(opts.Group) {
+			logger.Panicf("failed to add command '%s': group '%s' not found in parent command", use, opts.Group)
+		}
+	}
+
+	addCommand(cmd)
+}
+
+// AddCmdGroup adds a group of subcommands to the command (empty for root), to organize its help output in sections,
+// e.g. AddCmdGroup("", "db", "Database Commands:")
+//
+// Subcommands are assigned to groups by CmdOptions.Group. Subcommands without group are listed under "Additional
+// Commands:" if there is any group.
+func AddCmdGroup(cmdPath string, id string, title string) {
+	cmd := getCommand(cmdPath)
+	if cmd.ContainsGroup(id) {
+		logger.Panicf("failed to add group '%s' to command '%s': already exists", id, cmdPath)
+	}
+	cmd.AddGroup(&cobra.Group{ID: id, Title: title})
+}
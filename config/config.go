@@ -54,10 +54,18 @@ func init() {
 }
 
 // ReadConfigFile reads the file as the global config and makes that parseable
+//
+// If file is empty, the first one found by FindConfigFile is read, with the options of EnableGlobalConfigFlag if
+// called. Any error is fatal.
 func ReadConfigFile(file string) {
-	viper.SetConfigFile(file)
-
-	if err := viper.ReadInConfig(); err != nil {
+	if file == "" {
+		discovered, err := discoverConfigFile()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		file = discovered
+	}
+	if err := readConfigFile(file); err != nil {
 		logger.Fatal(err)
 	}
 }
@@ -215,6 +223,7 @@ func addCommand(cmd *cobra.Command) {
 	cmd.Use = strings.TrimLeft(cmd.Use[len(parentPath):], " ")
 	parentCmd.AddCommand(cmd)
 	commandRegistry[path] = cmd
+	checkShadowedFlags(cmd)
 
 	// check full path
 	expectedFullPath := GetCmdName() + " " + path
@@ -235,42 +244,58 @@ func getCommand(cmdPath string) *cobra.Command {
 
 // AddIntFlagToCmd adds new int flag to use with the command-line
 func AddIntFlagToCmd(cmdPath string, v *int, flag string, defaultValue int, help string) {
-	getCommand(cmdPath).PersistentFlags().IntVar(v, flag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().IntVar(v, flag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddBoolFlagToCmd adds new bool flag to use with the command-line
 func AddBoolFlagToCmd(cmdPath string, v *bool, flag string, defaultValue bool, help string) {
-	getCommand(cmdPath).PersistentFlags().BoolVar(v, flag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().BoolVar(v, flag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddStringFlagToCmd adds new string flag to use with the command-line
 func AddStringFlagToCmd(cmdPath string, v *string, flag string, defaultValue string, help string) {
-	getCommand(cmdPath).PersistentFlags().StringVar(v, flag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().StringVar(v, flag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddUint16FlagToCmd adds new string flag to use with the command-line
 func AddUint16FlagToCmd(cmdPath string, v *uint16, flag string, defaultValue uint16, help string) {
-	getCommand(cmdPath).PersistentFlags().Uint16Var(v, flag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().Uint16Var(v, flag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddIntPFlagToCmd adds new int flag and shortflag to use with the command-line
 func AddIntPFlagToCmd(cmdPath string, v *int, flag string, shortflag string, defaultValue int, help string) {
-	getCommand(cmdPath).PersistentFlags().IntVarP(v, flag, shortflag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().IntVarP(v, flag, shortflag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddBoolPFlagToCmd adds new bool flag and shortflag to use with the command-line
 func AddBoolPFlagToCmd(cmdPath string, v *bool, flag string, shortflag string, defaultValue bool, help string) {
-	getCommand(cmdPath).PersistentFlags().BoolVarP(v, flag, shortflag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().BoolVarP(v, flag, shortflag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddStringPFlagToCmd adds new string flag and shortflag to use with the command-line
 func AddStringPFlagToCmd(cmdPath string, v *string, flag string, shortflag string, defaultValue string, help string) {
-	getCommand(cmdPath).PersistentFlags().StringVarP(v, flag, shortflag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().StringVarP(v, flag, shortflag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // AddUint16PFlagToCmd adds new string flag to use with the command-line
 func AddUint16PFlagToCmd(cmdPath string, v *uint16, flag string, shortflag string, defaultValue uint16, help string) {
-	getCommand(cmdPath).PersistentFlags().Uint16VarP(v, flag, shortflag, defaultValue, help)
+	cmd := getCommand(cmdPath)
+	cmd.PersistentFlags().Uint16VarP(v, flag, shortflag, defaultValue, help)
+	checkShadowedFlags(cmd)
 }
 
 // SetCommandOutput sets an output to the command that you want
@@ -280,13 +305,24 @@ func SetCommandOutput(cmdPath string, output string) {
 
 // Execute executes the root command
 //
+// Errors returned from commands are logged once and the program exits with the code by their class, e.g.
+// ExitCodeConfig for errors wrapped by NewConfigError and invalid flags. See ExitCode. Usage is printed only for
+// config errors.
+//
+// Errors of environment variables recorded by envutil.Get and envutil.Require are reported all at once with
+// ExitCodeConfig before the selected command runs, not for help or version.
+//
 // The function finishes the program and DOES NOT return
 func Execute() {
 	rootCmd := getCommand("")
-	if err := rootCmd.Execute(); err != nil {
-		logger.Fatal(err)
-	}
-	logger.Exit(0)
+	addDefaultConfigFileFlags()
+	addGlobalConfigFileLoading()
+	addExplainToCommands()
+	addInterceptorsToCommands()
+	addInitializersToCommands()
+	addEnvCheckToCommands()
+	rootCmd.SetFlagErrorFunc(flagErrorAsConfigError)
+	logger.Exit(executeCommand(rootCmd))
 }
 
 // GetCmdHelp calls the cmd help for the cmd with the giving name
@@ -15,9 +15,20 @@ package config
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -120,6 +131,41 @@ Use "config.test say [command] --help" for more information about a command.
 	assert.True(t, rootCmdPostRunCalled)
 }
 
+func TestCmdWithOptions(t *testing.T) {
+	removed := []string{}
+	AddCmd("item", "Manage items", "", nil, nil)
+	AddCmdGroup("item", "write", "Write Commands:")
+	AddCmdWithOptions("item remove <name>", CmdOptions{
+		Short:   "Remove item",
+		Aliases: []string{"rm"},
+		Group:   "write",
+		Run:     func(args []string) { removed = append(removed, args...) },
+	})
+	AddCmdWithOptions("item list", CmdOptions{Short: "List items", Run: func(args []string) {}})
+
+	assert.Equal(t, `Manage items
+
+Usage:
+  config.test item [command]
+
+Write Commands:
+  remove      Remove item
+
+Additional Commands:
+  list        List items
+
+Use "config.test item [command] --help" for more information about a command.
+`, getCmdHelpStr("item"))
+
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{"item", "rm", "foo"})
+	assert.Nil(t, rootCmd.Execute())
+	assert.Equal(t, []string{"foo"}, removed)
+
+	assert.Panics(t, func() { AddCmdWithOptions("item add", CmdOptions{Group: "unknown"}) })
+	assert.Panics(t, func() { AddCmdGroup("item", "write", "Duplicate:") })
+}
+
 func TestAddFlags(t *testing.T) {
 	var currentIntValue int
 	var currentStringValue string
@@ -141,6 +187,140 @@ func TestAddFlags(t *testing.T) {
 	assert.True(t, currentBoolValue)
 }
 
+func TestConfigFileFlag(t *testing.T) {
+	var loadedName string
+	AddCmd("testconfig", "Test config", "", func(args []string) {
+		loaded := &enterprise{}
+		UnmarshalKey("enterprise", loaded)
+		loadedName = loaded.Name
+	}, nil)
+	AddConfigFileFlagToCmd("testconfig", "../test_data/config-test.yml", true)
+
+	AddCmd("testconfigopt", "Test optional config", "", func(args []string) {}, nil)
+	AddConfigFileFlagToCmd("testconfigopt", "../test_data/missing.yml", false)
+
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{"testconfig"})
+	assert.Nil(t, rootCmd.Execute())
+	assert.Equal(t, "Foo", loadedName)
+
+	rootCmd.SetArgs([]string{"testconfig", "--config", ""})
+	assert.ErrorContains(t, rootCmd.Execute(), "missing --config")
+
+	rootCmd.SetArgs([]string{"testconfigopt"})
+	assert.Nil(t, rootCmd.Execute(), "missing optional config file at default path should be ignored")
+
+	rootCmd.SetArgs([]string{"testconfigopt", "--config", "../test_data/missing.yml"})
+	assert.ErrorContains(t, rootCmd.Execute(), "failed to read config file")
+}
+
+func TestConfigDiscovery(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	opts := ConfigDiscoveryOptions{AppName: "testdiscovery", Extensions: []string{"yml", "json"}}
+	assert.Equal(t, []string{
+		"config.yml",
+		"config.json",
+		filepath.Join(configHome, "testdiscovery", "config.yml"),
+		filepath.Join(configHome, "testdiscovery", "config.json"),
+		"/etc/testdiscovery/config.yml",
+		"/etc/testdiscovery/config.json",
+	}, ConfigSearchPaths(opts))
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String(configFileFlag, "", "")
+	flag := flags.Lookup(configFileFlag)
+
+	_, found := FindConfigFile(opts)
+	assert.False(t, found)
+	assert.NoError(t, loadGlobalConfigFile(flag, opts), "missing optional config file should be ignored")
+	opts.Required = true
+	assert.ErrorContains(t, loadGlobalConfigFile(flag, opts), "missing --config and no config file found in config.yml, config.json, ")
+
+	appConfigDir := filepath.Join(configHome, "testdiscovery")
+	assert.NoError(t, os.MkdirAll(appConfigDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(appConfigDir, "config.json"), []byte(`{"discovered": "xdg"}`), 0644))
+	path, found := FindConfigFile(opts)
+	assert.True(t, found)
+	assert.Equal(t, filepath.Join(appConfigDir, "config.json"), path)
+	assert.NoError(t, loadGlobalConfigFile(flag, opts))
+	assert.Equal(t, "xdg", viper.GetString("discovered"))
+
+	assert.NoError(t, flags.Set(configFileFlag, "../test_data/missing.yml"))
+	assert.ErrorContains(t, loadGlobalConfigFile(flag, opts), "failed to read config file '../test_data/missing.yml'")
+}
+
+func TestExplain(t *testing.T) {
+	var name, other string
+	var sources map[string]Source
+	AddCmd("explaintest", "Test explain", "", func(args []string) { sources = Explain() }, nil)
+	AddStringFlagToCmd("explaintest", &name, "name", "", "Name")
+	AddStringFlagToCmd("explaintest", &other, "other", "", "Other")
+	AddConfigFileFlagToCmd("explaintest", "../test_data/config-test.yml", true)
+	addExplainToCommands()
+
+	t.Setenv("ENTERPRISE_ARGUMENTS", "from env")
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	defer viper.SetEnvKeyReplacer(strings.NewReplacer())
+
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{"explaintest", "--name", "foo"})
+	assert.Nil(t, rootCmd.Execute())
+	assert.Equal(t, SourceCommandLine, sources["name"])
+	assert.Equal(t, SourceDefault, sources["other"])
+	assert.Equal(t, SourceDefault, sources["config"])
+	assert.Equal(t, SourceConfigFile, sources["enterprise.name"])
+	assert.Equal(t, SourceEnv, sources["enterprise.arguments"])
+}
+
+func TestShadowedFlags(t *testing.T) {
+	var parentName, childName, childOther string
+	AddCmd("shadowtest", "Test shadowed flags", "", nil, nil)
+	AddStringFlagToCmd("shadowtest", &parentName, "name", "", "Name in parent")
+	AddCmd("shadowtest child", "Test shadowed flags", "", func(args []string) {}, nil)
+	AddStringFlagToCmd("shadowtest child", &childOther, "other", "", "Other in child")
+	assert.Empty(t, checkShadowedFlags(getCommand("shadowtest")))
+
+	AddStringFlagToCmd("shadowtest child", &childName, "name", "", "Name in child")
+	prog := GetCmdName()
+	assert.Empty(t, checkShadowedFlags(getCommand("shadowtest")), "reported already on registration")
+	assert.True(t, reportedShadowedFlags[fmt.Sprintf(
+		"flag --name of command '%s shadowtest child' shadows the persistent flag of parent command '%s shadowtest'", prog, prog)])
+}
+
+func TestSortInitializers(t *testing.T) {
+	noop := func() error { return nil }
+	getNames := func(list []*initializer) []string {
+		names := make([]string, len(list))
+		for i, entry := range list {
+			names[i] = entry.name
+		}
+		return names
+	}
+
+	sorted, err := sortInitializers([]*initializer{
+		{"db", []string{"metrics", "logging"}, noop},
+		{"cache", nil, noop},
+		{"metrics", []string{"logging"}, noop},
+		{"logging", nil, noop},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logging", "metrics", "db", "cache"}, getNames(sorted))
+
+	_, err = sortInitializers([]*initializer{
+		{"logging", nil, noop},
+		{"db", []string{"logging", "metrics"}, noop},
+		{"metrics", []string{"db"}, noop},
+	})
+	assert.EqualError(t, err, "initializer dependency cycle: db -> metrics -> db")
+
+	_, err = sortInitializers([]*initializer{
+		{"db", []string{"logging"}, noop},
+	})
+	assert.EqualError(t, err, "initializer 'db' depends on unknown 'logging'")
+}
+
 func getCmdHelpStr(cmdPath string) string {
 	cmd := getCommand(cmdPath)
 
@@ -156,3 +336,162 @@ func getCmdHelpStr(cmdPath string) string {
 
 	return helpOut.String()
 }
+
+func TestExitCode(t *testing.T) {
+	AddCmd("testexitcode", "Test exit code", "", nil, func(args []string) error {
+		return fmt.Errorf("failed to connect: %w", NewTransientError(fmt.Errorf("timeout")))
+	})
+	AddConfigFileFlagToCmd("testexitcode", "", false)
+
+	rootCmd := getCommand("")
+	errOutput := &bytes.Buffer{}
+	rootCmd.SetErr(errOutput)
+	defer rootCmd.SetErr(nil)
+	rootCmd.SetFlagErrorFunc(flagErrorAsConfigError)
+	rootCmd.SetArgs([]string{"testexitcode"})
+	assert.Equal(t, ExitCodeTransient, executeCommand(rootCmd))
+	assert.Empty(t, errOutput.String(), "errors should only be logged and usage not printed")
+
+	rootCmd.SetArgs([]string{"testexitcode", "--unknown"})
+	assert.Equal(t, ExitCodeConfig, executeCommand(rootCmd))
+	assert.NotContains(t, errOutput.String(), "Error:")
+	assert.Equal(t, 1, strings.Count(errOutput.String(), "Usage:"), "usage should be printed once for invalid flags")
+
+	rootCmd.SetArgs([]string{"testexitcode", "--config", "../test_data/missing.yml"})
+	assert.Equal(t, ExitCodeConfig, handleCommandError(rootCmd.Execute()))
+
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, ExitCodeError, ExitCode(fmt.Errorf("unknown")))
+	assert.Equal(t, ExitCodeFatal, ExitCode(NewFatalError(fmt.Errorf("corrupted"))))
+	assert.EqualError(t, NewFatalError(fmt.Errorf("corrupted")), "corrupted")
+}
+
+func TestOnConfigChange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "watched.yml")
+	assert.NoError(t, os.WriteFile(file, []byte("log_level: info\n"), 0644))
+	ReadConfigFile(file)
+
+	changed := make(chan string, 10)
+	OnConfigChange(func() { changed <- viper.GetString("log_level") })
+	assert.NoError(t, os.WriteFile(file, []byte("log_level: debug\n"), 0644))
+
+	select {
+	case level := <-changed:
+		assert.Equal(t, "debug", level)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "config change not detected")
+	}
+}
+
+func TestGenerateDocs(t *testing.T) {
+	AddDocsCommand()
+	AddCmdWithArgs("documented", "Documented command", &struct {
+		ListenAddress string `help:"Address to listen on"`
+	}{}, func(args []string) {})
+
+	dir := t.TempDir()
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{"docs", "--dir", dir})
+	assert.Nil(t, rootCmd.Execute())
+	markdown, mdErr := os.ReadFile(filepath.Join(dir, "config.test_documented.md"))
+	assert.NoError(t, mdErr)
+	assert.Contains(t, string(markdown), "Documented command")
+	assert.Contains(t, string(markdown), "--listen_address string   Address to listen on")
+	assert.NoFileExists(t, filepath.Join(dir, "config.test_docs.md"))
+
+	assert.NoError(t, GenerateDocs(DocsFormatMan, dir))
+	assert.FileExists(t, filepath.Join(dir, "config.test-documented.1"))
+	assert.ErrorContains(t, GenerateDocs("html", dir), "invalid format 'html'")
+}
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(next RunFunc) RunFunc {
+			return func(cmdPath string, args []string) error {
+				calls = append(calls, name+" before "+cmdPath)
+				err := next(cmdPath, args)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	AddCmd("intercepted", "Test interceptors", "", func(args []string) { calls = append(calls, "run "+args[0]) }, nil)
+
+	run := chainInterceptors(getRunFunc(getCommand("intercepted")), []Interceptor{trace("outer"), trace("inner")})
+	assert.NoError(t, run("intercepted", []string{"foo"}))
+	assert.Equal(t, []string{"outer before intercepted", "inner before intercepted", "run foo", "inner after", "outer after"}, calls)
+
+	panicking := RecoverPanics(func(cmdPath string, args []string) error { panic("oops") })
+	err := panicking("intercepted", nil)
+	assert.IsType(t, &logger.StructuredError{}, err)
+	assert.EqualError(t, errors.Unwrap(err), "panic in command: oops")
+	assert.Contains(t, err.Error(), "cmd=intercepted stack=")
+	assert.Equal(t, ExitCodeError, ExitCode(err))
+}
+
+func TestVerify(t *testing.T) {
+	output := &bytes.Buffer{}
+	probes := []*probe{
+		{"db", func(ctx context.Context) error { return nil }},
+		{"api", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{"broken", func(ctx context.Context) error { panic("oops") }},
+	}
+	err := runVerify(context.Background(), output, func() error { return errors.New("missing url") }, probes, 10*time.Millisecond)
+	assert.EqualError(t, err, "verification failed: 3 of 4 checks failed")
+	assert.Equal(t, ExitCodeConfig, ExitCode(err))
+	assert.Regexp(t, `^FAIL config: missing url
+OK   probe db \(\d+s\)
+FAIL probe api: context deadline exceeded
+FAIL probe broken: panic in probe: oops
+$`, output.String())
+
+	output.Reset()
+	assert.NoError(t, runVerify(context.Background(), output, func() error { return nil }, probes[:1], time.Second))
+	assert.Regexp(t, `^OK   config \(\d+s\)
+OK   probe db \(\d+s\)
+All 2 checks passed
+$`, output.String())
+}
+
+func TestVersionOutput(t *testing.T) {
+	rootCmd := getCommand("")
+	output := &bytes.Buffer{}
+	rootCmd.SetOut(output)
+	defer rootCmd.SetOut(nil)
+
+	assert.Equal(t, "1.2.3", AddVersionCommandWithOptions("1.2.3", VersionOptions{BuildInfo: true}))
+	info := GetVersionInfo()
+	assert.Equal(t, "1.2.3", info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.NotPanics(t, func() { AddVersionCommandWithOptions("1.2.3", VersionOptions{BuildInfo: true}) })
+
+	rootCmd.SetArgs([]string{"--version"})
+	assert.Nil(t, rootCmd.Execute())
+	assert.True(t, strings.HasPrefix(output.String(), rootCmd.Name()+" version 1.2.3\n"), output.String())
+	assert.Contains(t, output.String(), "\ngo:       "+runtime.Version()+" "+runtime.GOOS+"/"+runtime.GOARCH+"\n")
+
+	output.Reset()
+	rootCmd.SetArgs([]string{"--version", "--version-output=json"})
+	assert.Nil(t, rootCmd.Execute())
+	decoded := VersionInfo{}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &decoded))
+	assert.Equal(t, info, decoded)
+
+	rootCmd.SetFlagErrorFunc(flagErrorAsConfigError)
+	rootCmd.SetArgs([]string{"--version", "--version-output=xml"})
+	assert.Equal(t, ExitCodeConfig, handleCommandError(rootCmd.Execute()))
+
+	assert.Equal(t, "module:   example.com/app v1.0.0\nrevision: abc123 2021-05-06T07:48:48Z (modified)\n",
+		strings.TrimPrefix(formatVersionText("app", VersionInfo{
+			Version:       "1.0.0",
+			Module:        "example.com/app",
+			ModuleVersion: "v1.0.0",
+			Revision:      "abc123",
+			RevisionTime:  "2021-05-06T07:48:48Z",
+			Modified:      true,
+		}), "app version 1.0.0\n"))
+}
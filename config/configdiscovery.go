@@ -0,0 +1,155 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ConfigDiscoveryOptions defines where to look for the config file if it's not given by the global "--config" flag
+type ConfigDiscoveryOptions struct {
+	AppName    string   // directory name under /etc and $XDG_CONFIG_HOME, default the name of executable
+	FileName   string   // file name without extension, default "config"
+	Extensions []string // file extensions in order of preference, default "yml", "yaml", "json" and "toml"
+	Required   bool     // fail if no config file is given or found
+}
+
+// globalConfigFlag is set by EnableGlobalConfigFlag
+var globalConfigFlag *struct {
+	flag *pflag.Flag
+	opts ConfigDiscoveryOptions
+}
+
+// EnableGlobalConfigFlag adds a persistent "--config" flag to the root command, and loads the config file given by the
+// flag, or else the first one found by FindConfigFile, before any runnable command runs
+//
+// Commands having their own flags by AddConfigFileFlagToCmd are not affected. EnableConfigFileFlags is ignored.
+func EnableGlobalConfigFlag(opts ConfigDiscoveryOptions) {
+	opts = withDefaultDiscoveryOptions(opts)
+	var configFile string
+	rootCmd := getCommand("")
+	rootCmd.PersistentFlags().StringVar(&configFile, configFileFlag, "",
+		fmt.Sprintf("Path of config file (default: the first found of %s)", strings.Join(ConfigSearchPaths(opts), ", ")))
+	globalConfigFlag = &struct {
+		flag *pflag.Flag
+		opts ConfigDiscoveryOptions
+	}{rootCmd.PersistentFlags().Lookup(configFileFlag), opts}
+}
+
+// ConfigSearchPaths returns the paths to look for the config file in order of precedence, e.g. for "myapp":
+//
+//	./config.yml, ..., $XDG_CONFIG_HOME/myapp/config.yml, ..., /etc/myapp/config.yml, ...
+//
+// $XDG_CONFIG_HOME is "$HOME/.config" if unset.
+func ConfigSearchPaths(opts ConfigDiscoveryOptions) []string {
+	opts = withDefaultDiscoveryOptions(opts)
+	dirs := []string{"."}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		dirs = append(dirs, filepath.Join(configHome, opts.AppName))
+	}
+	dirs = append(dirs, filepath.Join("/etc", opts.AppName))
+
+	paths := make([]string, 0, len(dirs)*len(opts.Extensions))
+	for _, dir := range dirs {
+		for _, ext := range opts.Extensions {
+			paths = append(paths, filepath.Join(dir, opts.FileName+"."+ext))
+		}
+	}
+	return paths
+}
+
+// FindConfigFile returns the first existing file of ConfigSearchPaths
+func FindConfigFile(opts ConfigDiscoveryOptions) (string, bool) {
+	for _, path := range ConfigSearchPaths(opts) {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func withDefaultDiscoveryOptions(opts ConfigDiscoveryOptions) ConfigDiscoveryOptions {
+	if opts.AppName == "" {
+		opts.AppName = rootCommandName
+	}
+	if opts.FileName == "" {
+		opts.FileName = "config"
+	}
+	if len(opts.Extensions) == 0 {
+		opts.Extensions = []string{"yml", "yaml", "json", "toml"}
+	}
+	return opts
+}
+
+// getDiscoveryOptions returns the options of EnableGlobalConfigFlag, or the defaults if not enabled
+func getDiscoveryOptions() ConfigDiscoveryOptions {
+	if globalConfigFlag != nil {
+		return globalConfigFlag.opts
+	}
+	return withDefaultDiscoveryOptions(ConfigDiscoveryOptions{})
+}
+
+// discoverConfigFile finds the config file to be loaded by ReadConfigFile("")
+func discoverConfigFile() (string, error) {
+	opts := getDiscoveryOptions()
+	file, found := FindConfigFile(opts)
+	if !found {
+		return "", fmt.Errorf("failed to find config file in %s", strings.Join(ConfigSearchPaths(opts), ", "))
+	}
+	return file, nil
+}
+
+// addGlobalConfigFileLoading makes all runnable commands load the config file if enabled by EnableGlobalConfigFlag
+func addGlobalConfigFileLoading() {
+	if globalConfigFlag == nil {
+		return
+	}
+	for _, cmd := range commandRegistry {
+		if !cmd.Runnable() {
+			continue
+		}
+		if flag := cmd.Flags().Lookup(configFileFlag); flag != nil && flag != globalConfigFlag.flag {
+			continue // own flag by AddConfigFileFlagToCmd
+		}
+		prependPreRunE(cmd, func(*cobra.Command) error {
+			return loadGlobalConfigFile(globalConfigFlag.flag, globalConfigFlag.opts)
+		})
+	}
+}
+
+// loadGlobalConfigFile loads the config file given by the flag, or else the first one found
+func loadGlobalConfigFile(flag *pflag.Flag, opts ConfigDiscoveryOptions) error {
+	if flag.Changed {
+		return loadConfigFileFromFlag(flag.Value.String(), true, opts.Required)
+	}
+	file, found := FindConfigFile(opts)
+	if !found {
+		if opts.Required {
+			return fmt.Errorf("missing --%s and no config file found in %s", configFileFlag, strings.Join(ConfigSearchPaths(opts), ", "))
+		}
+		return nil
+	}
+	return readConfigFile(file)
+}
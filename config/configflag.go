@@ -0,0 +1,123 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configFileFlag is the name of the flag added by AddConfigFileFlagToCmd
+const configFileFlag = "config"
+
+// configFileFlagDefaults is set by EnableConfigFileFlags to add the config flag to every runnable command on Execute
+var configFileFlagDefaults *struct {
+	defaultPath string
+	required    bool
+}
+
+// AddConfigFileFlagToCmd adds a "--config" flag to the command, whose file is loaded as the global config before the
+// command runs (see ReadConfigFile)
+//
+// If "required" is false, the file is only loaded when it exists or when the flag is explicitly set. A missing file
+// is an error only if it's specified in the command-line, so that the default path can point to an optional file.
+func AddConfigFileFlagToCmd(cmdPath string, defaultPath string, required bool) {
+	addConfigFileFlag(getCommand(cmdPath), defaultPath, required)
+}
+
+// EnableConfigFileFlags adds a "--config" flag to all runnable commands on Execute, except those already having one
+//
+// Use AddConfigFileFlagToCmd for commands requiring different default paths. It's ignored if EnableGlobalConfigFlag is
+// called.
+func EnableConfigFileFlags(defaultPath string, required bool) {
+	configFileFlagDefaults = &struct {
+		defaultPath string
+		required    bool
+	}{defaultPath, required}
+}
+
+// addDefaultConfigFileFlags adds the "--config" flag to all runnable commands if enabled by EnableConfigFileFlags
+func addDefaultConfigFileFlags() {
+	if configFileFlagDefaults == nil || globalConfigFlag != nil {
+		return
+	}
+	for _, cmd := range commandRegistry {
+		if !cmd.Runnable() || cmd.Flags().Lookup(configFileFlag) != nil {
+			continue
+		}
+		addConfigFileFlag(cmd, configFileFlagDefaults.defaultPath, configFileFlagDefaults.required)
+	}
+}
+
+func addConfigFileFlag(cmd *cobra.Command, defaultPath string, required bool) {
+	var configFile string
+	if required {
+		cmd.Flags().StringVar(&configFile, configFileFlag, defaultPath, "Path of config file (required)")
+	} else {
+		cmd.Flags().StringVar(&configFile, configFileFlag, defaultPath, "Path of config file")
+	}
+
+	prependPreRunE(cmd, func(cmd *cobra.Command) error {
+		return loadConfigFileFromFlag(configFile, cmd.Flags().Changed(configFileFlag), required)
+	})
+}
+
+// prependPreRunE makes the command call "load" before its own PreRunE or PreRun, with errors as ConfigError
+func prependPreRunE(cmd *cobra.Command, load func(cmd *cobra.Command) error) {
+	oldPreRunE := cmd.PreRunE
+	oldPreRun := cmd.PreRun
+	cmd.PreRun = nil
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := load(cmd); err != nil {
+			return NewConfigError(err)
+		}
+		if oldPreRunE != nil {
+			return oldPreRunE(cmd, args)
+		}
+		if oldPreRun != nil {
+			oldPreRun(cmd, args)
+		}
+		return nil
+	}
+}
+
+func loadConfigFileFromFlag(file string, explicit bool, required bool) error {
+	if file == "" {
+		if required {
+			return fmt.Errorf("missing --%s", configFileFlag)
+		}
+		return nil
+	}
+	if !required && !explicit {
+		if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	return readConfigFile(file)
+}
+
+func readConfigFile(file string) error {
+	viper.SetConfigFile(file)
+
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file '%s': %w", file, err)
+	}
+	startConfigWatch()
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/viper"
+)
+
+var (
+	configWatchLock      sync.Mutex
+	configWatchCallbacks []func()
+	configWatchStarted   bool
+)
+
+// OnConfigChange registers a callback to be called after the global config file is changed and reloaded, e.g. to
+// apply log levels by logger.BindConfig
+//
+// The file loaded by ReadConfigFile or the "--config" flag is watched once any callback is registered. Callbacks are
+// called in the order of registration, in the background.
+func OnConfigChange(callback func()) {
+	configWatchLock.Lock()
+	configWatchCallbacks = append(configWatchCallbacks, callback)
+	configWatchLock.Unlock()
+
+	startConfigWatch()
+}
+
+// startConfigWatch starts watching the global config file if loaded and there are callbacks
+func startConfigWatch() {
+	configWatchLock.Lock()
+	defer configWatchLock.Unlock()
+
+	if configWatchStarted || len(configWatchCallbacks) == 0 || viper.ConfigFileUsed() == "" {
+		return
+	}
+	configWatchStarted = true
+
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		logger.WithField("component", "Config").Infof("reloaded config file '%s'", event.Name)
+
+		configWatchLock.Lock()
+		callbacks := append([]func(){}, configWatchCallbacks...)
+		configWatchLock.Unlock()
+		for _, callback := range callbacks {
+			callback()
+		}
+	})
+	viper.WatchConfig()
+}
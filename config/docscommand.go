@@ -0,0 +1,79 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// Documentation formats of the command added by AddDocsCommand
+const (
+	DocsFormatMarkdown = "markdown"
+	DocsFormatMan      = "man"
+)
+
+// AddDocsCommand adds a hidden "docs" command under the root command, which generates Markdown or man pages for the
+// whole command tree including flags from structs, e.g. "myapp docs --format man --dir ./man"
+func AddDocsCommand() {
+	var format, dir string
+
+	cmd := &cobra.Command{
+		Use:    "docs",
+		Short:  "Generate documentation of commands",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := GenerateDocs(format, dir); err != nil {
+				return NewConfigError(err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Documentation written to %s\n", dir)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", DocsFormatMarkdown, "Format of documentation: markdown or man")
+	cmd.Flags().StringVar(&dir, "dir", "docs", "Directory to write documentation")
+
+	addCommand(cmd)
+}
+
+// GenerateDocs generates Markdown or man pages for all registered commands into the directory, one file per command
+func GenerateDocs(format string, dir string) error {
+	rootCmd := getCommand("")
+	rootCmd.DisableAutoGenTag = true
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	}
+
+	var err error
+	switch format {
+	case DocsFormatMarkdown:
+		err = doc.GenMarkdownTree(rootCmd, dir)
+	case DocsFormatMan:
+		err = doc.GenManTree(rootCmd, &doc.GenManHeader{
+			Title:   strings.ToUpper(GetCmdName()),
+			Section: "1",
+			Source:  strings.TrimSpace(GetCmdName() + " " + rootCmd.Version),
+		}, dir)
+	default:
+		return fmt.Errorf("invalid format '%s', expected %s or %s", format, DocsFormatMarkdown, DocsFormatMan)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate documentation: %w", err)
+	}
+	return nil
+}
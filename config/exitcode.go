@@ -0,0 +1,117 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+
+	"github.com/relex/gotils/envutil"
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the program by the class of error returned from commands, see Execute
+const (
+	ExitCodeError     = 1 // unclassified errors
+	ExitCodeConfig    = 2 // invalid flags, arguments or config files, not to be retried until fixed
+	ExitCodeTransient = 3 // temporary failures which could succeed on retry, e.g. network errors
+	ExitCodeFatal     = 4 // permanent failures not to be retried
+)
+
+// ExitError is an error returned from commands to exit the program with a specific code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// NewConfigError wraps an error of invalid flags, arguments or config to exit with ExitCodeConfig
+func NewConfigError(err error) error {
+	return &ExitError{Code: ExitCodeConfig, Err: err}
+}
+
+// NewTransientError wraps a temporary error to exit with ExitCodeTransient
+func NewTransientError(err error) error {
+	return &ExitError{Code: ExitCodeTransient, Err: err}
+}
+
+// NewFatalError wraps a permanent error to exit with ExitCodeFatal
+func NewFatalError(err error) error {
+	return &ExitError{Code: ExitCodeFatal, Err: err}
+}
+
+// ExitCode returns the exit code for the error returned from commands: 0 if nil, the code of ExitError if wrapped
+// inside, or ExitCodeError otherwise
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitCodeError
+}
+
+// executeCommand executes the root command and returns the exit code, with errors logged only by handleCommandError
+//
+// Usage of the command is printed only for config errors including invalid flags.
+func executeCommand(rootCmd *cobra.Command) int {
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	cmd, err := rootCmd.ExecuteC()
+	if cmd != nil && ExitCode(err) == ExitCodeConfig {
+		cmd.PrintErr(cmd.UsageString())
+	}
+	return handleCommandError(err)
+}
+
+// handleCommandError logs the error returned from the root command and returns the exit code
+func handleCommandError(err error) int {
+	code := ExitCode(err)
+	if code != 0 {
+		logger.WithField("exitCode", code).Error(err)
+	}
+	return code
+}
+
+// flagErrorAsConfigError is the flag error function of commands to classify flag errors as config errors
+func flagErrorAsConfigError(_ *cobra.Command, err error) error {
+	return NewConfigError(err)
+}
+
+// addEnvCheckToCommands makes all runnable commands check errors of environment variables before initializers and
+// interceptors, see envutil.Check
+func addEnvCheckToCommands() {
+	for _, cmd := range commandRegistry {
+		if !cmd.Runnable() {
+			continue
+		}
+		run := getRunFunc(cmd)
+		cmd.Run = nil
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			if err := envutil.Check(); err != nil {
+				return NewConfigError(err)
+			}
+			return run("", args)
+		}
+	}
+}
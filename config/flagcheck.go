@@ -0,0 +1,67 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// reportedShadowedFlags keeps warnings already reported by checkShadowedFlags, to report each only once
+var reportedShadowedFlags = make(map[string]bool)
+
+// checkShadowedFlags warns about flags of the command or its subcommands which have the same names as persistent
+// flags of their parent commands
+//
+// Cobra silently lets subcommand flags shadow inherited ones, so that values are read from the wrong flag.
+//
+// Returns the new warnings
+func checkShadowedFlags(cmd *cobra.Command) []string {
+	var warnings []string
+	visitFlags := func(current *cobra.Command, flag *pflag.Flag) {
+		for parent := current.Parent(); parent != nil; parent = parent.Parent() {
+			if parent.PersistentFlags().Lookup(flag.Name) == nil {
+				continue
+			}
+			warning := fmt.Sprintf("flag --%s of command '%s' shadows the persistent flag of parent command '%s'",
+				flag.Name, current.CommandPath(), parent.CommandPath())
+			if !reportedShadowedFlags[warning] {
+				reportedShadowedFlags[warning] = true
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+
+	var visitCommand func(current *cobra.Command)
+	visitCommand = func(current *cobra.Command) {
+		current.Flags().VisitAll(func(flag *pflag.Flag) { visitFlags(current, flag) })
+		current.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			if current.Flags().Lookup(flag.Name) != flag {
+				visitFlags(current, flag)
+			}
+		})
+		for _, child := range current.Commands() {
+			visitCommand(child)
+		}
+	}
+	visitCommand(cmd)
+
+	for _, warning := range warnings {
+		logger.Warn(warning)
+	}
+	return warnings
+}
@@ -0,0 +1,67 @@
+package flagext
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mileusna/crontab"
+	"github.com/spf13/pflag"
+)
+
+// CronExpression is a cron schedule in the syntax used by promexporter and scheduler, e.g. "*/5 * * * *"
+//
+// Empty value means no schedule.
+type CronExpression string
+
+var (
+	cronValidatorLock sync.Mutex
+	cronValidator     *crontab.Crontab
+)
+
+func (c *CronExpression) String() string {
+	return string(*c)
+}
+
+// Set validates and sets the cron expression
+func (c *CronExpression) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if s != "" {
+		if err := ValidateCronExpression(s); err != nil {
+			return err
+		}
+	}
+	*c = CronExpression(s)
+	return nil
+}
+
+func (c *CronExpression) Type() string {
+	return "cron"
+}
+
+// ValidateCronExpression checks the syntax and bounds of the cron expression
+func ValidateCronExpression(s string) error {
+	cronValidatorLock.Lock()
+	defer cronValidatorLock.Unlock()
+	if cronValidator == nil {
+		cronValidator = crontab.New()
+		cronValidator.Shutdown() // only for parsing
+	}
+	defer cronValidator.Clear()
+	if err := cronValidator.AddJob(s, func() {}); err != nil {
+		return fmt.Errorf("invalid cron expression '%s': %w", s, err)
+	}
+	return nil
+}
+
+// CronExpressionVar defines a CronExpression flag with specified name, default value, and usage string.
+// The argument p points to a CronExpression variable in which to store the value of the flag.
+func CronExpressionVar(f *pflag.FlagSet, p *CronExpression, name string, value CronExpression, usage string) {
+	CronExpressionVarP(f, p, name, "", value, usage)
+}
+
+// CronExpressionVarP is like CronExpressionVar, but accepts a shorthand letter that can be used after a single dash.
+func CronExpressionVarP(f *pflag.FlagSet, p *CronExpression, name, shorthand string, value CronExpression, usage string) {
+	*p = value
+	f.VarP(p, name, shorthand, usage)
+}
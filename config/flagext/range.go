@@ -0,0 +1,124 @@
+package flagext
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// rangeSeparator separates the lower and upper bounds of ranges, e.g. "2h30m..6h"
+const rangeSeparator = ".."
+
+// DurationRange is a range of durations, inclusive at both ends
+type DurationRange struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Contains checks whether the duration is within the range
+func (r DurationRange) Contains(d time.Duration) bool {
+	return d >= r.Min && d <= r.Max
+}
+
+func (r *DurationRange) String() string {
+	return r.Min.String() + rangeSeparator + r.Max.String()
+}
+
+// Set parses the range from "min..max", e.g. "2h30m..6h"
+func (r *DurationRange) Set(s string) error {
+	minStr, maxStr, err := splitRange(s)
+	if err != nil {
+		return err
+	}
+	lower, minErr := time.ParseDuration(minStr)
+	if minErr != nil {
+		return fmt.Errorf("failed to parse duration range '%s': %w", s, minErr)
+	}
+	upper, maxErr := time.ParseDuration(maxStr)
+	if maxErr != nil {
+		return fmt.Errorf("failed to parse duration range '%s': %w", s, maxErr)
+	}
+	if lower > upper {
+		return fmt.Errorf("invalid duration range '%s': min is greater than max", s)
+	}
+	*r = DurationRange{lower, upper}
+	return nil
+}
+
+func (r *DurationRange) Type() string {
+	return "durationRange"
+}
+
+// TimeWindow is a window of time from Start to End, inclusive at both ends
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains checks whether the time is within the window
+func (w TimeWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && !t.After(w.End)
+}
+
+func (w *TimeWindow) String() string {
+	return w.Start.Format(time.RFC3339Nano) + rangeSeparator + w.End.Format(time.RFC3339Nano)
+}
+
+// Set parses the window from "start..end", each in RFC3339 or local date/time as accepted by Time flags, e.g.
+// "2021-01-02T00:00:00Z..2021-01-03T00:00:00Z"
+func (w *TimeWindow) Set(s string) error {
+	startStr, endStr, err := splitRange(s)
+	if err != nil {
+		return err
+	}
+	var start, end timeValue
+	if err := start.Set(startStr); err != nil {
+		return fmt.Errorf("failed to parse time window '%s': %w", s, err)
+	}
+	if err := end.Set(endStr); err != nil {
+		return fmt.Errorf("failed to parse time window '%s': %w", s, err)
+	}
+	if time.Time(start).After(time.Time(end)) {
+		return fmt.Errorf("invalid time window '%s': start is after end", s)
+	}
+	*w = TimeWindow{time.Time(start), time.Time(end)}
+	return nil
+}
+
+func (w *TimeWindow) Type() string {
+	return "timeWindow"
+}
+
+func splitRange(s string) (string, string, error) {
+	lower, upper, found := strings.Cut(strings.TrimSpace(s), rangeSeparator)
+	if !found {
+		return "", "", fmt.Errorf("missing '%s' in range '%s'", rangeSeparator, s)
+	}
+	return strings.TrimSpace(lower), strings.TrimSpace(upper), nil
+}
+
+// DurationRangeVar defines a DurationRange flag with specified name, default value, and usage string.
+// The argument p points to a DurationRange variable in which to store the value of the flag.
+func DurationRangeVar(f *pflag.FlagSet, p *DurationRange, name string, value DurationRange, usage string) {
+	DurationRangeVarP(f, p, name, "", value, usage)
+}
+
+// DurationRangeVarP is like DurationRangeVar, but accepts a shorthand letter that can be used after a single dash.
+func DurationRangeVarP(f *pflag.FlagSet, p *DurationRange, name, shorthand string, value DurationRange, usage string) {
+	*p = value
+	f.VarP(p, name, shorthand, usage)
+}
+
+// TimeWindowVar defines a TimeWindow flag with specified name, default value, and usage string.
+// The argument p points to a TimeWindow variable in which to store the value of the flag.
+func TimeWindowVar(f *pflag.FlagSet, p *TimeWindow, name string, value TimeWindow, usage string) {
+	TimeWindowVarP(f, p, name, "", value, usage)
+}
+
+// TimeWindowVarP is like TimeWindowVar, but accepts a shorthand letter that can be used after a single dash.
+func TimeWindowVarP(f *pflag.FlagSet, p *TimeWindow, name, shorthand string, value TimeWindow, usage string) {
+	*p = value
+	f.VarP(p, name, shorthand, usage)
+}
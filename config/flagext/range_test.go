@@ -0,0 +1,57 @@
+package flagext
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationRangeVar(t *testing.T) {
+	var r DurationRange
+
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	DurationRangeVar(f, &r, "delay", DurationRange{time.Second, time.Minute}, "Delay range")
+	assert.Equal(t, "1s..1m0s", f.Lookup("delay").Value.String())
+
+	assert.Nil(t, f.Parse([]string{"--delay", "2h30m..6h"}))
+	assert.Equal(t, DurationRange{150 * time.Minute, 6 * time.Hour}, r)
+	assert.True(t, r.Contains(3*time.Hour))
+	assert.False(t, r.Contains(time.Hour))
+
+	assert.ErrorContains(t, r.Set("6h..2h"), "min is greater than max")
+	assert.ErrorContains(t, r.Set("6h"), "missing '..'")
+	assert.ErrorContains(t, r.Set("1x..2h"), "failed to parse duration range")
+}
+
+func TestTimeWindowVar(t *testing.T) {
+	var w TimeWindow
+
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	TimeWindowVar(f, &w, "window", TimeWindow{}, "Time window")
+
+	assert.Nil(t, f.Parse([]string{"--window", "2021-01-02T03:04:05Z..2021-01-03T00:00:00Z"}))
+	assert.Equal(t, "2021-01-02T03:04:05Z..2021-01-03T00:00:00Z", w.String())
+	assert.True(t, w.Contains(time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2021, 1, 3, 0, 0, 1, 0, time.UTC)))
+
+	assert.Nil(t, w.Set("2021-01-02..2021-01-03"))
+	assert.Equal(t, time.Date(2021, 1, 2, 0, 0, 0, 0, time.Local), w.Start)
+	assert.ErrorContains(t, w.Set("2021-01-03..2021-01-02"), "start is after end")
+}
+
+func TestCronExpressionVar(t *testing.T) {
+	var c CronExpression
+
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	CronExpressionVar(f, &c, "schedule", "0 * * * *", "Schedule")
+
+	assert.Nil(t, f.Parse([]string{"--schedule", "*/5 * * * *"}))
+	assert.Equal(t, CronExpression("*/5 * * * *"), c)
+	assert.Error(t, f.Parse([]string{"--schedule", "61 * * * *"}))
+	assert.Equal(t, CronExpression("*/5 * * * *"), c)
+	assert.Nil(t, c.Set(""))
+	assert.NoError(t, ValidateCronExpression("0 3 * * 1-5"))
+	assert.ErrorContains(t, ValidateCronExpression("* * *"), "invalid cron expression '* * *'")
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/pflag"
+)
+
+// FlagTypeHandler adds a flag of the given name and help to the flag set, for the struct field pointed by ptr
+//
+// The current value of the field should be used as the default value of the flag.
+type FlagTypeHandler func(flags *pflag.FlagSet, ptr interface{}, name string, help string)
+
+type flagTypeRegistration struct {
+	fieldType reflect.Type
+	handler   FlagTypeHandler
+}
+
+var (
+	flagTypesLock sync.Mutex
+	flagTypes     []flagTypeRegistration
+)
+
+// RegisterFlagType registers a handler to add struct flags for fields of custom types not supported by default, e.g.:
+//
+//	config.RegisterFlagType(reflect.TypeOf(CustomerID(0)), func(flags *pflag.FlagSet, ptr interface{}, name, help string) {
+//		flags.Var(ptr.(*CustomerID), name, help) // *CustomerID implements pflag.Value
+//	})
+//
+// If fieldType is an interface, the handler is also used for fields whose types or pointer types implement it, e.g.
+// all fields implementing pflag.Value. Exact types take precedence over interfaces, which are tried in the order of
+// registration. Registering the same type again replaces the previous handler.
+//
+// Handlers are consulted by AddStructFlagsToCmd and AddStructFlagsToFlags for types without built-in support, before
+// struct types are expanded into nested flags.
+func RegisterFlagType(fieldType reflect.Type, handler FlagTypeHandler) {
+	if fieldType == nil || handler == nil {
+		logger.Panic("nil type or handler for flag type registration")
+	}
+
+	flagTypesLock.Lock()
+	defer flagTypesLock.Unlock()
+	for i, reg := range flagTypes {
+		if reg.fieldType == fieldType {
+			flagTypes[i].handler = handler
+			return
+		}
+	}
+	flagTypes = append(flagTypes, flagTypeRegistration{fieldType, handler})
+}
+
+// tryAddRegisteredFlag adds a flag for the field if there is a registered handler for its type
+func tryAddRegisteredFlag(flags *pflag.FlagSet, fieldValue reflect.Value, name, help string) bool {
+	handler := lookupFlagTypeHandler(fieldValue.Type())
+	if handler == nil {
+		return false
+	}
+	handler(flags, fieldValue.Addr().Interface(), name, help)
+	return true
+}
+
+func lookupFlagTypeHandler(fieldType reflect.Type) FlagTypeHandler {
+	flagTypesLock.Lock()
+	defer flagTypesLock.Unlock()
+	for _, reg := range flagTypes {
+		if reg.fieldType == fieldType {
+			return reg.handler
+		}
+	}
+	for _, reg := range flagTypes {
+		if reg.fieldType.Kind() != reflect.Interface {
+			continue
+		}
+		if fieldType.Implements(reg.fieldType) || reflect.PointerTo(fieldType).Implements(reg.fieldType) {
+			return reg.handler
+		}
+	}
+	return nil
+}
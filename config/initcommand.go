@@ -0,0 +1,236 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+
+	gotilsio "github.com/relex/gotils/io"
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// promptAnnotation is the flag annotation set from the `prompt` tag of struct flags, to be asked by the init command
+const promptAnnotation = "prompt"
+
+// InitCommandOptions configures the command added by AddInitCommand
+type InitCommandOptions struct {
+	DefaultPath string                             // default path of config file to generate
+	Validate    func(flagStruct interface{}) error // optional validation of answers, given a pointer to a copy of flagStruct
+}
+
+// AddInitCommand adds an "init" subcommand under the parent command (empty for root), which asks for values of the
+// flag struct and writes them into a commented config file, e.g. for first-time setup by operators
+//
+// Fields tagged by `prompt:"Question?"` are asked, or all fields if there is no such tag, with the current values in
+// flagStruct as defaults. An empty question means the help of field. Answers are validated by their flag types and
+// then by opts.Validate if set.
+//
+// The keys in config file are the flag names, see GenerateConfigTemplate.
+func AddInitCommand(parentPath string, flagStruct interface{}, opts InitCommandOptions) {
+	var output string
+	var force, useDefaults bool
+
+	cmd := &cobra.Command{
+		Use:   strings.TrimLeft(parentPath+" init", " "),
+		Short: "Generate config file interactively",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInitWizard(cmd.InOrStdin(), cmd.OutOrStdout(), flagStruct, opts.Validate, output, force, useDefaults)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", opts.DefaultPath, "Path of config file to generate")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing config file")
+	cmd.Flags().BoolVar(&useDefaults, "defaults", false, "Use default values without asking")
+
+	addCommand(cmd)
+}
+
+// GenerateConfigTemplate generates a YAML config file from the flag struct (pointer), with keys being the flag names
+// and values from the struct, e.g.:
+//
+//	# Listen address (string)
+//	listen_address: ":8080"
+//	# Upstream servers (stringSlice)
+//	upstreams: [a.example.com, b.example.com]
+//
+// Nested structs are flattened in the same way as flag names, see AddStructFlagsToCmd.
+func GenerateConfigTemplate(flagStruct interface{}) ([]byte, error) {
+	flagSet := pflag.NewFlagSet("template", pflag.ContinueOnError)
+	flagSet.SortFlags = false
+	AddStructFlagsToFlags(logger.WithField("cmd", "template"), flagSet, copyFlagStruct(flagStruct))
+	return generateConfigTemplate(flagSet)
+}
+
+func runInitWizard(input io.Reader, output io.Writer, flagStruct interface{}, validate func(interface{}) error,
+	path string, force bool, useDefaults bool) error {
+
+	if path == "" {
+		return fmt.Errorf("missing --output")
+	}
+	if !force {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("config file '%s' already exists, use --force to overwrite", path)
+		}
+	}
+
+	answers := copyFlagStruct(flagStruct)
+	flagSet := pflag.NewFlagSet("init", pflag.ContinueOnError)
+	flagSet.SortFlags = false
+	AddStructFlagsToFlags(logger.WithField("cmd", "init"), flagSet, answers)
+
+	if !useDefaults {
+		if err := promptFlagValues(input, output, flagSet); err != nil {
+			return err
+		}
+	}
+	if validate != nil {
+		if err := validate(answers); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	data, err := generateConfigTemplate(flagSet)
+	if err != nil {
+		return err
+	}
+	if err := gotilsio.TryWriteFileAtomicallyWithOptions(path, data, gotilsio.AtomicWriteOptions{Mode: 0600, CreateDir: true}); err != nil {
+		return fmt.Errorf("failed to write config file '%s': %w", path, err)
+	}
+	fmt.Fprintf(output, "Config file written to %s\n", path)
+	return nil
+}
+
+// promptFlagValues asks for values of flags with the prompt annotation, or all flags if none is annotated
+//
+// Empty answers keep the defaults, and invalid answers are asked again. The end of input keeps all remaining defaults.
+func promptFlagValues(input io.Reader, output io.Writer, flagSet *pflag.FlagSet) error {
+	var flags []*pflag.Flag
+	var promptedFlags []*pflag.Flag
+	flagSet.VisitAll(func(f *pflag.Flag) {
+		flags = append(flags, f)
+		if _, found := f.Annotations[promptAnnotation]; found {
+			promptedFlags = append(promptedFlags, f)
+		}
+	})
+	if len(promptedFlags) > 0 {
+		flags = promptedFlags
+	}
+
+	scanner := bufio.NewScanner(input)
+	for _, f := range flags {
+		question := f.Usage
+		if prompt := f.Annotations[promptAnnotation]; len(prompt) > 0 && prompt[0] != "" {
+			question = prompt[0]
+		}
+		if question == "" {
+			question = f.Name
+		}
+		for {
+			fmt.Fprintf(output, "%s [%s]: ", question, f.Value.String())
+			if !scanner.Scan() {
+				fmt.Fprintln(output)
+				return scanner.Err()
+			}
+			answer := strings.TrimSpace(scanner.Text())
+			if answer == "" {
+				break
+			}
+			if err := setFlagValue(f.Value, answer); err != nil {
+				fmt.Fprintf(output, "Invalid %s: %v\n", f.Value.Type(), err)
+				continue
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// setFlagValue sets the flag value and restores the previous value on error, since some types reset it on failure
+func setFlagValue(value pflag.Value, text string) error {
+	if sliceValue, isSlice := value.(pflag.SliceValue); isSlice {
+		previous := sliceValue.GetSlice()
+		if err := value.Set(text); err != nil {
+			_ = sliceValue.Replace(previous) // previous values are always valid
+			return err
+		}
+		return nil
+	}
+	previous := value.String()
+	if err := value.Set(text); err != nil {
+		_ = value.Set(previous) // previous value is always valid
+		return err
+	}
+	return nil
+}
+
+func generateConfigTemplate(flagSet *pflag.FlagSet) ([]byte, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	flagSet.VisitAll(func(f *pflag.Flag) {
+		comment := f.Value.Type()
+		if f.Usage != "" {
+			comment = fmt.Sprintf("%s (%s)", f.Usage, f.Value.Type())
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name, HeadComment: comment}
+		mapping.Content = append(mapping.Content, key, newFlagValueNode(f.Value))
+	})
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: fmt.Sprintf("Config file of %s", GetCmdName()),
+		Content:     []*yaml.Node{mapping},
+	}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate config: %w", err)
+	}
+	return data, nil
+}
+
+func newFlagValueNode(value pflag.Value) *yaml.Node {
+	if sliceValue, isSlice := value.(pflag.SliceValue); isSlice {
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range sliceValue.GetSlice() {
+			node.Content = append(node.Content, newScalarNode(item, value.Type() == "stringSlice"))
+		}
+		return node
+	}
+	return newScalarNode(value.String(), value.Type() == "string")
+}
+
+func newScalarNode(value string, isString bool) *yaml.Node {
+	if isString || value == "" {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+// copyFlagStruct creates a shallow copy of the struct pointed by flagStruct and returns the pointer to it
+func copyFlagStruct(flagStruct interface{}) interface{} {
+	ptrValue := reflect.ValueOf(flagStruct)
+	if ptrValue.Kind() != reflect.Ptr {
+		logger.Panic("flagStruct must be a pointer to struct: ", flagStruct)
+	}
+	copyValue := reflect.New(ptrValue.Elem().Type())
+	copyValue.Elem().Set(ptrValue.Elem())
+	return copyValue.Interface()
+}
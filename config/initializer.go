@@ -0,0 +1,142 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+)
+
+type initializer struct {
+	name         string
+	dependencies []string
+	initialize   func() error
+}
+
+// initializerRegistry keeps initializers in the order of registration
+var initializerRegistry []*initializer
+
+// RegisterInitializer registers a function to be called before the selected command runs, after all the initializers
+// named in "dependencies" (e.g. "logging" before "metrics" before "db")
+//
+// Initializers are called by Execute in dependency order, after flags are parsed and the config file is loaded if
+// enabled. Unknown dependencies and cycles are fatal errors on Execute, and so are errors returned by initializers.
+func RegisterInitializer(name string, dependencies []string, initialize func() error) {
+	for _, entry := range initializerRegistry {
+		if entry.name == name {
+			logger.Panicf("failed to register initializer '%s': already exists", name)
+		}
+	}
+	initializerRegistry = append(initializerRegistry, &initializer{
+		name:         name,
+		dependencies: dependencies,
+		initialize:   initialize,
+	})
+}
+
+// addInitializersToCommands makes all runnable commands call registered initializers before running, except those
+// annotated to skip them like the verify command
+func addInitializersToCommands() {
+	if len(initializerRegistry) == 0 {
+		return
+	}
+	sorted, err := sortInitializers(initializerRegistry)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	for _, cmd := range commandRegistry {
+		if !cmd.Runnable() || cmd.Annotations[skipInitializersAnnotation] != "" {
+			continue
+		}
+		oldRunE := cmd.RunE
+		oldRun := cmd.Run
+		cmd.Run = nil
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			if err := runInitializers(sorted); err != nil {
+				return err
+			}
+			if oldRunE != nil {
+				return oldRunE(cmd, args)
+			}
+			oldRun(cmd, args)
+			return nil
+		}
+	}
+}
+
+func runInitializers(sorted []*initializer) error {
+	for _, entry := range sorted {
+		logger.Debugf("run initializer '%s'", entry.name)
+		if err := entry.initialize(); err != nil {
+			return fmt.Errorf("failed to initialize '%s': %w", entry.name, err)
+		}
+	}
+	return nil
+}
+
+// sortInitializers sorts initializers topologically, keeping the order of registration for independent ones
+func sortInitializers(initializers []*initializer) ([]*initializer, error) {
+	byName := make(map[string]*initializer, len(initializers))
+	for _, entry := range initializers {
+		byName[entry.name] = entry
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(initializers))
+	sorted := make([]*initializer, 0, len(initializers))
+	var path []string
+
+	var visit func(entry *initializer) error
+	visit = func(entry *initializer) error {
+		switch states[entry.name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, name := range path {
+				if name == entry.name {
+					return fmt.Errorf("initializer dependency cycle: %s -> %s", strings.Join(path[i:], " -> "), entry.name)
+				}
+			}
+		}
+		states[entry.name] = visiting
+		path = append(path, entry.name)
+		for _, depName := range entry.dependencies {
+			dep, exists := byName[depName]
+			if !exists {
+				return fmt.Errorf("initializer '%s' depends on unknown '%s'", entry.name, depName)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[entry.name] = visited
+		sorted = append(sorted, entry)
+		return nil
+	}
+
+	for _, entry := range initializers {
+		if err := visit(entry); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+)
+
+// RunFunc is the run function of a command wrapped by interceptors, with the command path without the program name,
+// e.g. "show env" or "" for the root command
+type RunFunc func(cmdPath string, args []string) error
+
+// Interceptor wraps the run functions of all runnable commands, see Use
+type Interceptor func(next RunFunc) RunFunc
+
+// interceptorRegistry keeps interceptors in the order of registration, the first of which is the outermost
+var interceptorRegistry []Interceptor
+
+// Use registers an interceptor to wrap the run functions of all runnable commands, e.g. for startup logging, timing
+// or pushing metrics on exit
+//
+// Interceptors are applied by Execute, the first registered being the outermost. They run after initializers and the
+// errors they return are handled like errors returned from commands, see ExitCode.
+func Use(interceptor Interceptor) {
+	interceptorRegistry = append(interceptorRegistry, interceptor)
+}
+
+// RecoverPanics is an interceptor to convert panics in commands to errors with the fields "cmd" and "stack", which are
+// logged by Execute before exiting with ExitCodeError
+//
+// It should be registered first to cover panics in other interceptors.
+func RecoverPanics(next RunFunc) RunFunc {
+	return func(cmdPath string, args []string) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = logger.NewStructuredError(map[string]interface{}{
+					"cmd":   cmdPath,
+					"stack": string(debug.Stack()),
+				}, fmt.Errorf("panic in command: %v", rec))
+			}
+		}()
+		return next(cmdPath, args)
+	}
+}
+
+// addInterceptorsToCommands wraps the run functions of all runnable commands by registered interceptors
+func addInterceptorsToCommands() {
+	if len(interceptorRegistry) == 0 {
+		return
+	}
+	for path, cmd := range commandRegistry {
+		if !cmd.Runnable() {
+			continue
+		}
+		cmdPath := path
+		run := chainInterceptors(getRunFunc(cmd), interceptorRegistry)
+		cmd.Run = nil
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			return run(cmdPath, args)
+		}
+	}
+}
+
+// chainInterceptors wraps the run function with interceptors, the first of which is the outermost
+func chainInterceptors(run RunFunc, interceptors []Interceptor) RunFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		run = interceptors[i](run)
+	}
+	return run
+}
+
+// getRunFunc returns a RunFunc calling the original run function of the command
+func getRunFunc(cmd *cobra.Command) RunFunc {
+	oldRunE := cmd.RunE
+	oldRun := cmd.Run
+	return func(cmdPath string, args []string) error {
+		if oldRunE != nil {
+			return oldRunE(cmd, args)
+		}
+		oldRun(cmd, args)
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Source is where the final value of a flag or config key comes from
+type Source string
+
+// Sources of values, in the order of precedence from low to high
+const (
+	SourceDefault     Source = "default"
+	SourceConfigFile  Source = "config file"
+	SourceEnv         Source = "env"
+	SourceCommandLine Source = "command line"
+)
+
+var sourcePrecedence = map[Source]int{
+	SourceDefault:     0,
+	SourceConfigFile:  1,
+	SourceEnv:         2,
+	SourceCommandLine: 3,
+}
+
+// executedCommand is the command selected to run by Execute
+var executedCommand *cobra.Command
+
+// Explain returns the sources of all flags of the executed command and all keys of the global config, by flag names
+// and config keys
+//
+// Env vars are detected for config keys by the prefix set in viper.SetEnvPrefix, with dots replaced by underscores,
+// if viper is set to read them, e.g. by viper.AutomaticEnv.
+// If a flag and a config key have the same name, the source of higher precedence is returned.
+func Explain() map[string]Source {
+	sources := make(map[string]Source)
+	setSource := func(name string, source Source) {
+		if existing, found := sources[name]; !found || sourcePrecedence[source] > sourcePrecedence[existing] {
+			sources[name] = source
+		}
+	}
+
+	if executedCommand != nil {
+		executedCommand.Flags().VisitAll(func(f *pflag.Flag) {
+			if f.Changed {
+				setSource(f.Name, SourceCommandLine)
+			} else {
+				setSource(f.Name, SourceDefault)
+			}
+		})
+	}
+	for _, key := range viper.AllKeys() {
+		switch {
+		case isKeyFromEnv(key):
+			setSource(key, SourceEnv)
+		case viper.InConfig(key):
+			setSource(key, SourceConfigFile)
+		default:
+			setSource(key, SourceDefault)
+		}
+	}
+	return sources
+}
+
+func isKeyFromEnv(key string) bool {
+	name := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if prefix := viper.GetEnvPrefix(); prefix != "" {
+		name = strings.ToUpper(prefix) + "_" + name
+	}
+	value, found := os.LookupEnv(name)
+	return found && value == viper.GetString(key)
+}
+
+// addExplainToCommands makes all runnable commands record themselves for Explain and log the sources at debug level
+// before running
+func addExplainToCommands() {
+	for _, cmd := range commandRegistry {
+		if !cmd.Runnable() {
+			continue
+		}
+		oldRunE := cmd.RunE
+		oldRun := cmd.Run
+		cmd.Run = nil
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			executedCommand = cmd
+			logSources(Explain())
+			if oldRunE != nil {
+				return oldRunE(cmd, args)
+			}
+			oldRun(cmd, args)
+			return nil
+		}
+	}
+}
+
+func logSources(sources map[string]Source) {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		logger.Debugf("config '%s' from %s", name, sources[name])
+	}
+}
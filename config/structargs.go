@@ -16,6 +16,7 @@ package config
 import (
 	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -44,12 +45,14 @@ import (
 //   //   --str_io_opt string   Snake named flag (default "Hey there!")
 //   //   --timeout duration    (default 5s)
 //
-// Nested structs and embedded structs are also supported, see tests for more examples
+// Nested structs and embedded structs are also supported, see tests for more examples. Slices of structs are supported
+// by indexed flags up to the max count by tag, e.g. `max:"3"`. Other types can be supported by RegisterFlagType.
 func AddStructFlagsToCmd(cmdName string, flagStruct interface{}) {
 	cmd := getCommand(cmdName)
 	flagSet := cmd.PersistentFlags() // allow subcommands to inherit same flags
 
 	AddStructFlagsToFlags(logger.WithField("cmd", cmdName), flagSet, flagStruct)
+	checkShadowedFlags(cmd)
 }
 
 // AddStructFlagsToFlags adds new struct flags to use with the command-line
@@ -110,11 +113,66 @@ func addReflectedFlagsFromStruct(parentLogger logger.Logger, flags *pflag.FlagSe
 					}
 					addReflectedFlagsFromStruct(flogger, flags, fieldValue, nextNamePrefix, nextHelpPrefix)
 				}
+			} else if fieldValue.Kind() == reflect.Slice && fieldValue.Type().Elem().Kind() == reflect.Struct {
+				maxTag, _ := fieldType.Tag.Lookup("max")
+				addIndexedFlagsFromSlice(flogger, flags, fieldValue, maxTag, namePrefix+name+"_", helpPrefix+help)
 			} else {
-				flogger.Panicf("unsupported type")
+				flogger.Panicf("unsupported type, see RegisterFlagType")
 			}
 		}
+		if prompt, found := fieldType.Tag.Lookup("prompt"); found && flags.Lookup(namePrefix+name) != nil {
+			_ = flags.SetAnnotation(namePrefix+name, promptAnnotation, []string{prompt}) // flag exists and never fails
+		}
+	}
+}
+
+// addIndexedFlagsFromSlice adds flags for each element of a slice of structs up to the max count by the field tag
+// `max:"N"`, with the index in names, e.g. "--endpoints_0_host" and "--endpoints_0_port"
+//
+// The slice is extended to the highest index set, keeping elements before it from defaults or zero values.
+func addIndexedFlagsFromSlice(parentLogger logger.Logger, flags *pflag.FlagSet, sliceValue reflect.Value, maxTag string, namePrefix string, help string) {
+	maxLen, err := strconv.Atoi(maxTag)
+	if err != nil || maxLen <= 0 {
+		parentLogger.Panicf("slice of structs requires tag `max` for the max number of elements, got '%s'", maxTag)
+	}
+	if sliceValue.Len() > maxLen {
+		parentLogger.Panicf("default slice has %d elements, exceeding the max %d", sliceValue.Len(), maxLen)
+	}
+	if len(help) > 0 && !strings.HasSuffix(help, " ") {
+		help += " "
+	}
+
+	// flags are bound to elements in the backing array, which is shared by the slice resized on Set
+	backing := reflect.MakeSlice(sliceValue.Type(), maxLen, maxLen)
+	reflect.Copy(backing, sliceValue)
+	sliceValue.Set(backing.Slice(0, sliceValue.Len()))
+	for i := 0; i < maxLen; i++ {
+		elemFlags := pflag.NewFlagSet("", pflag.ContinueOnError)
+		addReflectedFlagsFromStruct(parentLogger, elemFlags, backing.Index(i), namePrefix+strconv.Itoa(i)+"_", help+"#"+strconv.Itoa(i)+" ")
+		length := i + 1
+		elemFlags.VisitAll(func(f *pflag.Flag) {
+			f.Value = &indexedFlagValue{f.Value, func() {
+				if sliceValue.Len() < length {
+					sliceValue.Set(backing.Slice(0, length))
+				}
+			}}
+			flags.AddFlag(f)
+		})
+	}
+}
+
+// indexedFlagValue wraps the flag value of a slice element to extend the slice when set
+type indexedFlagValue struct {
+	pflag.Value
+	extend func()
+}
+
+func (v *indexedFlagValue) Set(text string) error {
+	if err := v.Value.Set(text); err != nil {
+		return err
 	}
+	v.extend()
+	return nil
 }
 
 func tryAddReflectedFlag(flags *pflag.FlagSet, fieldValue reflect.Value, name, help string) bool {
@@ -132,6 +190,12 @@ func tryAddReflectedFlag(flags *pflag.FlagSet, fieldValue reflect.Value, name, h
 		flags.DurationVar(fieldValue.Addr().Interface().(*time.Duration), name, fieldValue.Interface().(time.Duration), help)
 	case "time.Time":
 		flagext.TimeVar(flags, fieldValue.Addr().Interface().(*time.Time), name, fieldValue.Interface().(time.Time), help)
+	case "flagext.DurationRange":
+		flagext.DurationRangeVar(flags, fieldValue.Addr().Interface().(*flagext.DurationRange), name, fieldValue.Interface().(flagext.DurationRange), help)
+	case "flagext.TimeWindow":
+		flagext.TimeWindowVar(flags, fieldValue.Addr().Interface().(*flagext.TimeWindow), name, fieldValue.Interface().(flagext.TimeWindow), help)
+	case "flagext.CronExpression":
+		flagext.CronExpressionVar(flags, fieldValue.Addr().Interface().(*flagext.CronExpression), name, fieldValue.Interface().(flagext.CronExpression), help)
 	case "[]net.IP":
 		flags.IPSliceVar(fieldValue.Addr().Interface().(*[]net.IP), name, fieldValue.Interface().([]net.IP), help)
 	case "[]time.Duration":
@@ -194,7 +258,7 @@ func tryAddReflectedFlag(flags *pflag.FlagSet, fieldValue reflect.Value, name, h
 		flags.StringSliceVar(fieldValue.Addr().Interface().(*[]string), name, fieldValue.Interface().([]string), help)
 
 	default:
-		return false
+		return tryAddRegisteredFlag(flags, fieldValue, name, help)
 	}
 	return true
 }
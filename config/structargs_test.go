@@ -14,9 +14,18 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/relex/gotils/config/flagext"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -90,6 +99,86 @@ Flags:
 	assert.True(t, runCalled)
 }
 
+func TestAddStructFlagsWithExtTypes(t *testing.T) {
+	cmdFlags := struct {
+		Delay    flagext.DurationRange  `help:"delay range"`
+		Window   flagext.TimeWindow     `help:"time window"`
+		Schedule flagext.CronExpression `help:"schedule"`
+	}{
+		Delay:    flagext.DurationRange{Min: time.Second, Max: time.Minute},
+		Schedule: "0 * * * *",
+	}
+
+	AddCmd("extflags", "Test command", "", func(_ []string) {}, nil)
+	AddStructFlagsToCmd("extflags", &cmdFlags)
+
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{
+		"extflags",
+		"--delay", "2h30m..6h",
+		"--window", "2021-01-02T00:00:00Z..2021-01-03T00:00:00Z",
+		"--schedule", "*/5 * * * *",
+	})
+	assert.Nil(t, rootCmd.Execute())
+	assert.Equal(t, flagext.DurationRange{Min: 150 * time.Minute, Max: 6 * time.Hour}, cmdFlags.Delay)
+	assert.Equal(t, time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC), cmdFlags.Window.End)
+	assert.Equal(t, flagext.CronExpression("*/5 * * * *"), cmdFlags.Schedule)
+
+	rootCmd.SetArgs([]string{"extflags", "--schedule", "* * *"})
+	assert.ErrorContains(t, rootCmd.Execute(), "invalid cron expression")
+}
+
+type testCustomerID int
+
+func (id *testCustomerID) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "C"))
+	*id = testCustomerID(n)
+	return err
+}
+
+func (id *testCustomerID) String() string {
+	return fmt.Sprintf("C%d", *id)
+}
+
+func (id *testCustomerID) Type() string {
+	return "customerID"
+}
+
+type testMoney struct {
+	Cents int64
+}
+
+func TestAddStructFlagsWithRegisteredTypes(t *testing.T) {
+	RegisterFlagType(reflect.TypeOf((*pflag.Value)(nil)).Elem(), func(flags *pflag.FlagSet, ptr interface{}, name, help string) {
+		flags.Var(ptr.(pflag.Value), name, help)
+	})
+	RegisterFlagType(reflect.TypeOf(testMoney{}), func(flags *pflag.FlagSet, ptr interface{}, name, help string) {
+		money := ptr.(*testMoney)
+		flags.Int64Var(&money.Cents, name, money.Cents, help+" in cents")
+	})
+
+	cmdFlags := struct {
+		Customer testCustomerID `help:"customer"`
+		Budget   testMoney      `help:"budget"`
+	}{
+		Customer: 7,
+		Budget:   testMoney{Cents: 100},
+	}
+
+	AddCmd("regflags", "Test command", "", func(_ []string) {}, nil)
+	AddStructFlagsToCmd("regflags", &cmdFlags)
+	assert.Contains(t, getCmdHelpStr("regflags"), `
+      --budget int            budget in cents (default 100)
+      --customer customerID   customer (default C7)
+`)
+
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{"regflags", "--customer", "C42", "--budget", "250"})
+	assert.Nil(t, rootCmd.Execute())
+	assert.Equal(t, testCustomerID(42), cmdFlags.Customer)
+	assert.Equal(t, testMoney{Cents: 250}, cmdFlags.Budget)
+}
+
 func TestAddStructFlagsWithEmbedAndNesting(t *testing.T) {
 
 	type commonConfig struct {
@@ -163,3 +252,91 @@ Flags:
 	assert.Nil(t, rootCmd.Execute()) // call runCmd() above
 	assert.True(t, runCalled)
 }
+
+func TestAddStructFlagsWithIndexedSlice(t *testing.T) {
+	type endpoint struct {
+		Host string `help:"host"`
+		Port int    `help:"port"`
+	}
+	cmdFlags := struct {
+		Endpoints []endpoint `help:"endpoint" max:"3"`
+	}{
+		Endpoints: []endpoint{{Host: "localhost", Port: 80}},
+	}
+
+	AddCmd("indexedflags", "Test command", "", func(_ []string) {}, nil)
+	AddStructFlagsToCmd("indexedflags", &cmdFlags)
+	assert.Contains(t, getCmdHelpStr("indexedflags"), `
+      --endpoints_0_host string   endpoint #0 host (default "localhost")
+      --endpoints_0_port int      endpoint #0 port (default 80)
+      --endpoints_1_host string   endpoint #1 host
+      --endpoints_1_port int      endpoint #1 port
+      --endpoints_2_host string   endpoint #2 host
+      --endpoints_2_port int      endpoint #2 port
+`)
+	assert.Equal(t, []endpoint{{Host: "localhost", Port: 80}}, cmdFlags.Endpoints)
+
+	rootCmd := getCommand("")
+	rootCmd.SetArgs([]string{"indexedflags", "--endpoints_2_host", "backup", "--endpoints_0_port", "8080"})
+	assert.Nil(t, rootCmd.Execute())
+	assert.Equal(t, []endpoint{{Host: "localhost", Port: 8080}, {}, {Host: "backup"}}, cmdFlags.Endpoints)
+
+	AddCmd("indexedflags-nomax", "Test command", "", func(_ []string) {}, nil)
+	assert.Panics(t, func() { AddStructFlagsToCmd("indexedflags-nomax", &struct{ Endpoints []endpoint }{}) })
+}
+
+type testServerFlags struct {
+	Host string `help:"Server host" prompt:"Server host name?"`
+	Port uint16 `help:"Server port" prompt:""`
+}
+
+type testInitFlags struct {
+	Server    testServerFlags
+	Upstreams []string      `help:"Upstream servers"`
+	Timeout   time.Duration `help:"Timeout" prompt:""`
+	Debug     bool
+}
+
+func TestInitWizard(t *testing.T) {
+	initFlags := testInitFlags{
+		Server:    testServerFlags{Host: "localhost", Port: 8080},
+		Upstreams: []string{"a", "b"},
+		Timeout:   5 * time.Second,
+	}
+
+	template, err := GenerateConfigTemplate(&initFlags)
+	assert.NoError(t, err)
+	assert.Equal(t, `# Config file of `+GetCmdName()+`
+
+# Server host (string)
+server_host: localhost
+# Server port (uint16)
+server_port: 8080
+# Upstream servers (stringSlice)
+upstreams: [a, b]
+# Timeout (duration)
+timeout: 5s
+# bool
+debug: false
+`, string(template))
+
+	path := filepath.Join(t.TempDir(), "conf", "test.yml")
+	output := &bytes.Buffer{}
+	validate := func(flagStruct interface{}) error {
+		if flagStruct.(*testInitFlags).Server.Host == "invalid" {
+			return fmt.Errorf("invalid host")
+		}
+		return nil
+	}
+	input := strings.NewReader("example.com\nabc\n9090\n")
+	assert.NoError(t, runInitWizard(input, output, &initFlags, validate, path, false, false))
+	assert.Equal(t, "Server host name? [localhost]: Server port [8080]: Invalid uint16: "+
+		`strconv.ParseUint: parsing "abc": invalid syntax`+"\nServer port [8080]: Timeout [5s]: \nConfig file written to "+path+"\n",
+		output.String())
+	written, _ := os.ReadFile(path)
+	assert.Contains(t, string(written), "server_host: example.com\n# Server port (uint16)\nserver_port: 9090\n")
+	assert.Equal(t, "localhost", initFlags.Server.Host, "original flag struct should be untouched")
+
+	assert.ErrorContains(t, runInitWizard(strings.NewReader(""), output, &initFlags, validate, path, false, true), "already exists")
+	assert.ErrorContains(t, runInitWizard(strings.NewReader("invalid\n"), output, &initFlags, validate, path, true, false), "invalid host")
+}
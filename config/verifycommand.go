@@ -0,0 +1,119 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/relex/gotils/logger"
+	"github.com/spf13/cobra"
+)
+
+// skipInitializersAnnotation is the command annotation to run it without registered initializers
+const skipInitializersAnnotation = "skipInitializers"
+
+type probe struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// probeRegistry keeps probes in the order of registration
+var probeRegistry []*probe
+
+// RegisterProbe registers a check of connectivity to an endpoint declared in config, e.g. DB or upstream API, to be
+// run by the verify command with the timeout in ctx
+func RegisterProbe(name string, check func(ctx context.Context) error) {
+	for _, entry := range probeRegistry {
+		if entry.name == name {
+			logger.Panicf("failed to register probe '%s': already exists", name)
+		}
+	}
+	probeRegistry = append(probeRegistry, &probe{name, check})
+}
+
+// AddVerifyCommand adds a "verify" command under the root command, which parses flags, loads the config file if
+// enabled, calls the validator (optional) and then runs all probes registered by RegisterProbe, without starting the
+// service, e.g. to validate config changes in CI before rollout
+//
+// A report of all checks is printed, and the command fails with ExitCodeConfig if any check fails. Initializers are
+// not called for the command.
+func AddVerifyCommand(validator func() error) {
+	var timeout time.Duration
+	var skipProbes bool
+
+	cmd := &cobra.Command{
+		Use:         "verify",
+		Short:       "Validate config and check connectivity without running",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipInitializersAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			probes := probeRegistry
+			if skipProbes {
+				probes = nil
+			}
+			return runVerify(cmd.Context(), cmd.OutOrStdout(), validator, probes, timeout)
+		},
+	}
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "Timeout of each probe")
+	cmd.Flags().BoolVar(&skipProbes, "skip-probes", false, "Validate config only, without connectivity checks")
+
+	addCommand(cmd)
+}
+
+func runVerify(ctx context.Context, output io.Writer, validator func() error, probes []*probe, timeout time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	numFailed := 0
+	report := func(name string, err error, duration time.Duration) {
+		if err != nil {
+			numFailed++
+			fmt.Fprintf(output, "FAIL %s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(output, "OK   %s (%s)\n", name, duration.Round(time.Millisecond))
+		}
+	}
+
+	if validator != nil {
+		start := time.Now()
+		report("config", validator(), time.Since(start))
+	}
+	for _, p := range probes {
+		start := time.Now()
+		report("probe "+p.name, runProbe(ctx, p, timeout), time.Since(start))
+	}
+
+	numChecks := len(probes)
+	if validator != nil {
+		numChecks++
+	}
+	if numFailed > 0 {
+		return NewConfigError(fmt.Errorf("verification failed: %d of %d checks failed", numFailed, numChecks))
+	}
+	fmt.Fprintf(output, "All %d checks passed\n", numChecks)
+	return nil
+}
+
+func runProbe(ctx context.Context, p *probe, timeout time.Duration) (err error) {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic in probe: %v", rec)
+		}
+	}()
+	return p.check(probeCtx)
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// versionOutputFlag is the flag to select the format of version output, named to not collide with "--output" of apps
+const versionOutputFlag = "version-output"
+
+// VersionOptions defines what to print for --version
+type VersionOptions struct {
+	// BuildInfo includes the main module, VCS revision and Go runtime from debug.ReadBuildInfo
+	BuildInfo bool
+}
+
+// VersionInfo is the information printed for --version
+type VersionInfo struct {
+	Version       string `json:"version"`
+	Module        string `json:"module,omitempty"`
+	ModuleVersion string `json:"moduleVersion,omitempty"`
+	Revision      string `json:"revision,omitempty"`
+	RevisionTime  string `json:"revisionTime,omitempty"`
+	Modified      bool   `json:"modified,omitempty"`
+	GoVersion     string `json:"goVersion,omitempty"`
+	Platform      string `json:"platform,omitempty"` // e.g. "linux/amd64"
+}
+
+// versionOutput is the value of output flag, validated on parsing
+type versionOutput string
+
+func (o *versionOutput) String() string {
+	return string(*o)
+}
+
+func (o *versionOutput) Set(value string) error {
+	if value != "text" && value != "json" {
+		return fmt.Errorf("must be text or json")
+	}
+	*o = versionOutput(value)
+	return nil
+}
+
+func (o *versionOutput) Type() string {
+	return "string"
+}
+
+// versionInfo is the info set by AddVersionCommandWithOptions, with Version being unused
+var versionInfo VersionInfo
+
+func init() {
+	cobra.AddTemplateFunc("configVersionOutput", formatVersionOutput)
+}
+
+// AddVersionCommandWithOptions adds -v and --version flags like AddVersionCommand, and the flag --version-output to
+// print version info as "text" (default) or "json", e.g. for deployment tools
+//
+// If version is an empty string or "dev", it's set to the version of main module from build info if available and
+// enabled by options, or dev-<timestamp> otherwise. Returns the version that was set
+//
+// The flag --version-output is only added once. Apps must not define a flag of the same name afterwards, which would
+// panic in cobra due to redefinition.
+func AddVersionCommandWithOptions(version string, opts VersionOptions) string {
+	versionInfo = VersionInfo{}
+	if opts.BuildInfo {
+		versionInfo = readBuildInfo()
+		if (version == "" || version == "dev") && versionInfo.ModuleVersion != "" {
+			version = versionInfo.ModuleVersion
+		}
+	}
+	version = AddVersionCommand(version)
+
+	cmd := getCommand("")
+	if cmd.Flags().Lookup(versionOutputFlag) == nil {
+		output := versionOutput("text")
+		cmd.Flags().Var(&output, versionOutputFlag, "format of version output with --version: text or json")
+	}
+	cmd.SetVersionTemplate(`{{configVersionOutput .}}`)
+	return version
+}
+
+// GetVersionInfo returns the version info set by AddVersionCommandWithOptions, or only the version set by
+// AddVersionCommand
+func GetVersionInfo() VersionInfo {
+	info := versionInfo
+	info.Version = GetVersion()
+	return info
+}
+
+// readBuildInfo reads the version info of the executable, without Version
+func readBuildInfo() VersionInfo {
+	info := VersionInfo{
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = buildInfo.Main.Path
+	if buildInfo.Main.Version != "(devel)" {
+		info.ModuleVersion = buildInfo.Main.Version
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.RevisionTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// formatVersionOutput formats version info for --version by the output flag
+func formatVersionOutput(cmd *cobra.Command) (string, error) {
+	info := GetVersionInfo()
+	if output := cmd.Flags().Lookup(versionOutputFlag); output != nil && output.Value.String() == "json" {
+		content, err := json.Marshal(info)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal version info: %w", err)
+		}
+		return string(content) + "\n", nil
+	}
+	return formatVersionText(cmd.Name(), info), nil
+}
+
+// formatVersionText formats version info as lines of text, the first being the same as the default of cobra
+func formatVersionText(name string, info VersionInfo) string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "%s version %s\n", name, info.Version)
+	if info.Module != "" {
+		fmt.Fprintf(builder, "module:   %s\n", strings.TrimSpace(info.Module+" "+info.ModuleVersion))
+	}
+	if info.Revision != "" {
+		revision := info.Revision
+		if revisionTime, err := time.Parse(time.RFC3339, info.RevisionTime); err == nil {
+			revision += " " + revisionTime.UTC().Format(time.RFC3339)
+		}
+		if info.Modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(builder, "revision: %s\n", revision)
+	}
+	if info.GoVersion != "" {
+		fmt.Fprintf(builder, "go:       %s %s\n", info.GoVersion, info.Platform)
+	}
+	return builder.String()
+}
@@ -0,0 +1,221 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// DefaultStreamBatchRows is the default number of rows inserted and committed in each transaction of BulkInsertStream
+const DefaultStreamBatchRows = 10000
+
+// StreamOptions configures BulkInsertStream, BulkInsertFromChannel and BulkInsertRows
+type StreamOptions struct {
+	// BatchRows is the number of rows inserted and committed in each transaction, DefaultStreamBatchRows if zero
+	BatchRows int
+
+	// OnProgress is called after each batch is committed, optional
+	OnProgress func(progress StreamProgress)
+
+	// Metrics creates metrics with the prefix "bulkinsert_stream_" and the label "table", optional
+	Metrics promreg.MetricCreator
+
+	// Quarantine receives rows which fail to be inserted, optional
+	//
+	// If set, a failed batch is retried row by row in separate transactions, and each failing row is passed to
+	// Quarantine with its error instead of aborting the load. Returning an error from Quarantine aborts the load.
+	Quarantine func(row []interface{}, err error) error
+
+	// MaxQuarantinedRows aborts the load when more rows are quarantined, e.g. when the DB is down, unlimited if zero
+	MaxQuarantinedRows int64
+}
+
+// StreamProgress is the accumulated progress of a streaming bulk-insert
+type StreamProgress struct {
+	Batches     int   // numbers of committed batches
+	Rows        int64 // numbers of committed rows
+	Quarantined int64 // numbers of rows passed to StreamOptions.Quarantine
+}
+
+type streamMetrics struct {
+	batches     promext.RWCounter
+	rows        promext.RWCounter
+	errors      promext.RWCounter
+	quarantined promext.RWCounter
+}
+
+// BulkInsertStream performs bulk-insert from rows pulled from the iterator "next" until it returns false, in batches
+// committed by separate transactions from the pool
+//
+// Unlike Dialect.BulkInsert, the number of rows doesn't need to be known and only one batch is kept in memory.
+//
+// On errors, the batches committed before remain in the database and their row count is returned with the error.
+// Set StreamOptions.Quarantine to skip failing rows instead.
+func BulkInsertStream(ctx context.Context, pool *Pool, dialect Dialect, tableName string, columnNames []string, next func() ([]interface{}, bool), opts StreamOptions) (int64, error) {
+	batchRows := opts.BatchRows
+	if batchRows <= 0 {
+		batchRows = DefaultStreamBatchRows
+	}
+	var metrics *streamMetrics
+	if opts.Metrics != nil {
+		metricCreator := opts.Metrics.AddOrGetPrefix("bulkinsert_stream_", []string{"table"}, []string{tableName})
+		metrics = &streamMetrics{
+			batches:     metricCreator.AddOrGetCounter("batches_total", "Numbers of committed batches", nil, nil),
+			rows:        metricCreator.AddOrGetCounter("rows_total", "Numbers of committed rows", nil, nil),
+			errors:      metricCreator.AddOrGetCounter("errors_total", "Numbers of failed batches", nil, nil),
+			quarantined: metricCreator.AddOrGetCounter("quarantined_rows_total", "Numbers of rows failed to insert and quarantined", nil, nil),
+		}
+	}
+
+	progress := StreamProgress{}
+	batch := make([][]interface{}, 0, batchRows)
+	for {
+		batch = batch[:0]
+		for len(batch) < batchRows {
+			row, ok := next()
+			if !ok {
+				break
+			}
+			batch = append(batch, row)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return progress.Rows, fmt.Errorf("aborted bulk-insert after %d rows: %w", progress.Rows, ctxErr)
+		}
+		if len(batch) == 0 {
+			return progress.Rows, nil
+		}
+
+		insertRows := func(rows [][]interface{}) (int64, error) {
+			var count int64
+			err := pool.WithTx(ctx, func(tx *sql.Tx) error {
+				var insertErr error
+				count, insertErr = dialect.BulkInsert(ctx, tx, tableName, columnNames, len(rows), func(index int) []interface{} {
+					return rows[index]
+				})
+				return insertErr
+			})
+			return count, err
+		}
+
+		count, err := insertRows(batch)
+		if err != nil {
+			if metrics != nil {
+				metrics.errors.Inc()
+			}
+			if opts.Quarantine == nil || ctx.Err() != nil {
+				return progress.Rows, fmt.Errorf("failed to insert batch #%d after %d rows: %w", progress.Batches, progress.Rows, err)
+			}
+			logger.WithField("table", tableName).Warnf("failed to insert batch #%d, retry row by row: %v", progress.Batches, err)
+
+			var quarantined int64
+			count, quarantined, err = insertRowsIndividually(ctx, batch, insertRows, opts, progress.Quarantined)
+			progress.Quarantined += quarantined
+			if metrics != nil {
+				metrics.quarantined.Add(uint64(quarantined))
+			}
+			if err != nil {
+				progress.Rows += count
+				if metrics != nil {
+					metrics.rows.Add(uint64(count))
+				}
+				return progress.Rows, fmt.Errorf("failed to insert batch #%d row by row after %d rows: %w", progress.Batches, progress.Rows, err)
+			}
+		}
+
+		progress.Batches++
+		progress.Rows += count
+		if metrics != nil {
+			metrics.batches.Inc()
+			metrics.rows.Add(uint64(count))
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+		if len(batch) < batchRows {
+			return progress.Rows, nil
+		}
+	}
+}
+
+// BulkInsertFromChannel performs bulk-insert like BulkInsertStream, with rows received from the channel until closed
+func BulkInsertFromChannel(ctx context.Context, pool *Pool, dialect Dialect, tableName string, columnNames []string, rows <-chan []interface{}, opts StreamOptions) (int64, error) {
+	next := func() ([]interface{}, bool) {
+		select {
+		case row, ok := <-rows:
+			return row, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	return BulkInsertStream(ctx, pool, dialect, tableName, columnNames, next, opts)
+}
+
+// BulkInsertRows performs bulk-insert from input rows represented by (rowCount, getRow) like Dialect.BulkInsert, in a
+// transaction from the pool
+//
+// All rows are inserted in one transaction unless StreamOptions.BatchRows is set. With StreamOptions.Quarantine, a
+// failed batch is retried row by row and failing rows are quarantined instead of aborting the load, as in
+// BulkInsertStream. Rows returned by getRow are kept until their batch is committed and must not be reused.
+func BulkInsertRows(ctx context.Context, pool *Pool, dialect Dialect, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}, opts StreamOptions) (int64, error) {
+	if opts.BatchRows <= 0 && rowCount > 0 {
+		opts.BatchRows = rowCount
+	}
+	index := 0
+	next := func() ([]interface{}, bool) {
+		if index >= rowCount {
+			return nil, false
+		}
+		index++
+		return getRow(index - 1), true
+	}
+	return BulkInsertStream(ctx, pool, dialect, tableName, columnNames, next, opts)
+}
+
+// insertRowsIndividually inserts each row of the failed batch by insertRows, and passes failing rows to
+// opts.Quarantine
+//
+// Returns the numbers of inserted and quarantined rows, and the error if the load should be aborted.
+func insertRowsIndividually(ctx context.Context, batch [][]interface{}, insertRows func(rows [][]interface{}) (int64, error),
+	opts StreamOptions, previouslyQuarantined int64) (inserted int64, quarantined int64, err error) {
+
+	for _, row := range batch {
+		count, insertErr := insertRows([][]interface{}{row})
+		if insertErr == nil {
+			inserted += count
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return inserted, quarantined, ctxErr
+		}
+		if opts.MaxQuarantinedRows > 0 && previouslyQuarantined+quarantined >= opts.MaxQuarantinedRows {
+			return inserted, quarantined, fmt.Errorf("too many quarantined rows (max %d), last error: %w", opts.MaxQuarantinedRows, insertErr)
+		}
+		if qErr := opts.Quarantine(row, insertErr); qErr != nil {
+			return inserted, quarantined, fmt.Errorf("failed to quarantine row: %w", qErr)
+		}
+		quarantined++
+	}
+	return inserted, quarantined, nil
+}
+
+// NewQuarantineWriter creates a StreamOptions.Quarantine callback which writes each row and its error as a JSON
+// line to the writer, e.g. an opened file:
+//
+//	{"row":[1,"abc"],"error":"pq: value too long for type character varying(2)"}
+func NewQuarantineWriter(writer io.Writer) func(row []interface{}, err error) error {
+	encoder := json.NewEncoder(writer)
+	return func(row []interface{}, err error) error {
+		return encoder.Encode(quarantinedRow{Row: row, Error: err.Error()})
+	}
+}
+
+type quarantinedRow struct {
+	Row   []interface{} `json:"row"`
+	Error string        `json:"error"`
+}
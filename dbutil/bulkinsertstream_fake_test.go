@@ -0,0 +1,138 @@
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/dbutil/dbutiltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInsertStream(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	pool, err := dbutil.NewPool(dbutiltest.DriverName, fake.DSN(), dbutil.PoolOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+
+	var progresses []dbutil.StreamProgress
+	count, err := dbutil.BulkInsertStream(context.Background(), pool, dbutiltest.Dialect, "orders", []string{"id"},
+		newRowIterator(5), dbutil.StreamOptions{
+			BatchRows:  2,
+			OnProgress: func(progress dbutil.StreamProgress) { progresses = append(progresses, progress) },
+		})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, count)
+	assert.Equal(t, []dbutil.StreamProgress{{Batches: 1, Rows: 2}, {Batches: 2, Rows: 4}, {Batches: 3, Rows: 5}}, progresses)
+	assert.Equal(t, [][]interface{}{{0}, {1}, {2}, {3}, {4}}, fake.InsertedRows("orders"))
+	assert.Len(t, fake.BulkInserts(), 3)
+	assert.Equal(t, 3, fake.Commits())
+
+	fake.Reset()
+	count, err = dbutil.BulkInsertStream(context.Background(), pool, dbutiltest.Dialect, "orders", []string{"id"},
+		newRowIterator(4), dbutil.StreamOptions{BatchRows: 2})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, count)
+	assert.Len(t, fake.BulkInserts(), 2, "no empty batch should be inserted after the last full batch")
+}
+
+func TestBulkInsertStreamAbort(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	pool, err := dbutil.NewPool(dbutiltest.DriverName, fake.DSN(), dbutil.PoolOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count, err := dbutil.BulkInsertStream(ctx, pool, dbutiltest.Dialect, "orders", []string{"id"},
+		newRowIterator(10), dbutil.StreamOptions{
+			BatchRows:  3,
+			OnProgress: func(progress dbutil.StreamProgress) { cancel() },
+		})
+	assert.EqualError(t, err, "aborted bulk-insert after 3 rows: context canceled")
+	assert.EqualValues(t, 3, count)
+	assert.Len(t, fake.InsertedRows("orders"), 3, "batches committed before cancellation should remain")
+
+	fake.Reset()
+	fake.FailOn(`INSERT BULK orders`, errors.New("table locked"))
+	rows := make(chan []interface{}, 2)
+	rows <- []interface{}{1}
+	rows <- []interface{}{2}
+	close(rows)
+	count, err = dbutil.BulkInsertFromChannel(context.Background(), pool, dbutiltest.Dialect, "orders", []string{"id"},
+		rows, dbutil.StreamOptions{})
+	assert.EqualError(t, err, "failed to insert batch #0 after 0 rows: failed during DB session: failed to execute bulk insert: table locked")
+	assert.Zero(t, count)
+	assert.Equal(t, 1, fake.Rollbacks())
+}
+
+func TestBulkInsertRows(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	pool, err := dbutil.NewPool(dbutiltest.DriverName, fake.DSN(), dbutil.PoolOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+
+	getRow := func(index int) []interface{} { return []interface{}{index} }
+	count, err := dbutil.BulkInsertRows(context.Background(), pool, dbutiltest.Dialect, "orders", []string{"id"}, 5, getRow, dbutil.StreamOptions{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, count)
+	assert.Len(t, fake.BulkInserts(), 1, "all rows should be inserted in one transaction")
+	assert.Equal(t, 1, fake.Commits())
+
+	fake.Reset()
+	var quarantined []interface{}
+	count, err = dbutil.BulkInsertRows(context.Background(), pool, rejectingDialect{dbutiltest.Dialect, 3}, "orders", []string{"id"}, 5, getRow,
+		dbutil.StreamOptions{Quarantine: func(row []interface{}, err error) error {
+			quarantined = append(quarantined, row[0])
+			assert.EqualError(t, err, "failed during DB session: invalid id 3")
+			return nil
+		}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, count)
+	assert.Equal(t, []interface{}{3}, quarantined)
+	assert.Equal(t, [][]interface{}{{0}, {1}, {2}, {4}}, fake.InsertedRows("orders"))
+
+	fake.Reset()
+	_, err = dbutil.BulkInsertRows(context.Background(), pool, rejectingDialect{dbutiltest.Dialect, 3}, "orders", []string{"id"}, 5, getRow,
+		dbutil.StreamOptions{})
+	assert.EqualError(t, err, "failed to insert batch #0 after 0 rows: failed during DB session: invalid id 3")
+	assert.Empty(t, fake.InsertedRows("orders"))
+}
+
+// rejectingDialect fails bulk inserts containing the invalid ID
+type rejectingDialect struct {
+	dbutil.Dialect
+	invalidID int
+}
+
+func (d rejectingDialect) BulkInsert(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
+	for i := 0; i < rowCount; i++ {
+		if getRow(i)[0] == d.invalidID {
+			return 0, fmt.Errorf("invalid id %d", d.invalidID)
+		}
+	}
+	return d.Dialect.BulkInsert(ctx, tx, tableName, columnNames, rowCount, getRow)
+}
+
+// newRowIterator creates an iterator of rows with a single column from 0 to count-1
+func newRowIterator(count int) func() ([]interface{}, bool) {
+	next := 0
+	return func() ([]interface{}, bool) {
+		if next >= count {
+			return nil, false
+		}
+		next++
+		return []interface{}{next - 1}, true
+	}
+}
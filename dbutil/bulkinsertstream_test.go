@@ -0,0 +1,42 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertRowsIndividually(t *testing.T) {
+	batch := [][]interface{}{{1, "a"}, {2, "too long"}, {3, "c"}, {4, "too long"}}
+	insertRows := func(rows [][]interface{}) (int64, error) {
+		for _, row := range rows {
+			if len(row[1].(string)) > 1 {
+				return 0, errors.New("value too long")
+			}
+		}
+		return int64(len(rows)), nil
+	}
+
+	output := &bytes.Buffer{}
+	opts := StreamOptions{Quarantine: NewQuarantineWriter(output)}
+	inserted, quarantined, err := insertRowsIndividually(context.Background(), batch, insertRows, opts, 0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, inserted)
+	assert.EqualValues(t, 2, quarantined)
+	assert.Equal(t, `{"row":[2,"too long"],"error":"value too long"}
+{"row":[4,"too long"],"error":"value too long"}
+`, output.String())
+
+	opts.MaxQuarantinedRows = 2
+	inserted, quarantined, err = insertRowsIndividually(context.Background(), batch, insertRows, opts, 1)
+	assert.ErrorContains(t, err, "too many quarantined rows (max 2), last error: value too long")
+	assert.EqualValues(t, 2, inserted)
+	assert.EqualValues(t, 1, quarantined)
+
+	opts = StreamOptions{Quarantine: func(row []interface{}, err error) error { return errors.New("disk full") }}
+	_, _, err = insertRowsIndividually(context.Background(), batch, insertRows, opts, 0)
+	assert.EqualError(t, err, "failed to quarantine row: disk full")
+}
@@ -0,0 +1,89 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// ConnectionConfig defines how to connect to a database, as an alternative to the driver name and URL
+//
+// It's needed for authentication methods not supported by URLs, e.g. Azure AD access tokens from mssqlutil.
+type ConnectionConfig struct {
+	Driver string // name of database/sql driver, also used for tracing
+	URL    string // URL or DSN passed to the driver, also used to select the default RetryPolicy
+
+	// Connector creates connections instead of opening the URL by the driver if set
+	Connector driver.Connector
+}
+
+// open opens a DB handle by the connector or the driver and URL
+func (cfg ConnectionConfig) open() (*sql.DB, error) {
+	if cfg.Connector != nil {
+		return sql.OpenDB(cfg.Connector), nil
+	}
+	db, err := sql.Open(cfg.Driver, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB driver '%s': %w", cfg.Driver, err)
+	}
+	return db, nil
+}
+
+// RunSessionWithConfig runs a simple DB session like RunSessionCtxE, connected by the given config
+func RunSessionWithConfig(ctx context.Context, cfg ConnectionConfig, do func(tx *sql.Tx) error) (err error) {
+	ctx, span := startSessionSpan(ctx, cfg.Driver)
+	defer func() { EndSpan(span, err) }()
+
+	db, dbErr := cfg.open()
+	if dbErr != nil {
+		return dbErr
+	}
+	defer db.Close()
+
+	var conn *sql.Conn
+	connErr := getRetryPolicy(cfg.URL).Do(ctx, "connect", func() error {
+		var err error
+		conn, err = db.Conn(ctx)
+		return err
+	})
+	if connErr != nil {
+		return fmt.Errorf("failed to connect to DB: %w", connErr)
+	}
+	defer conn.Close()
+
+	tx, txErr := conn.BeginTx(ctx, nil)
+	if txErr != nil {
+		return fmt.Errorf("failed to begin transaction: %w", txErr)
+	}
+	return runTransaction(ctx, tx, cfg.Driver, do)
+}
+
+// NewPoolWithConfig creates a connection pool like NewPool, connected by the given config
+func NewPoolWithConfig(cfg ConnectionConfig, opts PoolOptions) (*Pool, error) {
+	db, dbErr := cfg.open()
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+	retryPolicy := getRetryPolicy(cfg.URL)
+	if opts.RetryPolicy != nil {
+		retryPolicy = *opts.RetryPolicy
+	}
+	return &Pool{
+		db:          db,
+		driver:      cfg.Driver,
+		retryPolicy: retryPolicy,
+	}, nil
+}
@@ -0,0 +1,408 @@
+// Package dbutiltest provides a recording fake database and a disposable SQL Server for tests of code using dbutil
+package dbutiltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/relex/gotils/dbutil"
+)
+
+// DriverName is the name of database/sql driver and the URL scheme of Fake
+const DriverName = "dbutiltest"
+
+// bulkInsertPrefix is the prefix of statements recorded for bulk inserts, e.g. "INSERT BULK orders"
+const bulkInsertPrefix = "INSERT BULK "
+
+// Dialect is the dbutil.Dialect for Fake, registered for the URL scheme "dbutiltest"
+var Dialect dbutil.Dialect = dialect{}
+
+var (
+	fakesLock  sync.RWMutex
+	fakesByDSN = make(map[string]*Fake)
+	fakeSeq    int64
+)
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+	dbutil.RegisterDialect(DriverName, Dialect)
+}
+
+// Statement is a statement executed on Fake
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// BulkInsert is a bulk insert performed on Fake by Dialect
+type BulkInsert struct {
+	Table   string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Fake is an in-memory database which records statements and bulk inserts, and returns scripted results
+//
+// It can be used in place of any database by URL or driver name, e.g.:
+//
+//	fake := dbutiltest.NewFake()
+//	defer fake.Close()
+//	fake.OnQuery(`FROM orders`, []string{"id", "name"}, []interface{}{1, "first"})
+//	err := dbutil.RunSessionByURL(ctx, fake.URL(), doSomething)
+//
+// Statements without matching results return no rows and affect no rows.
+type Fake struct {
+	dsn         string
+	lock        sync.Mutex
+	results     []fakeResult
+	statements  []Statement
+	bulkInserts []BulkInsert
+	commits     int
+	rollbacks   int
+}
+
+// fakeResult is a scripted result for statements matching the pattern
+type fakeResult struct {
+	pattern      *regexp.Regexp
+	columns      []string
+	rows         [][]interface{}
+	rowsAffected int64
+	err          error
+	block        bool
+}
+
+// NewFake creates a Fake, available by its URL or DSN until closed
+func NewFake() *Fake {
+	fake := &Fake{
+		dsn: fmt.Sprintf("fake%d", atomic.AddInt64(&fakeSeq, 1)),
+	}
+
+	fakesLock.Lock()
+	defer fakesLock.Unlock()
+	fakesByDSN[fake.dsn] = fake
+	return fake
+}
+
+// URL returns the DB URL of this fake, e.g. for dbutil.RunSessionByURL
+func (f *Fake) URL() string {
+	return DriverName + "://" + f.dsn
+}
+
+// DSN returns the data source name of this fake for the driver DriverName, e.g. for dbutil.RunSessionE
+func (f *Fake) DSN() string {
+	return f.dsn
+}
+
+// Close makes this fake unavailable for new connections
+func (f *Fake) Close() {
+	fakesLock.Lock()
+	defer fakesLock.Unlock()
+	delete(fakesByDSN, f.dsn)
+}
+
+// OnQuery sets rows to be returned for statements matching the regular expression
+//
+// Results are matched in the order they're set.
+func (f *Fake) OnQuery(pattern string, columns []string, rows ...[]interface{}) {
+	f.addResult(fakeResult{pattern: regexp.MustCompile(pattern), columns: columns, rows: rows, rowsAffected: int64(len(rows))})
+}
+
+// OnExec sets the number of affected rows to be returned for statements matching the regular expression
+func (f *Fake) OnExec(pattern string, rowsAffected int64) {
+	f.addResult(fakeResult{pattern: regexp.MustCompile(pattern), rowsAffected: rowsAffected})
+}
+
+// FailOn sets the error to be returned for statements matching the regular expression
+//
+// Bulk inserts can be matched as "INSERT BULK <table>".
+func (f *Fake) FailOn(pattern string, err error) {
+	f.addResult(fakeResult{pattern: regexp.MustCompile(pattern), err: err})
+}
+
+// BlockOn makes statements matching the regular expression wait until their context is done, e.g. to test cancellation
+//
+// Blocked statements fail with the error from the context and are not recorded.
+func (f *Fake) BlockOn(pattern string) {
+	f.addResult(fakeResult{pattern: regexp.MustCompile(pattern), block: true})
+}
+
+// Statements returns all statements executed so far, excluding bulk inserts
+func (f *Fake) Statements() []Statement {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]Statement{}, f.statements...)
+}
+
+// BulkInserts returns all bulk inserts performed so far
+func (f *Fake) BulkInserts() []BulkInsert {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]BulkInsert{}, f.bulkInserts...)
+}
+
+// InsertedRows returns all rows bulk-inserted into the table so far
+func (f *Fake) InsertedRows(table string) [][]interface{} {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var rows [][]interface{}
+	for _, insert := range f.bulkInserts {
+		if insert.Table == table {
+			rows = append(rows, insert.Rows...)
+		}
+	}
+	return rows
+}
+
+// Commits returns the number of committed transactions
+func (f *Fake) Commits() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.commits
+}
+
+// Rollbacks returns the number of rolled back transactions
+func (f *Fake) Rollbacks() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rollbacks
+}
+
+// Reset clears recorded statements, bulk inserts and transactions, but keeps scripted results
+func (f *Fake) Reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.statements = nil
+	f.bulkInserts = nil
+	f.commits = 0
+	f.rollbacks = 0
+}
+
+func (f *Fake) addResult(result fakeResult) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.results = append(f.results, result)
+}
+
+// execute records the statement and finds its result
+func (f *Fake) execute(ctx context.Context, query string, args []driver.NamedValue) (fakeResult, error) {
+	result := f.match(query)
+	if result.block {
+		<-ctx.Done()
+		return result, ctx.Err()
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if result.err != nil {
+		return result, result.err
+	}
+
+	if len(args) == 1 {
+		if insert, ok := args[0].Value.(BulkInsert); ok {
+			f.bulkInserts = append(f.bulkInserts, insert)
+			result.rowsAffected = int64(len(insert.Rows))
+			return result, nil
+		}
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.statements = append(f.statements, Statement{Query: query, Args: values})
+	return result, nil
+}
+
+// match finds the first result for the statement
+func (f *Fake) match(query string) fakeResult {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, r := range f.results {
+		if r.pattern.MatchString(query) {
+			return r
+		}
+	}
+	return fakeResult{}
+}
+
+func (f *Fake) endTransaction(committed bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if committed {
+		f.commits++
+	} else {
+		f.rollbacks++
+	}
+}
+
+type dialect struct{}
+
+func (dialect) DriverName() string {
+	return DriverName
+}
+
+func (dialect) DataSourceName(dbURL string) (string, error) {
+	dsn, found := strings.CutPrefix(dbURL, DriverName+"://")
+	if !found {
+		return "", fmt.Errorf("not a %s URL", DriverName)
+	}
+	return dsn, nil
+}
+
+// BulkInsert records the rows as a single statement "INSERT BULK <table>" on Fake
+func (dialect) BulkInsert(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
+	insert := BulkInsert{Table: tableName, Columns: columnNames, Rows: make([][]interface{}, 0, rowCount)}
+	for i := 0; i < rowCount; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, fmt.Errorf("aborted bulk insert at row #%d: %w", i, ctxErr)
+		}
+		row := getRow(i)
+		if len(row) != len(columnNames) {
+			return 0, fmt.Errorf("wrong numbers of values in row #%d: %v", i, row)
+		}
+		insert.Rows = append(insert.Rows, append([]interface{}{}, row...))
+	}
+
+	result, err := tx.ExecContext(ctx, bulkInsertPrefix+tableName, insert)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute bulk insert: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakesLock.RLock()
+	defer fakesLock.RUnlock()
+	fake, exists := fakesByDSN[dsn]
+	if !exists {
+		return nil, fmt.Errorf("unknown or closed fake '%s'", dsn)
+	}
+	return &fakeConn{fake}, nil
+}
+
+type fakeConn struct {
+	fake *Fake
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c, query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{c.fake}, nil
+}
+
+// CheckNamedValue accepts all values as they are, to be recorded without conversion
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.fake.execute(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.rowsAffected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.fake.execute(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, toNamedValues(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, toNamedValues(args))
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func toNamedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type fakeTx struct {
+	fake *Fake
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.fake.endTransaction(true)
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.fake.endTransaction(false)
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]interface{}
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.next]
+	r.next++
+	if len(row) != len(dest) {
+		return fmt.Errorf("wrong numbers of values in row #%d: %v, expected columns %v", r.next-1, row, r.columns)
+	}
+	for i, value := range row {
+		dest[i] = value
+	}
+	return nil
+}
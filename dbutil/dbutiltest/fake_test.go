@@ -0,0 +1,110 @@
+package dbutiltest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOrder struct {
+	ID   int64
+	Name string
+}
+
+func TestFake(t *testing.T) {
+	fake := NewFake()
+	defer fake.Close()
+	fake.OnQuery(`^SELECT .* FROM orders`, []string{"id", "name"}, []interface{}{int64(1), "first"}, []interface{}{int64(2), "second"})
+	fake.OnExec(`^DELETE`, 3)
+	fake.FailOn(`INSERT BULK audit`, errors.New("table locked"))
+
+	var orders []testOrder
+	var deleted int64
+	err := dbutil.RunSessionByURL(context.Background(), fake.URL(), func(tx *sql.Tx) error {
+		var err error
+		if orders, err = dbutil.Select[testOrder](tx, "SELECT id, name FROM orders WHERE id > @p1", 0); err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM orders WHERE name = @p1", "old")
+		if err != nil {
+			return err
+		}
+		deleted, _ = result.RowsAffected()
+		_, err = Dialect.BulkInsert(context.Background(), tx, "orders", []string{"id", "name"}, 2, func(index int) []interface{} {
+			return []interface{}{index + 10, "new"}
+		})
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []testOrder{{1, "first"}, {2, "second"}}, orders)
+	assert.EqualValues(t, 3, deleted)
+	assert.Equal(t, []Statement{
+		{Query: "SELECT id, name FROM orders WHERE id > @p1", Args: []interface{}{0}},
+		{Query: "DELETE FROM orders WHERE name = @p1", Args: []interface{}{"old"}},
+	}, fake.Statements())
+	assert.Equal(t, [][]interface{}{{10, "new"}, {11, "new"}}, fake.InsertedRows("orders"))
+	assert.Equal(t, 1, fake.Commits())
+
+	err = dbutil.RunSessionE(DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		_, err := Dialect.BulkInsert(context.Background(), tx, "audit", []string{"id"}, 1, func(index int) []interface{} {
+			return []interface{}{1}
+		})
+		return err
+	})
+	assert.EqualError(t, err, "failed during DB session: failed to execute bulk insert: table locked")
+	assert.Equal(t, 1, fake.Rollbacks())
+	assert.Len(t, fake.BulkInserts(), 1)
+
+	fake.Reset()
+	assert.Empty(t, fake.Statements())
+	assert.Zero(t, fake.Commits())
+
+	fake.Close()
+	err = dbutil.RunSessionByURL(context.Background(), fake.URL(), func(tx *sql.Tx) error { return nil })
+	assert.ErrorContains(t, err, "unknown or closed fake 'fake")
+}
+
+func TestLoadFixtures(t *testing.T) {
+	dir := t.TempDir()
+	sqlPath := filepath.Join(dir, "schema.sql")
+	assert.NoError(t, os.WriteFile(sqlPath, []byte(`CREATE TABLE customers (id INT, name NVARCHAR(100))
+go
+CREATE TABLE orders (id INT, customer_id INT, tags NVARCHAR(MAX))
+GO
+
+`), 0644))
+	jsonPath := filepath.Join(dir, "data.json")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{
+  "orders": [{"id": 10, "customer_id": 1, "tags": ["new"]}, {"id": 11, "amount": 2.5}],
+  "customers": [{"id": 1, "name": "Alice"}]
+}`), 0644))
+
+	fake := NewFake()
+	defer fake.Close()
+	err := dbutil.RunSessionByURL(context.Background(), fake.URL(), func(tx *sql.Tx) error {
+		return LoadFixtures(context.Background(), tx, Dialect, sqlPath, jsonPath)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{Query: "CREATE TABLE customers (id INT, name NVARCHAR(100))", Args: []interface{}{}},
+		{Query: "CREATE TABLE orders (id INT, customer_id INT, tags NVARCHAR(MAX))", Args: []interface{}{}},
+	}, fake.Statements())
+	assert.Equal(t, []BulkInsert{
+		{Table: "orders", Columns: []string{"amount", "customer_id", "id", "tags"}, Rows: [][]interface{}{
+			{nil, int64(1), int64(10), `["new"]`},
+			{2.5, nil, int64(11), nil},
+		}},
+		{Table: "customers", Columns: []string{"id", "name"}, Rows: [][]interface{}{{int64(1), "Alice"}}},
+	}, fake.BulkInserts())
+
+	err = dbutil.RunSessionByURL(context.Background(), fake.URL(), func(tx *sql.Tx) error {
+		return LoadFixtures(context.Background(), tx, Dialect, filepath.Join(dir, "data.csv"))
+	})
+	assert.ErrorContains(t, err, "data.csv': unknown file type")
+}
@@ -0,0 +1,184 @@
+package dbutiltest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/relex/gotils/dbutil"
+)
+
+// LoadFixtures loads fixture files in order by their extensions, ".sql" by LoadSQLFixture or ".json" by
+// LoadJSONFixture
+func LoadFixtures(ctx context.Context, tx *sql.Tx, dialect dbutil.Dialect, paths ...string) error {
+	for _, path := range paths {
+		var err error
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".sql":
+			err = LoadSQLFixture(ctx, tx, path)
+		case ".json":
+			err = LoadJSONFixture(ctx, tx, dialect, path)
+		default:
+			err = fmt.Errorf("failed to load fixture '%s': unknown file type", path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadSQLFixture executes the SQL script in the transaction
+//
+// Scripts are split into batches by lines of "GO" as in sqlcmd. Each batch is executed as a single statement and may
+// contain multiple SQL statements if supported by the driver.
+func LoadSQLFixture(ctx context.Context, tx *sql.Tx, path string) error {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load fixture: %w", err)
+	}
+	for i, batch := range splitSQLBatches(string(script)) {
+		if _, err := tx.ExecContext(ctx, batch); err != nil {
+			return fmt.Errorf("failed to load fixture '%s': batch #%d: %w", path, i, err)
+		}
+	}
+	return nil
+}
+
+// LoadJSONFixture inserts rows from the JSON file by the bulk insert of dialect, in the order of tables in the file:
+//
+//	{
+//	  "customers": [{"id": 1, "name": "Alice"}],
+//	  "orders": [{"id": 10, "customer_id": 1, "tags": ["new"]}]
+//	}
+//
+// Columns are the sorted keys of all rows in a table, with missing values as NULL. Integers are converted to int64,
+// other numbers to float64 and nested objects or arrays to JSON strings.
+func LoadJSONFixture(ctx context.Context, tx *sql.Tx, dialect dbutil.Dialect, path string) error {
+	tables, err := readJSONFixture(path)
+	if err != nil {
+		return fmt.Errorf("failed to load fixture '%s': %w", path, err)
+	}
+	for _, table := range tables {
+		columns := table.columnNames()
+		getRow := func(index int) []interface{} {
+			row := make([]interface{}, len(columns))
+			for i, column := range columns {
+				row[i] = table.rows[index][column]
+			}
+			return row
+		}
+		if _, err := dialect.BulkInsert(ctx, tx, table.name, columns, len(table.rows), getRow); err != nil {
+			return fmt.Errorf("failed to load fixture '%s': table '%s': %w", path, table.name, err)
+		}
+	}
+	return nil
+}
+
+// splitSQLBatches splits the script by lines of "GO", skipping empty batches
+func splitSQLBatches(script string) []string {
+	var batches []string
+	var current strings.Builder
+	flush := func() {
+		if batch := strings.TrimSpace(current.String()); batch != "" {
+			batches = append(batches, batch)
+		}
+		current.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.EqualFold(strings.TrimSpace(line), "GO") {
+			flush()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	flush()
+	return batches
+}
+
+// fixtureTable is a table of rows read from JSON fixture
+type fixtureTable struct {
+	name string
+	rows []map[string]interface{}
+}
+
+func (t fixtureTable) columnNames() []string {
+	nameSet := make(map[string]bool)
+	for _, row := range t.rows {
+		for name := range row {
+			nameSet[name] = true
+		}
+	}
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readJSONFixture reads tables from the JSON fixture, keeping their order in file
+func readJSONFixture(path string) ([]fixtureTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected object of tables at the top level")
+	}
+	var tables []fixtureTable
+	for decoder.More() {
+		nameToken, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		table := fixtureTable{name: nameToken.(string)}
+		if err := decoder.Decode(&table.rows); err != nil {
+			return nil, fmt.Errorf("table '%s': %w", table.name, err)
+		}
+		for _, row := range table.rows {
+			for column, value := range row {
+				converted, err := convertJSONValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("table '%s': column '%s': %w", table.name, column, err)
+				}
+				row[column] = converted
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// convertJSONValue converts the value decoded from JSON fixture into a type accepted by drivers
+func convertJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,141 @@
+package dbutiltest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/dbutil/mssqlutil"
+	"github.com/relex/gotils/logger"
+)
+
+// SQLServerOptions defines how to start a disposable SQL Server
+type SQLServerOptions struct {
+	Image        string        // docker image, default "mcr.microsoft.com/mssql/server:2022-latest"
+	Password     string        // password of "sa", default "Dbutiltest-Passw0rd"
+	StartTimeout time.Duration // max time to wait for the server to accept connections, default 2 minutes
+}
+
+// SQLServer is a disposable SQL Server running in docker
+type SQLServer struct {
+	ContainerID string
+	URL         string // DB URL of "master" database as "sa", e.g. for dbutil.RunSessionByURL
+}
+
+// StartSQLServer starts a SQL Server container by the docker CLI and waits until it accepts connections
+//
+// The container is bound to a random local port and removed on Close.
+func StartSQLServer(ctx context.Context, opts SQLServerOptions) (*SQLServer, error) {
+	if opts.Image == "" {
+		opts.Image = "mcr.microsoft.com/mssql/server:2022-latest"
+	}
+	if opts.Password == "" {
+		opts.Password = "Dbutiltest-Passw0rd"
+	}
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = 2 * time.Minute
+	}
+
+	containerID, err := runDocker(ctx, "run", "--detach", "--rm",
+		"--env", "ACCEPT_EULA=Y",
+		"--env", "MSSQL_SA_PASSWORD="+opts.Password,
+		"--publish", "127.0.0.1::1433",
+		opts.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SQL Server container: %w", err)
+	}
+	server := &SQLServer{ContainerID: containerID}
+
+	hostPort, err := runDocker(ctx, "port", containerID, "1433/tcp")
+	if err != nil {
+		server.Close()
+		return nil, fmt.Errorf("failed to find port of SQL Server container: %w", err)
+	}
+	hostPort, _, _ = strings.Cut(hostPort, "\n") // the first address if bound to both IPv4 and IPv6
+	server.URL = (&url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword("sa", opts.Password),
+		Host:     hostPort,
+		RawQuery: "database=master",
+	}).String()
+
+	if err := server.waitReady(ctx, opts.StartTimeout); err != nil {
+		server.Close()
+		return nil, err
+	}
+	logger.WithFields(logger.Fields{"container": containerID, "address": hostPort}).Info("started SQL Server")
+	return server, nil
+}
+
+// RequireSQLServer starts a disposable SQL Server for the test and removes it after the test
+//
+// The test is skipped in short mode or if docker is not available, and fails if the server cannot be started.
+func RequireSQLServer(t testing.TB, opts SQLServerOptions) *SQLServer {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipped SQL Server test in short mode")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("skipped SQL Server test without docker")
+	}
+	server, err := StartSQLServer(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return server
+}
+
+// Close stops and removes the container
+func (s *SQLServer) Close() error {
+	if _, err := runDocker(context.Background(), "rm", "--force", s.ContainerID); err != nil {
+		return fmt.Errorf("failed to remove SQL Server container: %w", err)
+	}
+	return nil
+}
+
+// waitReady pings the server until it succeeds or the timeout is reached
+func (s *SQLServer) waitReady(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, err := sql.Open(mssqlutil.Dialect.DriverName(), s.URL)
+	if err != nil {
+		return fmt.Errorf("failed to open SQL Server: %w", err)
+	}
+	defer db.Close()
+
+	for {
+		pingErr := db.PingContext(ctx)
+		if pingErr == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to connect to SQL Server in %s: %w", timeout, pingErr)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// runDocker runs the docker CLI and returns the trimmed stdout
+func runDocker(ctx context.Context, args ...string) (string, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
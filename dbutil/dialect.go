@@ -0,0 +1,108 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Dialect provides database-specific operations, selected by the scheme of DB URL
+//
+// Dialects are registered by sub-packages like database/sql drivers, e.g.:
+//
+//	import _ "github.com/relex/gotils/dbutil/pgutil"
+type Dialect interface {
+	// DriverName returns the name of database/sql driver
+	DriverName() string
+
+	// DataSourceName converts the DB URL to the data source name accepted by the driver
+	DataSourceName(dbURL string) (string, error)
+
+	// BulkInsert performs bulk-insert from input rows represented by (rowCount, getRow) in the most efficient way
+	//
+	// The getRow parameter must transform source data fields into formats compatible to the destination columns
+	BulkInsert(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error)
+}
+
+var (
+	dialectsLock     sync.RWMutex
+	dialectsByScheme = make(map[string]Dialect)
+)
+
+// RegisterDialect makes a dialect available for the URL scheme, e.g. "postgres"
+//
+// It panics if called twice for the same scheme.
+func RegisterDialect(scheme string, dialect Dialect) {
+	dialectsLock.Lock()
+	defer dialectsLock.Unlock()
+
+	if _, exists := dialectsByScheme[scheme]; exists {
+		panic("dbutil: RegisterDialect called twice for scheme " + scheme)
+	}
+	dialectsByScheme[scheme] = dialect
+}
+
+// DialectByURL finds the registered dialect by the scheme of DB URL, e.g. "postgres://user@host/db"
+func DialectByURL(dbURL string) (Dialect, error) {
+	scheme, _, found := strings.Cut(dbURL, "://")
+	if !found {
+		return nil, fmt.Errorf("failed to find dialect: missing scheme in DB URL '%s'", redactURL(dbURL))
+	}
+
+	dialectsLock.RLock()
+	defer dialectsLock.RUnlock()
+
+	dialect, exists := dialectsByScheme[strings.ToLower(scheme)]
+	if !exists {
+		schemes := make([]string, 0, len(dialectsByScheme))
+		for s := range dialectsByScheme {
+			schemes = append(schemes, s)
+		}
+		sort.Strings(schemes)
+		return nil, fmt.Errorf("failed to find dialect: unknown scheme '%s' (registered: %s)", scheme, strings.Join(schemes, ", "))
+	}
+	return dialect, nil
+}
+
+// RunSessionByURL runs a DB session like RunSessionCtxE, with the driver selected by the scheme of DB URL
+func RunSessionByURL(ctx context.Context, dbURL string, do func(tx *sql.Tx) error) error {
+	dialect, dsn, err := resolveURL(dbURL)
+	if err != nil {
+		return err
+	}
+	return RunSessionCtxE(ctx, dialect.DriverName(), dsn, do)
+}
+
+// NewPoolByURL creates a connection pool like NewPool, with the driver selected by the scheme of DB URL
+func NewPoolByURL(dbURL string, opts PoolOptions) (*Pool, error) {
+	dialect, dsn, err := resolveURL(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewPool(dialect.DriverName(), dsn, opts)
+}
+
+func resolveURL(dbURL string) (Dialect, string, error) {
+	dialect, err := DialectByURL(dbURL)
+	if err != nil {
+		return nil, "", err
+	}
+	dsn, dsnErr := dialect.DataSourceName(dbURL)
+	if dsnErr != nil {
+		return nil, "", fmt.Errorf("failed to convert DB URL '%s': %w", redactURL(dbURL), dsnErr)
+	}
+	return dialect, dsn, nil
+}
+
+// redactURL removes the password from DB URL for logging
+func redactURL(dbURL string) string {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,99 @@
+package dbutil
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	slowStatementThreshold atomic.Int64 // time.Duration
+	statementMetrics       atomic.Pointer[statementMetricVecs]
+)
+
+type statementMetricVecs struct {
+	statements *promext.RWCounterVec
+	errors     *promext.RWCounterVec
+	duration   *promext.RWCounterVec
+}
+
+// operationKey is the key of operation label in context, see WithOperation
+type operationKey struct{}
+
+// statementSpan wraps the span of a statement to log and record metrics when ended by EndSpan
+type statementSpan struct {
+	trace.Span
+	operation string
+	statement string
+	startTime time.Time
+}
+
+// EnableSlowStatementLogging enables warning logs of statements and bulk operations taking longer than the threshold,
+// in dbutil and sub-packages
+//
+// Logged statements have literals replaced by '?' and comments removed, like in tracing. Zero threshold disables it.
+func EnableSlowStatementLogging(threshold time.Duration) {
+	slowStatementThreshold.Store(int64(threshold))
+}
+
+// EnableMetrics enables metrics of statements and bulk operations in dbutil and sub-packages, labeled by operation:
+//
+//   - dbutil_statements_total
+//   - dbutil_statement_errors_total
+//   - dbutil_statement_duration_milliseconds_total
+//
+// The operation is set by WithOperation, or else the name of function, e.g. "dbutil.Select".
+func EnableMetrics(creator promreg.MetricCreator) {
+	labelNames := []string{"operation"}
+	metricCreator := creator.AddOrGetPrefix("dbutil_", nil, nil)
+	statementMetrics.Store(&statementMetricVecs{
+		statements: metricCreator.AddOrGetCounterVec("statements_total", "Numbers of executed statements", labelNames, nil),
+		errors:     metricCreator.AddOrGetCounterVec("statement_errors_total", "Numbers of failed statements", labelNames, nil),
+		duration:   metricCreator.AddOrGetCounterVec("statement_duration_milliseconds_total", "Total duration of statements in milliseconds", labelNames, nil),
+	})
+}
+
+// WithOperation sets the operation name for logging and metrics of statements run with the returned context,
+// e.g. "load_invoices"
+//
+// Operation names should be from a small set to avoid high cardinality of metrics.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// instrumentStatement wraps the span of a statement if logging or metrics are enabled
+func instrumentStatement(ctx context.Context, span trace.Span, name string, statement string) trace.Span {
+	if slowStatementThreshold.Load() <= 0 && statementMetrics.Load() == nil {
+		return span
+	}
+	operation, found := ctx.Value(operationKey{}).(string)
+	if !found {
+		operation = name
+	}
+	return &statementSpan{
+		Span:      span,
+		operation: operation,
+		statement: statement,
+		startTime: time.Now(),
+	}
+}
+
+// finish logs the statement if slow and records metrics
+func (s *statementSpan) finish(err error) {
+	elapsed := time.Since(s.startTime)
+	if metrics := statementMetrics.Load(); metrics != nil {
+		metrics.statements.WithLabelValues(s.operation).Inc()
+		if err != nil {
+			metrics.errors.WithLabelValues(s.operation).Inc()
+		}
+		metrics.duration.WithLabelValues(s.operation).Add(uint64(elapsed.Milliseconds()))
+	}
+	if threshold := time.Duration(slowStatementThreshold.Load()); threshold > 0 && elapsed >= threshold {
+		logger.WithField("operation", s.operation).Warnf("slow statement took %s: %s", elapsed.Round(time.Millisecond), redactStatement(s.statement))
+	}
+}
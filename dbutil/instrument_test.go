@@ -0,0 +1,40 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext/promexttest"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementInstrumentation(t *testing.T) {
+	_, span := StartStatementSpan(context.Background(), nil, "dbutil.Select", "SELECT 1")
+	_, isStatementSpan := span.(*statementSpan)
+	assert.False(t, isStatementSpan, "statements should not be instrumented by default")
+	EndSpan(span, nil)
+
+	factory := promreg.NewMetricFactory("test_", nil, nil)
+	EnableMetrics(factory)
+	EnableSlowStatementLogging(time.Millisecond)
+	defer func() {
+		statementMetrics.Store(nil)
+		slowStatementThreshold.Store(0)
+	}()
+
+	_, span = StartStatementSpan(context.Background(), nil, "dbutil.Select", "SELECT * FROM orders WHERE id = 42")
+	time.Sleep(2 * time.Millisecond)
+	EndSpan(span, nil)
+	_, span = StartStatementSpan(WithOperation(context.Background(), "load_orders"), nil, "dbutil.Get", "SELECT 1")
+	EndSpan(span, errors.New("failed"))
+
+	promexttest.AssertCounterValue(t, statementMetrics.Load().statements, prometheus.Labels{"operation": "dbutil.Select"}, 1)
+	promexttest.AssertCounterValue(t, statementMetrics.Load().errors, prometheus.Labels{"operation": "dbutil.Select"}, 0)
+	promexttest.AssertCounterValue(t, statementMetrics.Load().statements, prometheus.Labels{"operation": "load_orders"}, 1)
+	promexttest.AssertCounterValue(t, statementMetrics.Load().errors, prometheus.Labels{"operation": "load_orders"}, 1)
+	assert.Contains(t, promexttest.CollectAsMap(factory), `test_dbutil_statement_duration_milliseconds_total{operation="dbutil.Select"}`)
+}
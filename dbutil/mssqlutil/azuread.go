@@ -0,0 +1,205 @@
+package mssqlutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/logger"
+)
+
+const (
+	azureSQLResource    = "https://database.windows.net/"
+	azureADAuthority    = "https://login.microsoftonline.com/"
+	azureIMDSEndpoint   = "http://169.254.169.254/metadata/identity/oauth2/token"
+	tokenRefreshMargin  = 5 * time.Minute
+	tokenRequestTimeout = 30 * time.Second
+)
+
+// AzureADAuth defines how to acquire Azure AD access tokens for Azure SQL connections
+//
+// Client secret authentication is used if ClientSecret is set, otherwise managed identity.
+type AzureADAuth struct {
+	TenantID     string // directory (tenant) ID, required for client secret
+	ClientID     string // application (client) ID, or client ID of user-assigned managed identity (optional)
+	ClientSecret string // client secret of application
+}
+
+// azureADTokenSource acquires and caches access tokens, refreshing them shortly before expiry
+type azureADTokenSource struct {
+	auth       AzureADAuth
+	authority  string
+	imds       string
+	httpClient *http.Client
+	logger     logger.Logger
+
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type azureADTokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"` // string from managed identity, number from client secret
+	ExpiresOn   json.Number `json:"expires_on"` // unix time, only from managed identity
+}
+
+// NewAzureADConnectionConfig creates a dbutil.ConnectionConfig for "sqlserver://" URL with Azure AD authentication
+//
+// The URL must not contain user and password. Tokens are acquired when new connections are made and refreshed before
+// expiry, e.g.:
+//
+//	cfg, err := mssqlutil.NewAzureADConnectionConfig("sqlserver://myserver.database.windows.net?database=mydb", mssqlutil.AzureADAuth{})
+//	err = dbutil.RunSessionWithConfig(ctx, cfg, func(tx *sql.Tx) error { ... })
+func NewAzureADConnectionConfig(dbURL string, auth AzureADAuth) (dbutil.ConnectionConfig, error) {
+	if auth.ClientSecret != "" && (auth.TenantID == "" || auth.ClientID == "") {
+		return dbutil.ConnectionConfig{}, fmt.Errorf("missing TenantID or ClientID for client secret authentication")
+	}
+	source := newAzureADTokenSource(auth)
+	connector, err := mssql.NewAccessTokenConnector(dbURL, source.getToken)
+	if err != nil {
+		return dbutil.ConnectionConfig{}, fmt.Errorf("failed to create connector: %w", err)
+	}
+	return dbutil.ConnectionConfig{
+		Driver:    "sqlserver",
+		URL:       dbURL,
+		Connector: connector,
+	}, nil
+}
+
+func newAzureADTokenSource(auth AzureADAuth) *azureADTokenSource {
+	return &azureADTokenSource{
+		auth:       auth,
+		authority:  azureADAuthority,
+		imds:       azureIMDSEndpoint,
+		httpClient: &http.Client{Timeout: tokenRequestTimeout},
+		logger:     logger.WithField("component", "AzureADTokenSource").WithField("clientID", auth.ClientID),
+	}
+}
+
+// getToken returns the cached token or acquires a new one if it's about to expire
+func (s *azureADTokenSource) getToken() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" && time.Until(s.expiry) > tokenRefreshMargin {
+		return s.token, nil
+	}
+
+	var req *http.Request
+	var reqErr error
+	if s.auth.ClientSecret != "" {
+		req, reqErr = s.newClientSecretRequest()
+	} else {
+		req, reqErr = s.newManagedIdentityRequest()
+	}
+	if reqErr != nil {
+		return "", fmt.Errorf("failed to create token request: %w", reqErr)
+	}
+
+	resp, err := s.fetchToken(req)
+	if err != nil {
+		if s.token != "" && time.Now().Before(s.expiry) {
+			s.logger.Warnf("failed to refresh token, use the current one until %s: %v", s.expiry.Format(time.RFC3339), err)
+			return s.token, nil
+		}
+		return "", err
+	}
+
+	s.token = resp.AccessToken
+	s.expiry = getTokenExpiry(resp)
+	s.logger.Debugf("acquired token valid until %s", s.expiry.Format(time.RFC3339))
+	return s.token, nil
+}
+
+func (s *azureADTokenSource) newClientSecretRequest() (*http.Request, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.auth.ClientID},
+		"client_secret": {s.auth.ClientSecret},
+		"scope":         {azureSQLResource + ".default"},
+	}
+	tokenURL := s.authority + url.PathEscape(s.auth.TenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// newManagedIdentityRequest creates the token request for App Service if IDENTITY_ENDPOINT is set, or for VM and AKS
+// by the instance metadata service
+func (s *azureADTokenSource) newManagedIdentityRequest() (*http.Request, error) {
+	query := url.Values{"resource": {azureSQLResource}}
+	if s.auth.ClientID != "" {
+		query.Set("client_id", s.auth.ClientID)
+	}
+
+	if endpoint := os.Getenv("IDENTITY_ENDPOINT"); endpoint != "" {
+		query.Set("api-version", "2019-08-01")
+		req, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER"))
+		return req, nil
+	}
+
+	query.Set("api-version", "2018-02-01")
+	req, err := http.NewRequest(http.MethodGet, s.imds+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	return req, nil
+}
+
+func (s *azureADTokenSource) fetchToken(req *http.Request) (*azureADTokenResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenRequestTimeout)
+	defer cancel()
+
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, bodyErr := io.ReadAll(resp.Body)
+	if bodyErr != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", bodyErr)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request token: %s: %s", resp.Status, string(body))
+	}
+
+	result := &azureADTokenResponse{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("failed to parse token response: missing access_token")
+	}
+	return result, nil
+}
+
+// getTokenExpiry returns the expiry time from "expires_on" or "expires_in", or a short time if neither is valid
+func getTokenExpiry(resp *azureADTokenResponse) time.Time {
+	if expiresOn, err := strconv.ParseInt(string(resp.ExpiresOn), 10, 64); err == nil {
+		return time.Unix(expiresOn, 0)
+	}
+	if expiresIn, err := strconv.ParseInt(string(resp.ExpiresIn), 10, 64); err == nil {
+		return time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return time.Now().Add(tokenRefreshMargin + time.Minute)
+}
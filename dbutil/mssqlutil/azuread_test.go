@@ -0,0 +1,55 @@
+package mssqlutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAzureADTokenSource(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/tenant1/oauth2/v2.0/token":
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "secret", r.PostForm.Get("client_secret"))
+			assert.Equal(t, "https://database.windows.net/.default", r.PostForm.Get("scope"))
+			fmt.Fprintf(w, `{"access_token":"token%d","expires_in":3599}`, requests)
+		case "/imds":
+			assert.Equal(t, "true", r.Header.Get("Metadata"))
+			assert.Equal(t, "https://database.windows.net/", r.URL.Query().Get("resource"))
+			fmt.Fprintf(w, `{"access_token":"token%d","expires_in":"60","expires_on":"%d"}`, requests, time.Now().Unix()+60)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	secretSource := newAzureADTokenSource(AzureADAuth{TenantID: "tenant1", ClientID: "app", ClientSecret: "secret"})
+	secretSource.authority = server.URL + "/"
+	token, err := secretSource.getToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	token, _ = secretSource.getToken()
+	assert.Equal(t, "token1", token, "cached")
+
+	identitySource := newAzureADTokenSource(AzureADAuth{})
+	identitySource.imds = server.URL + "/imds"
+	token, _ = identitySource.getToken()
+	assert.Equal(t, "token2", token)
+	token, _ = identitySource.getToken()
+	assert.Equal(t, "token3", token, "refreshed as it expires within the margin")
+
+	identitySource.imds = server.URL + "/broken"
+	token, err = identitySource.getToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token3", token, "still valid after refresh failure")
+
+	_, err = NewAzureADConnectionConfig("sqlserver://db.local", AzureADAuth{ClientSecret: "secret"})
+	assert.EqualError(t, err, "missing TenantID or ClientID for client secret authentication")
+}
@@ -1,35 +1,54 @@
 package mssqlutil
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/relex/gotils/dbutil"
 	"github.com/relex/gotils/logger"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // BulkInsert performs SQL Server bulk-insert from input rows represented by (rowCount, getRow)
 //
 // No reflection here. The getRow parameter must transform source data fields into formats compatible to the destination columns
 func BulkInsert(tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
-	stmt, stmtErr := tx.Prepare(mssql.CopyIn(tableName, mssql.BulkOptions{}, columnNames...))
+	return BulkInsertCtx(context.Background(), tx, tableName, columnNames, rowCount, getRow)
+}
+
+// BulkInsertCtx performs SQL Server bulk-insert like BulkInsert, with the context for deadline and cancellation
+//
+// The context is checked between rows, so that long bulk inserts can be aborted before sending to the server.
+func BulkInsertCtx(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (count int64, err error) {
+	ctx, span := dbutil.StartStatementSpan(ctx, tx, "mssqlutil.BulkInsert", "INSERT BULK "+tableName)
+	span.SetAttributes(attribute.String("db.sql.table", tableName), attribute.Int("db.rows", rowCount))
+	defer func() { dbutil.EndSpan(span, err) }()
+
+	stmt, stmtErr := tx.PrepareContext(ctx, mssql.CopyIn(tableName, mssql.BulkOptions{}, columnNames...))
 	if stmtErr != nil {
 		return 0, fmt.Errorf("failed to prepare bulk insert statement: %w", stmtErr)
 	}
 
 	for i := 0; i < rowCount; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("aborted bulk insert at row #%d: %w", i, ctxErr)
+		}
+
 		row := getRow(i)
 		if len(row) != len(columnNames) {
 			logger.WithField("table", tableName).Panicf("bulkInsert: wrong numbers of values in row #d: %v", row)
 		}
 
-		_, appendErr := stmt.Exec(row...)
+		_, appendErr := stmt.ExecContext(ctx, row...)
 		if appendErr != nil {
 			return 0, fmt.Errorf("failed to append locally: row #%d %v: %w", i, row, appendErr)
 		}
 	}
 
-	result, execErr := stmt.Exec()
+	result, execErr := stmt.ExecContext(ctx)
 	if execErr != nil {
 		return 0, fmt.Errorf("failed to execute bulk insert: %w", execErr)
 	}
@@ -0,0 +1,60 @@
+package mssqlutil_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/dbutil/dbutiltest"
+	"github.com/relex/gotils/dbutil/mssqlutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInsertCtxCancel(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+
+	err := dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		lastRow := -1
+		_, err := mssqlutil.BulkInsertCtx(ctx, tx, "dbo.Items", []string{"ID"}, 5, func(index int) []interface{} {
+			lastRow = index
+			if index == 2 {
+				cancel()
+			}
+			return []interface{}{index}
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 2, lastRow)
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, fake.Statements(), 2, "only rows before cancellation are sent") {
+		assert.Equal(t, []interface{}{1}, fake.Statements()[1].Args)
+	}
+}
+
+func TestBulkInsertCtxCancelDuringStatement(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	fake.BlockOn(`^INSERTBULK`)
+
+	err := dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		calls := 0
+		_, err := mssqlutil.BulkInsertCtx(ctx, tx, "dbo.Items", []string{"ID"}, 5, func(index int) []interface{} {
+			calls++
+			return []interface{}{index}
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "failed to append locally: row #0")
+		assert.Equal(t, 1, calls)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, fake.Statements())
+}
@@ -0,0 +1,121 @@
+package mssqlutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/relex/gotils/dbutil"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// upsertTempTable is the session-scoped temporary table to load rows for BulkUpsert
+const upsertTempTable = "#bulk_upsert"
+
+// UpsertResult is the numbers of rows inserted and updated by BulkUpsert
+type UpsertResult struct {
+	Inserted int64
+	Updated  int64
+}
+
+// BulkUpsert bulk-inserts input rows represented by (rowCount, getRow) into a temporary table and MERGEs them into the
+// target table by the key columns, which must be included in columnNames
+//
+// Rows identical to existing ones are not updated or counted. Keys must be unique in input rows, or MERGE fails.
+//
+// See BulkInsert for the requirement of getRow.
+func BulkUpsert(tx *sql.Tx, tableName string, keyColumns []string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (UpsertResult, error) {
+	return BulkUpsertCtx(context.Background(), tx, tableName, keyColumns, columnNames, rowCount, getRow)
+}
+
+// BulkUpsertCtx performs bulk-upsert like BulkUpsert, with the context for deadline and cancellation
+func BulkUpsertCtx(ctx context.Context, tx *sql.Tx, tableName string, keyColumns []string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (result UpsertResult, err error) {
+	mergeSQL, sqlErr := buildMergeStatement(tableName, upsertTempTable, keyColumns, columnNames)
+	if sqlErr != nil {
+		return result, sqlErr
+	}
+
+	ctx, span := dbutil.StartStatementSpan(ctx, tx, "mssqlutil.BulkUpsert", "MERGE INTO "+tableName)
+	span.SetAttributes(attribute.String("db.sql.table", tableName), attribute.Int("db.rows", rowCount))
+	defer func() { dbutil.EndSpan(span, err) }()
+
+	createSQL := fmt.Sprintf("IF OBJECT_ID('tempdb..%[1]s') IS NOT NULL DROP TABLE %[1]s; SELECT TOP 0 %[2]s INTO %[1]s FROM %[3]s",
+		upsertTempTable, joinQuotedColumns("", columnNames), tableName)
+	if _, err := tx.ExecContext(ctx, createSQL); err != nil {
+		return result, fmt.Errorf("failed to create temporary table: %w", err)
+	}
+	defer func() {
+		if _, dropErr := tx.ExecContext(ctx, "DROP TABLE "+upsertTempTable); dropErr != nil && err == nil {
+			err = fmt.Errorf("failed to drop temporary table: %w", dropErr)
+		}
+	}()
+
+	if _, err := BulkInsertCtx(ctx, tx, upsertTempTable, columnNames, rowCount, getRow); err != nil {
+		return result, err
+	}
+
+	if err := tx.QueryRowContext(ctx, mergeSQL).Scan(&result.Inserted, &result.Updated); err != nil {
+		return result, fmt.Errorf("failed to merge into %s: %w", tableName, err)
+	}
+	return result, nil
+}
+
+// buildMergeStatement builds the MERGE statement from source table, followed by a query of inserted and updated counts
+func buildMergeStatement(tableName string, sourceTable string, keyColumns []string, columnNames []string) (string, error) {
+	if len(keyColumns) == 0 {
+		return "", fmt.Errorf("no key columns to upsert into %s", tableName)
+	}
+	isKey := make(map[string]bool, len(keyColumns))
+	for _, key := range keyColumns {
+		isKey[key] = true
+	}
+	var valueColumns []string
+	for _, column := range columnNames {
+		if isKey[column] {
+			delete(isKey, column)
+		} else {
+			valueColumns = append(valueColumns, column)
+		}
+	}
+	for key := range isKey {
+		return "", fmt.Errorf("key column '%s' not in columns to upsert into %s", key, tableName)
+	}
+
+	conditions := make([]string, len(keyColumns))
+	for i, key := range keyColumns {
+		conditions[i] = fmt.Sprintf("t.%[1]s = s.%[1]s", quoteIdentifier(key))
+	}
+
+	var builder strings.Builder
+	builder.WriteString("DECLARE @actions TABLE (action nvarchar(10));\n")
+	fmt.Fprintf(&builder, "MERGE INTO %s WITH (HOLDLOCK) AS t\n", tableName)
+	fmt.Fprintf(&builder, "USING %s AS s ON %s\n", sourceTable, strings.Join(conditions, " AND "))
+	if len(valueColumns) > 0 {
+		assignments := make([]string, len(valueColumns))
+		for i, column := range valueColumns {
+			assignments[i] = fmt.Sprintf("t.%[1]s = s.%[1]s", quoteIdentifier(column))
+		}
+		// EXCEPT compares NULLs as equal, to skip unchanged rows
+		fmt.Fprintf(&builder, "WHEN MATCHED AND EXISTS (SELECT %s EXCEPT SELECT %s) THEN UPDATE SET %s\n",
+			joinQuotedColumns("s.", valueColumns), joinQuotedColumns("t.", valueColumns), strings.Join(assignments, ", "))
+	}
+	fmt.Fprintf(&builder, "WHEN NOT MATCHED BY TARGET THEN INSERT (%s) VALUES (%s)\n",
+		joinQuotedColumns("", columnNames), joinQuotedColumns("s.", columnNames))
+	builder.WriteString("OUTPUT $action INTO @actions;\n")
+	builder.WriteString("SELECT COUNT(CASE WHEN action = 'INSERT' THEN 1 END), COUNT(CASE WHEN action = 'UPDATE' THEN 1 END) FROM @actions;")
+	return builder.String(), nil
+}
+
+func joinQuotedColumns(prefix string, columnNames []string) string {
+	quoted := make([]string, len(columnNames))
+	for i, column := range columnNames {
+		quoted[i] = prefix + quoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteIdentifier quotes the name of column by brackets
+func quoteIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
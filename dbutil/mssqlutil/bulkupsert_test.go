@@ -0,0 +1,28 @@
+package mssqlutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildMergeStatement(t *testing.T) {
+	sql, err := buildMergeStatement("dbo.Items", "#src", []string{"Tenant", "ID"}, []string{"ID", "Tenant", "Name", "Price]"})
+	assert.NoError(t, err)
+	assert.Equal(t, `DECLARE @actions TABLE (action nvarchar(10));
+MERGE INTO dbo.Items WITH (HOLDLOCK) AS t
+USING #src AS s ON t.[Tenant] = s.[Tenant] AND t.[ID] = s.[ID]
+WHEN MATCHED AND EXISTS (SELECT s.[Name], s.[Price]]] EXCEPT SELECT t.[Name], t.[Price]]]) THEN UPDATE SET t.[Name] = s.[Name], t.[Price]]] = s.[Price]]]
+WHEN NOT MATCHED BY TARGET THEN INSERT ([ID], [Tenant], [Name], [Price]]]) VALUES (s.[ID], s.[Tenant], s.[Name], s.[Price]]])
+OUTPUT $action INTO @actions;
+SELECT COUNT(CASE WHEN action = 'INSERT' THEN 1 END), COUNT(CASE WHEN action = 'UPDATE' THEN 1 END) FROM @actions;`, sql)
+
+	sql, err = buildMergeStatement("Tags", "#src", []string{"Name"}, []string{"Name"})
+	assert.NoError(t, err)
+	assert.NotContains(t, sql, "WHEN MATCHED", "no update if all columns are keys")
+
+	_, err = buildMergeStatement("Tags", "#src", nil, []string{"Name"})
+	assert.EqualError(t, err, "no key columns to upsert into Tags")
+	_, err = buildMergeStatement("Tags", "#src", []string{"ID"}, []string{"Name"})
+	assert.EqualError(t, err, "key column 'ID' not in columns to upsert into Tags")
+}
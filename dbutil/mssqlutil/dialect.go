@@ -0,0 +1,29 @@
+package mssqlutil
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/relex/gotils/dbutil"
+)
+
+// Dialect is the dbutil.Dialect for SQL Server, registered for the URL scheme "sqlserver"
+var Dialect dbutil.Dialect = dialect{}
+
+func init() {
+	dbutil.RegisterDialect("sqlserver", Dialect)
+}
+
+type dialect struct{}
+
+func (dialect) DriverName() string {
+	return "sqlserver"
+}
+
+func (dialect) DataSourceName(dbURL string) (string, error) {
+	return dbURL, nil // go-mssqldb accepts URLs directly
+}
+
+func (dialect) BulkInsert(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
+	return BulkInsertCtx(ctx, tx, tableName, columnNames, rowCount, getRow)
+}
@@ -0,0 +1,105 @@
+// Package mysqlutil provides MySQL support for dbutil
+//
+// Importing this package registers the dialect for the URL scheme "mysql", along with the database/sql driver "mysql"
+// from go-sql-driver.
+package mysqlutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql" // register driver
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/logger"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultBatchRows is the max number of rows in each INSERT statement from BulkInsert
+const DefaultBatchRows = 1000
+
+// maxPlaceholders is the limit of placeholders in a prepared statement of MySQL
+const maxPlaceholders = 65535
+
+// BulkInsert performs multi-row INSERTs from input rows represented by (rowCount, getRow), in batches of DefaultBatchRows
+//
+// No reflection here. The getRow parameter must transform source data fields into formats compatible to the destination columns
+func BulkInsert(tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
+	return BulkInsertCtx(context.Background(), tx, tableName, columnNames, rowCount, getRow)
+}
+
+// BulkInsertCtx performs multi-row INSERTs like BulkInsert, with the context for deadline and cancellation
+func BulkInsertCtx(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (count int64, err error) {
+	ctx, span := dbutil.StartStatementSpan(ctx, tx, "mysqlutil.BulkInsert", "INSERT INTO "+tableName)
+	span.SetAttributes(attribute.String("db.sql.table", tableName), attribute.Int("db.rows", rowCount))
+	defer func() { dbutil.EndSpan(span, err) }()
+
+	batchRows := DefaultBatchRows
+	if batchRows*len(columnNames) > maxPlaceholders {
+		batchRows = maxPlaceholders / len(columnNames)
+	}
+
+	var total int64
+	args := make([]interface{}, 0, batchRows*len(columnNames))
+	for start := 0; start < rowCount; start += batchRows {
+		end := start + batchRows
+		if end > rowCount {
+			end = rowCount
+		}
+
+		args = args[:0]
+		for i := start; i < end; i++ {
+			row := getRow(i)
+			if len(row) != len(columnNames) {
+				logger.WithField("table", tableName).Panicf("bulkInsert: wrong numbers of values in row #%d: %v", i, row)
+			}
+			args = append(args, row...)
+		}
+
+		result, execErr := tx.ExecContext(ctx, buildInsertStatement(tableName, columnNames, end-start), args...)
+		if execErr != nil {
+			return total, fmt.Errorf("failed to execute INSERT for rows #%d-#%d: %w", start, end-1, execErr)
+		}
+		affected, countErr := result.RowsAffected()
+		if countErr != nil {
+			return total, fmt.Errorf("failed to count inserted rows: %w", countErr)
+		}
+		total += affected
+	}
+
+	return total, nil
+}
+
+// buildInsertStatement builds "INSERT INTO `table` (`col1`, `col2`) VALUES (?, ?), (?, ?)..."
+func buildInsertStatement(tableName string, columnNames []string, rows int) string {
+	quotedColumns := make([]string, len(columnNames))
+	for i, name := range columnNames {
+		quotedColumns[i] = quoteIdentifier(name)
+	}
+	rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columnNames)), ", ") + ")"
+
+	var sb strings.Builder
+	sb.Grow(len(tableName) + len(columnNames)*16 + rows*len(rowPlaceholders))
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(quoteIdentifier(tableName))
+	sb.WriteString(" (")
+	sb.WriteString(strings.Join(quotedColumns, ", "))
+	sb.WriteString(") VALUES ")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(rowPlaceholders)
+	}
+	return sb.String()
+}
+
+// quoteIdentifier quotes table or column names by backticks, e.g. "db.table" to "`db`.`table`"
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
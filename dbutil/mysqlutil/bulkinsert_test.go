@@ -0,0 +1,59 @@
+package mysqlutil_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/dbutil/dbutiltest"
+	"github.com/relex/gotils/dbutil/mysqlutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInsertCtxCancel(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	fake.OnExec(`^INSERT INTO`, mysqlutil.DefaultBatchRows)
+
+	err := dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		count, err := mysqlutil.BulkInsertCtx(ctx, tx, "sales.orders", []string{"id"}, 3*mysqlutil.DefaultBatchRows, func(index int) []interface{} {
+			if index == mysqlutil.DefaultBatchRows+1 {
+				cancel()
+			}
+			return []interface{}{index}
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "failed to execute INSERT for rows #1000-#1999")
+		assert.EqualValues(t, mysqlutil.DefaultBatchRows, count)
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, fake.Statements(), 1, "only the batch before cancellation is sent") {
+		assert.True(t, strings.HasPrefix(fake.Statements()[0].Query, "INSERT INTO `sales`.`orders`"))
+	}
+}
+
+func TestBulkInsertCtxCancelDuringStatement(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	fake.BlockOn(`^INSERT INTO`)
+
+	err := dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		count, err := mysqlutil.BulkInsertCtx(ctx, tx, "sales.orders", []string{"id"}, 2*mysqlutil.DefaultBatchRows, func(index int) []interface{} {
+			return []interface{}{index}
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "failed to execute INSERT for rows #0-#999")
+		assert.Zero(t, count)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, fake.Statements())
+}
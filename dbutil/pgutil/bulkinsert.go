@@ -0,0 +1,75 @@
+// Package pgutil provides PostgreSQL support for dbutil
+//
+// Importing this package registers the dialect for URL schemes "postgres" and "postgresql", along with the
+// database/sql driver "postgres" from lib/pq.
+package pgutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/logger"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BulkInsert performs PostgreSQL COPY from input rows represented by (rowCount, getRow)
+//
+// No reflection here. The getRow parameter must transform source data fields into formats compatible to the destination columns
+func BulkInsert(tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
+	return BulkInsertCtx(context.Background(), tx, tableName, columnNames, rowCount, getRow)
+}
+
+// BulkInsertCtx performs PostgreSQL COPY like BulkInsert, with the context for deadline and cancellation
+//
+// The table name may be qualified by schema, e.g. "sales.orders"
+func BulkInsertCtx(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (count int64, err error) {
+	ctx, span := dbutil.StartStatementSpan(ctx, tx, "pgutil.BulkInsert", "COPY "+tableName)
+	span.SetAttributes(attribute.String("db.sql.table", tableName), attribute.Int("db.rows", rowCount))
+	defer func() { dbutil.EndSpan(span, err) }()
+
+	stmt, stmtErr := tx.PrepareContext(ctx, buildCopyStatement(tableName, columnNames))
+	if stmtErr != nil {
+		return 0, fmt.Errorf("failed to prepare COPY statement: %w", stmtErr)
+	}
+
+	for i := 0; i < rowCount; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("aborted COPY at row #%d: %w", i, ctxErr)
+		}
+
+		row := getRow(i)
+		if len(row) != len(columnNames) {
+			logger.WithField("table", tableName).Panicf("bulkInsert: wrong numbers of values in row #%d: %v", i, row)
+		}
+
+		if _, appendErr := stmt.ExecContext(ctx, row...); appendErr != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("failed to append locally: row #%d %v: %w", i, row, appendErr)
+		}
+	}
+
+	// lib/pq sends remaining data and finishes COPY on the final Exec without arguments
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return 0, fmt.Errorf("failed to execute COPY: %w", err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		return int64(rowCount), fmt.Errorf("failed to close COPY statement: %w", err)
+	}
+
+	return int64(rowCount), nil
+}
+
+// buildCopyStatement builds "COPY ..." with quoted identifiers, e.g. "sales.orders" to "\"sales\".\"orders\""
+func buildCopyStatement(tableName string, columnNames []string) string {
+	if schema, table, qualified := strings.Cut(tableName, "."); qualified {
+		return pq.CopyInSchema(schema, table, columnNames...)
+	}
+	return pq.CopyIn(tableName, columnNames...)
+}
@@ -0,0 +1,30 @@
+package pgutil
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/relex/gotils/dbutil"
+)
+
+// Dialect is the dbutil.Dialect for PostgreSQL, registered for the URL schemes "postgres" and "postgresql"
+var Dialect dbutil.Dialect = dialect{}
+
+func init() {
+	dbutil.RegisterDialect("postgres", Dialect)
+	dbutil.RegisterDialect("postgresql", Dialect)
+}
+
+type dialect struct{}
+
+func (dialect) DriverName() string {
+	return "postgres"
+}
+
+func (dialect) DataSourceName(dbURL string) (string, error) {
+	return dbURL, nil // lib/pq accepts URLs directly
+}
+
+func (dialect) BulkInsert(ctx context.Context, tx *sql.Tx, tableName string, columnNames []string, rowCount int, getRow func(index int) []interface{}) (int64, error) {
+	return BulkInsertCtx(ctx, tx, tableName, columnNames, rowCount, getRow)
+}
@@ -0,0 +1,99 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// PoolOptions defines the limits of connection pool, zero values mean the defaults of database/sql
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// RetryPolicy is used to begin transactions, nil for the default by URL (AzureSQLRetryPolicy or NoRetry)
+	RetryPolicy *RetryPolicy
+}
+
+// Pool keeps a long-lived *sql.DB for reusing connections across transactions
+//
+// Unlike RunSession, which opens a new DB for each session, Pool should be created once and shared in servers.
+type Pool struct {
+	db          *sql.DB
+	driver      string
+	retryPolicy RetryPolicy
+}
+
+// NewPool creates a connection pool for the driver and URL
+//
+// Connections are not made until used. Call Ping to verify the connectivity.
+func NewPool(driver string, url string, opts PoolOptions) (*Pool, error) {
+	return NewPoolWithConfig(ConnectionConfig{Driver: driver, URL: url}, opts)
+}
+
+// DB returns the underlying *sql.DB
+func (p *Pool) DB() *sql.DB {
+	return p.db
+}
+
+// WithTx runs "do" within a new transaction from the pool, like RunSessionCtxE
+//
+// The transaction is committed if "do" succeeds, or rolled back otherwise.
+func (p *Pool) WithTx(ctx context.Context, do func(tx *sql.Tx) error) (err error) {
+	ctx, span := startSessionSpan(ctx, p.driver)
+	defer func() { EndSpan(span, err) }()
+
+	var tx *sql.Tx
+	txErr := p.retryPolicy.Do(ctx, "begin", func() error {
+		var err error
+		tx, err = p.db.BeginTx(ctx, nil)
+		return err
+	})
+	if txErr != nil {
+		return fmt.Errorf("failed to begin transaction: %w", txErr)
+	}
+	return runTransaction(ctx, tx, p.driver, do)
+}
+
+// WithTxRetry runs "do" within a new transaction like WithTx, and retries the whole transaction by the policy on
+// transient errors such as deadlocks
+//
+// The "do" function may be called multiple times, each time in a new transaction after the previous is rolled back.
+func (p *Pool) WithTxRetry(ctx context.Context, policy RetryPolicy, do func(tx *sql.Tx) error) error {
+	return policy.Do(ctx, "transaction", func() error {
+		return p.WithTx(ctx, do)
+	})
+}
+
+// Ping verifies a connection to the database is still alive, establishing one if necessary
+func (p *Pool) Ping(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping DB: %w", err)
+	}
+	return nil
+}
+
+// HealthCheck pings the database with the given timeout, meant for readiness checks
+func (p *Pool) HealthCheck(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.Ping(ctx)
+}
+
+// Collector returns a Prometheus collector of pool statistics, e.g. open connections, wait count and wait duration
+//
+// The dbName is added as the label "db_name" to all metrics, which are named "go_sql_*"
+func (p *Pool) Collector(dbName string) prometheus.Collector {
+	return collectors.NewDBStatsCollector(p.db, dbName)
+}
+
+// Close closes the pool and all idle connections
+func (p *Pool) Close() error {
+	return p.db.Close()
+}
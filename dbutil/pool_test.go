@@ -0,0 +1,75 @@
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/dbutil/dbutiltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	pool, err := dbutil.NewPool(dbutiltest.DriverName, fake.DSN(), dbutil.PoolOptions{MaxOpenConns: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+	assert.NoError(t, pool.HealthCheck(time.Second))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, pool.WithTx(context.Background(), func(tx *sql.Tx) error {
+			_, err := tx.Exec("UPDATE orders SET state = @p1", i)
+			return err
+		}))
+	}
+	assert.Equal(t, 3, fake.Commits())
+	assert.Equal(t, 1, pool.DB().Stats().OpenConnections, "connection should be reused")
+	assert.Equal(t, 1, pool.DB().Stats().MaxOpenConnections)
+
+	err = pool.WithTx(context.Background(), func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		return pool.WithTx(ctx, func(tx *sql.Tx) error {
+			t.Error("nested transaction should not start beyond MaxOpenConns")
+			return nil
+		})
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "failed during DB session: failed to begin transaction")
+	assert.Equal(t, 3, fake.Commits())
+	assert.Equal(t, 1, fake.Rollbacks())
+}
+
+func TestPoolWithTxRetry(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	pool, err := dbutil.NewPool(dbutiltest.DriverName, fake.DSN(), dbutil.PoolOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+
+	errDeadlock := errors.New("deadlock")
+	policy := dbutil.RetryPolicy{
+		MaxAttempts: 3,
+		IsRetryable: func(err error) bool { return errors.Is(err, errDeadlock) },
+	}
+	attempts := 0
+	err = pool.WithTxRetry(context.Background(), policy, func(tx *sql.Tx) error {
+		attempts++
+		if attempts < 2 {
+			return errDeadlock
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 1, fake.Rollbacks())
+	assert.Equal(t, 1, fake.Commits())
+}
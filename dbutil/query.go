@@ -0,0 +1,327 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iancoleman/strcase"
+)
+
+// columnFieldsCache caches column names to field indexes by struct types
+var columnFieldsCache sync.Map // reflect.Type => map[string][]int
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// timeLayouts are tried in order to parse time columns returned as text, e.g. from MySQL without "parseTime=true"
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Select runs a query within the given transaction and scans all rows into a slice of T
+//
+// T can be a struct, whose fields are mapped to columns by the tag `db:"column_name"`, or by the field name in snake
+// case if untagged. Fields tagged by `db:"-"` are ignored, and embedded structs are flattened. Every column must be
+// mapped to a field.
+//
+// T can also be a simple type or sql.Scanner for queries with a single column.
+//
+// NULL values are scanned as zero values, or nil if the field is a pointer. Time values returned as text by some
+// drivers are parsed automatically.
+func Select[T any](tx *sql.Tx, query string, args ...interface{}) ([]T, error) {
+	return SelectCtx[T](context.Background(), tx, query, args...)
+}
+
+// SelectCtx runs a query like Select, with the context for deadline and cancellation
+func SelectCtx[T any](ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (result []T, err error) {
+	ctx, span := StartStatementSpan(ctx, tx, "dbutil.Select", query)
+	defer func() { EndSpan(span, err) }()
+
+	rows, queryErr := tx.QueryContext(ctx, query, args...)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to query: %w", queryErr)
+	}
+	defer rows.Close()
+
+	result = make([]T, 0)
+	for rows.Next() {
+		var item T
+		if err := scanRow(rows, &item); err != nil {
+			return nil, fmt.Errorf("failed to scan row #%d: %w", len(result), err)
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	return result, nil
+}
+
+// Get runs a query within the given transaction and scans the first row into T, see Select for the types of T
+//
+// Returns sql.ErrNoRows (wrapped) if there is no result.
+func Get[T any](tx *sql.Tx, query string, args ...interface{}) (T, error) {
+	return GetCtx[T](context.Background(), tx, query, args...)
+}
+
+// GetCtx runs a query like Get, with the context for deadline and cancellation
+func GetCtx[T any](ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (result T, err error) {
+	ctx, span := StartStatementSpan(ctx, tx, "dbutil.Get", query)
+	defer func() { EndSpan(span, err) }()
+
+	rows, queryErr := tx.QueryContext(ctx, query, args...)
+	if queryErr != nil {
+		return result, fmt.Errorf("failed to query: %w", queryErr)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return result, fmt.Errorf("failed to read rows: %w", err)
+		}
+		return result, fmt.Errorf("failed to get row: %w", sql.ErrNoRows)
+	}
+	if err := scanRow(rows, &result); err != nil {
+		return result, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return result, nil
+}
+
+func scanRow(rows *sql.Rows, dest interface{}) error {
+	columns, colErr := rows.Columns()
+	if colErr != nil {
+		return fmt.Errorf("failed to get columns: %w", colErr)
+	}
+
+	destValue := reflect.ValueOf(dest).Elem()
+	if !isStructDest(destValue.Type()) {
+		if len(columns) != 1 {
+			return fmt.Errorf("expected 1 column for %s, got %d", destValue.Type(), len(columns))
+		}
+		return rows.Scan(newFieldScanner(destValue))
+	}
+
+	fieldsByColumn := getColumnFields(destValue.Type())
+	targets := make([]interface{}, len(columns))
+	for i, column := range columns {
+		index, found := fieldsByColumn[column]
+		if !found {
+			index, found = fieldsByColumn[strings.ToLower(column)]
+		}
+		if !found {
+			return fmt.Errorf("no field for column '%s' in %s", column, destValue.Type())
+		}
+		targets[i] = newFieldScanner(destValue.FieldByIndex(index))
+	}
+	return rows.Scan(targets...)
+}
+
+func isStructDest(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// getColumnFields returns column names to field indexes of the struct type
+func getColumnFields(t reflect.Type) map[string][]int {
+	if cached, ok := columnFieldsCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	collectColumnFields(t, nil, fields)
+	columnFieldsCache.Store(t, fields)
+	return fields
+}
+
+func collectColumnFields(t reflect.Type, parentIndex []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		index := append(append([]int{}, parentIndex...), i)
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && field.Anonymous && isStructDest(field.Type) {
+			collectColumnFields(field.Type, index, fields)
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = strcase.ToSnake(field.Name)
+		}
+		if _, exists := fields[name]; !exists {
+			fields[name] = index
+		}
+	}
+}
+
+// fieldScanner scans a column into a field with NULL handling and type conversion
+type fieldScanner struct {
+	field reflect.Value
+}
+
+func newFieldScanner(field reflect.Value) interface{} {
+	if field.Addr().Type().Implements(scannerType) {
+		return field.Addr().Interface()
+	}
+	return &fieldScanner{field}
+}
+
+func (s *fieldScanner) Scan(src interface{}) error {
+	if src == nil {
+		s.field.Set(reflect.Zero(s.field.Type()))
+		return nil
+	}
+	target := s.field
+	if target.Kind() == reflect.Pointer {
+		target = reflect.New(target.Type().Elem())
+		if err := assignValue(target.Elem(), src); err != nil {
+			return err
+		}
+		s.field.Set(target)
+		return nil
+	}
+	return assignValue(target, src)
+}
+
+// assignValue assigns the value returned by database/sql driver to the target
+func assignValue(target reflect.Value, src interface{}) error {
+	if target.Type() == timeType {
+		return assignTime(target, src)
+	}
+
+	if b, isBytes := src.([]byte); isBytes {
+		if target.Kind() == reflect.Slice && target.Type().Elem().Kind() == reflect.Uint8 {
+			target.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		src = string(b)
+	}
+
+	srcValue := reflect.ValueOf(src)
+	switch target.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case string:
+			target.SetString(v)
+		case time.Time:
+			target.SetString(v.Format(time.RFC3339Nano))
+		default:
+			target.SetString(fmt.Sprint(v))
+		}
+		return nil
+	case reflect.Bool:
+		switch v := src.(type) {
+		case bool:
+			target.SetBool(v)
+		case int64:
+			target.SetBool(v != 0)
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("failed to convert '%s' to bool: %w", v, err)
+			}
+			target.SetBool(parsed)
+		default:
+			return fmt.Errorf("unsupported conversion from %T to %s", src, target.Type())
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		str, isStr := src.(string)
+		if !isStr && !srcValue.CanInt() {
+			return fmt.Errorf("unsupported conversion from %T to %s", src, target.Type())
+		}
+		var num int64
+		if isStr {
+			parsed, err := strconv.ParseInt(str, 10, target.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("failed to convert '%s' to %s: %w", str, target.Type(), err)
+			}
+			num = parsed
+		} else {
+			num = srcValue.Int()
+		}
+		if target.OverflowInt(num) {
+			return fmt.Errorf("value %d overflows %s", num, target.Type())
+		}
+		target.SetInt(num)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var num uint64
+		switch {
+		case srcValue.Kind() == reflect.String:
+			parsed, err := strconv.ParseUint(srcValue.String(), 10, target.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("failed to convert '%s' to %s: %w", srcValue.String(), target.Type(), err)
+			}
+			num = parsed
+		case srcValue.CanInt() && srcValue.Int() >= 0:
+			num = uint64(srcValue.Int())
+		case srcValue.CanUint():
+			num = srcValue.Uint()
+		default:
+			return fmt.Errorf("unsupported conversion from %T(%v) to %s", src, src, target.Type())
+		}
+		if target.OverflowUint(num) {
+			return fmt.Errorf("value %d overflows %s", num, target.Type())
+		}
+		target.SetUint(num)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case srcValue.Kind() == reflect.String:
+			parsed, err := strconv.ParseFloat(srcValue.String(), target.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("failed to convert '%s' to %s: %w", srcValue.String(), target.Type(), err)
+			}
+			target.SetFloat(parsed)
+		case srcValue.CanFloat():
+			target.SetFloat(srcValue.Float())
+		case srcValue.CanInt():
+			target.SetFloat(float64(srcValue.Int()))
+		default:
+			return fmt.Errorf("unsupported conversion from %T to %s", src, target.Type())
+		}
+		return nil
+	}
+
+	if srcValue.Type().AssignableTo(target.Type()) {
+		target.Set(srcValue)
+		return nil
+	}
+	return fmt.Errorf("unsupported conversion from %T to %s", src, target.Type())
+}
+
+func assignTime(target reflect.Value, src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case time.Time:
+		target.Set(reflect.ValueOf(v))
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("unsupported conversion from %T to time", src)
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			target.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to parse time '%s'", text)
+}
@@ -0,0 +1,64 @@
+package dbutil
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAudit struct {
+	CreatedAt time.Time
+	Ignored   string `db:"-"`
+}
+
+type testOrder struct {
+	testAudit
+	OrderID  int64  `db:"id"`
+	Customer string `db:"customer_name"`
+	Amount   float64
+	Note     *string
+	Region   sql.NullString
+	internal int
+}
+
+func TestGetColumnFields(t *testing.T) {
+	fields := getColumnFields(reflect.TypeOf(testOrder{}))
+	assert.Equal(t, map[string][]int{
+		"created_at":    {0, 0},
+		"id":            {1},
+		"customer_name": {2},
+		"amount":        {3},
+		"note":          {4},
+		"region":        {5},
+	}, fields)
+}
+
+func TestFieldScanner(t *testing.T) {
+	order := testOrder{Note: new(string), Customer: "old"}
+	value := reflect.ValueOf(&order).Elem()
+	scan := func(name string, src interface{}) error {
+		return newFieldScanner(value.FieldByIndex(getColumnFields(value.Type())[name])).(sql.Scanner).Scan(src)
+	}
+
+	assert.NoError(t, scan("id", []byte("42")))
+	assert.NoError(t, scan("customer_name", nil))
+	assert.NoError(t, scan("amount", int64(3)))
+	assert.NoError(t, scan("note", nil))
+	assert.NoError(t, scan("region", "EU"))
+	assert.NoError(t, scan("created_at", []byte("2021-03-04 05:06:07")))
+	assert.Equal(t, testOrder{
+		testAudit: testAudit{CreatedAt: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)},
+		OrderID:   42,
+		Amount:    3,
+		Region:    sql.NullString{String: "EU", Valid: true},
+	}, order)
+
+	assert.NoError(t, scan("note", "hello"))
+	assert.Equal(t, "hello", *order.Note)
+
+	assert.ErrorContains(t, scan("id", "abc"), "failed to convert 'abc' to int64")
+	assert.ErrorContains(t, scan("created_at", true), "unsupported conversion from bool to time")
+}
@@ -0,0 +1,106 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/retry"
+)
+
+// RetryPolicy defines how operations are retried on transient errors, with exponential backoff and jitter
+//
+// IsRetryable defaults to IsTransientError instead of all errors, see retry.Policy for other fields.
+type RetryPolicy retry.Policy
+
+// NoRetry is the RetryPolicy which never retries
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// AzureSQLRetryPolicy is the default RetryPolicy for Azure SQL Servers, which are often unavailable temporarily
+var AzureSQLRetryPolicy = RetryPolicy{
+	MaxAttempts:    10,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// transientSQLServerErrors are error numbers of SQL Server and Azure SQL for transient failures
+var transientSQLServerErrors = map[int32]bool{
+	1205:  true, // deadlock victim
+	4060:  true, // cannot open database
+	10928: true, // resource limit reached
+	10929: true, // resource limit reached
+	40197: true, // service error processing request
+	40501: true, // service busy
+	40613: true, // database not currently available
+	49918: true, // not enough resources to process request
+	49919: true, // too many create or update operations
+	49920: true, // too many operations in progress
+}
+
+// transientSQLStates are SQLSTATE codes of PostgreSQL for transient failures
+var transientSQLStates = map[string]bool{
+	"40001": true, // serialization failure
+	"40P01": true, // deadlock detected
+	"57P03": true, // cannot connect now
+}
+
+var retryCounterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+	Name: "dbutil_retries_total",
+	Help: "Numbers of retries after transient DB errors",
+}, []string{"operation"})
+
+func init() {
+	promext.SafeRegister(retryCounterVec)
+}
+
+// Do calls fn until it succeeds, the error is not retryable, attempts are exhausted or the context is done
+//
+// The operation is a short name for logging and the label of metric "dbutil_retries_total", e.g. "connect"
+func (policy RetryPolicy) Do(ctx context.Context, operation string, fn func() error) error {
+	p := retry.Policy(policy)
+	if p.IsRetryable == nil {
+		p.IsRetryable = IsTransientError
+	}
+	retryCounter := retryCounterVec.WithLabelValues(operation)
+	p.OnRetry = func(attempt int, backoff time.Duration, err error) {
+		logger.WithField("operation", operation).Warnf("retry attempt #%d in %s after %v", attempt, backoff, err)
+		retryCounter.Inc()
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, backoff, err)
+		}
+	}
+	return retry.Do(ctx, p, fn)
+}
+
+// Backoff returns the wait after the given attempt (1 for the first attempt), with jitter applied
+func (policy RetryPolicy) Backoff(attempt int) time.Duration {
+	return retry.Policy(policy).Backoff(attempt)
+}
+
+// IsTransientError checks whether the error is likely temporary and the operation could succeed on retry, such as
+// deadlocks, throttling and unavailability of Azure SQL
+func IsTransientError(err error) bool {
+	var sqlServerErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &sqlServerErr) && transientSQLServerErrors[sqlServerErr.SQLErrorNumber()] {
+		return true
+	}
+	var sqlStateErr interface{ SQLState() string }
+	if errors.As(err, &sqlStateErr) && transientSQLStates[sqlStateErr.SQLState()] {
+		return true
+	}
+	return strings.Contains(err.Error(), " is not currently available")
+}
+
+// getRetryPolicy returns the default policy to retry connection for the DB URL
+func getRetryPolicy(url string) RetryPolicy {
+	if strings.Contains(url, "database.windows.net") {
+		return AzureSQLRetryPolicy
+	}
+	return NoRetry
+}
@@ -0,0 +1,68 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSQLServerError struct {
+	number int32
+}
+
+func (e testSQLServerError) Error() string {
+	return fmt.Sprintf("mssql: error %d", e.number)
+}
+
+func (e testSQLServerError) SQLErrorNumber() int32 {
+	return e.number
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	calls := 0
+	err := policy.Do(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("wrapped: %w", testSQLServerError{1205})
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, uint64(2), retryCounterVec.WithLabelValues("test").Get())
+
+	calls = 0
+	err = policy.Do(context.Background(), "test", func() error {
+		calls++
+		return testSQLServerError{208}
+	})
+	assert.EqualError(t, err, "mssql: error 208")
+	assert.Equal(t, 1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = policy.Do(ctx, "test", func() error {
+		return errors.New("Database 'foo' on server 'bar' is not currently available")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 300*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 900*time.Millisecond, policy.Backoff(3))
+	assert.Equal(t, time.Second, policy.Backoff(4))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(1)
+		assert.True(t, backoff >= 50*time.Millisecond && backoff <= 150*time.Millisecond, backoff)
+	}
+}
@@ -3,59 +3,65 @@ package dbutil
 import (
 	"context"
 	"database/sql"
-	"strings"
+	"fmt"
 
 	"github.com/relex/gotils/logger"
 )
 
-const azureSQLRetryAttempts = 10
-
 // RunSession runs a simple DB session with all actions enclosed within a transaction
 //
 // It connects to DB, starts a transaction, calls "do" and then commits it.
 //
-// Special handling for Azure SQL Server, which are often unavailable temporarily
+// Connection is retried by AzureSQLRetryPolicy for Azure SQL Server, which are often unavailable temporarily.
+// Any error is fatal. Use RunSessionE in servers or tests.
 func RunSession(driver string, url string, do func(tx *sql.Tx) error) {
-	var retryAttempts int
-	if strings.Contains(url, "database.windows.net") {
-		retryAttempts = azureSQLRetryAttempts
-	} else {
-		retryAttempts = 0
+	if err := RunSessionE(driver, url, do); err != nil {
+		logger.Fatal(err)
 	}
+}
 
-	db, dbErr := sql.Open(driver, url)
-	if dbErr != nil {
-		logger.Fatalf("failed to open DB driver '%s': %v", driver, dbErr)
-	}
-	defer db.Close()
-
-	var round = 0
-	var conn *sql.Conn
-	var connErr error
-	for {
-		round++
-		conn, connErr = db.Conn(context.Background())
-		if connErr != nil {
-			if round > retryAttempts || !strings.Contains(connErr.Error(), " is not currently available") {
-				logger.Fatalf("failed to connect to DB: %v", connErr)
-			}
-		} else {
-			break
-		}
-		logger.Warnf("reconnect attempt #%d after %v", round, connErr)
+// RunSessionCtx runs a simple DB session like RunSession, with the context for deadline and cancellation
+//
+// Any error is fatal, including cancellation.
+func RunSessionCtx(ctx context.Context, driver string, url string, do func(tx *sql.Tx) error) {
+	if err := RunSessionCtxE(ctx, driver, url, do); err != nil {
+		logger.Fatal(err)
 	}
-	defer conn.Close()
+}
 
-	tx, txErr := conn.BeginTx(context.Background(), nil)
-	if txErr != nil {
-		logger.Fatalf("failed to begin transaction: %v", txErr)
-	}
+// RunSessionE runs a simple DB session like RunSession, but returns errors instead of exiting
+//
+// The transaction is rolled back if "do" or commit fails.
+func RunSessionE(driver string, url string, do func(tx *sql.Tx) error) error {
+	return RunSessionCtxE(context.Background(), driver, url, do)
+}
+
+// RunSessionCtxE runs a simple DB session like RunSessionE, with the context for deadline and cancellation
+//
+// The context is used for connection, retries and the transaction, which is rolled back if the context is done
+// before commit. Statements inside "do" should use the Ctx variants of functions, e.g. ExecOneCtx.
+func RunSessionCtxE(ctx context.Context, driver string, url string, do func(tx *sql.Tx) error) error {
+	return RunSessionWithConfig(ctx, ConnectionConfig{Driver: driver, URL: url}, do)
+}
+
+// runTransaction calls "do" within the transaction and then commits it, or rolls back on failure
+func runTransaction(ctx context.Context, tx *sql.Tx, driver string, do func(tx *sql.Tx) error) error {
+	defer trackTransaction(ctx, tx, driver)()
 
 	if err := do(tx); err != nil {
-		logger.Fatalf("failed during DB session: %v", err)
+		return rollback(tx, fmt.Errorf("failed during DB session: %w", err))
 	}
 
 	if err := tx.Commit(); err != nil {
-		logger.Fatalf("failed to commit ")
+		return rollback(tx, fmt.Errorf("failed to commit: %w", err))
+	}
+	return nil
+}
+
+// rollback rolls back the transaction after the given error, and returns the error combined with any rollback error
+func rollback(tx *sql.Tx, cause error) error {
+	if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return fmt.Errorf("%w (failed to rollback: %v)", cause, err)
 	}
+	return cause
 }
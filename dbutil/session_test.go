@@ -0,0 +1,60 @@
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/dbutil/dbutiltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSessionE(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+
+	err := dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE orders SET state = @p1", "done")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.Commits())
+	assert.Equal(t, 0, fake.Rollbacks())
+
+	err = dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		return errors.New("invalid order")
+	})
+	assert.EqualError(t, err, "failed during DB session: invalid order")
+	assert.Equal(t, 1, fake.Commits())
+	assert.Equal(t, 1, fake.Rollbacks())
+
+	fake.FailOn(`^DELETE`, errors.New("table locked"))
+	err = dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM orders")
+		return err
+	})
+	assert.EqualError(t, err, "failed during DB session: table locked")
+	assert.Equal(t, 2, fake.Rollbacks())
+}
+
+func TestRunSessionCtxE(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := dbutil.RunSessionCtxE(ctx, dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled, "commit should fail after cancellation")
+	assert.Equal(t, 0, fake.Commits())
+
+	err = dbutil.RunSessionCtxE(ctx, dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		t.Error("session should not start after cancellation")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, fake.Commits())
+}
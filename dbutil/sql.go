@@ -1,13 +1,22 @@
 package dbutil
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 // ExecOne executes a query within the given transaction and returns the number of affected rows
 func ExecOne(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
-	result, execErr := tx.Exec(query, args...)
+	return ExecOneCtx(context.Background(), tx, query, args...)
+}
+
+// ExecOneCtx executes a query like ExecOne, with the context for deadline and cancellation
+func ExecOneCtx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (count int64, err error) {
+	ctx, span := StartStatementSpan(ctx, tx, "dbutil.ExecOne", query)
+	defer func() { EndSpan(span, err) }()
+
+	result, execErr := tx.ExecContext(ctx, query, args...)
 	if execErr != nil {
 		return 0, fmt.Errorf("failed to execute: %w", execErr)
 	}
@@ -0,0 +1,37 @@
+package dbutil_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/dbutil"
+	"github.com/relex/gotils/dbutil/dbutiltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecOneCtx(t *testing.T) {
+	fake := dbutiltest.NewFake()
+	defer fake.Close()
+	fake.OnExec(`^UPDATE orders`, 2)
+	fake.BlockOn(`^UPDATE stock`)
+
+	err := dbutil.RunSessionE(dbutiltest.DriverName, fake.DSN(), func(tx *sql.Tx) error {
+		count, err := dbutil.ExecOneCtx(context.Background(), tx, "UPDATE orders SET state = @p1", "done")
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, err = dbutil.ExecOneCtx(ctx, tx, "UPDATE stock SET count = count - 1")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "failed to execute: ")
+
+		_, err = dbutil.ExecOneCtx(ctx, tx, "UPDATE orders SET state = @p1", "cancelled")
+		assert.ErrorIs(t, err, context.Canceled, "no statement after cancellation")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []dbutiltest.Statement{{Query: "UPDATE orders SET state = @p1", Args: []interface{}{"done"}}}, fake.Statements())
+}
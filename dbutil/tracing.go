@@ -0,0 +1,132 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/relex/gotils/dbutil"
+
+var (
+	noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+	tracer     atomic.Pointer[trace.Tracer] // set by EnableTracing, or noopTracer if nil
+
+	// txTraces keeps the parent context and DB system of active transactions, for spans of statements inside
+	txTraces sync.Map // *sql.Tx => txTrace
+
+	// literalOrCommentPattern matches string literals and comments from left to right, so that quotes in comments and
+	// comment markers in strings are not mistaken
+	literalOrCommentPattern = regexp.MustCompile(`'(?:[^']|'')*'|--[^\n]*|(?s:/\*.*?\*/)`)
+	numberLiteralPattern    = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	dbSystemByDriverNames   = map[string]string{
+		"sqlserver": "mssql",
+		"mssql":     "mssql",
+		"azuresql":  "mssql",
+		"postgres":  "postgresql",
+		"pgx":       "postgresql",
+		"mysql":     "mysql",
+		"sqlite3":   "sqlite",
+	}
+)
+
+type txTrace struct {
+	ctx      context.Context
+	dbSystem string
+}
+
+// EnableTracing enables OpenTelemetry spans for sessions, statements and bulk operations in dbutil and sub-packages
+//
+// Tracing is disabled by default. Statements in spans have string and number literals replaced by '?' and comments
+// removed. It's safe to be called while statements are being executed.
+func EnableTracing(provider trace.TracerProvider) {
+	t := provider.Tracer(tracerName)
+	tracer.Store(&t)
+}
+
+func getTracer() trace.Tracer {
+	if t := tracer.Load(); t != nil {
+		return *t
+	}
+	return noopTracer
+}
+
+// StartStatementSpan starts a span for a statement or operation within the transaction
+//
+// The span is a child of the span in ctx if any, or else the session span if the transaction is created by RunSession.
+// The returned context carries the new span along with the deadline and cancellation of ctx.
+//
+// It's meant for sub-packages like mssqlutil and the span must be ended by EndSpan.
+func StartStatementSpan(ctx context.Context, tx *sql.Tx, name string, statement string) (context.Context, trace.Span) {
+	parent := txTrace{ctx, ""}
+	if t, ok := txTraces.Load(tx); ok {
+		parent.dbSystem = t.(txTrace).dbSystem
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			parent.ctx = t.(txTrace).ctx
+		}
+	}
+
+	attrs := make([]attribute.KeyValue, 0, 2)
+	if parent.dbSystem != "" {
+		attrs = append(attrs, attribute.String("db.system", parent.dbSystem))
+	}
+	if statement != "" {
+		attrs = append(attrs, attribute.String("db.statement", redactStatement(statement)))
+	}
+	_, span := getTracer().Start(parent.ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return trace.ContextWithSpan(ctx, span), instrumentStatement(ctx, span, name, statement)
+}
+
+// EndSpan ends the span and records the error if not nil
+//
+// Spans from StartStatementSpan are also logged if slow and recorded in metrics, see EnableSlowStatementLogging and
+// EnableMetrics.
+func EndSpan(span trace.Span, err error) {
+	if stmt, ok := span.(*statementSpan); ok {
+		stmt.finish(err)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startSessionSpan starts the span of a DB session
+func startSessionSpan(ctx context.Context, driver string) (context.Context, trace.Span) {
+	return getTracer().Start(ctx, "dbutil.RunSession", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", getDBSystem(driver))))
+}
+
+// trackTransaction associates the transaction with the session span for child spans, until the returned func is called
+func trackTransaction(ctx context.Context, tx *sql.Tx, driver string) func() {
+	txTraces.Store(tx, txTrace{ctx, getDBSystem(driver)})
+	return func() { txTraces.Delete(tx) }
+}
+
+func getDBSystem(driver string) string {
+	if system, ok := dbSystemByDriverNames[driver]; ok {
+		return system
+	}
+	return driver
+}
+
+// redactStatement replaces string and number literals in SQL statement by '?' and removes comments, to avoid leaking
+// data to traces
+func redactStatement(statement string) string {
+	redacted := literalOrCommentPattern.ReplaceAllStringFunc(statement, func(match string) string {
+		if strings.HasPrefix(match, "'") {
+			return "'?'"
+		}
+		return ""
+	})
+	return strings.TrimSpace(numberLiteralPattern.ReplaceAllLiteralString(redacted, "?"))
+}
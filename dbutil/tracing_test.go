@@ -0,0 +1,139 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRedactStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		expected  string
+	}{
+		{"strings", "SELECT * FROM users WHERE name = 'Alice' AND city = 'O''Brien'", "SELECT * FROM users WHERE name = '?' AND city = '?'"},
+		{"numbers", "UPDATE t1 SET price = 12.5 WHERE id IN (1, 23)", "UPDATE t1 SET price = ? WHERE id IN (?, ?)"},
+		{"line comment", "SELECT 1 -- customer 42\nFROM dual", "SELECT ? \nFROM dual"},
+		{"block comment", "SELECT /* user 'bob' */ id FROM users", "SELECT  id FROM users"},
+		{"quote in comment", "-- don't\nSELECT 'x'", "SELECT '?'"},
+		{"comment marker in string", "SELECT '--secret', '/*' FROM t", "SELECT '?', '?' FROM t"},
+		{"parameters", "SELECT * FROM orders WHERE id = @p1", "SELECT * FROM orders WHERE id = @p1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, redactStatement(tt.statement))
+		})
+	}
+}
+
+func TestStatementSpans(t *testing.T) {
+	recorder := &spanRecorder{}
+	EnableTracing(recorder)
+	defer tracer.Store(nil)
+
+	ctx, sessionSpan := startSessionSpan(context.Background(), "sqlserver")
+	tx := &sql.Tx{}
+	untrack := trackTransaction(ctx, tx, "sqlserver")
+	_, span := StartStatementSpan(context.Background(), tx, "dbutil.Select", "SELECT name FROM users WHERE id = 42")
+	EndSpan(span, nil)
+	_, span = StartStatementSpan(context.Background(), tx, "dbutil.Exec", "DELETE FROM users")
+	EndSpan(span, errors.New("locked"))
+	untrack()
+	EndSpan(sessionSpan, nil)
+
+	spans := recorder.ended
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	selectSpan, execSpan, session := spans[0], spans[1], spans[2]
+	assert.Equal(t, "dbutil.RunSession", session.name)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("db.system", "mssql")}, session.attributes)
+
+	assert.Equal(t, "dbutil.Select", selectSpan.name)
+	assert.Equal(t, trace.SpanKindClient, selectSpan.kind)
+	assert.Equal(t, session.spanContext.SpanID(), selectSpan.parent.SpanID(), "statement should be child of session")
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("db.system", "mssql"),
+		attribute.String("db.statement", "SELECT name FROM users WHERE id = ?"),
+	}, selectSpan.attributes)
+	assert.Equal(t, codes.Unset, selectSpan.statusCode)
+
+	assert.Equal(t, codes.Error, execSpan.statusCode)
+	assert.Equal(t, "locked", execSpan.statusDescription)
+	assert.Equal(t, []error{errors.New("locked")}, execSpan.errors)
+}
+
+// spanRecorder is a minimal TracerProvider recording ended spans, in place of the OpenTelemetry SDK
+type spanRecorder struct {
+	noop.TracerProvider
+	lastID uint64
+	ended  []*recordedSpan
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{recorder: r}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	recorder *spanRecorder
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	parent := trace.SpanContextFromContext(ctx)
+	traceID := parent.TraceID()
+	if !traceID.IsValid() {
+		traceID = trace.TraceID{1}
+	}
+	t.recorder.lastID++
+	var spanID trace.SpanID
+	spanID[7] = byte(t.recorder.lastID)
+	span := &recordedSpan{
+		recorder:    t.recorder,
+		name:        name,
+		kind:        config.SpanKind(),
+		attributes:  config.Attributes(),
+		parent:      parent,
+		spanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}),
+	}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordedSpan struct {
+	noop.Span
+	recorder          *spanRecorder
+	name              string
+	kind              trace.SpanKind
+	attributes        []attribute.KeyValue
+	parent            trace.SpanContext
+	spanContext       trace.SpanContext
+	statusCode        codes.Code
+	statusDescription string
+	errors            []error
+}
+
+func (s *recordedSpan) SpanContext() trace.SpanContext { return s.spanContext }
+
+func (s *recordedSpan) IsRecording() bool { return true }
+
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {
+	s.statusCode = code
+	s.statusDescription = description
+}
+
+func (s *recordedSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.recorder.ended = append(s.recorder.ended, s)
+}
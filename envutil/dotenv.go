@@ -0,0 +1,90 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package envutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadDotEnv sets environment variables from a .env file of "NAME=value" lines, without overriding existing ones
+//
+// Empty lines and lines starting with '#' are skipped. Lines may start with "export ". Values may be quoted in single
+// quotes for literal text or double quotes for Go escape sequences such as "\n". Unquoted values end at " #".
+func LoadDotEnv(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open .env file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		name, value, ok, err := parseDotEnvLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("failed to parse .env file '%s' at line %d: %w", path, lineNum, err)
+		}
+		if !ok {
+			continue
+		}
+		if _, exists := os.LookupEnv(name); exists {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read .env file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// parseDotEnvLine parses a line of .env file, returns false if it's empty or a comment
+func parseDotEnvLine(line string) (string, string, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, nil
+	}
+	line = strings.TrimPrefix(line, "export ")
+	name, value, found := strings.Cut(line, "=")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", false, fmt.Errorf("expected NAME=value")
+	}
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, `"`):
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return "", "", false, fmt.Errorf("invalid double-quoted value of %s: %w", name, err)
+		}
+		unquoted, _ := strconv.Unquote(quoted) // never fails for valid prefix
+		return name, unquoted, true, nil
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated single-quoted value of %s", name)
+		}
+		return name, value[1 : end+1], true, nil
+	default:
+		if comment := strings.Index(value, " #"); comment >= 0 {
+			value = strings.TrimSpace(value[:comment])
+		}
+		return name, value, true, nil
+	}
+}
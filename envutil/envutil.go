@@ -0,0 +1,140 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package envutil provides typed parsing of environment variables with aggregated errors for startup checks
+package envutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Value is the constraint of types parseable from environment variables
+type Value interface {
+	string | bool | int | int64 | uint | uint64 | float64 | time.Duration
+}
+
+var (
+	errorsLock sync.Mutex
+	errorList  []error // errors recorded by Get and Require, see Check
+)
+
+// Lookup parses the environment variable into the given type, and returns whether it's set and non-empty
+//
+// Booleans accept "1", "true", "y", "yes", "on" and "0", "false", "n", "no", "off" case-insensitively. Durations
+// are parsed by time.ParseDuration, e.g. "1m30s".
+func Lookup[T Value](name string) (T, bool, error) {
+	var value T
+	text := strings.TrimSpace(os.Getenv(name))
+	if text == "" {
+		return value, false, nil
+	}
+	if err := parseValue(text, &value); err != nil {
+		return value, true, fmt.Errorf("invalid %s value '%s': %w", name, text, err)
+	}
+	return value, true, nil
+}
+
+// Get parses the environment variable into the type of defaultValue, or returns defaultValue if it's not set
+//
+// Invalid values are recorded as errors for Check, and defaultValue is returned.
+func Get[T Value](name string, defaultValue T) T {
+	value, found, err := Lookup[T](name)
+	if err != nil {
+		recordError(err)
+		return defaultValue
+	}
+	if !found {
+		return defaultValue
+	}
+	return value
+}
+
+// Require parses the environment variable which must be set into the given type
+//
+// Missing or invalid values are recorded as errors for Check, and the zero value is returned.
+func Require[T Value](name string) T {
+	value, found, err := Lookup[T](name)
+	if err != nil {
+		recordError(err)
+	} else if !found {
+		recordError(fmt.Errorf("missing required %s", name))
+	}
+	return value
+}
+
+// Check returns all errors recorded by Get and Require so far in one error, or nil if there is none
+//
+// It's meant to be called once all variables are read during startup, to report all problems at once, e.g.:
+//
+//	port := envutil.Require[int]("PORT")
+//	timeout := envutil.Get("TIMEOUT", 10*time.Second)
+//	if err := envutil.Check(); err != nil {
+//		logger.Fatal(err)
+//	}
+func Check() error {
+	errorsLock.Lock()
+	defer errorsLock.Unlock()
+	if len(errorList) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to parse environment variables: %w", errors.Join(errorList...))
+}
+
+func recordError(err error) {
+	errorsLock.Lock()
+	defer errorsLock.Unlock()
+	errorList = append(errorList, err)
+}
+
+func parseValue(text string, ptr interface{}) error {
+	var err error
+	switch p := ptr.(type) {
+	case *string:
+		*p = text
+	case *bool:
+		*p, err = parseBool(text)
+	case *int:
+		*p, err = strconv.Atoi(text)
+	case *int64:
+		*p, err = strconv.ParseInt(text, 10, 64)
+	case *uint:
+		var parsed uint64
+		parsed, err = strconv.ParseUint(text, 10, strconv.IntSize)
+		*p = uint(parsed)
+	case *uint64:
+		*p, err = strconv.ParseUint(text, 10, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(text, 64)
+	case *time.Duration:
+		*p, err = time.ParseDuration(text)
+	default:
+		err = fmt.Errorf("unsupported type %T", ptr)
+	}
+	return err
+}
+
+func parseBool(text string) (bool, error) {
+	switch strings.ToLower(text) {
+	case "1", "true", "y", "yes", "on":
+		return true, nil
+	case "0", "false", "n", "no", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("not a boolean")
+	}
+}
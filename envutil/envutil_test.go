@@ -0,0 +1,80 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package envutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAndRequire(t *testing.T) {
+	errorList = nil
+	defer func() { errorList = nil }()
+	t.Setenv("TEST_ENVUTIL_INT", " 42 ")
+	t.Setenv("TEST_ENVUTIL_BOOL", "Yes")
+	t.Setenv("TEST_ENVUTIL_DURATION", "1m30s")
+	t.Setenv("TEST_ENVUTIL_INVALID", "abc")
+
+	assert.Equal(t, 42, Get("TEST_ENVUTIL_INT", 1))
+	assert.Equal(t, uint64(42), Require[uint64]("TEST_ENVUTIL_INT"))
+	assert.Equal(t, true, Get("TEST_ENVUTIL_BOOL", false))
+	assert.Equal(t, 90*time.Second, Get("TEST_ENVUTIL_DURATION", time.Second))
+	assert.Equal(t, "default", Get("TEST_ENVUTIL_MISSING", "default"))
+	assert.NoError(t, Check())
+
+	value, found, err := Lookup[float64]("TEST_ENVUTIL_INVALID")
+	assert.Equal(t, 0.0, value)
+	assert.True(t, found)
+	assert.EqualError(t, err, `invalid TEST_ENVUTIL_INVALID value 'abc': strconv.ParseFloat: parsing "abc": invalid syntax`)
+	assert.NoError(t, Check(), "Lookup should not record errors")
+
+	assert.Equal(t, 5, Get("TEST_ENVUTIL_INVALID", 5))
+	assert.Equal(t, "", Require[string]("TEST_ENVUTIL_MISSING"))
+	assert.EqualError(t, Check(), "failed to parse environment variables: "+
+		`invalid TEST_ENVUTIL_INVALID value 'abc': strconv.Atoi: parsing "abc": invalid syntax`+"\n"+
+		"missing required TEST_ENVUTIL_MISSING")
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+# comment
+TEST_DOTENV_PLAIN=hello world # trailing comment
+export TEST_DOTENV_EXPORTED = 1
+TEST_DOTENV_DOUBLE="line1\nline2 # not comment"
+TEST_DOTENV_SINGLE='$HOME\n'
+TEST_DOTENV_EMPTY=
+TEST_DOTENV_EXISTING=new
+`), 0644))
+	t.Setenv("TEST_DOTENV_EXISTING", "old")
+	for _, name := range []string{"TEST_DOTENV_PLAIN", "TEST_DOTENV_EXPORTED", "TEST_DOTENV_DOUBLE", "TEST_DOTENV_SINGLE", "TEST_DOTENV_EMPTY"} {
+		t.Setenv(name, "") // restore after test
+		os.Unsetenv(name)
+	}
+
+	assert.NoError(t, LoadDotEnv(path))
+	assert.Equal(t, "hello world", os.Getenv("TEST_DOTENV_PLAIN"))
+	assert.Equal(t, "1", os.Getenv("TEST_DOTENV_EXPORTED"))
+	assert.Equal(t, "line1\nline2 # not comment", os.Getenv("TEST_DOTENV_DOUBLE"))
+	assert.Equal(t, `$HOME\n`, os.Getenv("TEST_DOTENV_SINGLE"))
+	_, emptySet := os.LookupEnv("TEST_DOTENV_EMPTY")
+	assert.True(t, emptySet)
+	assert.Equal(t, "old", os.Getenv("TEST_DOTENV_EXISTING"))
+
+	assert.NoError(t, os.WriteFile(path, []byte("TEST_DOTENV_OK=1\nTEST_DOTENV_BAD='abc\n"), 0644))
+	assert.EqualError(t, LoadDotEnv(path), "failed to parse .env file '"+path+"' at line 2: unterminated single-quoted value of TEST_DOTENV_BAD")
+}
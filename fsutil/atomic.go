@@ -0,0 +1,119 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package fsutil provides helpers of files and directories, such as atomic writes, copying and pruning
+package fsutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AtomicWriteOptions configures AtomicWriteFile and CopyFile
+type AtomicWriteOptions struct {
+	Mode      os.FileMode // permission of the file, 0644 if zero (or the source permission for CopyFile)
+	CreateDir bool        // create missing parent directories with permission 0755
+}
+
+// AtomicWriteFile writes file contents to specified path atomically, by writing to a temporary file in the same
+// directory, syncing and then renaming it
+//
+// Readers never see partially written contents, and the previous file is kept if any error occurs. The parent
+// directory is synced after renaming, so that the new file survives crashes.
+func AtomicWriteFile(path string, contents []byte, opts AtomicWriteOptions) error {
+	return writeAtomically(path, opts, func(file *os.File) error {
+		_, err := file.Write(contents)
+		return err
+	})
+}
+
+// SyncDir flushes the directory entries to disk, e.g. to persist newly created or renamed files
+//
+// It's no-op on Windows, where directories can't be synced.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open dir: %w", err)
+	}
+	defer file.Close()
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dir: %w", err)
+	}
+	return nil
+}
+
+// writeAtomically writes a temporary file by the write function and then moves it to the path
+func writeAtomically(path string, opts AtomicWriteOptions, write func(file *os.File) error) error {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	dir := filepath.Dir(path)
+	if opts.CreateDir {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create dir: %w", err)
+		}
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath) // no-op after successful rename
+
+	if err := write(tmpFile); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return SyncDir(dir)
+}
+
+// CopyFile copies the file at src to dst atomically like AtomicWriteFile, with the permission of src by default
+func CopyFile(src string, dst string, opts AtomicWriteOptions) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	if opts.Mode == 0 {
+		info, err := srcFile.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat source file: %w", err)
+		}
+		opts.Mode = info.Mode().Perm()
+	}
+	return writeAtomically(dst, opts, func(file *os.File) error {
+		_, err := io.Copy(file, srcFile)
+		return err
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package fsutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// PruneOptions defines which files are removed by Prune. Zero fields are unlimited.
+type PruneOptions struct {
+	MaxAge       time.Duration // remove files modified longer ago than this
+	MaxFiles     int           // remove the oldest files until the number of files is within this
+	MaxTotalSize int64         // remove the oldest files until the total size is within this
+}
+
+// EnsureDir creates the directory and missing parents if not existing, and sets its permission to perm regardless
+// of umask
+func EnsureDir(dir string, perm os.FileMode) error {
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return fmt.Errorf("failed to create dir: %w", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to stat dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("failed to create dir: '%s' is not a directory", dir)
+	}
+	if info.Mode().Perm() != perm.Perm() {
+		if err := os.Chmod(dir, perm); err != nil {
+			return fmt.Errorf("failed to chmod dir: %w", err)
+		}
+	}
+	return nil
+}
+
+// DirSize returns the total size of regular files under the directory, recursively
+func DirSize(dir string) (int64, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, file := range files {
+		size += file.size
+	}
+	return size, nil
+}
+
+// Prune removes regular files under the directory recursively, starting from the oldest by modification time, until
+// all limits in options are satisfied. Directories are kept.
+//
+// Returns the paths of removed files
+func Prune(dir string, opts PruneOptions) ([]string, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.size
+	}
+	minModTime := time.Now().Add(-opts.MaxAge)
+
+	var removed []string
+	for i, file := range files {
+		remaining := len(files) - i
+		expired := opts.MaxAge > 0 && file.modTime.Before(minModTime)
+		tooMany := opts.MaxFiles > 0 && remaining > opts.MaxFiles
+		tooLarge := opts.MaxTotalSize > 0 && totalSize > opts.MaxTotalSize
+		if !expired && !tooMany && !tooLarge {
+			break // the rest are newer
+		}
+		if err := os.Remove(file.path); err != nil {
+			return removed, fmt.Errorf("failed to remove file: %w", err)
+		}
+		removed = append(removed, file.path)
+		totalSize -= file.size
+	}
+	return removed, nil
+}
+
+type fileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func listFiles(dir string) ([]fileInfo, error) {
+	var files []fileInfo
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, fileInfo{path, info.Size(), info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicWriteFileAndCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "a.txt")
+	assert.Error(t, AtomicWriteFile(path, []byte("hello"), AtomicWriteOptions{}))
+	assert.NoError(t, AtomicWriteFile(path, []byte("hello"), AtomicWriteOptions{Mode: 0600, CreateDir: true}))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	copyPath := filepath.Join(dir, "b.txt")
+	assert.NoError(t, CopyFile(path, copyPath, AtomicWriteOptions{}))
+	data, err = os.ReadFile(copyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	info, err = os.Stat(copyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "permission should be copied")
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2, "no temporary file should be left")
+}
+
+func TestEnsureDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	assert.NoError(t, EnsureDir(dir, 0700))
+	assert.NoError(t, os.Chmod(dir, 0755))
+	assert.NoError(t, EnsureDir(dir, 0700))
+	info, err := os.Stat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0644))
+	assert.Error(t, EnsureDir(file, 0700))
+}
+
+func TestDirSizeAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeFileWithAge := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+		assert.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		return path
+	}
+	oldest := writeFileWithAge("oldest", 100, 3*time.Hour)
+	older := writeFileWithAge("sub/older", 200, 2*time.Hour)
+	writeFileWithAge("newer", 300, time.Hour)
+	writeFileWithAge("sub/newest", 400, time.Minute)
+
+	size, err := DirSize(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), size)
+
+	removed, err := Prune(dir, PruneOptions{MaxAge: 150 * time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{oldest}, removed)
+
+	removed, err = Prune(dir, PruneOptions{MaxFiles: 3, MaxTotalSize: 800})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{older}, removed)
+
+	removed, err = Prune(dir, PruneOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+	size, err = DirSize(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(700), size)
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// LivenessHandler serves the liveness report of the default Registry, see Registry.LivenessHandler
+func LivenessHandler() http.Handler {
+	return defaultRegistry.LivenessHandler()
+}
+
+// ReadinessHandler serves the readiness report of the default Registry, see Registry.ReadinessHandler
+func ReadinessHandler() http.Handler {
+	return defaultRegistry.ReadinessHandler()
+}
+
+// LivenessHandler serves the liveness report in JSON for "/healthz", with status 503 if any non-optional check for
+// liveness fails
+func (r *Registry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.CheckLiveness(req.Context()))
+	})
+}
+
+// ReadinessHandler serves the readiness report in JSON for "/readyz", with status 503 if any non-optional check fails
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.CheckReadiness(req.Context()))
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusFailing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report) // nothing to do if the client is gone
+}
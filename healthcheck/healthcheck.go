@@ -0,0 +1,264 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck aggregates health checks of components for liveness and readiness probes
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// DefaultTimeout is the default timeout of each check
+const DefaultTimeout = 5 * time.Second
+
+// Status is the result of a check or a report
+type Status string
+
+// Statuses of checks and reports
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded" // only optional checks failed
+	StatusFailing  Status = "failing"
+)
+
+// Options configures a check
+type Options struct {
+	Timeout  time.Duration // max time of the check, default 5 seconds
+	Optional bool          // failure of optional checks doesn't fail the report, only degrades it
+	Liveness bool          // include the check in liveness probe, for failures which can only be fixed by restart
+}
+
+// CheckResult is the result of a check in Report
+type CheckResult struct {
+	Status   Status        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Optional bool          `json:"optional,omitempty"`
+}
+
+// Report is the aggregated result of checks
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Registry keeps named checks of components
+type Registry struct {
+	lock         sync.Mutex
+	checks       map[string]*check
+	logger       logger.Logger
+	statusGauges *promext.RWGaugeVec
+}
+
+type check struct {
+	name    string
+	fn      func(ctx context.Context) error
+	options Options
+	failing bool // whether the last run failed, to log changes only
+}
+
+var defaultRegistry = newDefaultRegistry()
+
+// NewRegistry creates an empty Registry
+//
+// The status of checks is exported as the metric "healthcheck_status" from the given creator, or not exported if the
+// creator is nil. Registries sharing a creator need different check names.
+func NewRegistry(creator promreg.MetricCreator) *Registry {
+	if creator == nil {
+		creator = promreg.NewMetricFactory("", nil, nil)
+	}
+	return &Registry{
+		checks:       make(map[string]*check),
+		logger:       logger.WithField("component", "HealthCheck"),
+		statusGauges: creator.AddOrGetGaugeVec("healthcheck_status", "Results of health checks by name, 1 for ok and 0 for failing", []string{"check"}, nil),
+	}
+}
+
+// newDefaultRegistry creates the default Registry, exporting its metrics from the default Prometheus registry
+func newDefaultRegistry() *Registry {
+	factory := promreg.NewMetricFactory("", nil, nil)
+	promext.SafeRegister(factory)
+	return NewRegistry(factory)
+}
+
+// Default returns the default Registry used by package-level functions, with metrics in the default Prometheus registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a check to the default Registry, see Registry.Register
+func Register(name string, fn func() error, options ...Options) {
+	defaultRegistry.Register(name, fn, options...)
+}
+
+// RegisterCtx adds a context-aware check to the default Registry, see Registry.RegisterCtx
+func RegisterCtx(name string, fn func(ctx context.Context) error, options ...Options) {
+	defaultRegistry.RegisterCtx(name, fn, options...)
+}
+
+// Unregister removes a check from the default Registry
+func Unregister(name string) {
+	defaultRegistry.Unregister(name)
+}
+
+// Register adds a check which returns error on failure, e.g. the HealthCheck of redis Cache
+//
+// The check is abandoned on timeout and should not block forever. Registering the same name again replaces the check.
+func (r *Registry) Register(name string, fn func() error, options ...Options) {
+	r.RegisterCtx(name, func(ctx context.Context) error {
+		return fn()
+	}, options...)
+}
+
+// RegisterCtx adds a check which receives a context cancelled on timeout
+func (r *Registry) RegisterCtx(name string, fn func(ctx context.Context) error, options ...Options) {
+	var opts Options
+	switch len(options) {
+	case 0:
+	case 1:
+		opts = options[0]
+	default:
+		r.logger.Panicf("too many options for check '%s'", name)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.checks[name] = &check{name: name, fn: fn, options: opts}
+}
+
+// Unregister removes a check
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.checks, name)
+	r.statusGauges.DeleteLabelValues(name)
+}
+
+// CheckReadiness runs all checks in parallel and aggregates the results
+func (r *Registry) CheckReadiness(ctx context.Context) Report {
+	return r.run(ctx, false)
+}
+
+// CheckLiveness runs checks marked for liveness in parallel and aggregates the results
+func (r *Registry) CheckLiveness(ctx context.Context) Report {
+	return r.run(ctx, true)
+}
+
+func (r *Registry) run(ctx context.Context, livenessOnly bool) Report {
+	r.lock.Lock()
+	checks := make([]*check, 0, len(r.checks))
+	for _, c := range r.checks {
+		if !livenessOnly || c.options.Liveness {
+			checks = append(checks, c)
+		}
+	}
+	r.lock.Unlock()
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+
+	results := make([]CheckResult, len(checks))
+	wg := &sync.WaitGroup{}
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		result := results[i]
+		report.Checks[c.name] = result
+		r.recordResult(c, result)
+		if result.Status == StatusOK {
+			continue
+		}
+		if !c.options.Optional {
+			report.Status = StatusFailing
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// recordResult updates the status gauge and logs changes of status
+func (r *Registry) recordResult(c *check, result CheckResult) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.checks[c.name] != c {
+		return // unregistered or replaced during the check
+	}
+	failing := result.Status != StatusOK
+	if failing {
+		r.statusGauges.WithLabelValues(c.name).Set(0)
+	} else {
+		r.statusGauges.WithLabelValues(c.name).Set(1)
+	}
+	if failing != c.failing {
+		if failing {
+			r.logger.WithField("check", c.name).Warnf("check failed: %s", result.Error)
+		} else {
+			r.logger.WithField("check", c.name).Info("check recovered")
+		}
+		c.failing = failing
+	}
+}
+
+// run runs the check with timeout, recovering from panics
+func (c *check) run(parentCtx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(parentCtx, c.options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("panic: %v", rec)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if parentErr := parentCtx.Err(); parentErr != nil {
+			err = parentErr // cancelled by caller, e.g. the probe request is aborted
+		} else {
+			err = fmt.Errorf("timed out after %s", c.options.Timeout)
+		}
+	}
+
+	result := CheckResult{Status: StatusOK, Duration: time.Since(start), Optional: c.options.Optional}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	factory := promreg.NewMetricFactory("", nil, nil)
+	registry := NewRegistry(factory)
+	redisErr := error(nil)
+	registry.Register("redis", func() error { return redisErr })
+	registry.Register("search", func() error { return errors.New("unreachable") }, Options{Optional: true})
+	registry.RegisterCtx("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, Options{Timeout: 10 * time.Millisecond, Optional: true})
+	registry.Register("deadlock", func() error { panic("stuck") }, Options{Liveness: true, Optional: true})
+
+	report := registry.CheckReadiness(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["redis"].Status)
+	assert.Equal(t, "unreachable", report.Checks["search"].Error)
+	assert.Equal(t, "timed out after 10ms", report.Checks["slow"].Error)
+	assert.Equal(t, "panic: stuck", report.Checks["deadlock"].Error)
+
+	liveness := registry.CheckLiveness(context.Background())
+	assert.Len(t, liveness.Checks, 1)
+	assert.Equal(t, StatusDegraded, liveness.Status)
+
+	redisErr = errors.New("connection refused")
+	recorder := httptest.NewRecorder()
+	registry.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	parsed := Report{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &parsed))
+	assert.Equal(t, StatusFailing, parsed.Status)
+	assert.Equal(t, "connection refused", parsed.Checks["redis"].Error)
+
+	recorder = httptest.NewRecorder()
+	registry.LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	assert.Equal(t, `healthcheck_status{check="deadlock"} 0
+healthcheck_status{check="redis"} 0
+healthcheck_status{check="search"} 0
+healthcheck_status{check="slow"} 0
+`, promext.DumpMetricsFrom("healthcheck_status", true, false, factory))
+	registry.Unregister("slow")
+	assert.NotContains(t, promext.DumpMetricsFrom("healthcheck_status", true, false, factory), "slow")
+
+	Register("default-only", func() error { return nil })
+	defer Unregister("default-only")
+	Default().CheckReadiness(context.Background())
+	assert.Contains(t, promext.DumpMetrics("healthcheck_status", true, false), `healthcheck_status{check="default-only"} 1`)
+	assert.NotContains(t, promext.DumpMetricsFrom("healthcheck_status", true, false, factory), "default-only",
+		"registries shouldn't share metrics")
+}
+
+func TestCheckCancelledByCaller(t *testing.T) {
+	registry := NewRegistry(nil)
+	registry.RegisterCtx("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, Options{Timeout: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	report := registry.CheckReadiness(ctx)
+	assert.Equal(t, StatusFailing, report.Status)
+	assert.Equal(t, "context canceled", report.Checks["slow"].Error)
+}
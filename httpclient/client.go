@@ -0,0 +1,116 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient builds instrumented HTTP clients with retry, timeouts, connection limits, metrics and logging
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/relex/gotils/logger"
+)
+
+// Defaults of Options
+const (
+	DefaultTimeout             = 30 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultMaxIdleConnsPerHost = 10
+)
+
+// Options configures clients created by New
+type Options struct {
+	Name                  string                                // name of client for logging and the "client" label of metrics
+	Timeout               time.Duration                         // max time of a request including retries and reading body, default 30 seconds
+	DialTimeout           time.Duration                         // max time to establish connections, default 10 seconds
+	TLSHandshakeTimeout   time.Duration                         // max time of TLS handshakes, default 10 seconds
+	ResponseHeaderTimeout time.Duration                         // max wait for response headers after sending request, zero for none
+	IdleConnTimeout       time.Duration                         // max idle time of keep-alive connections, default 90 seconds
+	MaxConnsPerHost       int                                   // max connections per host including active ones, zero for unlimited
+	MaxIdleConnsPerHost   int                                   // max idle connections per host, default 10
+	Proxy                 func(*http.Request) (*url.URL, error) // proxy selector, default to http.ProxyFromEnvironment
+	TLSConfig             *tls.Config                           // TLS settings, e.g. client certificates or custom CA, optional
+	Retry                 RetryPolicy                           // retry policy of failed requests, default no retry
+	MetricLabel           func(req *http.Request) string        // value of the "host" label of metrics, default to LabelByHost
+}
+
+// New creates an HTTP client with the given options
+//
+// Requests and retries are counted in metrics labeled by the client name, host (or Options.MetricLabel), method and
+// status code:
+//
+//   - httpclient_requests_total
+//   - httpclient_request_duration_milliseconds_total
+//   - httpclient_retries_total
+func New(opts Options) *http.Client {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{
+		Transport: NewTransport(opts),
+		Timeout:   timeout,
+	}
+}
+
+// NewTransport creates the http.RoundTripper of clients created by New, for use in other clients
+//
+// The Timeout in options is not applied since it's enforced by http.Client.
+func NewTransport(opts Options) http.RoundTripper {
+	if opts.Name == "" {
+		opts.Name = "default"
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	if opts.TLSHandshakeTimeout <= 0 {
+		opts.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if opts.Proxy == nil {
+		opts.Proxy = http.ProxyFromEnvironment
+	}
+	if opts.MetricLabel == nil {
+		opts.MetricLabel = LabelByHost
+	}
+
+	base := &http.Transport{
+		Proxy:                 opts.Proxy,
+		DialContext:           (&net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       opts.TLSConfig,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &instrumentedTransport{
+		base:        base,
+		name:        opts.Name,
+		retry:       opts.Retry,
+		metricLabel: opts.MetricLabel,
+		logger:      logger.WithField("component", "HTTPClient").WithField("client", opts.Name),
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "hello", string(body))
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := New(Options{
+		Name:  "TestClientRetry",
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+	})
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("hello"))
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+	}
+	assert.Equal(t, int32(3), calls.Load())
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	assert.Equal(t, `httpclient_requests_total{client="TestClientRetry",code="200",host="`+host+`",method="POST"} 1
+httpclient_requests_total{client="TestClientRetry",code="503",host="`+host+`",method="POST"} 2
+`, promext.DumpMetrics("httpclient_requests_total", true, false))
+	assert.Equal(t, `httpclient_retries_total{client="TestClientRetry",host="`+host+`"} 2
+`, promext.DumpMetrics("httpclient_retries_total", true, false))
+
+	calls.Store(0)
+	resp, err = New(Options{Name: "TestClientNoRetry"}).Post(server.URL, "text/plain", strings.NewReader("hello"))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClientRetryAbortClosesBodyOnce(t *testing.T) {
+	var bodies []*closeCountingBody
+	transport := NewTransport(Options{
+		Name:  "TestClientRetryAbort",
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour},
+	}).(*instrumentedTransport)
+	transport.base = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := &closeCountingBody{Reader: strings.NewReader("busy")}
+		bodies = append(bodies, body)
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: body}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.invalid/", nil)
+	resp, err := transport.RoundTrip(req)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, context.Canceled)
+	if assert.Len(t, bodies, 1) {
+		assert.Equal(t, int32(1), bodies[0].closes.Load(), "body should be closed exactly once")
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeCountingBody is a response body counting calls of Close
+type closeCountingBody struct {
+	io.Reader
+	closes atomic.Int32
+}
+
+func (b *closeCountingBody) Close() error {
+	b.closes.Add(1)
+	return nil
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	wait, ok := parseRetryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, wait)
+
+	wait, ok = parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.InDelta(t, float64(time.Minute), float64(wait), float64(2*time.Second))
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
+
+func TestClientMetricLabel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := New(Options{
+		Name:        "TestClientMetricLabel",
+		MetricLabel: LabelByPathTemplate("/items/{id}", "/items/{id}/tags"),
+	})
+	for _, path := range []string{"/items/1?token=a", "/items/2/?token=b", "/items/3/tags", "/users/4"} {
+		resp, err := client.Get(server.URL + path)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	assert.Equal(t, `httpclient_requests_total{client="TestClientMetricLabel",code="200",host="`+host+`/items/{id}",method="GET"} 2
+httpclient_requests_total{client="TestClientMetricLabel",code="200",host="`+host+`/items/{id}/tags",method="GET"} 1
+httpclient_requests_total{client="TestClientMetricLabel",code="200",host="`+host+`/other",method="GET"} 1
+`, promext.DumpMetrics("httpclient_requests_total", true, false, metricsOfClient("TestClientMetricLabel")))
+}
+
+// metricsOfClient returns a gatherer of metrics with the client label from the default gatherer
+func metricsOfClient(name string) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := prometheus.DefaultGatherer.Gather()
+		for _, family := range families {
+			family.Metric = promext.MatchExportedMetrics(family.Metric, prometheus.Labels{"client": name})
+		}
+		return families, err
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package httpclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// unmatchedPathLabel is the path part of metric labels from LabelByPathTemplate for unmatched requests
+const unmatchedPathLabel = "/other"
+
+// LabelByHost returns the host of request URL, which is the default value of "host" label in metrics
+func LabelByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+// LabelByPathTemplate creates a function for Options.MetricLabel, which returns the host and the first template
+// matching the request path, e.g. "api.example.com/items/{id}" for template "/items/{id}", or "api.example.com/other"
+// if none matches
+//
+// Segments in braces match any single segment of paths. Queries are never included.
+func LabelByPathTemplate(templates ...string) func(req *http.Request) string {
+	splitTemplates := make([][]string, len(templates))
+	for i, template := range templates {
+		splitTemplates[i] = strings.Split(strings.Trim(template, "/"), "/")
+	}
+	return func(req *http.Request) string {
+		segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		for i, template := range splitTemplates {
+			if matchPathTemplate(template, segments) {
+				return req.URL.Host + "/" + strings.Trim(templates[i], "/")
+			}
+		}
+		return req.URL.Host + unmatchedPathLabel
+	}
+}
+
+func matchPathTemplate(template []string, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, part := range template {
+		isParam := strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+		if !isParam && part != segments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/retry"
+)
+
+// maxDrainedBodySize is the max size of response bodies read before retry, to reuse connections
+const maxDrainedBodySize = 64 * 1024
+
+// RetryPolicy defines how failed requests are retried, with exponential backoff and jitter
+//
+// Requests with body are only retried if the body can be recreated, i.e. http.Request.GetBody is set, which is the case
+// for requests created by http.NewRequest with common body types.
+type RetryPolicy struct {
+	MaxAttempts    int                                       // max attempts including the first one, no retry if less than 2
+	InitialBackoff time.Duration                             // wait before the first retry, zero to retry immediately
+	MaxBackoff     time.Duration                             // max wait between attempts, also the max of "Retry-After", zero for unlimited
+	Multiplier     float64                                   // growth of backoff after each retry, 2 if zero
+	Jitter         float64                                   // randomization of each backoff from 0 to 1, e.g. 0.2 for +/- 20%
+	MaxElapsedTime time.Duration                             // max time from the first attempt to a retry, zero for unlimited
+	IsRetryable    func(resp *http.Response, err error) bool // classifier of retryable results, IsRetryableResult if nil
+}
+
+var (
+	requestCounterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_requests_total",
+		Help: "Numbers of HTTP client requests by status code, or 'error' for failures without response",
+	}, []string{"client", "host", "method", "code"})
+	durationCounterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_request_duration_milliseconds_total",
+		Help: "Total duration of HTTP client requests until response headers in milliseconds",
+	}, []string{"client", "host", "method", "code"})
+	retryCounterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+		Name: "httpclient_retries_total",
+		Help: "Numbers of HTTP client retries",
+	}, []string{"client", "host"})
+)
+
+func init() {
+	promext.SafeRegister(requestCounterVec)
+	promext.SafeRegister(durationCounterVec)
+	promext.SafeRegister(retryCounterVec)
+}
+
+// IsRetryableResult checks whether a request could succeed on retry, for network errors and status codes 429, 502,
+// 503 and 504
+//
+// Cancellation and deadline of request context are not retryable.
+func IsRetryableResult(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Backoff returns the wait after the given attempt (1 for the first attempt), with jitter applied
+func (policy RetryPolicy) Backoff(attempt int) time.Duration {
+	return policy.toRetryPolicy().Backoff(attempt)
+}
+
+func (policy RetryPolicy) toRetryPolicy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts:    policy.MaxAttempts,
+		InitialBackoff: policy.InitialBackoff,
+		MaxBackoff:     policy.MaxBackoff,
+		Multiplier:     policy.Multiplier,
+		Jitter:         policy.Jitter,
+		MaxElapsedTime: policy.MaxElapsedTime,
+	}
+}
+
+// retryableResponse is the error for retryable responses, to pass them through retry.Do
+//
+// The body is owned by the transport until the response is returned as the last attempt, and closed only once.
+type retryableResponse struct {
+	resp       *http.Response
+	bodyClosed bool
+}
+
+// closeBody closes the body unless closed before, after draining it to reuse the connection if drain is true
+func (r *retryableResponse) closeBody(drain bool) {
+	if r.bodyClosed {
+		return
+	}
+	r.bodyClosed = true
+	if drain {
+		drainAndClose(r.resp.Body)
+	} else {
+		r.resp.Body.Close()
+	}
+}
+
+func (r *retryableResponse) Error() string {
+	return r.resp.Status
+}
+
+func (r *retryableResponse) RetryAfter() time.Duration {
+	retryAfter, _ := parseRetryAfter(r.resp.Header.Get("Retry-After"))
+	return retryAfter
+}
+
+// instrumentedTransport records metrics and logs of requests and retries them by the policy
+type instrumentedTransport struct {
+	base        http.RoundTripper
+	name        string
+	retry       RetryPolicy
+	metricLabel func(req *http.Request) string
+	logger      logger.Logger
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	isRetryable := t.retry.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableResult
+	}
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	rlogger := t.logger.WithFields(logger.Fields{
+		"method": req.Method,
+		"url":    req.URL.Redacted(),
+	})
+
+	policy := t.retry.toRetryPolicy()
+	policy.IsRetryable = func(err error) bool {
+		var retryable *retryableResponse
+		return canRetry && (errors.As(err, &retryable) || isRetryable(nil, err))
+	}
+	policy.OnRetry = func(attempt int, backoff time.Duration, err error) {
+		if retryable, ok := err.(*retryableResponse); ok {
+			retryable.closeBody(true)
+		}
+		rlogger.Warnf("retry attempt #%d in %s after %v", attempt, backoff, err)
+		retryCounterVec.WithLabelValues(t.name, t.metricLabel(req)).Inc()
+	}
+
+	var resp *http.Response
+	attempt := 0
+	err := retry.Do(req.Context(), policy, func() error {
+		attempt++
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return retry.Permanent(fmt.Errorf("failed to recreate request body for retry: %w", err))
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		var err error
+		resp, err = t.roundTripOnce(req, rlogger)
+		if err != nil {
+			return err
+		}
+		if canRetry && isRetryable(resp, nil) {
+			return &retryableResponse{resp: resp}
+		}
+		return nil
+	})
+	if retryable, ok := err.(*retryableResponse); ok {
+		return retryable.resp, nil // last attempt
+	}
+	if err != nil {
+		var retryable *retryableResponse
+		if errors.As(err, &retryable) {
+			retryable.closeBody(false) // aborted by context before or during the wait for retry
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *instrumentedTransport) roundTripOnce(req *http.Request, rlogger logger.Logger) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		rlogger.Debugf("received %s in %s", resp.Status, elapsed)
+	} else {
+		rlogger.Debugf("failed in %s: %v", elapsed, err)
+	}
+	label := t.metricLabel(req)
+	requestCounterVec.WithLabelValues(t.name, label, req.Method, code).Inc()
+	durationCounterVec.WithLabelValues(t.name, label, req.Method, code).Add(uint64(elapsed.Milliseconds()))
+	return resp, err
+}
+
+// parseRetryAfter parses the "Retry-After" header in seconds or HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, maxDrainedBodySize)
+	body.Close()
+}
@@ -0,0 +1,243 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// RequestIDHeader is the header to receive and return request IDs
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware wraps a handler to add functionality before or after it, compatible with most routers
+type Middleware func(http.Handler) http.Handler
+
+type requestIDKey struct{}
+
+type requestLoggerKey struct{}
+
+// knownMethods are HTTP methods used as metric labels, others are labeled "other"
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Chain wraps the handler with middleware, the first of which is the outermost
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// RequestIDMiddleware takes the request ID from the header "X-Request-ID" or generates a new one, and sets it to the
+// request context and response header
+func RequestIDMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+		})
+	}
+}
+
+// LoggingMiddleware logs each request after completion, and sets a request logger with request ID and W3C trace
+// context to the context, see logger.ForRequest
+//
+// Server errors (5xx) are logged as warnings, and others as info.
+func LoggingMiddleware(baseLogger logger.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqLogger := baseLogger.ForRequest(r)
+			if requestID := RequestID(r.Context()); requestID != "" {
+				reqLogger = reqLogger.WithField("requestID", requestID)
+			}
+			recorder := wrapResponseWriter(w)
+			start := time.Now()
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLoggerKey{}, reqLogger)))
+
+			entry := reqLogger.WithFields(logger.Fields{
+				"method":   r.Method,
+				"path":     r.URL.Path,
+				"status":   recorder.status,
+				"bytes":    recorder.bytes,
+				"duration": time.Since(start).String(),
+				"remote":   r.RemoteAddr,
+			})
+			if recorder.status >= http.StatusInternalServerError {
+				entry.Warn("request failed")
+			} else {
+				entry.Info("request served")
+			}
+		})
+	}
+}
+
+// MetricsMiddleware records request rate, errors and duration to the metric creator, labeled by method and status
+// code:
+//
+//   - http_requests_total
+//   - http_request_duration_milliseconds_total
+//   - http_requests_in_flight
+func MetricsMiddleware(creator promreg.MetricCreator) Middleware {
+	labelNames := []string{"method", "code"}
+	requestsVec := creator.AddOrGetCounterVec("http_requests_total", "Numbers of HTTP requests", labelNames, nil)
+	durationVec := creator.AddOrGetCounterVec("http_request_duration_milliseconds_total", "Total duration of HTTP requests in milliseconds", labelNames, nil)
+	inFlight := creator.AddOrGetGauge("http_requests_in_flight", "Numbers of HTTP requests being served", nil, nil)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := wrapResponseWriter(w)
+			start := time.Now()
+			inFlight.Inc()
+			defer func() {
+				inFlight.Dec()
+				method := r.Method
+				if !knownMethods[method] {
+					method = "other"
+				}
+				code := strconv.Itoa(recorder.status)
+				requestsVec.WithLabelValues(method, code).Inc()
+				durationVec.WithLabelValues(method, code).Add(uint64(time.Since(start).Milliseconds()))
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// RecoveryMiddleware recovers from panics in handlers, logs them with stack trace, and responds 500 if nothing has
+// been written
+//
+// http.ErrAbortHandler is re-panicked to abort the response as intended.
+func RecoveryMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := wrapResponseWriter(w)
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if err, isErr := rec.(error); isErr && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+				RequestLogger(r.Context()).WithField("stack", string(debug.Stack())).Errorf("panic in handler: %v", rec)
+				if !recorder.wroteHeader {
+					http.Error(recorder, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// RequestID returns the request ID set by RequestIDMiddleware, or empty string if not found
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestLogger returns the request logger set by LoggingMiddleware, or the root logger if not found
+func RequestLogger(ctx context.Context) logger.Logger {
+	if reqLogger, found := ctx.Value(requestLoggerKey{}).(logger.Logger); found {
+		return reqLogger
+	}
+	return logger.Root()
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// responseRecorder records the status and size of response
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// wrapResponseWriter wraps the writer in responseRecorder, or returns it as-is if it's already one, so that nested
+// middleware share the same recorder
+func wrapResponseWriter(w http.ResponseWriter) *responseRecorder {
+	if recorder, isRecorder := w.(*responseRecorder); isRecorder {
+		return recorder
+	}
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for websockets
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking not supported by %T", r.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the original writer for http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpserver provides an instrumented HTTP server with request logging, metrics, panic recovery, health
+// endpoints and graceful shutdown
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/relex/gotils/healthcheck"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// Paths of health endpoints served by Server
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Defaults of Options
+const (
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+)
+
+// Options configures Server
+type Options struct {
+	Metrics           promreg.MetricCreator // creator of HTTP metrics, no metrics if nil
+	ShutdownTimeout   time.Duration         // max wait for active requests in graceful shutdown, default 30 seconds
+	ReadHeaderTimeout time.Duration         // max time to read request headers, default 10 seconds
+	IdleTimeout       time.Duration         // max idle time of keep-alive connections, default 2 minutes
+	StartUnready      bool                  // report not ready until SetReady(true), e.g. to warm up caches first
+	HealthChecks      *healthcheck.Registry // checks to report in health endpoints, optional
+}
+
+// Server is an HTTP server which wraps the handler with request ID, logging, metrics and panic recovery middleware,
+// and serves liveness and readiness endpoints
+//
+// Readiness is reported by SetReady and by the results of HealthChecks if set.
+//
+// The server is shut down gracefully by logger.Exit, after readiness is turned off and active requests finish.
+type Server struct {
+	handler         http.Handler
+	server          *http.Server
+	shutdownTimeout time.Duration
+	logger          logger.Logger
+	healthChecks    *healthcheck.Registry
+	ready           atomic.Bool
+	shutdownOnce    sync.Once
+	shutdownErr     error
+}
+
+// NewServer creates a server on the given address, e.g. ":8080", to serve the handler which can be any router
+func NewServer(address string, handler http.Handler, opts Options) *Server {
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if opts.ReadHeaderTimeout <= 0 {
+		opts.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	slogger := logger.WithField("component", "HTTPServer")
+
+	middlewares := []Middleware{RequestIDMiddleware()}
+	if opts.Metrics != nil {
+		middlewares = append(middlewares, MetricsMiddleware(opts.Metrics))
+	}
+	middlewares = append(middlewares, LoggingMiddleware(slogger), RecoveryMiddleware())
+
+	s := &Server{
+		handler:         Chain(handler, middlewares...),
+		shutdownTimeout: opts.ShutdownTimeout,
+		healthChecks:    opts.HealthChecks,
+		logger:          slogger,
+	}
+	s.ready.Store(!opts.StartUnready)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, s.serveLiveness)
+	mux.HandleFunc(ReadinessPath, s.serveReadiness)
+	mux.Handle("/", s.handler)
+
+	s.server = &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		ErrorLog:          slogger.NewStdLogger(logger.WarnLevel),
+	}
+	return s
+}
+
+// Start listens on the address and serves in background
+//
+// If the address contains unspecified port (":0"), a random port is assigned and returned by Addr.
+func (s *Server) Start() error {
+	lsnr, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+	s.server.Addr = lsnr.Addr().String()
+	s.logger.Infof("listening on %s...", s.server.Addr)
+
+	logger.AtExit(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			s.logger.Warn("failed to shut down gracefully: ", err)
+		}
+	})
+
+	go func() {
+		if err := s.server.Serve(lsnr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("failed to serve: ", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the listening address
+func (s *Server) Addr() string {
+	return s.server.Addr
+}
+
+// Handler returns the handler wrapped with middleware, without health endpoints
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// SetReady sets whether the server is ready to serve requests, as reported by the readiness endpoint
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Shutdown turns off readiness and stops the server gracefully, waiting for active requests until the context is done
+//
+// Subsequent calls return the result of the first one.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.ready.Store(false)
+		s.logger.Info("shutting down...")
+		s.shutdownErr = s.server.Shutdown(ctx)
+		if s.shutdownErr == nil {
+			s.logger.Info("shut down")
+		}
+	})
+	return s.shutdownErr
+}
+
+func (s *Server) serveLiveness(w http.ResponseWriter, r *http.Request) {
+	if s.healthChecks != nil {
+		s.healthChecks.LivenessHandler().ServeHTTP(w, r)
+		return
+	}
+	writeHealthStatus(w, true)
+}
+
+func (s *Server) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	if s.healthChecks != nil && s.ready.Load() {
+		s.healthChecks.ReadinessHandler().ServeHTTP(w, r)
+		return
+	}
+	writeHealthStatus(w, s.ready.Load())
+}
+
+func writeHealthStatus(w http.ResponseWriter, ok bool) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "not ready")
+	}
+}
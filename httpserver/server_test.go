@@ -0,0 +1,109 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/relex/gotils/healthcheck"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, RequestID(r.Context()))
+		io.WriteString(w, "hello")
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	})
+	factory := promreg.NewMetricFactory("testhttpserver_", nil, nil)
+	server := NewServer("127.0.0.1:0", mux, Options{Metrics: factory, StartUnready: true})
+	assert.NoError(t, server.Start())
+	baseURL := "http://" + server.Addr()
+
+	get := func(path string, header http.Header) (int, string, http.Header) {
+		req, _ := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0, "", nil
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), resp.Header
+	}
+
+	status, _, _ := get(LivenessPath, nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _, _ = get(ReadinessPath, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	server.SetReady(true)
+	status, _, _ = get(ReadinessPath, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	health := healthcheck.NewRegistry(nil)
+	health.Register("db", func() error { return errors.New("down") })
+	server.healthChecks = health
+	status, body, header := get(ReadinessPath, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body, `"error": "down"`)
+	server.healthChecks = nil
+
+	status, body, header = get("/hello", http.Header{RequestIDHeader: {"abc"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, "abc", header.Get(RequestIDHeader))
+
+	status, _, header = get("/panic", nil)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Len(t, header.Get(RequestIDHeader), 16)
+
+	status, _, _ = get("/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	assert.Equal(t, `testhttpserver_http_requests_total{code="200",method="GET"} 1
+testhttpserver_http_requests_total{code="404",method="GET"} 1
+testhttpserver_http_requests_total{code="500",method="GET"} 1
+`, promext.DumpMetrics("testhttpserver_http_requests_total", true, false, factory))
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+	_, err := http.Get(baseURL + LivenessPath)
+	assert.Error(t, err)
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	newMiddleware := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := Chain(http.NotFoundHandler(), newMiddleware("outer"), newMiddleware("inner"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"outer", "inner"}, order)
+}
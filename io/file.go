@@ -14,20 +14,34 @@
 package io
 
 import (
-	"io/ioutil"
-	"os"
-	"strconv"
-
+	"github.com/relex/gotils/fsutil"
 	"github.com/relex/gotils/logger"
 )
 
 // WriteFileAtomically writes file contents to specified path atomically
+//
+// Any error is fatal. Use TryWriteFileAtomically to handle errors.
 func WriteFileAtomically(path string, contents []byte) {
-	tmpFile := path + "." + strconv.Itoa(os.Getpid())
-	if err := ioutil.WriteFile(tmpFile, contents, 0644); err != nil {
-		logger.Fatalf("failed to write file: %v", err)
-	}
-	if err := os.Rename(tmpFile, path); err != nil {
-		logger.Fatalf("failed to rename file: %v", err)
+	if err := TryWriteFileAtomically(path, contents); err != nil {
+		logger.Fatal(err)
 	}
 }
+
+// AtomicWriteOptions configures TryWriteFileAtomicallyWithOptions
+type AtomicWriteOptions = fsutil.AtomicWriteOptions
+
+// TryWriteFileAtomically writes file contents to specified path atomically, by writing to a temporary file in the same
+// directory, syncing and then renaming it
+//
+// Readers never see partially written contents, and the previous file is kept if any error occurs.
+func TryWriteFileAtomically(path string, contents []byte) error {
+	return TryWriteFileAtomicallyWithOptions(path, contents, AtomicWriteOptions{})
+}
+
+// TryWriteFileAtomicallyWithOptions writes file contents to specified path atomically like TryWriteFileAtomically,
+// with options for file permission and directory creation
+//
+// See fsutil.AtomicWriteFile
+func TryWriteFileAtomicallyWithOptions(path string, contents []byte, opts AtomicWriteOptions) error {
+	return fsutil.AtomicWriteFile(path, contents, opts)
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxJSONDiffs is the max number of differences reported by JSONEqual
+const maxJSONDiffs = 20
+
+// CanonicalizeJSON re-encodes JSON into the canonical form: object keys sorted, numbers normalized and insignificant
+// whitespace stripped
+//
+// Numbers are decoded without conversion to float64, so that large integers such as int64 IDs keep their precision.
+// Integral numbers are written without fraction or exponent, e.g. "1.0" and "1e2" become "1" and "100".
+func CanonicalizeJSON(data []byte) ([]byte, error) {
+	value, err := decodeJSONValue(data)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := writeCanonicalJSON(buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSONEqual compares two JSON documents regardless of key order, number formatting and whitespace
+//
+// Returns a human-readable description of differences by JSON paths if they're not equal, e.g. for tests:
+//
+//	$.items[1].id: 1 != 2
+//	$.name: missing in b
+func JSONEqual(a, b []byte) (bool, string) {
+	valueA, errA := decodeJSONValue(a)
+	if errA != nil {
+		return false, fmt.Sprintf("invalid JSON a: %v", errA)
+	}
+	valueB, errB := decodeJSONValue(b)
+	if errB != nil {
+		return false, fmt.Sprintf("invalid JSON b: %v", errB)
+	}
+	var diffs []string
+	diffJSONValues("$", valueA, valueB, &diffs)
+	if len(diffs) == 0 {
+		return true, ""
+	}
+	if len(diffs) > maxJSONDiffs {
+		diffs = append(diffs[:maxJSONDiffs], fmt.Sprintf("... %d more", len(diffs)-maxJSONDiffs))
+	}
+	return false, strings.Join(diffs, "\n")
+}
+
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to decode JSON: unexpected data after top-level value")
+	}
+	return value, nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		buf.WriteByte('{')
+		for i, key := range getSortedKeys(v) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		num, err := normalizeJSONNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(num)
+	case string:
+		writeJSONString(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected JSON value type %T", value)
+	}
+	return nil
+}
+
+// writeJSONString writes a quoted string without escaping HTML characters
+func writeJSONString(buf *bytes.Buffer, str string) {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(str) // never fails for strings
+	buf.Truncate(buf.Len() - 1)
+}
+
+// normalizeJSONNumber formats integral numbers in plain digits and others in the shortest float64 form
+func normalizeJSONNumber(num json.Number) (string, error) {
+	rat, ok := new(big.Rat).SetString(string(num))
+	if !ok {
+		return "", fmt.Errorf("invalid JSON number '%s'", num)
+	}
+	if rat.IsInt() {
+		return rat.Num().String(), nil
+	}
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSON number '%s': %w", num, err)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
+
+func diffJSONValues(path string, a, b interface{}, diffs *[]string) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range getSortedKeys(va) {
+			keyPath := path + "." + key
+			if itemB, exists := vb[key]; exists {
+				diffJSONValues(keyPath, va[key], itemB, diffs)
+			} else {
+				*diffs = append(*diffs, keyPath+": missing in b")
+			}
+		}
+		for _, key := range getSortedKeys(vb) {
+			if _, exists := va[key]; !exists {
+				*diffs = append(*diffs, path+"."+key+": missing in a")
+			}
+		}
+		return
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(va) || i < len(vb); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(vb):
+				*diffs = append(*diffs, itemPath+": missing in b")
+			case i >= len(va):
+				*diffs = append(*diffs, itemPath+": missing in a")
+			default:
+				diffJSONValues(itemPath, va[i], vb[i], diffs)
+			}
+		}
+		return
+	}
+
+	textA := formatCanonicalJSON(a)
+	textB := formatCanonicalJSON(b)
+	if textA != textB {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", path, textA, textB))
+	}
+}
+
+func formatCanonicalJSON(value interface{}) string {
+	buf := &bytes.Buffer{}
+	if err := writeCanonicalJSON(buf, value); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return buf.String()
+}
+
+func getSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
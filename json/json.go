@@ -14,31 +14,89 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+
+	"github.com/relex/gotils/fsutil"
 )
 
+// SortingOptions defines how MarshalJSONWithSortingOptions sorts object keys
+type SortingOptions struct {
+	TopLevelOnly bool // sort only keys of the top-level object and keep nested objects in original (struct field) order
+}
+
 // MarshalJSONWithSorting marshals JSON with keys sorted by alphabet, same rule as marshalling map
 //
+// Keys of nested objects are sorted too. Numbers are copied verbatim so that int64/uint64 values keep their precision.
+//
 // When called from a Marshaller, the input should be a new/derived type without Marshaller defined to prevent infinite recursion
 func MarshalJSONWithSorting(input interface{}) ([]byte, error) {
+	return MarshalJSONWithSortingOptions(input, SortingOptions{})
+}
+
+// MarshalJSONWithSortingOptions marshals JSON with keys sorted by alphabet like MarshalJSONWithSorting, with options
+//
+// When called from a Marshaller, the input should be a new/derived type without Marshaller defined to prevent infinite recursion
+func MarshalJSONWithSortingOptions(input interface{}, opts SortingOptions) ([]byte, error) {
 	mJSON, mErr := json.Marshal(input)
 	if mErr != nil {
 		return nil, fmt.Errorf("error marshalling intermediate input: %v: %w", input, mErr)
 	}
-	// remarshal from map to sort labels
-	fieldMap := make(map[string]interface{})
-	if err := json.Unmarshal(mJSON, &fieldMap); err != nil {
-		return nil, fmt.Errorf("error unmarshalling intermediate field map: %v: %w", fieldMap, err)
-	}
-	sortedJSON, sErr := json.Marshal(fieldMap)
+	sortedJSON, sErr := sortJSONKeys(mJSON, !opts.TopLevelOnly)
 	if sErr != nil {
-		return nil, fmt.Errorf("error marshalling intermediate field map: %v: %w", fieldMap, sErr)
+		return nil, fmt.Errorf("error sorting intermediate JSON: %s: %w", mJSON, sErr)
 	}
 	return sortedJSON, nil
 }
 
+// sortJSONKeys re-encodes the object or array in data with keys sorted, optionally recursively for nested values
+//
+// Nested values which are not sorted are kept as raw messages, so numbers and key order inside are preserved as-is.
+func sortJSONKeys(data json.RawMessage, recursive bool) (json.RawMessage, error) {
+	switch firstNonSpace(data) {
+	case '{':
+		fieldMap := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(data, &fieldMap); err != nil {
+			return nil, err
+		}
+		if recursive {
+			for key, value := range fieldMap {
+				sortedValue, err := sortJSONKeys(value, true)
+				if err != nil {
+					return nil, err
+				}
+				fieldMap[key] = sortedValue
+			}
+		}
+		return json.Marshal(fieldMap) // keys of maps are always sorted
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			sortedItem, err := sortJSONKeys(item, recursive)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = sortedItem
+		}
+		return json.Marshal(items)
+	default:
+		return data, nil
+	}
+}
+
+func firstNonSpace(data []byte) byte {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
+
 // MarshalToJSONFile marshals structure to a JSON file at the specified path
 func MarshalToJSONFile(filepath string, input interface{}) error {
 	data, err := json.MarshalIndent(input, "", "  ")
@@ -49,6 +107,20 @@ func MarshalToJSONFile(filepath string, input interface{}) error {
 	return ioutil.WriteFile(filepath, data, 0644)
 }
 
+// MarshalToJSONFileAtomic marshals structure to a JSON file at the specified path atomically, with options for file
+// permission and directory creation
+//
+// The file is written to a temporary file first, synced and then renamed, so that a crash can't leave a truncated
+// file to readers.
+func MarshalToJSONFileAtomic(filepath string, input interface{}, opts fsutil.AtomicWriteOptions) error {
+	data, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return fsutil.AtomicWriteFile(filepath, data, opts)
+}
+
 // UnmarshalFromJSONFile unmarshals JSON file at the specified path
 func UnmarshalFromJSONFile(filepath string, outputPtr interface{}) error {
 	data, err := ioutil.ReadFile(filepath)
@@ -0,0 +1,94 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relex/gotils/fsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalToJSONFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "dir", "test.json")
+	input := map[string]int{"b": 2, "a": 1}
+
+	assert.Error(t, MarshalToJSONFileAtomic(path, input, fsutil.AtomicWriteOptions{}))
+
+	assert.NoError(t, MarshalToJSONFileAtomic(path, input, fsutil.AtomicWriteOptions{Mode: 0600, CreateDir: true}))
+	info, statErr := os.Stat(path)
+	assert.NoError(t, statErr)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	var output map[string]int
+	assert.NoError(t, UnmarshalFromJSONFile(path, &output))
+	assert.Equal(t, input, output)
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(t, entries, 1)
+}
+
+func TestCanonicalizeJSON(t *testing.T) {
+	output, err := CanonicalizeJSON([]byte(`{ "b": [1.0, 2.5, 1e2, -0], "a": {"z": 9007199254740993, "y": "<&>"}, "c": null }`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":{"y":"<&>","z":9007199254740993},"b":[1,2.5,100,0],"c":null}`, string(output))
+
+	_, err = CanonicalizeJSON([]byte(`{"a": 1} {}`))
+	assert.ErrorContains(t, err, "unexpected data")
+}
+
+func TestJSONEqual(t *testing.T) {
+	equal, diff := JSONEqual([]byte(`{"a": 1, "b": [true, "x"]}`), []byte(`{"b":[true,"x"],"a":1.0}`))
+	assert.True(t, equal)
+	assert.Empty(t, diff)
+
+	equal, diff = JSONEqual([]byte(`{"a": 1, "b": [true, "x"], "c": {}}`), []byte(`{"a": 2, "b": [true], "d": 0}`))
+	assert.False(t, equal)
+	assert.Equal(t, "$.a: 1 != 2\n$.b[1]: missing in b\n$.c: missing in b\n$.d: missing in a", diff)
+}
+
+func TestMarshalJSONWithSorting(t *testing.T) {
+	type inner struct {
+		Zeta  int64  `json:"zeta"`
+		Alpha uint64 `json:"alpha"`
+	}
+	type outer struct {
+		Name   string  `json:"name"`
+		Nested inner   `json:"nested"`
+		Items  []inner `json:"items"`
+		ID     int64   `json:"id"`
+	}
+	input := outer{
+		Name:   "<x>",
+		Nested: inner{Zeta: 9007199254740993, Alpha: 18446744073709551615},
+		Items:  []inner{{Zeta: 1, Alpha: 2}},
+		ID:     -9223372036854775808,
+	}
+
+	data, err := MarshalJSONWithSorting(input)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":-9223372036854775808,"items":[{"alpha":2,"zeta":1}],"name":"\u003cx\u003e",`+
+		`"nested":{"alpha":18446744073709551615,"zeta":9007199254740993}}`, string(data))
+
+	data, err = MarshalJSONWithSortingOptions(input, SortingOptions{TopLevelOnly: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":-9223372036854775808,"items":[{"zeta":1,"alpha":2}],"name":"\u003cx\u003e",`+
+		`"nested":{"zeta":9007199254740993,"alpha":18446744073709551615}}`, string(data))
+
+	data, err = MarshalJSONWithSorting([]interface{}{map[string]int{"b": 1, "a": 2}, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"a":2,"b":1},3]`, string(data))
+}
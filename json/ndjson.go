@@ -0,0 +1,137 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// NDJSONLineErrorHandler is called for each line that cannot be unmarshalled, before it's skipped
+//
+// The line number starts from 1 and the line content excludes the trailing newline
+type NDJSONLineErrorHandler func(lineNumber int, line []byte, err error)
+
+// NDJSONReader iterates records in newline-delimited JSON, e.g. the JSON logs produced by the logger package
+//
+// Lines that cannot be unmarshalled into T are skipped and reported to the error handler. Empty lines are ignored.
+// Only read errors from the underlying reader stop the iteration.
+//
+// Usage:
+//
+//	reader := NewNDJSONReader[MyRecord](file, nil)
+//	for reader.Next() {
+//		process(reader.Record())
+//	}
+//	if err := reader.Err(); err != nil {
+//		...
+//	}
+type NDJSONReader[T any] struct {
+	input      *bufio.Reader
+	onError    NDJSONLineErrorHandler
+	lineNumber int
+	skipped    int
+	record     T
+	err        error
+}
+
+// NewNDJSONReader creates a NDJSONReader from the given input. The error handler may be nil.
+func NewNDJSONReader[T any](input io.Reader, onError NDJSONLineErrorHandler) *NDJSONReader[T] {
+	return &NDJSONReader[T]{
+		input:   bufio.NewReader(input),
+		onError: onError,
+	}
+}
+
+// Next reads the next valid record, returning false at the end of input or on read error
+func (r *NDJSONReader[T]) Next() bool {
+	for r.err == nil {
+		// ReadBytes instead of bufio.Scanner to support lines of any length
+		line, rerr := r.input.ReadBytes('\n')
+		if rerr != nil {
+			if !errors.Is(rerr, io.EOF) {
+				r.err = fmt.Errorf("failed to read line %d: %w", r.lineNumber+1, rerr)
+				return false
+			}
+			if len(line) == 0 {
+				r.err = io.EOF
+				return false
+			}
+		}
+		r.lineNumber++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var record T
+		if err := json.Unmarshal(line, &record); err != nil {
+			r.skipped++
+			if r.onError != nil {
+				r.onError(r.lineNumber, line, err)
+			}
+			continue
+		}
+		r.record = record
+		return true
+	}
+	return false
+}
+
+// Record returns the last record read by Next
+func (r *NDJSONReader[T]) Record() T {
+	return r.record
+}
+
+// Err returns the read error which stopped the iteration, or nil if the end of input has been reached
+func (r *NDJSONReader[T]) Err() error {
+	if errors.Is(r.err, io.EOF) {
+		return nil
+	}
+	return r.err
+}
+
+// LineNumber returns the number of the last line read, starting from 1
+func (r *NDJSONReader[T]) LineNumber() int {
+	return r.lineNumber
+}
+
+// Skipped returns the count of lines skipped due to unmarshalling errors
+func (r *NDJSONReader[T]) Skipped() int {
+	return r.skipped
+}
+
+// ReadNDJSONFile reads all valid records from a NDJSON file at the specified path
+//
+// Returns the records and the count of skipped lines
+func ReadNDJSONFile[T any](filepath string, onError NDJSONLineErrorHandler) ([]T, int, error) {
+	file, oerr := os.Open(filepath)
+	if oerr != nil {
+		return nil, 0, oerr
+	}
+	defer file.Close()
+
+	reader := NewNDJSONReader[T](file, onError)
+	records := make([]T, 0, 100)
+	for reader.Next() {
+		records = append(records, reader.Record())
+	}
+	return records, reader.Skipped(), reader.Err()
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logRecord struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func TestNDJSONReader(t *testing.T) {
+	input := `{"level":"info","message":"first"}
+
+{"level":"warn","message":
+{"level":"error","message":"third"}
+"not a record"
+{"level":"debug","message":"` + strings.Repeat("x", 100000) + `"}`
+
+	badLines := make([]int, 0)
+	reader := NewNDJSONReader[logRecord](strings.NewReader(input), func(lineNumber int, line []byte, err error) {
+		badLines = append(badLines, lineNumber)
+	})
+
+	records := make([]logRecord, 0)
+	for reader.Next() {
+		records = append(records, reader.Record())
+	}
+	assert.NoError(t, reader.Err())
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, logRecord{"info", "first"}, records[0])
+	assert.Equal(t, logRecord{"error", "third"}, records[1])
+	assert.Equal(t, "debug", records[2].Level)
+	assert.Equal(t, 100000, len(records[2].Message))
+	assert.Equal(t, []int{3, 5}, badLines)
+	assert.Equal(t, 2, reader.Skipped())
+	assert.Equal(t, 6, reader.LineNumber())
+}
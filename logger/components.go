@@ -0,0 +1,49 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// componentRegistry keeps all component names seen by WithField(s)
+var componentRegistry sync.Map // string => struct{}
+
+func registerComponent(name string) {
+	componentRegistry.LoadOrStore(name, struct{}{})
+}
+
+// ListComponents lists all component names used in sub-loggers so far, in alphabetical order
+func ListComponents() []string {
+	names := make([]string, 0, 50)
+	componentRegistry.Range(func(key, _ interface{}) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// ComponentsHandler returns a HTTP handler to list component names in JSON array
+func ComponentsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ListComponents()); err != nil {
+			ownLogger.Warn("failed to write component list: ", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// DedupeRepeatedKey is the field of the repeat counter in entries collapsed by SetDedupeWindow
+const DedupeRepeatedKey = "repeated"
+
+var primaryDedupe = &dedupeState{}
+
+func init() {
+	AtExit(primaryDedupe.flush)
+}
+
+// SetDedupeWindow collapses consecutive identical entries of the primary output, with the same level, component,
+// message and fields, into the first entry and a single line of the last one with a "repeated" field counting the
+// dropped entries, e.g. to avoid flooding logs when a dependency is flapping
+//
+// The collapsed line is written at the end of the window since the first entry, or earlier when a different entry
+// arrives or at Exit. Zero window disables deduplication, which is the default.
+func SetDedupeWindow(window time.Duration) {
+	primaryDedupe.setWindow(window)
+}
+
+// dedupeState tracks the last entry written to the primary output and the identical entries dropped after it
+type dedupeState struct {
+	lock      sync.Mutex
+	window    time.Duration
+	formatter logrus.Formatter // formatter used for the last entry, to write the pending repeat counter at exit
+	lastKey   string
+	lastStart time.Time     // time of the first entry in the current window
+	repeated  *logrus.Entry // the last dropped entry
+	count     int
+	timer     *time.Timer // timer to write the repeat counter at the end of the current window
+	windowID  int         // ID of the current window, to ignore timers of previous ones
+}
+
+func (s *dedupeState) setWindow(window time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closeWindow(s.formatter)
+	s.window = window
+}
+
+// flush writes the pending repeat counter if any
+func (s *dedupeState) flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closeWindow(s.formatter)
+}
+
+// expire closes the window of the given ID by its timer, unless it has been closed already
+func (s *dedupeState) expire(windowID int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if windowID == s.windowID {
+		s.closeWindow(s.formatter)
+	}
+}
+
+// closeWindow writes the pending repeat counter if any and starts over, must be called with the lock held
+func (s *dedupeState) closeWindow(formatter logrus.Formatter) {
+	s.writeRepeated(formatter)
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.windowID++
+	s.lastKey = ""
+}
+
+// format formats the entry by the formatter, or returns nil to drop it if it's a repeat of the last one
+func (s *dedupeState) format(formatter logrus.Formatter, entry *logrus.Entry) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.window <= 0 {
+		return formatter.Format(entry)
+	}
+
+	key := getDedupeKey(entry)
+	if key == s.lastKey && entry.Time.Sub(s.lastStart) < s.window {
+		s.repeated = entry.Dup()
+		s.repeated.Level = entry.Level
+		s.repeated.Message = entry.Message
+		s.count++
+		if s.timer == nil {
+			windowID := s.windowID
+			s.timer = time.AfterFunc(time.Until(s.lastStart.Add(s.window)), func() { s.expire(windowID) })
+		}
+		return nil, nil
+	}
+
+	s.closeWindow(formatter)
+	data, err := formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	s.formatter = formatter
+	s.lastKey = key
+	s.lastStart = entry.Time
+	return data, nil
+}
+
+// writeRepeated writes the last dropped entry with the repeat counter to the primary output and resets the count
+func (s *dedupeState) writeRepeated(formatter logrus.Formatter) {
+	if s.count == 0 || formatter == nil {
+		return
+	}
+	entry := s.repeated
+	entry.Data[DedupeRepeatedKey] = s.count
+	s.repeated = nil
+	s.count = 0
+	if data, err := formatter.Format(entry); err == nil {
+		primaryOutput.writeEntry(entry.Level, data)
+	}
+}
+
+func getDedupeKey(entry *logrus.Entry) string {
+	return fmt.Sprintf("%d\x00%v\x00%s\x00%s", entry.Level, entry.Data[priv.LabelComponent], entry.Message,
+		priv.FormatFields(entry.Data))
+}
+
+// dedupeFormatter drops repeated entries by the dedupe state before formatting
+type dedupeFormatter struct {
+	formatter logrus.Formatter
+	state     *dedupeState
+}
+
+func (f dedupeFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return f.state.format(f.formatter, entry)
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultExitHandlerTimeout is the default timeout of each exit handler, see RegisterExitHandler
+const DefaultExitHandlerTimeout = 10 * time.Second
+
+// Priorities of exit handlers, higher ones are called first. Any other value can be used.
+const (
+	ExitPriorityEarly  = 100  // e.g. to stop accepting requests
+	ExitPriorityNormal = 0    // default for AtExit
+	ExitPriorityLate   = -100 // e.g. to close connections used by other handlers
+)
+
+type exitHandler struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	handler  func(ctx context.Context)
+}
+
+var (
+	exitLock     sync.Mutex
+	exitHandlers []exitHandler
+	exitHandled  bool
+)
+
+func init() {
+	logrus.DeferExitHandler(runExitHandlers) // before logrus handlers registered by upstreams to flush logs
+}
+
+// RegisterExitHandler registers a named function to be called when the program exits by Exit, ExitWith or Fatal
+//
+// Handlers are called one by one after flushers registered by RegisterFlusher, by priority from high to low and then
+// in reverse order of registration (like "defer"). Each handler is given a context canceled after the timeout or
+// DefaultExitHandlerTimeout if zero, and skipped if still running by then. Names are for logging only.
+func RegisterExitHandler(name string, priority int, timeout time.Duration, handler func(ctx context.Context)) {
+	if timeout <= 0 {
+		timeout = DefaultExitHandlerTimeout
+	}
+	exitLock.Lock()
+	defer exitLock.Unlock()
+	exitHandlers = append(exitHandlers, exitHandler{name, priority, timeout, handler})
+}
+
+// AtExit registers a function to be called when the program is shut down, with ExitPriorityNormal and the default
+// timeout, see RegisterExitHandler
+//
+// AtExit can be called multiple times and functions registered are called in reverse order (like "defer").
+//
+// Since go doesn't provide any shutdown callback at the moment, the mechanism only works when application quits by calling Exit() here.
+func AtExit(handler func()) {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	RegisterExitHandler(name, ExitPriorityNormal, 0, func(context.Context) { handler() })
+}
+
+// Exit quits the program by calling exit on the underlying logger, after calling flushers registered by
+// RegisterFlusher and exit handlers, and flushes all remaining logs if any
+func Exit(code int) {
+	ExitWith(func() int { return code })
+}
+
+// ExitWith quits the program like Exit, with the exit code returned by codeProvider after all flushers and exit
+// handlers are called, e.g. to fail if any of them reports errors
+func ExitWith(codeProvider func() int) {
+	runFlushers()
+	runExitHandlers()
+	logrus.Exit(codeProvider())
+}
+
+// runExitHandlers calls all exit handlers unless they have been called
+func runExitHandlers() {
+	exitLock.Lock()
+	if exitHandled {
+		exitLock.Unlock()
+		return
+	}
+	exitHandled = true
+	handlers := make([]exitHandler, 0, len(exitHandlers))
+	for i := len(exitHandlers) - 1; i >= 0; i-- {
+		handlers = append(handlers, exitHandlers[i])
+	}
+	exitLock.Unlock()
+
+	sort.SliceStable(handlers, func(i, j int) bool { return handlers[i].priority > handlers[j].priority })
+	for _, h := range handlers {
+		runExitHandler(h)
+	}
+}
+
+func runExitHandler(h exitHandler) {
+	hlogger := ownLogger.WithField("handler", h.name)
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				hlogger.Errorf("exit handler panicked: %v", r)
+			}
+		}()
+		h.handler(ctx)
+	}()
+
+	select {
+	case <-done:
+		hlogger.Debugf("exit handler completed in %s", time.Since(start))
+	case <-ctx.Done():
+		hlogger.Warnf("exit handler timed out after %s", h.timeout)
+	}
+}
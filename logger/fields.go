@@ -0,0 +1,92 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"sync/atomic"
+
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// JSONFieldNames defines the names of standard fields in JSON format
+type JSONFieldNames struct {
+	Timestamp string // default "timestamp"
+	Level     string // default "level"
+	Message   string // default "message"
+}
+
+var globalFields atomic.Pointer[Fields]
+
+// SetGlobalFields sets static fields to be added to every log of all outputs, e.g. service name, version and hostname
+//
+// Fields of logs or sub-loggers with the same keys take precedence. Calling it again replaces the previous fields.
+func SetGlobalFields(fields Fields) {
+	copied := make(Fields, len(fields))
+	for k, v := range fields {
+		copied[k] = v
+	}
+	globalFields.Store(&copied)
+}
+
+// GetGlobalFields returns the fields set by SetGlobalFields
+func GetGlobalFields() Fields {
+	if fields := globalFields.Load(); fields != nil {
+		return *fields
+	}
+	return nil
+}
+
+// SetJSONFieldNames renames the standard fields in JSON format, e.g. "@timestamp" and "log.level" for ELK
+//
+// Empty names keep defaults. It can be called at any time and takes effect for upstream and all outputs in JSON
+// format, as the field names are swapped atomically.
+func SetJSONFieldNames(names JSONFieldNames) {
+	if names == (JSONFieldNames{}) {
+		priv.SharedJSONFormatter.SetFieldMap(nil)
+		return
+	}
+	fieldMap := logrus.FieldMap{
+		logrus.FieldKeyTime:  "timestamp",
+		logrus.FieldKeyLevel: "level",
+		logrus.FieldKeyMsg:   "message",
+	}
+	if names.Timestamp != "" {
+		fieldMap[logrus.FieldKeyTime] = names.Timestamp
+	}
+	if names.Level != "" {
+		fieldMap[logrus.FieldKeyLevel] = names.Level
+	}
+	if names.Message != "" {
+		fieldMap[logrus.FieldKeyMsg] = names.Message
+	}
+	priv.SharedJSONFormatter.SetFieldMap(fieldMap)
+}
+
+// globalFieldsHook adds global fields to entries before they're formatted or passed to other hooks
+//
+// It's added before any other hook.
+type globalFieldsHook struct{}
+
+func (h globalFieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h globalFieldsHook) Fire(entry *logrus.Entry) error {
+	for k, v := range GetGlobalFields() {
+		if _, exists := entry.Data[k]; !exists {
+			entry.Data[k] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultFlushTimeout is the default timeout for all flushers to complete at exit, see SetFlushTimeout
+const DefaultFlushTimeout = 10 * time.Second
+
+var (
+	flushLock     sync.Mutex
+	flushers      []registeredFlusher
+	nextFlusherID int
+	flushTimeout  = DefaultFlushTimeout
+)
+
+type registeredFlusher struct {
+	id    int
+	flush func(ctx context.Context) error
+}
+
+// RegisterFlusher registers a function to flush pending data before the program exits by Exit or Fatal, e.g. to push
+// final metrics of batch jobs or to shut down HTTP listeners gracefully, and returns a function to unregister it
+//
+// Flushers are called once in reverse order of registration (like "defer") and before AtExit handlers, with a context
+// whose deadline is shared by all flushers, see SetFlushTimeout. Errors are logged as warnings.
+//
+// Panic doesn't call flushers, since the panic may be recovered and the program goes on.
+func RegisterFlusher(flusher func(ctx context.Context) error) func() {
+	flushLock.Lock()
+	defer flushLock.Unlock()
+	id := nextFlusherID
+	nextFlusherID++
+	flushers = append(flushers, registeredFlusher{id, flusher})
+	return func() {
+		flushLock.Lock()
+		defer flushLock.Unlock()
+		for i, f := range flushers {
+			if f.id == id {
+				flushers = append(flushers[:i:i], flushers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SetFlushTimeout sets the timeout for all flushers registered by RegisterFlusher to complete at exit
+func SetFlushTimeout(timeout time.Duration) {
+	flushLock.Lock()
+	defer flushLock.Unlock()
+	flushTimeout = timeout
+}
+
+// runFlushers calls and removes all registered flushers
+func runFlushers() {
+	flushLock.Lock()
+	currentFlushers := flushers
+	flushers = nil
+	timeout := flushTimeout
+	flushLock.Unlock()
+
+	if len(currentFlushers) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for i := len(currentFlushers) - 1; i >= 0; i-- {
+		if err := currentFlushers[i].flush(ctx); err != nil {
+			ownLogger.Warnf("failed to flush at exit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Go runs the function in a new goroutine with the root logger, see Logger.Go
+func Go(fn func(l Logger)) {
+	root.Go(fn)
+}
+
+// GoE runs the function in a new goroutine with the root logger, see Logger.GoE
+func GoE(fn func(l Logger) error) <-chan error {
+	return root.GoE(fn)
+}
+
+// Go runs the function in a new goroutine with this logger, so that logs from the goroutine carry the same fields
+//
+// Panics in the function are recovered and logged at error level by this logger, with the field "stack".
+func (logger Logger) Go(fn func(l Logger)) {
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				logger.WithField("stack", string(debug.Stack())).Errorf("panic in goroutine: %v", rec)
+			}
+		}()
+		fn(logger)
+	}()
+}
+
+// GoE runs the function in a new goroutine with this logger like Go, and returns a channel to receive its result
+//
+// The channel receives the returned error, or a panic in the function as StructuredError with the fields of this
+// logger and "stack", and is then closed.
+func (logger Logger) GoE(fn func(l Logger) error) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		defer close(result)
+		defer func() {
+			if rec := recover(); rec != nil {
+				serr := NewStructuredError(logger.entry.Data, fmt.Errorf("panic in goroutine: %v", rec))
+				serr.fields["stack"] = string(debug.Stack())
+				result <- serr
+			}
+		}()
+		result <- fn(logger)
+	}()
+	return result
+}
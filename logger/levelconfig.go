@@ -0,0 +1,123 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config keys of log levels read by BindConfig
+const (
+	LevelConfigKey           = "log_level"
+	ComponentLevelsConfigKey = "log_component_levels"
+)
+
+// BindConfig applies log levels from the config by the getter, e.g. viper.Get, and returns a function to apply them
+// again after config changes, e.g.:
+//
+//	config.OnConfigChange(logger.BindConfig(viper.Get))
+//
+// The key "log_level" sets the level like SetLogLevel, and "log_component_levels" sets levels of components by a map
+// like SetComponentLevel, replacing all previous component levels. Missing keys leave levels unchanged.
+//
+// Invalid values are logged as errors and ignored together with other keys, to keep the current levels.
+func BindConfig(get func(key string) interface{}) func() {
+	apply := func() {
+		if err := applyLevelConfig(get); err != nil {
+			ownLogger.Errorf("failed to apply log levels from config: %v", err)
+		}
+	}
+	apply()
+	return apply
+}
+
+func applyLevelConfig(get func(key string) interface{}) error {
+	var newLevel *logrus.Level
+	if value := get(LevelConfigKey); value != nil {
+		level, err := parseConfigLevel(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", LevelConfigKey, err)
+		}
+		newLevel = &level
+	}
+
+	var newComponentLevels map[string]logrus.Level
+	if value := get(ComponentLevelsConfigKey); value != nil {
+		levelMapValue, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected map of component names to levels, got %T", ComponentLevelsConfigKey, value)
+		}
+		newComponentLevels = make(map[string]logrus.Level, len(levelMapValue))
+		for component, v := range levelMapValue {
+			level, err := parseConfigLevel(v)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", ComponentLevelsConfigKey, component, err)
+			}
+			newComponentLevels[strings.ToLower(component)] = level
+		}
+	}
+
+	if oldLevel := getPrimaryLevel(); newLevel != nil && *newLevel != oldLevel {
+		// log the change at whichever level is more verbose, so it's visible before or after the change
+		if *newLevel > oldLevel {
+			setPrimaryLevel(*newLevel)
+		}
+		ownLogger.Infof("log level changed from %s to %s", reverseLevelMap[oldLevel], reverseLevelMap[*newLevel])
+		setPrimaryLevel(*newLevel)
+	}
+	if newComponentLevels != nil {
+		if changes := diffComponentLevels(getComponentLevels(), newComponentLevels); len(changes) > 0 {
+			ownLogger.Infof("component log levels changed: %s", strings.Join(changes, ", "))
+			setComponentLevels(newComponentLevels)
+		}
+	}
+	return nil
+}
+
+func parseConfigLevel(value interface{}) (logrus.Level, error) {
+	text, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("expected level name, got %T", value)
+	}
+	level, exists := levelMap[LogLevel(strings.ToLower(text))]
+	if !exists {
+		return 0, fmt.Errorf("invalid log level: '%s'", text)
+	}
+	return level, nil
+}
+
+// diffComponentLevels describes the changes of component levels, e.g. "Cacher: info => debug"
+func diffComponentLevels(oldLevels map[string]logrus.Level, newLevels map[string]logrus.Level) []string {
+	changes := make([]string, 0, len(newLevels))
+	for component, newLevel := range newLevels {
+		oldLevel, exists := oldLevels[component]
+		switch {
+		case !exists:
+			changes = append(changes, fmt.Sprintf("%s: (default) => %s", component, reverseLevelMap[newLevel]))
+		case oldLevel != newLevel:
+			changes = append(changes, fmt.Sprintf("%s: %s => %s", component, reverseLevelMap[oldLevel], reverseLevelMap[newLevel]))
+		}
+	}
+	for component, oldLevel := range oldLevels {
+		if _, exists := newLevels[component]; !exists {
+			changes = append(changes, fmt.Sprintf("%s: %s => (default)", component, reverseLevelMap[oldLevel]))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/envutil"
 	"github.com/relex/gotils/logger/priv"
 	"github.com/relex/gotils/promexporter/promext"
 	"github.com/sirupsen/logrus"
@@ -103,43 +104,48 @@ var (
 )
 
 func init() {
-	SetAutoFormat()
+	root.entry.Logger.SetOutput(primaryOutput)
+	root.entry.Logger.AddHook(globalFieldsHook{})
 	SetDefaultLevel()
+	SetDefaultComponentLevels()
+	SetAutoFormat()
 	setDefaultUpstream()
-	prometheus.MustRegister(counterVec)
+	setDefaultSystemOutput()
+	setDefaultSentry()
+	promext.SafeRegister(counterVec)
 }
 
 // SetAutoFormat uses the environment variable `LOG_COLOR` and terminal detection to select console or text output format
 //
 // SetAutoFormat is the default choice and always invoked during initialization
 func SetAutoFormat() {
-	colorYN := strings.ToLower(os.Getenv("LOG_COLOR"))
+	colorYN := strings.ToLower(envutil.Get("LOG_COLOR", ""))
 	switch colorYN {
 	case "1", "true", "y", "yes", "on":
-		root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(true, priv.TextFormatter))
+		setFormatter(priv.NewConsoleLogFormatter(true, priv.TextFormatter))
 	case "0", "false", "n", "no", "off":
-		root.entry.Logger.SetFormatter(priv.TextFormatter)
+		setFormatter(priv.TextFormatter)
 	case "", "auto":
-		root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(false, priv.TextFormatter))
+		setFormatter(priv.NewConsoleLogFormatter(false, priv.TextFormatter))
 	default:
 		ownLogger.Errorf("Invalid LOG_COLOR value: '%s', select 'auto' with text as fallback", colorYN)
-		root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(false, priv.TextFormatter))
+		setFormatter(priv.NewConsoleLogFormatter(false, priv.TextFormatter))
 	}
 }
 
 // SetAutoJSONFormat uses the environment variable `LOG_COLOR` and terminal detection to select console or JSON output format
 func SetAutoJSONFormat() {
-	colorYN := strings.ToLower(os.Getenv("LOG_COLOR"))
+	colorYN := strings.ToLower(envutil.Get("LOG_COLOR", ""))
 	switch colorYN {
 	case "1", "true", "y", "yes", "on":
-		root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(true, priv.JSONFormatter))
+		setFormatter(priv.NewConsoleLogFormatter(true, priv.SharedJSONFormatter))
 	case "0", "false", "n", "no", "off":
-		root.entry.Logger.SetFormatter(priv.JSONFormatter)
+		setFormatter(priv.SharedJSONFormatter)
 	case "", "auto":
-		root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(false, priv.JSONFormatter))
+		setFormatter(priv.NewConsoleLogFormatter(false, priv.SharedJSONFormatter))
 	default:
 		ownLogger.Errorf("Invalid LOG_COLOR value: '%s', select 'auto' with JSON as fallback", colorYN)
-		root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(false, priv.JSONFormatter))
+		setFormatter(priv.NewConsoleLogFormatter(false, priv.SharedJSONFormatter))
 	}
 }
 
@@ -147,19 +153,19 @@ func SetAutoJSONFormat() {
 //
 //	{"timestamp":"2006/02/01T15:04:05.123+0200","level":"info","message":"A group of walrus emerges from theocean"}
 func SetJSONFormat() {
-	root.entry.Logger.SetFormatter(priv.JSONFormatter)
+	setFormatter(priv.SharedJSONFormatter)
 }
 
 // SetTextFormat sets the default text format. For example:
 //
 //	time="2006/02/01T15:04:05.123+0200" level=debug msg="Started observing beach"
 func SetTextFormat() {
-	root.entry.Logger.SetFormatter(priv.TextFormatter)
+	setFormatter(priv.TextFormatter)
 }
 
 // SetDefaultLevel sets the default logging level depending on environment variable "LOG_LEVEL"
 func SetDefaultLevel() {
-	level := os.Getenv("LOG_LEVEL")
+	level := envutil.Get("LOG_LEVEL", "")
 	if len(level) == 0 {
 		SetLogLevel(InfoLevel)
 		return
@@ -170,11 +176,35 @@ func SetDefaultLevel() {
 		ownLogger.Errorf("Invalid LOG_LEVEL value: '%s', select 'info'", level)
 		logrusLevel = logrus.InfoLevel
 	}
-	root.entry.Logger.SetLevel(logrusLevel)
+	setPrimaryLevel(logrusLevel)
 }
 
+// SetDefaultComponentLevels sets levels of components from environment variable "LOG_LEVELS", replacing all previous
+// component levels, e.g. "Cacher=debug,MetricListener=warn"
+//
+// Invalid entries are logged as errors and ignored.
+func SetDefaultComponentLevels() {
+	levels := make(map[string]logrus.Level)
+	for _, entry := range strings.Split(envutil.Get("LOG_LEVELS", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		component, level, found := strings.Cut(entry, "=")
+		logrusLevel, exists := levelMap[LogLevel(strings.ToLower(strings.TrimSpace(level)))]
+		component = strings.TrimSpace(component)
+		if !found || !exists || component == "" {
+			ownLogger.Errorf("Invalid LOG_LEVELS entry: '%s', ignored", entry)
+			continue
+		}
+		levels[strings.ToLower(component)] = logrusLevel
+	}
+	setComponentLevels(levels)
+}
+
+// GetLogLevel gets the level of the root logger
 func GetLogLevel() LogLevel {
-	return reverseLevelMap[root.entry.Logger.GetLevel()]
+	return reverseLevelMap[getPrimaryLevel()]
 }
 
 // SetLogLevel sets the level of the root logger
@@ -183,12 +213,28 @@ func SetLogLevel(level LogLevel) {
 	if !exists {
 		ownLogger.Fatalf("Invalid log level: '%s'", level)
 	}
-	root.entry.Logger.SetLevel(logrusLevel)
+	setPrimaryLevel(logrusLevel)
 }
 
 // SetOutput configures the root logger to output into specified Writer
 func SetOutput(output io.Writer) {
-	root.entry.Logger.SetOutput(output)
+	primaryOutput.set(output)
+}
+
+// SetSplitOutput configures the root logger to output entries at the severe level or above into the severe Writer and
+// the rest into the normal Writer, e.g. warnings and errors to stderr and others to stdout for container platforms
+//
+// The order of entries is preserved within each Writer. Calling SetOutput afterwards replaces both.
+func SetSplitOutput(normal io.Writer, severe io.Writer, severeLevel LogLevel) {
+	logrusLevel, exists := levelMap[severeLevel]
+	if !exists {
+		ownLogger.Fatalf("Invalid log level: '%s'", severeLevel)
+	}
+	SetOutput(&priv.SplitWriter{
+		Normal:    normal,
+		Severe:    severe,
+		Threshold: logrusLevel,
+	})
 }
 
 // SetOutputFile configure the root logger to write into specified file
@@ -202,26 +248,69 @@ func SetOutputFile(path string) error {
 }
 
 func setDefaultUpstream() {
-	if upstreamEndpoint := os.Getenv("LOG_UPSTREAM"); upstreamEndpoint != "" {
+	if upstreamEndpoint := envutil.Get("LOG_UPSTREAM", ""); upstreamEndpoint != "" {
 		SetUpstreamEndpoint(upstreamEndpoint)
 	}
 }
 
+// UpstreamOptions defines the queue and flushing of the buffered upstream for remote endpoints
+type UpstreamOptions = priv.UpstreamBufferOptions
+
+// UpstreamOverflowPolicy defines what the buffered upstream does when its queue is full
+type UpstreamOverflowPolicy = priv.OverflowPolicy
+
+// Upstream overflow policies
+const (
+	UpstreamOverflowBlock      = priv.OverflowBlock      // block logging until there is space in the queue
+	UpstreamOverflowDropOldest = priv.OverflowDropOldest // drop the oldest log in the queue to make space
+	UpstreamOverflowDropNewest = priv.OverflowDropNewest // drop the new log
+)
+
 // SetUpstreamEndpoint configures the root logger to duplicate and forward all logs to upstream
 // This function should be called at most once.
 func SetUpstreamEndpoint(endpoint string) {
+	SetUpstreamEndpointWithOptions(endpoint, UpstreamOptions{})
+}
+
+// SetUpstreamEndpointWithOptions configures the root logger to forward all logs to upstream like SetUpstreamEndpoint,
+// with custom options for remote endpoints. Local endpoints are unbuffered and not affected.
+//
+// Logs dropped due to full queue are reported to stderr periodically and counted in the metric
+// "logger_upstream_dropped_total".
+func SetUpstreamEndpointWithOptions(endpoint string, opts UpstreamOptions) {
 	host, _, err := net.SplitHostPort(endpoint)
 	if err != nil {
 		ownLogger.Errorf("Unable to parse upstream endpoint '%s': %v", endpoint, err)
 		return
 	}
+	validateUpstreamOptions(opts)
 	var hook logrus.Hook
 	if isLocalhost(host) {
 		hook = priv.NewUpstreamTCPUnbufferedHook(endpoint)
 	} else {
-		hook = priv.NewUpstreamTCPBufferedHook(endpoint)
+		hook = priv.NewUpstreamTCPBufferedHookWithOptions(endpoint, opts)
+	}
+	root.entry.Logger.Hooks.Add(primaryLevelHook{hook})
+}
+
+// UpstreamTransport sends logs to custom upstream, see SetUpstreamTransport
+type UpstreamTransport = priv.UpstreamTransport
+
+// SetUpstreamTransport configures the root logger to forward all logs to upstream by a custom transport, e.g. for
+// Kafka or HTTP bulk API, with the same buffering, retry and flushing at exit as remote endpoints
+//
+// The name is printed with internal errors of upstream to stderr. This function should be called at most once.
+func SetUpstreamTransport(name string, transport UpstreamTransport, opts UpstreamOptions) {
+	validateUpstreamOptions(opts)
+	root.entry.Logger.Hooks.Add(primaryLevelHook{priv.NewUpstreamBufferedHook(name, transport, opts)})
+}
+
+func validateUpstreamOptions(opts UpstreamOptions) {
+	switch opts.OverflowPolicy {
+	case "", UpstreamOverflowBlock, UpstreamOverflowDropOldest, UpstreamOverflowDropNewest:
+	default:
+		ownLogger.Fatalf("Invalid upstream overflow policy: '%s'", opts.OverflowPolicy)
 	}
-	root.entry.Logger.Hooks.Add(hook)
 }
 
 func isLocalhost(host string) bool {
@@ -234,20 +323,6 @@ func isLocalhost(host string) bool {
 	return false
 }
 
-// AtExit registers a function to be called when the program is shut down.
-//
-// AtExit can be called multiple times and functions registered are called in reverse order (like "defer").
-//
-// Since go doesn't provide any shutdown callback at the moment, the mechanism only works when application quits by calling Exit() here.
-func AtExit(handler func()) {
-	logrus.DeferExitHandler(handler)
-}
-
-// Exit quits the program by calling exit on the underlying logger and flushes all remaining logs if any
-func Exit(code int) {
-	logrus.Exit(code)
-}
-
 /*****************************************************************************
  * Logging via the root logger
  *****************************************************************************/
@@ -358,71 +433,107 @@ func (logger Logger) Panicf(format string, args ...interface{}) {
 // Fatal logs critical errros
 func (logger Logger) Fatal(args ...interface{}) {
 	logger.counterForFatal.Inc()
-	getMergedEntryFromArgs(logger.entry, args).Fatal(args...)
+	entry := getMergedEntryFromArgs(logger.entry, args)
+	entry.Log(logrus.FatalLevel, args...) // same as entry.Fatal, with flushers called before exit
+	runFlushers()
+	entry.Logger.Exit(1)
 }
 
 // Fatalf logs critical errros with formatting
 func (logger Logger) Fatalf(format string, args ...interface{}) {
 	logger.counterForFatal.Inc()
-	getMergedEntryFromArgs(logger.entry, args).Fatalf(format, args...)
+	entry := getMergedEntryFromArgs(logger.entry, args)
+	entry.Logf(logrus.FatalLevel, format, args...) // same as entry.Fatalf, with flushers called before exit
+	runFlushers()
+	entry.Logger.Exit(1)
 }
 
 // Error logs errors via the root logger
 func (logger Logger) Error(args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.ErrorLevel) {
+		return
+	}
 	logger.counterForError.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Error(args...)
 }
 
 // Errorf logs errors with formatting
 func (logger Logger) Errorf(format string, args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.ErrorLevel) {
+		return
+	}
 	logger.counterForError.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Errorf(format, args...)
 }
 
 // Warn logs warnings
 func (logger Logger) Warn(args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.WarnLevel) {
+		return
+	}
 	logger.counterForWarn.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Warn(args...)
 }
 
 // Warnf logs warnings with formatting
 func (logger Logger) Warnf(format string, args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.WarnLevel) {
+		return
+	}
 	logger.counterForWarn.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Warnf(format, args...)
 }
 
 // Info logs information
 func (logger Logger) Info(args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.InfoLevel) {
+		return
+	}
 	logger.counterForInfo.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Info(args...)
 }
 
 // Infof logs information with formatting
 func (logger Logger) Infof(format string, args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.InfoLevel) {
+		return
+	}
 	logger.counterForInfo.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Infof(format, args...)
 }
 
 // Debug logs debugging information
 func (logger Logger) Debug(args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
 	logger.counterForDebug.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Debug(args...)
 }
 
 // Debugf logs debugging information with formatting
 func (logger Logger) Debugf(format string, args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
 	logger.counterForDebug.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Debugf(format, args...)
 }
 
 // Trace logs tracing information
 func (logger Logger) Trace(args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.TraceLevel) {
+		return
+	}
 	logger.counterForTrace.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Trace(args...)
 }
 
 // Tracef logs tracing information with formatting
 func (logger Logger) Tracef(format string, args ...interface{}) {
+	if !logger.entry.Logger.IsLevelEnabled(logrus.TraceLevel) {
+		return
+	}
 	logger.counterForTrace.Inc()
 	getMergedEntryFromArgs(logger.entry, args).Tracef(format, args...)
 }
@@ -492,7 +603,7 @@ func (logger Logger) WithFields(fields map[string]interface{}) Logger {
 	return wrapLogger(entry, logger)
 }
 
-// NewWriter creates an io.Writer on this logger. Each .Write() call would log a message.
+// NewWriter creates an io.Writer on this logger. Each .Write() call would log a message, without trailing line breaks.
 func (logger Logger) NewWriter(level LogLevel) io.Writer {
 	return newLogWriter(logger, level)
 }
@@ -540,6 +651,7 @@ func wrapLogger(entry *logrus.Entry, parent Logger) Logger {
 
 func wrapLoggerWithNewComponent(entry *logrus.Entry, component interface{}) Logger {
 	compName := fmt.Sprint(component)
+	registerComponent(compName)
 	return Logger{
 		entry:           entry,
 		counterForPanic: counterVec.WithLabelValues(compName, string(PanicLevel)),
@@ -15,16 +15,28 @@ package logger
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/relex/gotils/logger/priv"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -78,6 +90,32 @@ func TestConsoleLogger(t *testing.T) {
 	Error("some error")
 }
 
+func TestConsoleTheme(t *testing.T) {
+	formatter := priv.NewConsoleLogFormatter(true, nil)
+	entry := WithField("component", "Beach").entry.WithField("name", "foo")
+	entry.Level = logrus.WarnLevel
+	entry.Message = "walrus spotted"
+
+	SetConsoleTheme(ConsoleTheme{
+		LevelColors:          map[LogLevel]ConsoleColor{WarnLevel: Color256(130), ErrorLevel: TrueColor(200, 0, 16)},
+		NoComponentUnderline: true,
+	})
+	out, err := formatter.Format(entry)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "\x1b[38;5;130m\x1b[1m")
+	assert.Contains(t, string(out), "\x1b[38;5;130mBeach\x1b[0m")
+	assert.NotContains(t, string(out), "\x1b[4m")
+
+	entry.Level = logrus.InfoLevel
+	out, _ = formatter.Format(entry)
+	assert.Contains(t, string(out), string(ColorYellow)+"walrus spotted")
+
+	SetConsoleTheme(DefaultConsoleTheme)
+	entry.Level = logrus.WarnLevel
+	out, _ = formatter.Format(entry)
+	assert.Contains(t, string(out), string(ColorMagenta)+"\x1b[4mBeach")
+}
+
 func TestFallbackTextLogger(t *testing.T) {
 	before()
 	root.entry.Logger.SetFormatter(priv.NewConsoleLogFormatter(false, nil))
@@ -175,6 +213,39 @@ func TestForwardBuffered(t *testing.T) {
 	assert.True(t, strings.Contains(logs[3], "{\"key1\":\"val1\",\"key2\":\"val2\",\"key3\":\"val3\",\"level\":\"warning\",\"message\":\"OK\""))
 }
 
+type testUpstreamTransport struct {
+	lines chan string
+}
+
+func (t *testUpstreamTransport) Connect() error {
+	return nil
+}
+
+func (t *testUpstreamTransport) Send(lines []string) error {
+	for _, line := range lines {
+		t.lines <- line
+	}
+	return nil
+}
+
+func (t *testUpstreamTransport) Close() error {
+	return nil
+}
+
+func TestForwardCustomTransport(t *testing.T) {
+	transport := &testUpstreamTransport{make(chan string, 10000)}
+	SetUpstreamTransport("test", transport, UpstreamOptions{BatchSize: 10})
+	before()
+	WithField("key1", "val1").Warn("custom transport")
+	after()
+	select {
+	case line := <-transport.lines:
+		assert.Contains(t, line, "{\"key1\":\"val1\",\"level\":\"warning\",\"message\":\"custom transport\"")
+	case <-time.After(time.Second):
+		assert.Fail(t, "no log forwarded")
+	}
+}
+
 func TestForwardUnbuffered(t *testing.T) {
 	upstreamLogCollector := make(chan string, 10000)
 	doneChannel := make(chan bool)
@@ -240,6 +311,149 @@ func TestStructuredError(t *testing.T) {
 	assert.True(t, errors.Is(outsideLogger.Ewrap(os.ErrNotExist), os.ErrNotExist))
 }
 
+func TestSecondaryOutput(t *testing.T) {
+	before()
+	secondary := &bytes.Buffer{}
+	SetSecondaryOutput(secondary, JSONFormat, DebugLevel)
+	Debug("only secondary")
+	Info("both outputs")
+	SetSecondaryOutput(nil, JSONFormat, DebugLevel)
+	Info("only primary")
+
+	body := readLogFile()
+	assert.NotContains(t, body, "only secondary")
+	assert.Contains(t, body, "level=info msg=\"both outputs\"")
+	assert.Contains(t, body, "level=info msg=\"only primary\"")
+	assert.Contains(t, secondary.String(), "{\"level\":\"debug\",\"message\":\"only secondary\"")
+	assert.Contains(t, secondary.String(), "{\"level\":\"info\",\"message\":\"both outputs\"")
+	assert.NotContains(t, secondary.String(), "only primary")
+	assert.Equal(t, InfoLevel, GetLogLevel())
+	after()
+}
+
+func TestAddOutput(t *testing.T) {
+	before()
+	debugOutput := &bytes.Buffer{}
+	warnOutput := &bytes.Buffer{}
+	removeDebugOutput := AddOutput(debugOutput, JSONFormat, DebugLevel)
+	removeWarnOutput := AddOutput(warnOutput, TextFormat, WarnLevel)
+	Debug("debug log")
+	Warn("warn log")
+	removeDebugOutput()
+	removeDebugOutput()
+	Debug("debug after removal")
+	Warn("warn after removal")
+	assert.Equal(t, logrus.InfoLevel, root.entry.Logger.GetLevel(), "removal should restore the level of underlying logger")
+	removeWarnOutput()
+
+	body := readLogFile()
+	assert.NotContains(t, body, "debug log")
+	assert.Contains(t, body, "level=warning msg=\"warn log\"")
+	assert.Contains(t, debugOutput.String(), "{\"level\":\"debug\",\"message\":\"debug log\"")
+	assert.Contains(t, debugOutput.String(), "{\"level\":\"warning\",\"message\":\"warn log\"")
+	assert.NotContains(t, warnOutput.String(), "debug log")
+	assert.Contains(t, warnOutput.String(), "level=warning msg=\"warn log\"")
+	assert.NotContains(t, debugOutput.String(), "after removal")
+	assert.Contains(t, warnOutput.String(), "level=warning msg=\"warn after removal\"")
+	assert.Panics(t, func() { AddOutput(nil, TextFormat, InfoLevel) })
+	after()
+}
+
+func TestNamedOutput(t *testing.T) {
+	before()
+	report := &bytes.Buffer{}
+	AddNamedOutput("report", report, TextFormat, DebugLevel)
+	reportLogger := WithField("job", "daily").ToOutput("report")
+	reportLogger.Debug("report details")
+	reportLogger.WithField("rows", 3).Info("report summary")
+	Info("regular log")
+
+	body := readLogFile()
+	assert.NotContains(t, body, "report")
+	assert.Contains(t, body, "msg=\"regular log\"")
+	assert.Contains(t, report.String(), "level=debug msg=\"report details\" job=daily")
+	assert.Contains(t, report.String(), "level=info msg=\"report summary\" job=daily rows=3")
+	assert.NotContains(t, report.String(), "regular log")
+	assert.Panics(t, func() { Root().ToOutput("unknown") })
+
+	AddNamedOutput("report", nil, TextFormat, DebugLevel)
+	after()
+}
+
+func TestDedupe(t *testing.T) {
+	before()
+	SetDedupeWindow(time.Hour)
+	dbLogger := WithField("component", "DB")
+	for i := 0; i < 5; i++ {
+		dbLogger.Error("connection refused")
+	}
+	dbLogger.WithField("attempt", 2).Error("connection refused")
+	Info("recovered")
+	Info("recovered")
+	SetDedupeWindow(0)
+	Info("recovered")
+
+	lines := strings.Split(strings.TrimSpace(readLogFile()), "\n")
+	if assert.Len(t, lines, 6) {
+		assert.Contains(t, lines[0], "level=error msg=\"connection refused\" component=DB")
+		assert.NotContains(t, lines[0], "repeated")
+		assert.Contains(t, lines[1], "level=error msg=\"connection refused\" component=DB repeated=4")
+		assert.Contains(t, lines[2], "level=error msg=\"connection refused\" attempt=2 component=DB")
+		assert.Contains(t, lines[3], "level=info msg=recovered")
+		assert.Contains(t, lines[4], "level=info msg=recovered repeated=1")
+		assert.Contains(t, lines[5], "level=info msg=recovered")
+	}
+	after()
+}
+
+func TestDedupeWindowEnd(t *testing.T) {
+	before()
+	SetDedupeWindow(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		Warn("flapping")
+	}
+	assert.Eventually(t, func() bool {
+		return strings.Contains(readLogFile(), "level=warning msg=flapping repeated=2\n")
+	}, time.Second, 10*time.Millisecond)
+	Warn("flapping")
+	SetDedupeWindow(0)
+
+	lines := strings.Split(strings.TrimSpace(readLogFile()), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.NotContains(t, lines[2], "repeated")
+	}
+	after()
+}
+
+func TestForwardCommandOutput(t *testing.T) {
+	before()
+	cmd := exec.Command("sh", "-c", `echo 'plain line'; echo 'level=debug msg=details' >&2; echo '[ERROR] failed'; `+
+		`echo '{"level":"warning","msg":"slow","time":"x","items":2}'; echo 'FATAL crashed' >&2; printf 'last'`)
+	flush := WithField("job", "test").ForwardCommandOutput(cmd, CommandOutputOptions{Name: "mytool", ParseJSON: true})
+	assert.NoError(t, cmd.Run())
+	flush()
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=info msg=\"plain line\" component=mytool job=test")
+	assert.NotContains(t, body, "details")
+	assert.Contains(t, body, "level=error msg=\"[ERROR] failed\" component=mytool job=test")
+	assert.Contains(t, body, "level=warning msg=slow component=mytool items=2 job=test")
+	assert.Contains(t, body, "level=error msg=\"FATAL crashed\" component=mytool job=test")
+	assert.Contains(t, body, "level=info msg=last component=mytool job=test")
+	after()
+}
+
+func TestListComponents(t *testing.T) {
+	WithField(priv.LabelComponent, "ZetaComponent").WithFields(Fields{priv.LabelComponent: "AlphaComponent"})
+	WithField("other", "NotComponent")
+
+	components := ListComponents()
+	assert.Contains(t, components, "AlphaComponent")
+	assert.Contains(t, components, "ZetaComponent")
+	assert.NotContains(t, components, "NotComponent")
+	assert.True(t, sort.StringsAreSorted(components))
+}
+
 func startUpstreamListener(endpoint string, logCollector chan string, maxLogs int, doneChannel chan bool) {
 	lsnr, err := net.Listen("tcp", endpoint)
 	if err != nil {
@@ -281,3 +495,405 @@ func collectLogs(logChan chan string) []string {
 		}
 	}
 }
+
+func TestOperation(t *testing.T) {
+	before()
+	op := WithField("component", "Importer").BeginOperation("import", Fields{"file": "a.csv"})
+	op.AddField("rows", 3)
+	op.Logger().Info("step done")
+	op.Success()
+	assert.Panics(t, func() { op.Fail(errors.New("late")) })
+	BeginOperation("export", nil).Fail(errors.New("disk full"))
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=info msg=\"operation started\" component=Importer file=a.csv operation=import")
+	assert.Contains(t, body, "level=info msg=\"step done\" component=Importer file=a.csv operation=import rows=3")
+	assert.Regexp(t, "level=info msg=\"operation succeeded\" component=Importer duration=[^ ]+ file=a.csv operation=import outcome=success rows=3", body)
+	assert.Regexp(t, "level=error msg=\"operation failed: disk full\" duration=[^ ]+ operation=export outcome=failure", body)
+	assert.Equal(t, `logger_operations_total{component="(root)",operation="export",outcome="failure"} 1
+logger_operations_total{component="Importer",operation="import",outcome="success"} 1
+`, promext.DumpMetrics("logger_operations_total", true, true))
+
+	creator := &testMetricCreator{prefix: "test_"}
+	SetOperationMetricCreator(creator)
+	BeginOperation("export", nil).Success()
+	SetOperationMetricCreator(nil)
+	BeginOperation("export", nil).Success()
+	assert.Equal(t, `test_logger_operations_total{component="(root)",operation="export",outcome="success"} 1
+`, promext.DumpMetricsFrom("test_logger_operations_total", true, true, creator.collectors...))
+	assert.Contains(t, promext.DumpMetrics("logger_operations_total", true, true),
+		`logger_operations_total{component="(root)",operation="export",outcome="success"} 1`)
+	after()
+}
+
+// testMetricCreator creates unregistered metrics with the prefix, since promreg cannot be imported here
+type testMetricCreator struct {
+	prefix     string
+	collectors []prometheus.Collector
+}
+
+func (c *testMetricCreator) AddOrGetCounterVec(name string, help string, labelNames []string, leftmostLabelValues []string) *promext.RWCounterVec {
+	vec := promext.NewRWCounterVec(prometheus.CounterOpts{Name: c.prefix + name, Help: help}, labelNames)
+	c.collectors = append(c.collectors, vec)
+	return vec
+}
+
+func TestCountMatches(t *testing.T) {
+	before()
+	pattern := regexp.MustCompile(`(?P<op>query|exec) timed out`)
+	remove, err := CountMatches("logger_test_timeouts_total", ErrorLevel, pattern, "component")
+	assert.NoError(t, err)
+	_, err = CountMatches("logger_test_timeouts_total", ErrorLevel, pattern, "component")
+	assert.EqualError(t, err, "failed to count matches for 'logger_test_timeouts_total': duplicate name")
+	_, err = CountMatches("logger_test_other_total", "verbose", pattern)
+	assert.EqualError(t, err, "failed to count matches for 'logger_test_other_total': invalid log level 'verbose'")
+
+	dbLogger := WithField("component", "DB")
+	dbLogger.Error("query timed out after 5s")
+	dbLogger.Errorf("%s timed out", "query")
+	dbLogger.Warn("exec timed out")
+	dbLogger.Error("connection refused")
+	Error("exec timed out")
+	assert.Equal(t, `logger_test_timeouts_total{component="",op="exec"} 1
+logger_test_timeouts_total{component="DB",op="query"} 2
+`, promext.DumpMetrics("logger_test_timeouts_total", true, true))
+
+	remove()
+	Error("exec timed out")
+	assert.Empty(t, promext.DumpMetrics("logger_test_timeouts_total", true, true))
+	remove, err = CountMatches("logger_test_timeouts_total", ErrorLevel, pattern)
+	assert.NoError(t, err, "removed counter should be added again")
+	remove()
+	after()
+}
+
+func TestBindConfig(t *testing.T) {
+	before()
+	conf := map[string]interface{}{
+		"log_level":            "warn",
+		"log_component_levels": map[string]interface{}{"cacher": "debug"},
+	}
+	reload := BindConfig(func(key string) interface{} { return conf[key] })
+	assert.Equal(t, WarnLevel, GetLogLevel())
+	assert.Equal(t, map[string]LogLevel{"cacher": DebugLevel}, GetComponentLevels())
+
+	cacherLogger := WithField("component", "Cacher")
+	cacherLogger.Debug("cacher details")
+	WithField("component", "Other").Info("other info")
+	Warn("root warning")
+
+	conf["log_level"] = "verbose"
+	reload()
+	assert.Equal(t, WarnLevel, GetLogLevel())
+
+	conf["log_level"] = "info"
+	conf["log_component_levels"] = map[string]interface{}{"other": "error"}
+	reload()
+	cacherLogger.Debug("cacher hidden")
+	WithField("component", "Other").Warn("other hidden")
+	Info("root info")
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=debug msg=\"cacher details\" component=Cacher")
+	assert.NotContains(t, body, "other info")
+	assert.Contains(t, body, "level=warning msg=\"root warning\"")
+	assert.Contains(t, body, "level=error msg=\"failed to apply log levels from config: log_level: invalid log level: 'verbose'\"")
+	assert.Contains(t, body, "level=info msg=\"log level changed from warn to info\"")
+	assert.Contains(t, body, "level=info msg=\"component log levels changed: cacher: debug => (default), other: (default) => error\"")
+	assert.NotContains(t, body, "hidden")
+	assert.Contains(t, body, "level=info msg=\"root info\"")
+
+	ResetComponentLevels()
+	after()
+}
+
+func TestDefaultComponentLevels(t *testing.T) {
+	before()
+	os.Setenv("LOG_LEVELS", " Cacher=debug, MetricListener = WARN,broken,Other=verbose,")
+	SetDefaultComponentLevels()
+	assert.Equal(t, map[string]LogLevel{"cacher": DebugLevel, "metriclistener": WarnLevel}, GetComponentLevels())
+
+	WithField("component", "Cacher").Debug("cacher details")
+	WithField("component", "MetricListener").Info("listener hidden")
+
+	os.Unsetenv("LOG_LEVELS")
+	SetDefaultComponentLevels()
+	assert.Empty(t, GetComponentLevels())
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=error msg=\"Invalid LOG_LEVELS entry: 'broken', ignored\"")
+	assert.Contains(t, body, "level=error msg=\"Invalid LOG_LEVELS entry: 'Other=verbose', ignored\"")
+	assert.Contains(t, body, "level=debug msg=\"cacher details\" component=Cacher")
+	assert.NotContains(t, body, "hidden")
+	after()
+}
+
+func TestSplitOutput(t *testing.T) {
+	before()
+	normal := &bytes.Buffer{}
+	severe := &bytes.Buffer{}
+	SetSplitOutput(normal, severe, WarnLevel)
+	Info("info 1")
+	Debug("hidden")
+	Warn("warn 1")
+	Info("info 2")
+	Error("error 1")
+	SetDedupeWindow(time.Hour)
+	Error("error 2")
+	Error("error 2")
+	Info("info 3")
+	SetDedupeWindow(0)
+
+	assert.Equal(t, []string{"level=info msg=\"info 1\"", "level=info msg=\"info 2\"", "level=info msg=\"info 3\""}, getLogLineSuffixes(normal.String()))
+	assert.Equal(t, []string{"level=warning msg=\"warn 1\"", "level=error msg=\"error 1\"", "level=error msg=\"error 2\"", "level=error msg=\"error 2\" repeated=1"}, getLogLineSuffixes(severe.String()))
+	after()
+}
+
+// getLogLineSuffixes returns lines of text logs starting from "level="
+func getLogLineSuffixes(body string) []string {
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = line[strings.Index(line, "level="):]
+	}
+	return lines
+}
+
+func TestStdLogger(t *testing.T) {
+	before()
+	stdLogger := NewStdLogger("HTTPServer", WarnLevel)
+	stdLogger.Printf("http: TLS handshake error from %s: EOF", "127.0.0.1:1234")
+	WithField("component", "DB").NewStdLogger(warningLevel).Print("slow query\n\n")
+	NewStdLogger("Unused", DebugLevel).Print("debug log")
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=warning msg=\"http: TLS handshake error from 127.0.0.1:1234: EOF\" component=HTTPServer\n")
+	assert.Contains(t, body, "level=warning msg=\"slow query\" component=DB\n")
+	assert.NotContains(t, body, "debug log")
+	after()
+}
+
+func TestGlobalFieldsAndJSONFieldNames(t *testing.T) {
+	before()
+	SetJSONFieldNames(JSONFieldNames{Timestamp: "@timestamp", Level: "log.level"})
+	assert.Equal(t, "timestamp", priv.JSONFormatter.FieldMap[logrus.FieldKeyTime], "exported formatter should be kept")
+	SetGlobalFields(Fields{"service": "walrus", "env": "test"})
+	SetJSONFormat()
+	WithField("env", "override").Info("with global fields")
+	SetGlobalFields(nil)
+	SetJSONFieldNames(JSONFieldNames{})
+	Info("without global fields")
+
+	body := readLogFile()
+	assert.Contains(t, body, "{\"@timestamp\":\"")
+	assert.Contains(t, body, "\"env\":\"override\",\"log.level\":\"info\",\"message\":\"with global fields\",\"service\":\"walrus\"}\n")
+	assert.Contains(t, body, "{\"level\":\"info\",\"message\":\"without global fields\",\"timestamp\":\"")
+	after()
+}
+
+func TestExitHandlers(t *testing.T) {
+	before()
+	SetLogLevel(DebugLevel)
+	exitLock.Lock()
+	savedHandlers := exitHandlers
+	exitHandlers = nil
+	exitLock.Unlock()
+
+	var calls []string
+	var callsLock sync.Mutex
+	record := func(name string) {
+		callsLock.Lock()
+		calls = append(calls, name)
+		callsLock.Unlock()
+	}
+	RegisterExitHandler("close-db", ExitPriorityLate, 0, func(ctx context.Context) { record("close-db") })
+	AtExit(func() { record("at-exit") })
+	RegisterExitHandler("stop-server", ExitPriorityEarly, 0, func(ctx context.Context) { record("stop-server") })
+	RegisterExitHandler("hung", ExitPriorityNormal, 50*time.Millisecond, func(ctx context.Context) {
+		record("hung")
+		time.Sleep(time.Hour)
+	})
+	RegisterExitHandler("broken", ExitPriorityNormal, 0, func(ctx context.Context) { panic("oops") })
+	runExitHandlers()
+	runExitHandlers()
+	assert.Equal(t, []string{"stop-server", "hung", "at-exit", "close-db"}, calls,
+		"handlers should be called once by priority and then in reverse order")
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=debug msg=\"exit handler completed in ")
+	assert.Contains(t, body, "level=warning msg=\"exit handler timed out after 50ms\" component=logger handler=hung\n")
+	assert.Contains(t, body, "level=error msg=\"exit handler panicked: oops\" component=logger handler=broken\n")
+	assert.Contains(t, body, "handler=github.com/relex/gotils/logger.TestExitHandlers.func")
+
+	exitLock.Lock()
+	exitHandlers = savedHandlers
+	exitHandled = false
+	exitLock.Unlock()
+	SetLogLevel(InfoLevel)
+	after()
+}
+
+func TestFlushers(t *testing.T) {
+	before()
+	var calls []string
+	SetFlushTimeout(time.Second)
+	RegisterFlusher(func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		calls = append(calls, "first")
+		return nil
+	})
+	RegisterFlusher(func(ctx context.Context) error {
+		calls = append(calls, "second")
+		return errors.New("push failed")
+	})
+	unregister := RegisterFlusher(func(ctx context.Context) error {
+		calls = append(calls, "unregistered")
+		return nil
+	})
+	unregister()
+	assert.Panics(t, func() { Panic("panic log") })
+	assert.Empty(t, calls, "flushers should not be called by panics")
+	runFlushers()
+	runFlushers()
+	assert.Equal(t, []string{"second", "first"}, calls, "flushers should be called once in reverse order")
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=panic msg=\"panic log\"")
+	assert.Contains(t, body, "level=warning msg=\"failed to flush at exit: push failed\" component=logger")
+
+	SetFlushTimeout(DefaultFlushTimeout)
+	after()
+}
+
+func TestSetSentryReplaces(t *testing.T) {
+	before()
+	var requestsLock sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsLock.Lock()
+		requests[r.URL.Path]++
+		requestsLock.Unlock()
+	}))
+	defer server.Close()
+	dsn := strings.Replace(server.URL, "://", "://key1@", 1)
+
+	assert.NoError(t, SetSentry(dsn+"/1", SentryOptions{}))
+	assert.NoError(t, SetSentry(dsn+"/2", SentryOptions{}))
+	Error("to sentry")
+	runFlushers()
+
+	requestsLock.Lock()
+	assert.Equal(t, map[string]int{"/api/2/envelope/": 1}, requests, "only the last Sentry hook should be used")
+	requestsLock.Unlock()
+
+	sentryLock.Lock()
+	sentryOutput.hook.Swap(nil).Close()
+	sentryLock.Unlock()
+	after()
+}
+
+func TestForRequest(t *testing.T) {
+	before()
+	r := httptest.NewRequest(http.MethodGet, "/items", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+	r.Header.Add("tracestate", "congo=t61rcWkgMzE")
+	r.Header.Set("X-Request-ID", "req-1")
+	WithField("component", "API").ForRequest(r).Info("with trace context")
+
+	r = httptest.NewRequest(http.MethodGet, "/items", nil)
+	r.Header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	r.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+	ForRequest(r).Info("with invalid trace context")
+
+	body := readLogFile()
+	assert.Contains(t, body, "level=info msg=\"with trace context\" component=API parentSpanID=00f067aa0ba902b7 requestID=req-1 traceID=4bf92f3577b34da6a3ce929d0e0e4736 traceState=\"rojo=00f067aa0ba902b7,congo=t61rcWkgMzE\"\n")
+	assert.Contains(t, body, "level=info msg=\"with invalid trace context\"\n")
+	after()
+}
+
+func TestGo(t *testing.T) {
+	before()
+	done := make(chan struct{})
+	WithField("job", "sync").Go(func(l Logger) {
+		defer close(done)
+		l.Info("in goroutine")
+		panic("boom")
+	})
+	<-done
+
+	errChan := WithField("job", "import").GoE(func(l Logger) error {
+		panic("crash")
+	})
+	err := <-errChan
+	assert.ErrorContains(t, err, "panic in goroutine: crash")
+	serr := err.(*StructuredError)
+	assert.Contains(t, serr.fields["stack"], "goroutine.go")
+	_, open := <-errChan
+	assert.False(t, open)
+
+	assert.NoError(t, <-GoE(func(l Logger) error { return nil }))
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(readLogFile(), "level=error msg=\"panic in goroutine: boom\" job=sync stack=")
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, readLogFile(), "level=info msg=\"in goroutine\" job=sync\n")
+	after()
+}
+
+// newIsolatedLogger creates a logger separate from the root, since outputs added by other tests may enable debug level
+// on the root logger
+func newIsolatedLogger(output io.Writer, level logrus.Level) Logger {
+	logrusLogger := logrus.New()
+	logrusLogger.SetOutput(output)
+	logrusLogger.SetLevel(level)
+	return wrapRootLogger(logrus.NewEntry(logrusLogger))
+}
+
+func TestDisabledLevelFastPath(t *testing.T) {
+	output := &bytes.Buffer{}
+	sub := newIsolatedLogger(output, logrus.InfoLevel).WithField("component", "FastPath")
+
+	debugCounter := sub.counterForDebug.Get()
+	count := len(os.Args) + 1000 // neither constant nor within 0-255, which would be boxed without allocation
+	var boxedCount interface{} = count
+	allocs := testing.AllocsPerRun(100, func() {
+		sub.Debugf("value %d", boxedCount)
+		sub.Trace("value")
+	})
+	assert.Zero(t, allocs)
+	assert.Equal(t, debugCounter, sub.counterForDebug.Get(), "disabled logs should not be counted")
+
+	allocs = testing.AllocsPerRun(100, func() {
+		sub.Debugf("value %d", count)
+	})
+	assert.Equal(t, 1.0, allocs, "arguments should still be boxed by the caller")
+
+	sub.Info("enabled")
+	assert.Contains(t, output.String(), "level=info msg=enabled component=FastPath\n")
+}
+
+func BenchmarkDisabledDebugf(b *testing.B) {
+	sub := newIsolatedLogger(io.Discard, logrus.InfoLevel).WithField("component", "Benchmark")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sub.Debugf("processed %d of %s", i, "items")
+	}
+}
+
+func BenchmarkDisabledDebugWithStructuredError(b *testing.B) {
+	sub := newIsolatedLogger(io.Discard, logrus.InfoLevel)
+	err := NewStructuredError(Fields{"file": "a.txt"}, errors.New("not found"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sub.Debug("failed to open: ", err)
+	}
+}
+
+func BenchmarkEnabledInfof(b *testing.B) {
+	sub := newIsolatedLogger(io.Discard, logrus.InfoLevel).WithField("component", "Benchmark")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sub.Infof("processed %d of %s", i, "items")
+	}
+}
@@ -1,31 +1,55 @@
 package logger
 
+import (
+	"log"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
 type logWriter struct {
 	lg    Logger
 	level LogLevel
 }
 
 func newLogWriter(lg Logger, level LogLevel) *logWriter {
+	if _, exists := levelMap[level]; !exists {
+		ownLogger.Fatalf("Invalid log level: '%s'", level)
+	}
 	return &logWriter{lg, level}
 }
 
 func (lw *logWriter) Write(p []byte) (n int, err error) {
-	s := string(p)
-	switch lw.level {
-	case PanicLevel:
+	s := strings.TrimRight(string(p), "\r\n")
+	if len(s) == 0 {
+		return len(p), nil
+	}
+	switch levelMap[lw.level] {
+	case logrus.PanicLevel:
 		lw.lg.Panic(s)
-	case FatalLevel:
+	case logrus.FatalLevel:
 		lw.lg.Fatal(s)
-	case ErrorLevel:
+	case logrus.ErrorLevel:
 		lw.lg.Error(s)
-	case WarnLevel:
+	case logrus.WarnLevel:
 		lw.lg.Warn(s)
-	case InfoLevel:
+	case logrus.InfoLevel:
 		lw.lg.Info(s)
-	case DebugLevel:
+	case logrus.DebugLevel:
 		lw.lg.Debug(s)
-	case TraceLevel:
+	case logrus.TraceLevel:
 		lw.lg.Trace(s)
 	}
-	return len(s), nil
+	return len(p), nil
+}
+
+// NewStdLogger creates a standard library logger on this logger, e.g. for http.Server.ErrorLog. Each line would log a
+// message at the given level, without timestamp and prefix of the standard logger.
+func (logger Logger) NewStdLogger(level LogLevel) *log.Logger {
+	return log.New(newLogWriter(logger, level), "", 0)
+}
+
+// NewStdLogger creates a standard library logger for the component, see Logger.NewStdLogger
+func NewStdLogger(component string, level LogLevel) *log.Logger {
+	return WithField("component", component).NewStdLogger(level)
 }
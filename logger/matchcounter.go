@@ -0,0 +1,166 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	matchCountersLock    sync.Mutex                      // for changes of matchCounters
+	matchCounters        atomic.Pointer[[]*matchCounter] // replaced on changes, to be read without lock
+	matchCountersHookSet sync.Once
+)
+
+// CountMatches registers a counter of logs at the level or above whose messages match the pattern, e.g.:
+//
+//	logger.CountMatches("db_timeouts_total", logger.ErrorLevel, regexp.MustCompile(`(?P<op>query|exec) timed out`), "component")
+//
+// The counter is labeled by named groups of the pattern and then by the given fields of logs, with missing values as
+// empty. Only logs enabled by the level of any output are counted.
+//
+// The counter is registered by promext.SafeRegister and the returned function removes and unregisters it. Names must
+// be unique among counters added here.
+func CountMatches(name string, level LogLevel, pattern *regexp.Regexp, labelFields ...string) (func(), error) {
+	logrusLevel, exists := levelMap[level]
+	if !exists {
+		return nil, fmt.Errorf("failed to count matches for '%s': invalid log level '%s'", name, level)
+	}
+
+	counter := &matchCounter{
+		name:        name,
+		level:       logrusLevel,
+		pattern:     pattern,
+		labelFields: labelFields,
+	}
+	var labelNames []string
+	for i, groupName := range pattern.SubexpNames() {
+		if groupName != "" {
+			counter.groupIndexes = append(counter.groupIndexes, i)
+			labelNames = append(labelNames, groupName)
+		}
+	}
+	labelNames = append(labelNames, labelFields...)
+	counter.counterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+		Name: name,
+		Help: fmt.Sprintf("Numbers of logs at %s level or above matching /%s/", level, pattern),
+	}, labelNames)
+
+	matchCountersHookSet.Do(func() {
+		root.entry.Logger.AddHook(matchCountersHook{})
+	})
+	if err := addMatchCounter(counter); err != nil {
+		return nil, err
+	}
+	promext.SafeRegister(counter.counterVec)
+	return func() {
+		if removeMatchCounter(counter) {
+			prometheus.DefaultRegisterer.Unregister(counter.counterVec)
+		}
+	}, nil
+}
+
+func addMatchCounter(counter *matchCounter) error {
+	matchCountersLock.Lock()
+	defer matchCountersLock.Unlock()
+	var counters []*matchCounter
+	if current := matchCounters.Load(); current != nil {
+		counters = *current
+	}
+	for _, c := range counters {
+		if c.name == counter.name {
+			return fmt.Errorf("failed to count matches for '%s': duplicate name", counter.name)
+		}
+	}
+	newCounters := append(counters[:len(counters):len(counters)], counter)
+	matchCounters.Store(&newCounters)
+	return nil
+}
+
+func removeMatchCounter(counter *matchCounter) bool {
+	matchCountersLock.Lock()
+	defer matchCountersLock.Unlock()
+	counters := *matchCounters.Load()
+	for i, c := range counters {
+		if c == counter {
+			newCounters := append(counters[:i:i], counters[i+1:]...)
+			matchCounters.Store(&newCounters)
+			return true
+		}
+	}
+	return false
+}
+
+// matchCountersHook passes entries to all counters added by CountMatches
+type matchCountersHook struct{}
+
+func (h matchCountersHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h matchCountersHook) Fire(entry *logrus.Entry) error {
+	counters := matchCounters.Load()
+	if counters == nil {
+		return nil
+	}
+	for _, counter := range *counters {
+		if err := counter.count(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchCounter counts entries whose messages match the pattern, by named groups and fields as labels
+type matchCounter struct {
+	name         string
+	level        logrus.Level
+	pattern      *regexp.Regexp
+	groupIndexes []int
+	labelFields  []string
+	counterVec   *promext.RWCounterVec
+}
+
+func (c *matchCounter) count(entry *logrus.Entry) error {
+	if entry.Level > c.level {
+		return nil
+	}
+	submatches := c.pattern.FindStringSubmatch(entry.Message)
+	if submatches == nil {
+		return nil
+	}
+	labelValues := make([]string, 0, len(c.groupIndexes)+len(c.labelFields))
+	for _, index := range c.groupIndexes {
+		labelValues = append(labelValues, submatches[index])
+	}
+	for _, field := range c.labelFields {
+		if value, exists := entry.Data[field]; exists {
+			labelValues = append(labelValues, fmt.Sprint(value))
+		} else {
+			labelValues = append(labelValues, "")
+		}
+	}
+	counter, err := c.counterVec.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		return fmt.Errorf("failed to count log for '%s': %w", c.name, err)
+	}
+	counter.Inc()
+	return nil
+}
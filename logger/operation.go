@@ -0,0 +1,174 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/logger/priv"
+	"github.com/relex/gotils/promexporter/promext"
+)
+
+// Outcomes of operations in logs and metrics
+const (
+	OperationSucceeded = "success"
+	OperationFailed    = "failure"
+)
+
+// MetricCreator creates metrics of logger with its own prefix and fixed labels, implemented by promreg.MetricCreator
+//
+// It's declared here since promreg depends on logger.
+type MetricCreator interface {
+	AddOrGetCounterVec(name string, help string, labelNames []string, leftmostLabelValues []string) *promext.RWCounterVec
+}
+
+type operationMetricVecs struct {
+	count    *promext.RWCounterVec
+	duration *promext.RWCounterVec
+}
+
+var (
+	defaultOperationMetrics = &operationMetricVecs{
+		count: promext.NewRWCounterVec(prometheus.CounterOpts{
+			Name: "logger_operations_total",
+			Help: operationCountHelp,
+		}, operationCountLabels),
+		duration: promext.NewRWCounterVec(prometheus.CounterOpts{
+			Name: "logger_operation_duration_milliseconds_total",
+			Help: operationDurationHelp,
+		}, operationDurationLabels),
+	}
+	operationMetrics atomic.Pointer[operationMetricVecs]
+
+	operationCountLabels    = []string{priv.LabelComponent, "operation", "outcome"}
+	operationDurationLabels = []string{priv.LabelComponent, "operation"}
+)
+
+const (
+	operationCountHelp    = "Numbers of finished operations by outcome"
+	operationDurationHelp = "Total duration of finished operations in milliseconds"
+)
+
+func init() {
+	promext.SafeRegister(defaultOperationMetrics.count)
+	promext.SafeRegister(defaultOperationMetrics.duration)
+	operationMetrics.Store(defaultOperationMetrics)
+}
+
+// SetOperationMetricCreator creates the metrics of operations from the creator, e.g. to add a prefix or fixed labels,
+// instead of the default registerer
+//
+// A nil creator restores the default metrics. Operations finished before are not moved to the new metrics.
+func SetOperationMetricCreator(creator MetricCreator) {
+	if creator == nil {
+		operationMetrics.Store(defaultOperationMetrics)
+		return
+	}
+	operationMetrics.Store(&operationMetricVecs{
+		count:    creator.AddOrGetCounterVec("logger_operations_total", operationCountHelp, operationCountLabels, nil),
+		duration: creator.AddOrGetCounterVec("logger_operation_duration_milliseconds_total", operationDurationHelp, operationDurationLabels, nil),
+	})
+}
+
+// Operation is a multi-step job started by Logger.BeginOperation, to be finished by Success or Fail
+type Operation struct {
+	name     string
+	start    time.Time
+	lock     sync.Mutex
+	logger   Logger
+	finished bool
+}
+
+// BeginOperation logs the start of an operation and returns it to be finished by Success or Fail, e.g.:
+//
+//	op := logger.WithField("component", "Importer").BeginOperation("import", logger.Fields{"file": path})
+//	op.AddField("rows", count)
+//	op.Fail(err) // or op.Success()
+//
+// All entries of the operation have the field "operation" set to the name, plus the given fields. Finished operations
+// are counted in the metrics "logger_operations_total" and "logger_operation_duration_milliseconds_total", see
+// SetOperationMetricCreator.
+func (logger Logger) BeginOperation(name string, fields map[string]interface{}) *Operation {
+	opLogger := logger.WithField("operation", name)
+	if len(fields) > 0 {
+		opLogger = opLogger.WithFields(fields)
+	}
+	opLogger.Info("operation started")
+	return &Operation{
+		name:   name,
+		start:  time.Now(),
+		logger: opLogger,
+	}
+}
+
+// BeginOperation logs the start of an operation by the root logger, see Logger.BeginOperation
+func BeginOperation(name string, fields map[string]interface{}) *Operation {
+	return root.BeginOperation(name, fields)
+}
+
+// AddField adds a field to the logger of the operation, e.g. to record results in the finish entry
+func (op *Operation) AddField(key string, value interface{}) {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+	op.logger = op.logger.WithField(key, value)
+}
+
+// Logger returns the logger with all fields of the operation, to log steps in the middle
+func (op *Operation) Logger() Logger {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+	return op.logger
+}
+
+// Success logs the finish of the operation with the duration and outcome
+func (op *Operation) Success() {
+	op.finish(nil).Info("operation succeeded")
+}
+
+// Fail logs the failure of the operation with the error, duration and outcome
+func (op *Operation) Fail(err error) {
+	op.finish(err).Errorf("operation failed: %v", err)
+}
+
+// finish marks the operation finished, updates metrics and returns the logger for the finish entry
+func (op *Operation) finish(err error) Logger {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+	if op.finished {
+		ownLogger.Panicf("Operation '%s' already finished", op.name)
+	}
+	op.finished = true
+
+	duration := time.Since(op.start)
+	outcome := OperationSucceeded
+	if err != nil {
+		outcome = OperationFailed
+	}
+	component := "(root)"
+	if c, hasComponent := op.logger.entry.Data[priv.LabelComponent]; hasComponent {
+		component = fmt.Sprint(c)
+	}
+	metrics := operationMetrics.Load()
+	metrics.count.WithLabelValues(component, op.name, outcome).Inc()
+	metrics.duration.WithLabelValues(component, op.name).Add(uint64(duration.Milliseconds()))
+
+	return op.logger.WithFields(map[string]interface{}{
+		"duration": duration.String(),
+		"outcome":  outcome,
+	})
+}
@@ -0,0 +1,411 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// LogFormat represents the format of an additional log output
+type LogFormat string
+
+// Log formats
+const (
+	TextFormat LogFormat = "text"
+	JSONFormat LogFormat = "json"
+)
+
+var (
+	primaryLevel    atomic.Uint32                           // logrus.Level of the primary output and upstream, may be less verbose than the logger
+	componentLevels atomic.Pointer[map[string]logrus.Level] // levels overriding primaryLevel for components
+	primaryOutput   = &primaryWriter{writer: os.Stderr}
+
+	secondaryLock         sync.Mutex
+	removeSecondaryOutput func()
+
+	outputsLock           sync.Mutex
+	namedOutputs          = make(map[string]*outputHook)
+	additionalOutputs     []*outputHook // replaced instead of modified in place, so it can be iterated without lock
+	additionalOutputsHook sync.Once
+)
+
+// outputNameKey is the key of the output name in the context of entries routed by Logger.ToOutput
+type outputNameKey struct{}
+
+// SetSecondaryOutput configures the root logger to write each entry to the given writer as well, in its own format
+// and with its own level threshold independent of SetLogLevel, e.g. to keep legacy text logs in a file while sending
+// JSON to upstream during migrations.
+//
+// Calling it again replaces the previous secondary output. A nil writer disables it.
+//
+// The secondary output is an output added by AddOutput, which is removed on replacement.
+func SetSecondaryOutput(writer io.Writer, format LogFormat, level LogLevel) {
+	getOutputFormatterAndLevel(format, level) // validate even if disabled
+
+	secondaryLock.Lock()
+	defer secondaryLock.Unlock()
+	removePrevious := removeSecondaryOutput
+	removeSecondaryOutput = nil
+	if writer != nil {
+		removeSecondaryOutput = AddOutput(writer, format, level)
+	}
+	if removePrevious != nil {
+		removePrevious()
+	}
+}
+
+// AddOutput adds an output which receives all entries not routed to named outputs, like the primary output, in its
+// own format and level threshold, e.g. JSON at debug level to a file in addition to text at info level to console
+//
+// Returns a function to remove the output, e.g. before closing its writer.
+func AddOutput(writer io.Writer, format LogFormat, level LogLevel) func() {
+	if writer == nil {
+		ownLogger.Panicf("Nil writer for additional log output")
+	}
+	formatter, logrusLevel := getOutputFormatterAndLevel(format, level)
+
+	output := &outputHook{}
+	output.set(writer, formatter, logrusLevel)
+	additionalOutputsHook.Do(func() {
+		root.entry.Logger.AddHook(additionalOutputsDispatcher{})
+	})
+	outputsLock.Lock()
+	additionalOutputs = append(additionalOutputs[:len(additionalOutputs):len(additionalOutputs)], output)
+	outputsLock.Unlock()
+	updateLoggerLevel()
+
+	return func() {
+		outputsLock.Lock()
+		for i, o := range additionalOutputs {
+			if o == output {
+				additionalOutputs = append(additionalOutputs[:i:i], additionalOutputs[i+1:]...)
+				break
+			}
+		}
+		outputsLock.Unlock()
+		updateLoggerLevel()
+	}
+}
+
+// AddNamedOutput registers an output which only receives entries from sub-loggers created by Logger.ToOutput(name),
+// in its own format and level threshold
+//
+// Calling it again with the same name replaces the previous output. A nil writer disables it.
+func AddNamedOutput(name string, writer io.Writer, format LogFormat, level LogLevel) {
+	formatter, logrusLevel := getOutputFormatterAndLevel(format, level)
+
+	outputsLock.Lock()
+	output, exists := namedOutputs[name]
+	if !exists {
+		output = &outputHook{name: name}
+		namedOutputs[name] = output
+		root.entry.Logger.AddHook(output)
+	}
+	outputsLock.Unlock()
+
+	output.set(writer, formatter, logrusLevel)
+	updateLoggerLevel()
+}
+
+// ToOutput creates a sub-logger whose entries are written only to the named output registered by AddNamedOutput,
+// instead of the primary, secondary and upstream outputs
+func (logger Logger) ToOutput(name string) Logger {
+	outputsLock.Lock()
+	_, exists := namedOutputs[name]
+	outputsLock.Unlock()
+	if !exists {
+		ownLogger.Panicf("Unknown log output: '%s'", name)
+	}
+
+	ctx := logger.entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return wrapLogger(logger.entry.WithContext(context.WithValue(ctx, outputNameKey{}, name)), logger)
+}
+
+// getOutputName returns the name of output the entry is routed to, or empty string for the default outputs
+func getOutputName(entry *logrus.Entry) string {
+	if entry.Context == nil {
+		return ""
+	}
+	name, _ := entry.Context.Value(outputNameKey{}).(string)
+	return name
+}
+
+func getOutputFormatterAndLevel(format LogFormat, level LogLevel) (logrus.Formatter, logrus.Level) {
+	logrusLevel, exists := levelMap[level]
+	if !exists {
+		ownLogger.Fatalf("Invalid log level: '%s'", level)
+	}
+	var formatter logrus.Formatter
+	switch format {
+	case TextFormat:
+		formatter = priv.TextFormatter
+	case JSONFormat:
+		formatter = priv.SharedJSONFormatter
+	default:
+		ownLogger.Fatalf("Invalid log format: '%s'", format)
+	}
+	return formatter, logrusLevel
+}
+
+// setPrimaryLevel sets the level of the primary output, which is what SetLogLevel and GetLogLevel deal with
+func setPrimaryLevel(level logrus.Level) {
+	primaryLevel.Store(uint32(level))
+	updateLoggerLevel()
+}
+
+func getPrimaryLevel() logrus.Level {
+	return logrus.Level(primaryLevel.Load())
+}
+
+// SetComponentLevel sets the level of the primary output and upstream for entries of the component, overriding
+// SetLogLevel, e.g. to debug a single component
+//
+// Component names are case-insensitive, since config keys may be lowercased.
+func SetComponentLevel(component string, level LogLevel) {
+	logrusLevel, exists := levelMap[level]
+	if !exists {
+		ownLogger.Fatalf("Invalid log level: '%s'", level)
+	}
+	levels := getComponentLevels()
+	newLevels := make(map[string]logrus.Level, len(levels)+1)
+	for c, l := range levels {
+		newLevels[c] = l
+	}
+	newLevels[strings.ToLower(component)] = logrusLevel
+	setComponentLevels(newLevels)
+}
+
+// GetComponentLevels returns the levels of components set by SetComponentLevel, by lowercased component names
+func GetComponentLevels() map[string]LogLevel {
+	levels := getComponentLevels()
+	result := make(map[string]LogLevel, len(levels))
+	for c, l := range levels {
+		result[c] = reverseLevelMap[l]
+	}
+	return result
+}
+
+// ResetComponentLevels removes all levels of components set by SetComponentLevel
+func ResetComponentLevels() {
+	setComponentLevels(nil)
+}
+
+func getComponentLevels() map[string]logrus.Level {
+	if levels := componentLevels.Load(); levels != nil {
+		return *levels
+	}
+	return nil
+}
+
+func setComponentLevels(levels map[string]logrus.Level) {
+	componentLevels.Store(&levels)
+	updateLoggerLevel()
+}
+
+// isPrimaryLevelEnabled checks the entry's level against the level of its component or the primary level
+func isPrimaryLevelEnabled(entry *logrus.Entry) bool {
+	level := getPrimaryLevel()
+	if levels := getComponentLevels(); len(levels) > 0 {
+		if component, hasComponent := entry.Data[priv.LabelComponent]; hasComponent {
+			if componentLevel, found := levels[strings.ToLower(fmt.Sprint(component))]; found {
+				level = componentLevel
+			}
+		}
+	}
+	return entry.Level <= level
+}
+
+// updateLoggerLevel sets the level of the underlying logger to the most verbose one of all outputs
+func updateLoggerLevel() {
+	outputsLock.Lock()
+	defer outputsLock.Unlock()
+
+	level := getPrimaryLevel()
+	for _, componentLevel := range getComponentLevels() {
+		if componentLevel > level {
+			level = componentLevel
+		}
+	}
+	for _, output := range additionalOutputs {
+		if outputLevel, enabled := output.getLevel(); enabled && outputLevel > level {
+			level = outputLevel
+		}
+	}
+	for _, output := range namedOutputs {
+		if outputLevel, enabled := output.getLevel(); enabled && outputLevel > level {
+			level = outputLevel
+		}
+	}
+	root.entry.Logger.SetLevel(level)
+}
+
+// setFormatter sets the formatter of the primary output
+func setFormatter(formatter logrus.Formatter) {
+	root.entry.Logger.SetFormatter(primaryLevelFormatter{dedupeFormatter{formatter, primaryDedupe}})
+}
+
+// primaryLevelFormatter drops entries more verbose than the primary level or routed to named outputs, which are only
+// meant for other outputs, and writes the rest to the primary output by their levels
+//
+// Nothing is returned for the underlying logger to write, as its writer doesn't receive the levels of entries.
+type primaryLevelFormatter struct {
+	formatter logrus.Formatter
+}
+
+func (f primaryLevelFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if !isPrimaryLevelEnabled(entry) || getOutputName(entry) != "" {
+		return nil, nil
+	}
+	data, err := f.formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	primaryOutput.writeEntry(entry.Level, data)
+	return nil, nil
+}
+
+// primaryWriter is the writer of the underlying logger, holding the actual writer of the primary output
+type primaryWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func (w *primaryWriter) set(writer io.Writer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writer = writer
+}
+
+// WriterFor returns the actual writer for entries at the given level
+func (w *primaryWriter) WriterFor(level logrus.Level) io.Writer {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writerFor(level)
+}
+
+func (w *primaryWriter) writerFor(level logrus.Level) io.Writer {
+	if levelWriter, ok := w.writer.(priv.LevelWriter); ok {
+		return levelWriter.WriterFor(level)
+	}
+	return w.writer
+}
+
+// writeEntry writes a formatted entry to the actual writer for its level
+func (w *primaryWriter) writeEntry(level logrus.Level, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, err := w.writerFor(level).Write(data); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
+	}
+}
+
+// Write writes data from the underlying logger, which is empty unless the formatter of primary output is bypassed
+func (w *primaryWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Write(p)
+}
+
+// primaryLevelHook wraps a hook to only receive entries for the primary output
+type primaryLevelHook struct {
+	logrus.Hook
+}
+
+func (h primaryLevelHook) Fire(entry *logrus.Entry) error {
+	if !isPrimaryLevelEnabled(entry) || getOutputName(entry) != "" {
+		return nil
+	}
+	return h.Hook.Fire(entry)
+}
+
+// outputHook writes entries to an additional output, either the secondary or an added output for all entries not
+// routed to named outputs, or a named output for entries routed to it
+// additionalOutputsDispatcher is the hook to fire outputs added by AddOutput
+type additionalOutputsDispatcher struct{}
+
+func (additionalOutputsDispatcher) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (additionalOutputsDispatcher) Fire(entry *logrus.Entry) error {
+	outputsLock.Lock()
+	outputs := additionalOutputs
+	outputsLock.Unlock()
+
+	var firstErr error
+	for _, output := range outputs {
+		if err := output.Fire(entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type outputHook struct {
+	name      string // empty for the secondary and added outputs
+	lock      sync.Mutex
+	writer    io.Writer
+	formatter logrus.Formatter
+	level     logrus.Level
+}
+
+func (h *outputHook) set(writer io.Writer, formatter logrus.Formatter, level logrus.Level) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.writer = writer
+	h.formatter = formatter
+	h.level = level
+}
+
+func (h *outputHook) getLevel() (logrus.Level, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.level, h.writer != nil
+}
+
+func (h *outputHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *outputHook) Fire(entry *logrus.Entry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.writer == nil || entry.Level > h.level || getOutputName(entry) != h.name {
+		return nil
+	}
+	data, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(data)
+	return err
+}
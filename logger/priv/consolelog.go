@@ -19,6 +19,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/term"
@@ -28,7 +29,8 @@ import (
 const LabelComponent = "component"
 
 // ConsoleLogFormatter is colored output format for console / terminals
-// It detects the type of output writers automatically and only enables if the type is terminal
+// It detects the type of output writers automatically and only enables if the type is terminal and the environment
+// variable "NO_COLOR" is unset or empty (https://no-color.org/)
 type ConsoleLogFormatter struct {
 	ForceColor        bool             // Force enable colored mode even for non-terminal log writer
 	FallbackFormatter logrus.Formatter // Fallback formatter to use for non-terminal. If nil, use built-in fallback format (human readable, not for field parsing)
@@ -61,8 +63,16 @@ const (
 	ansiColorWhite   = "\x1b[37m"
 )
 
-var (
-	logColorByLevel = map[logrus.Level]string{
+// ConsoleTheme defines the colors and styles of ConsoleLogFormatter
+type ConsoleTheme struct {
+	LevelColors     map[logrus.Level]string // ANSI sequences of colors by level
+	DefaultColor    string                  // ANSI sequence of color for levels not in LevelColors
+	ComponentFormat string                  // ANSI sequence of style for component in addition to level color
+}
+
+// DefaultConsoleTheme is the default theme for dark terminal backgrounds
+var DefaultConsoleTheme = &ConsoleTheme{
+	LevelColors: map[logrus.Level]string{
 		logrus.TraceLevel: ansiColorWhite,
 		logrus.DebugLevel: ansiColorWhite,
 		logrus.InfoLevel:  ansiColorYellow,
@@ -70,10 +80,29 @@ var (
 		logrus.ErrorLevel: ansiColorRed,
 		logrus.FatalLevel: ansiColorRed,
 		logrus.PanicLevel: ansiColorRed,
-	}
+	},
+	DefaultColor:    ansiColorCyan,
+	ComponentFormat: ansiUnderline,
+}
+
+var (
+	consoleTheme         atomic.Pointer[ConsoleTheme]
 	fieldsFormatReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
 )
 
+// SetConsoleTheme sets the theme of all ConsoleLogFormatter(s), or nil to restore DefaultConsoleTheme
+func SetConsoleTheme(theme *ConsoleTheme) {
+	consoleTheme.Store(theme)
+}
+
+// GetConsoleTheme returns the theme of all ConsoleLogFormatter(s)
+func GetConsoleTheme() *ConsoleTheme {
+	if theme := consoleTheme.Load(); theme != nil {
+		return theme
+	}
+	return DefaultConsoleTheme
+}
+
 // NewConsoleLogFormatter creates a new ConsoleLogFormatter
 func NewConsoleLogFormatter(forceColor bool, fallbackFormatter logrus.Formatter) *ConsoleLogFormatter {
 	return &ConsoleLogFormatter{
@@ -88,7 +117,7 @@ func (f *ConsoleLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	if levelStr == "WARNING" {
 		levelStr = "WARN"
 	}
-	colored := f.determineColorMode(entry.Logger.Out)
+	colored := f.determineColorMode(entry.Logger.Out, entry.Level)
 	if !colored {
 		if fallback := f.FallbackFormatter; fallback != nil {
 			return fallback.Format(entry)
@@ -105,13 +134,14 @@ func (f *ConsoleLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 		message := fmt.Sprintf("%-29s %-5s%s %s%s\n", entry.Time.Format(RFC3339Milli), levelStr, compStr, entry.Message, tail)
 		return []byte(message), nil
 	}
-	levelColor := logColorByLevel[entry.Level]
+	theme := GetConsoleTheme()
+	levelColor := theme.LevelColors[entry.Level]
 	if levelColor == "" {
-		levelColor = ansiColorCyan
+		levelColor = theme.DefaultColor
 	}
 	strHead := formatAnsi(fmt.Sprintf("%-12s %-5s", entry.Time.Format(shortTimestamp), levelStr), levelColor, ansiBold)
 	if comp, ok := entry.Data[LabelComponent]; ok {
-		strHead = strHead + " " + formatAnsi(fmt.Sprint(comp), levelColor, ansiUnderline)
+		strHead = strHead + " " + formatAnsi(fmt.Sprint(comp), levelColor, theme.ComponentFormat)
 	}
 	strBody := formatAnsi(entry.Message, levelColor)
 	strTail := ""
@@ -121,10 +151,16 @@ func (f *ConsoleLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	return []byte(strHead + " " + strBody + strTail + "\n"), nil
 }
 
-func (f *ConsoleLogFormatter) determineColorMode(writer io.Writer) bool {
+func (f *ConsoleLogFormatter) determineColorMode(writer io.Writer, level logrus.Level) bool {
 	if f.ForceColor {
 		return true
 	}
+	if levelWriter, ok := writer.(LevelWriter); ok {
+		writer = levelWriter.WriterFor(level)
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
 	last := f.cachedTestResult
 	if last != nil && last.writer == writer {
 		return last.colored
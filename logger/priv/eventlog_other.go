@@ -0,0 +1,31 @@
+//go:build !windows
+
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IsEventLogAvailable checks whether the program is running as a Windows service, always false here
+func IsEventLogAvailable() bool {
+	return false
+}
+
+// NewEventLogHook returns error since Windows Event Log is not available on this platform
+func NewEventLogHook(source string) (logrus.Hook, error) {
+	return nil, fmt.Errorf("event log is only supported on Windows")
+}
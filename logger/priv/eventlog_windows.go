@@ -0,0 +1,82 @@
+//go:build windows
+
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogEventID is the event ID of all logs, since messages are not defined in any message file
+const eventLogEventID = 1
+
+// EventLogHook writes logs to Windows Event Log, with fields of entries appended to messages
+type EventLogHook struct {
+	log *eventlog.Log
+}
+
+// IsEventLogAvailable checks whether the program is running as a Windows service
+func IsEventLogAvailable() bool {
+	isService, err := svc.IsWindowsService()
+	return err == nil && isService
+}
+
+// NewEventLogHook creates a hook to write logs to Windows Event Log under the event source
+//
+// The source should have been registered, e.g. by eventlog.InstallAsEventCreate in service installation, otherwise
+// Event Viewer shows a warning about missing descriptions in addition to messages.
+func NewEventLogHook(source string) (logrus.Hook, error) {
+	log, err := eventlog.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log '%s': %w", source, err)
+	}
+	return &EventLogHook{log}, nil
+}
+
+// Fire is called to write a logrus Entry / log record
+func (hook *EventLogHook) Fire(entry *logrus.Entry) error {
+	message := formatEventLogMessage(entry)
+	switch {
+	case entry.Level <= logrus.ErrorLevel:
+		return hook.log.Error(eventLogEventID, message)
+	case entry.Level == logrus.WarnLevel:
+		return hook.log.Warning(eventLogEventID, message)
+	default:
+		return hook.log.Info(eventLogEventID, message)
+	}
+}
+
+// Levels defines the levels of logs to be sent to this hook
+func (hook *EventLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// formatEventLogMessage formats the message as "Component: message key=value ..."
+func formatEventLogMessage(entry *logrus.Entry) string {
+	parts := make([]string, 0, 2)
+	if comp, ok := entry.Data[LabelComponent]; ok {
+		parts = append(parts, fmt.Sprintf("%v: %s", comp, entry.Message))
+	} else {
+		parts = append(parts, entry.Message)
+	}
+	if fields := FormatFields(entry.Data); fields != "" {
+		parts = append(parts, fields)
+	}
+	return strings.Join(parts, " ")
+}
@@ -14,6 +14,8 @@
 package priv
 
 import (
+	"sync/atomic"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,6 +36,10 @@ var (
 		TimestampFormat: RFC3339Milli,
 	}
 
+	// SharedJSONFormatter is used by logger for upstream and all outputs in JSON format
+	// It formats by JSONFormatter unless its field names are overridden by SetFieldMap, which may be called at any time
+	SharedJSONFormatter = &JSONFieldMapFormatter{}
+
 	// TextFormatter is the default text format
 	// Reassignment of this field only takes effect after it's reapplied (e.g. by logger.SetTextFormat)
 	TextFormatter = &logrus.TextFormatter{
@@ -42,3 +48,27 @@ var (
 		DisableColors:   true,
 	}
 )
+
+// JSONFieldMapFormatter formats entries by JSONFormatter, with a field map replaceable while logging
+type JSONFieldMapFormatter struct {
+	override atomic.Pointer[logrus.JSONFormatter] // copy of JSONFormatter with another field map, nil if not overridden
+}
+
+// SetFieldMap replaces the field map of JSONFormatter for entries formatted afterwards, nil to restore it
+func (f *JSONFieldMapFormatter) SetFieldMap(fieldMap logrus.FieldMap) {
+	if fieldMap == nil {
+		f.override.Store(nil)
+		return
+	}
+	formatter := *JSONFormatter
+	formatter.FieldMap = fieldMap
+	f.override.Store(&formatter)
+}
+
+// Format formats the entry in JSON by the current field map
+func (f *JSONFieldMapFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if formatter := f.override.Load(); formatter != nil {
+		return formatter.Format(entry)
+	}
+	return JSONFormatter.Format(entry)
+}
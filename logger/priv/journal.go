@@ -0,0 +1,140 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JournalSocketPath is the socket of systemd journal for the native protocol
+const JournalSocketPath = "/run/systemd/journal/socket"
+
+// journalPriorities maps logrus levels to syslog priorities
+var journalPriorities = map[logrus.Level]int{
+	logrus.PanicLevel: 0, // emerg
+	logrus.FatalLevel: 2, // crit
+	logrus.ErrorLevel: 3, // err
+	logrus.WarnLevel:  4, // warning
+	logrus.InfoLevel:  6, // info
+	logrus.DebugLevel: 7, // debug
+	logrus.TraceLevel: 7, // debug
+}
+
+// journalReservedFields are written by the hook and can't be overridden by fields of entries
+var journalReservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+}
+
+// JournalHook writes logs to systemd journal by the native protocol, with fields of entries as journal fields in
+// uppercase, e.g. "component" as "COMPONENT"
+type JournalHook struct {
+	lock       sync.Mutex
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier string
+}
+
+// IsJournalAvailable checks whether the socket of systemd journal exists
+func IsJournalAvailable() bool {
+	_, err := os.Stat(JournalSocketPath)
+	return err == nil
+}
+
+// NewJournalHook creates a hook to write logs to systemd journal
+func NewJournalHook() (*JournalHook, error) {
+	return newJournalHook(JournalSocketPath)
+}
+
+func newJournalHook(socketPath string) (*JournalHook, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	return &JournalHook{
+		conn:       conn,
+		addr:       &net.UnixAddr{Name: socketPath, Net: "unixgram"},
+		identifier: filepath.Base(os.Args[0]),
+	}, nil
+}
+
+// Fire is called to write a logrus Entry / log record
+func (hook *JournalHook) Fire(entry *logrus.Entry) error {
+	data := &bytes.Buffer{}
+	writeJournalField(data, "MESSAGE", entry.Message)
+	writeJournalField(data, "PRIORITY", fmt.Sprint(journalPriorities[entry.Level]))
+	writeJournalField(data, "SYSLOG_IDENTIFIER", hook.identifier)
+	for _, key := range getSortedFieldKeys(entry.Data) {
+		name := toJournalFieldName(key)
+		if name == "" {
+			continue
+		}
+		if journalReservedFields[name] {
+			name = "FIELD_" + name
+		}
+		writeJournalField(data, name, fmt.Sprint(entry.Data[key]))
+	}
+
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	if _, err := hook.conn.WriteToUnix(data.Bytes(), hook.addr); err != nil {
+		return fmt.Errorf("failed to write to journal: %w", err)
+	}
+	return nil
+}
+
+// Levels defines the levels of logs to be sent to this hook
+func (hook *JournalHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// writeJournalField writes a field in the native protocol, in binary form if the value contains line breaks
+func writeJournalField(data *bytes.Buffer, name string, value string) {
+	data.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		data.WriteByte('=')
+		data.WriteString(value)
+		data.WriteByte('\n')
+		return
+	}
+	data.WriteByte('\n')
+	_ = binary.Write(data, binary.LittleEndian, uint64(len(value)))
+	data.WriteString(value)
+	data.WriteByte('\n')
+}
+
+// toJournalFieldName converts the key to a valid journal field name, which consists of uppercase letters, digits and
+// underscores, and doesn't start with digit or underscore
+func toJournalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	return strings.TrimLeft(name, "_0123456789")
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalHook(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "journal.socket")
+	journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer journal.Close()
+
+	hook, hookErr := newJournalHook(socketPath)
+	if !assert.NoError(t, hookErr) {
+		return
+	}
+	hook.identifier = "test"
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		LabelComponent: "DB",
+		"message":      "overridden",
+		"_retry-count": 3,
+		"query":        "SELECT 1\nFROM dual",
+	})
+	entry.Level = logrus.WarnLevel
+	entry.Message = "slow query"
+	assert.NoError(t, hook.Fire(entry))
+
+	buf := make([]byte, 1024)
+	n, readErr := journal.Read(buf)
+	assert.NoError(t, readErr)
+	assert.Equal(t, "MESSAGE=slow query\n"+
+		"PRIORITY=4\n"+
+		"SYSLOG_IDENTIFIER=test\n"+
+		"RETRY_COUNT=3\n"+
+		"COMPONENT=DB\n"+
+		"FIELD_MESSAGE=overridden\n"+
+		"QUERY\n\x12\x00\x00\x00\x00\x00\x00\x00SELECT 1\nFROM dual\n", string(buf[:n]))
+}
@@ -0,0 +1,435 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sentryRequestTimeout    = 10 * time.Second
+	sentryPanicFlushTimeout = 3 * time.Second
+	sentryMaxFrames         = 50
+)
+
+// SentryOptions defines the events sent by the Sentry hook, zero fields for defaults
+type SentryOptions struct {
+	Environment string // environment tag, e.g. "production"
+	Release     string // release tag, e.g. "my-service@1.2.3"
+	RateLimit   int    // max number of events per minute, default 60
+	QueueSize   int    // max number of events waiting to be sent, default 100
+}
+
+var (
+	// SentryLogLevels are the levels of logs sent by SentryHook
+	SentryLogLevels = []logrus.Level{
+		logrus.PanicLevel,
+		logrus.FatalLevel,
+		logrus.ErrorLevel,
+	}
+
+	sentryDroppedCounterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+		Name: "logger_sentry_dropped_total",
+		Help: "Numbers of error logs not sent to Sentry",
+	}, []string{"reason"})
+	sentryDroppedByRateLimit = sentryDroppedCounterVec.WithLabelValues("rate_limit")
+	sentryDroppedByOverflow  = sentryDroppedCounterVec.WithLabelValues("overflow")
+	sentryDroppedBySendFail  = sentryDroppedCounterVec.WithLabelValues("send_failure")
+)
+
+func init() {
+	promext.SafeRegister(sentryDroppedCounterVec)
+}
+
+func (opts SentryOptions) withDefaults() SentryOptions {
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = 60
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	return opts
+}
+
+// SentryHook forwards error logs to Sentry-compatible endpoints as events, with fields as extra data and stack traces
+//
+// Events are sent in background until the hook is closed, and should be flushed before exit. Events over the rate limit
+// or while the server is throttling are dropped.
+type SentryHook struct {
+	envelopeURL string
+	authHeader  string
+	serverName  string
+	options     SentryOptions
+	httpClient  *http.Client
+	events      chan []byte
+	flushes     chan chan void // worker sends queued events and closes the received channel
+	closing     chan void      // close() to signal "closing": send remaining events and stop worker
+	closeOnce   sync.Once
+	closed      chan void // close() to signal "closed": fully stopped
+
+	limiterLock  sync.Mutex
+	tokens       float64   // available events in token bucket
+	lastRefill   time.Time // last time tokens were added
+	blockedUntil time.Time // throttled by server until, accessed only by worker
+}
+
+// sentryEvent is the event payload in Sentry envelopes, see https://develop.sentry.dev/sdk/event-payloads/
+type sentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Logger      string                 `json:"logger,omitempty"`
+	Platform    string                 `json:"platform"`
+	Message     string                 `json:"message"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Threads     *sentryThreads         `json:"threads,omitempty"`
+}
+
+// sentryEnvelopeHeader is the first line of Sentry envelopes, see https://develop.sentry.dev/sdk/envelopes/
+type sentryEnvelopeHeader struct {
+	EventID string `json:"event_id"`
+}
+
+// sentryItemHeader is the header line before each item payload in Sentry envelopes
+type sentryItemHeader struct {
+	Type   string `json:"type"`
+	Length int    `json:"length"`
+}
+
+type sentryThreads struct {
+	Values []sentryThread `json:"values"`
+}
+
+type sentryThread struct {
+	ID         int              `json:"id"`
+	Current    bool             `json:"current"`
+	Crashed    bool             `json:"crashed"`
+	Stacktrace sentryStacktrace `json:"stacktrace"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// NewSentryHook creates a hook to be added to an instance of logger, to send error logs to the Sentry DSN, e.g.
+// "https://public_key@sentry.example.com/42"
+//
+// The hook should be flushed by Flush before exit and closed by Close when no longer used.
+func NewSentryHook(dsn string, opts SentryOptions) (*SentryHook, error) {
+	envelopeURL, key, err := parseSentryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	serverName, _ := os.Hostname()
+	hook := &SentryHook{
+		envelopeURL: envelopeURL,
+		authHeader:  fmt.Sprintf("Sentry sentry_version=7, sentry_client=gotils-logger/1.0, sentry_key=%s", key),
+		serverName:  serverName,
+		options:     opts,
+		httpClient:  &http.Client{Timeout: sentryRequestTimeout},
+		events:      make(chan []byte, opts.QueueSize),
+		flushes:     make(chan chan void),
+		closing:     make(chan void),
+		closed:      make(chan void),
+		tokens:      float64(opts.RateLimit),
+		lastRefill:  time.Now(),
+	}
+	go hook.run()
+	return hook, nil
+}
+
+// parseSentryDSN parses "scheme://key@host[:port]/[path/]project" into the URL of envelope API and the public key
+func parseSentryDSN(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse Sentry DSN: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return "", "", fmt.Errorf("failed to parse Sentry DSN: missing public key")
+	}
+	path, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		path, project = "/"+project[:i], project[i+1:]
+	}
+	if project == "" {
+		return "", "", fmt.Errorf("failed to parse Sentry DSN: missing project ID")
+	}
+	envelopeURL := fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path, project)
+	return envelopeURL, u.User.Username(), nil
+}
+
+// Fire is called to forward a logrus Entry / log record
+func (hook *SentryHook) Fire(entry *logrus.Entry) error {
+	if !hook.allow(time.Now()) {
+		sentryDroppedByRateLimit.Inc()
+	} else {
+		data, err := newSentryEnvelope(hook.newEvent(entry))
+		if err != nil {
+			return err
+		}
+		select {
+		case hook.events <- data:
+		default:
+			sentryDroppedByOverflow.Inc()
+		}
+	}
+	if entry.Level <= logrus.PanicLevel {
+		// panics may be recovered, so the worker is kept running
+		ctx, cancel := context.WithTimeout(context.Background(), sentryPanicFlushTimeout)
+		defer cancel()
+		_ = hook.Flush(ctx)
+	}
+	return nil
+}
+
+// Levels defines the levels of logs to be sent to this hook
+func (hook *SentryHook) Levels() []logrus.Level {
+	return SentryLogLevels
+}
+
+// Flush waits until events queued so far are sent or the context is done, without stopping the hook
+func (hook *SentryHook) Flush(ctx context.Context) error {
+	done := make(chan void)
+	select {
+	case hook.flushes <- done:
+	case <-hook.closed:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush Sentry events: %w", ctx.Err())
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush Sentry events: %w", ctx.Err())
+	}
+}
+
+// Close stops the hook after sending queued events in background. Events fired afterwards are queued but not sent.
+func (hook *SentryHook) Close() {
+	hook.closeOnce.Do(func() { close(hook.closing) })
+}
+
+// allow takes a token from the bucket refilled at the rate limit per minute, returning false if there is none
+func (hook *SentryHook) allow(now time.Time) bool {
+	hook.limiterLock.Lock()
+	defer hook.limiterLock.Unlock()
+
+	limit := float64(hook.options.RateLimit)
+	hook.tokens = min(limit, hook.tokens+now.Sub(hook.lastRefill).Minutes()*limit)
+	hook.lastRefill = now
+	if hook.tokens < 1 {
+		return false
+	}
+	hook.tokens--
+	return true
+}
+
+func (hook *SentryHook) newEvent(entry *logrus.Entry) *sentryEvent {
+	event := &sentryEvent{
+		EventID:     newSentryEventID(),
+		Timestamp:   entry.Time.UTC().Format(time.RFC3339Nano),
+		Level:       entry.Level.String(),
+		Platform:    "go",
+		Message:     entry.Message,
+		Environment: hook.options.Environment,
+		Release:     hook.options.Release,
+		ServerName:  hook.serverName,
+		Extra:       make(map[string]interface{}, len(entry.Data)),
+	}
+	for key, value := range entry.Data {
+		if key == LabelComponent {
+			event.Logger = fmt.Sprint(value)
+			event.Tags = map[string]string{LabelComponent: event.Logger}
+			continue
+		}
+		switch v := value.(type) {
+		case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+			event.Extra[key] = v
+		default:
+			event.Extra[key] = fmt.Sprint(v)
+		}
+	}
+	if frames := captureSentryFrames(); len(frames) > 0 {
+		event.Threads = &sentryThreads{Values: []sentryThread{{
+			Current:    true,
+			Crashed:    entry.Level <= logrus.FatalLevel,
+			Stacktrace: sentryStacktrace{Frames: frames},
+		}}}
+	}
+	return event
+}
+
+// captureSentryFrames returns the stack frames of caller outside of logger and logrus, from the oldest to the newest
+func captureSentryFrames() []sentryFrame {
+	pcs := make([]uintptr, sentryMaxFrames)
+	n := runtime.Callers(3, pcs)
+	callerFrames := runtime.CallersFrames(pcs[:n])
+	frames := make([]sentryFrame, 0, n)
+	for {
+		frame, more := callerFrames.Next()
+		if !isLoggerFunction(frame.Function) {
+			module, function := splitFunctionName(frame.Function)
+			frames = append(frames, sentryFrame{
+				Function: function,
+				Module:   module,
+				AbsPath:  frame.File,
+				Lineno:   frame.Line,
+				InApp:    strings.Contains(strings.SplitN(module, "/", 2)[0], "."),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+func isLoggerFunction(function string) bool {
+	return strings.HasPrefix(function, "github.com/sirupsen/logrus.") ||
+		strings.HasPrefix(function, "github.com/relex/gotils/logger.") ||
+		strings.HasPrefix(function, "github.com/relex/gotils/logger/priv.")
+}
+
+// splitFunctionName splits "github.com/org/pkg.(*Type).Method" into "github.com/org/pkg" and "(*Type).Method"
+func splitFunctionName(name string) (string, string) {
+	lastSlash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[lastSlash+1:], "."); dot >= 0 {
+		return name[:lastSlash+1+dot], name[lastSlash+1+dot+1:]
+	}
+	return "", name
+}
+
+// newSentryEnvelope creates an envelope containing only the event
+func newSentryEnvelope(event *sentryEvent) ([]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	header, _ := json.Marshal(sentryEnvelopeHeader{EventID: event.EventID})
+	itemHeader, _ := json.Marshal(sentryItemHeader{Type: "event", Length: len(payload)})
+
+	envelope := make([]byte, 0, len(header)+len(itemHeader)+len(payload)+3)
+	envelope = append(append(envelope, header...), '\n')
+	envelope = append(append(envelope, itemHeader...), '\n')
+	envelope = append(append(envelope, payload...), '\n')
+	return envelope, nil
+}
+
+func newSentryEventID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func (hook *SentryHook) run() {
+	defer close(hook.closed)
+	for {
+		select {
+		case data := <-hook.events:
+			hook.send(data)
+		case done := <-hook.flushes:
+			hook.sendQueued()
+			close(done)
+		case <-hook.closing:
+			hook.sendQueued()
+			return
+		}
+	}
+}
+
+// sendQueued sends events in the queue until it's empty
+func (hook *SentryHook) sendQueued() {
+	for {
+		select {
+		case data := <-hook.events:
+			hook.send(data)
+		default:
+			return
+		}
+	}
+}
+
+// send sends the event unless the server is throttling, which is indicated by 429 and "Retry-After"
+func (hook *SentryHook) send(data []byte) {
+	if time.Now().Before(hook.blockedUntil) {
+		sentryDroppedByRateLimit.Inc()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sentryRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.envelopeURL, bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sentry: failed to create request: %v\n", err)
+		sentryDroppedBySendFail.Inc()
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", hook.authHeader)
+
+	resp, err := hook.httpClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sentry: failed to send: %v\n", err)
+		sentryDroppedBySendFail.Inc()
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter, parseErr := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if parseErr != nil || retryAfter <= 0 {
+			retryAfter = 60
+		}
+		hook.blockedUntil = time.Now().Add(time.Duration(retryAfter) * time.Second)
+		sentryDroppedByRateLimit.Inc()
+	case resp.StatusCode >= 300:
+		fmt.Fprintf(os.Stderr, "sentry: failed to send: %s\n", resp.Status)
+		sentryDroppedBySendFail.Inc()
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSentryDSN(t *testing.T) {
+	envelopeURL, key, err := parseSentryDSN("https://abc@sentry.example.com/42")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sentry.example.com/api/42/envelope/", envelopeURL)
+	assert.Equal(t, "abc", key)
+
+	envelopeURL, _, err = parseSentryDSN("http://abc@localhost:9000/sentry/7")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:9000/sentry/api/7/envelope/", envelopeURL)
+
+	_, _, err = parseSentryDSN("https://sentry.example.com/42")
+	assert.ErrorContains(t, err, "missing public key")
+	_, _, err = parseSentryDSN("https://abc@sentry.example.com/")
+	assert.ErrorContains(t, err, "missing project ID")
+}
+
+func TestSentryHook(t *testing.T) {
+	var auths []string
+	var events []sentryEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/42/envelope/", r.URL.Path)
+		assert.Equal(t, "application/x-sentry-envelope", r.Header.Get("Content-Type"))
+		auths = append(auths, r.Header.Get("X-Sentry-Auth"))
+		events = append(events, parseSentryEnvelope(t, r.Body))
+	}))
+	defer server.Close()
+
+	hook, err := NewSentryHook(strings.Replace(server.URL, "://", "://key1@", 1)+"/42", SentryOptions{
+		Environment: "test",
+		Release:     "app@1.0",
+		RateLimit:   2,
+	})
+	assert.NoError(t, err)
+	lg := logrus.New()
+	lg.SetOutput(io.Discard)
+	lg.AddHook(hook)
+
+	lg.WithFields(logrus.Fields{LabelComponent: "DB", "table": "orders", "error": errors.New("timeout")}).Error("failed to insert")
+	lg.Warn("not sent")
+	lg.Error("second")
+	lg.Error("over rate limit")
+	assert.NoError(t, hook.Flush(context.Background()))
+	hook.Close()
+
+	if assert.Len(t, events, 2) {
+		event := events[0]
+		assert.Len(t, event.EventID, 32)
+		assert.Equal(t, "error", event.Level)
+		assert.Equal(t, "DB", event.Logger)
+		assert.Equal(t, "failed to insert", event.Message)
+		assert.Equal(t, "test", event.Environment)
+		assert.Equal(t, "app@1.0", event.Release)
+		assert.Equal(t, map[string]string{"component": "DB"}, event.Tags)
+		assert.Equal(t, map[string]interface{}{"table": "orders", "error": "timeout"}, event.Extra)
+		frames := event.Threads.Values[0].Stacktrace.Frames
+		assert.Equal(t, sentryFrame{Function: "tRunner", Module: "testing"}, sentryFrame{
+			Function: frames[len(frames)-1].Function,
+			Module:   frames[len(frames)-1].Module,
+		}, "the newest frame should be the caller outside of logger (the test is inside)")
+		assert.Equal(t, "second", events[1].Message)
+	}
+	assert.Contains(t, auths[0], "sentry_key=key1")
+}
+
+func TestSentryHookFlushAndClose(t *testing.T) {
+	var messages []string
+	var messagesLock sync.Mutex
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := parseSentryEnvelope(t, r.Body)
+		if event.Message == "slow" {
+			<-release
+		}
+		messagesLock.Lock()
+		messages = append(messages, event.Message)
+		messagesLock.Unlock()
+	}))
+	defer server.Close()
+	getMessages := func() []string {
+		messagesLock.Lock()
+		defer messagesLock.Unlock()
+		return append([]string{}, messages...)
+	}
+
+	hook, err := NewSentryHook(strings.Replace(server.URL, "://", "://key1@", 1)+"/42", SentryOptions{})
+	assert.NoError(t, err)
+	lg := logrus.New()
+	lg.SetOutput(io.Discard)
+	lg.AddHook(hook)
+
+	assert.Panics(t, func() { lg.Panic("recoverable") })
+	assert.Equal(t, []string{"recoverable"}, getMessages(), "panic should flush the event")
+
+	lg.Error("after panic")
+	assert.NoError(t, hook.Flush(context.Background()))
+	assert.Equal(t, []string{"recoverable", "after panic"}, getMessages(), "panic shouldn't stop the hook")
+
+	lg.Error("slow")
+	lg.Error("queued")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hook.Flush(ctx), context.DeadlineExceeded)
+
+	hook.Close()
+	close(release)
+	<-hook.closed
+	assert.Equal(t, []string{"recoverable", "after panic", "slow", "queued"}, getMessages(), "close should send queued events")
+	lg.Error("after close")
+	assert.NoError(t, hook.Flush(context.Background()), "flush after close should return immediately")
+	assert.Len(t, getMessages(), 4)
+}
+
+// parseSentryEnvelope parses the envelope of a single event
+func parseSentryEnvelope(t *testing.T, body io.Reader) sentryEvent {
+	data, _ := io.ReadAll(body)
+	lines := strings.SplitN(string(data), "\n", 3)
+	if !assert.Len(t, lines, 3) {
+		return sentryEvent{}
+	}
+	var header sentryEnvelopeHeader
+	var itemHeader sentryItemHeader
+	var event sentryEvent
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &itemHeader))
+	assert.Equal(t, "event", itemHeader.Type)
+	assert.Equal(t, len(lines[2])-1, itemHeader.Length)
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Equal(t, event.EventID, header.EventID)
+	return event
+}
+
+func TestSentryRateLimit(t *testing.T) {
+	now := time.Now()
+	hook := &SentryHook{options: SentryOptions{RateLimit: 60}, tokens: 1, lastRefill: now}
+	assert.True(t, hook.allow(now))
+	assert.False(t, hook.allow(now.Add(500*time.Millisecond)))
+	assert.True(t, hook.allow(now.Add(1500*time.Millisecond)))
+	assert.False(t, hook.allow(now.Add(1500*time.Millisecond)))
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelWriter is a writer choosing the actual writer of each entry by its level
+type LevelWriter interface {
+	io.Writer
+	WriterFor(level logrus.Level) io.Writer
+}
+
+// SplitWriter writes entries at or above a level of severity to one writer and the rest to another
+//
+// Entries must be written to the writer returned by WriterFor their levels. Write is for data without level and goes
+// to the normal writer.
+type SplitWriter struct {
+	Normal    io.Writer    // writer for entries less severe than Threshold, e.g. stdout
+	Severe    io.Writer    // writer for entries at Threshold or more severe, e.g. stderr
+	Threshold logrus.Level // least severe level written to Severe
+}
+
+// WriterFor returns the writer for entries at the given level
+func (w *SplitWriter) WriterFor(level logrus.Level) io.Writer {
+	if level <= w.Threshold {
+		return w.Severe
+	}
+	return w.Normal
+}
+
+func (w *SplitWriter) Write(p []byte) (int, error) {
+	return w.Normal.Write(p)
+}
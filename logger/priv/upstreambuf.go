@@ -0,0 +1,329 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package priv
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/retry"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bufferedExitTimeout  = 3 * time.Second
+	bufferedPanicTimeout = 1 * time.Second
+)
+
+// UpstreamTransport sends logs to upstream for the buffered hook, which deals with queueing, retry and flushing at exit
+//
+// Methods are never called concurrently.
+type UpstreamTransport interface {
+	// Connect establishes connection to upstream, with its own timeout. It's called before sending if not connected,
+	// or after Close.
+	Connect() error
+
+	// Send sends a batch of logs, each as a line of JSON without line break. The transport is closed on error and the
+	// batch is sent again after reconnection.
+	Send(lines []string) error
+
+	// Close closes the connection
+	Close() error
+}
+
+// OverflowPolicy defines what the buffered upstream does when its queue is full
+type OverflowPolicy string
+
+// Overflow policies
+const (
+	OverflowBlock      OverflowPolicy = "block"       // block logging until there is space in the queue
+	OverflowDropOldest OverflowPolicy = "drop-oldest" // drop the oldest log in the queue to make space
+	OverflowDropNewest OverflowPolicy = "drop-newest" // drop the new log
+)
+
+// UpstreamBufferOptions defines the queue and flushing of the buffered upstream, zero fields for defaults
+type UpstreamBufferOptions struct {
+	QueueSize      int            // max number of logs in queue, default 100000
+	OverflowPolicy OverflowPolicy // what to do with new logs when queue is full, default OverflowBlock
+	FlushInterval  time.Duration  // interval to send queued logs, default 100ms
+	BatchSize      int            // max number of logs per write, default 1
+}
+
+var (
+	// DropReportInterval is how often the numbers of dropped logs are reported to stderr
+	DropReportInterval = time.Minute
+
+	upstreamDroppedCounterVec = promext.NewRWCounterVec(prometheus.CounterOpts{
+		Name: "logger_upstream_dropped_total",
+		Help: "Numbers of logs dropped by buffered upstream",
+	}, []string{"reason"})
+	upstreamDroppedByOverflow = upstreamDroppedCounterVec.WithLabelValues("overflow")
+	upstreamDroppedBySendFail = upstreamDroppedCounterVec.WithLabelValues("send_failure")
+)
+
+func init() {
+	promext.SafeRegister(upstreamDroppedCounterVec)
+}
+
+func (opts UpstreamBufferOptions) withDefaults() UpstreamBufferOptions {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100000
+	}
+	if opts.OverflowPolicy == "" {
+		opts.OverflowPolicy = OverflowBlock
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 100 * time.Millisecond
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	return opts
+}
+
+// UpstreamBufferedHook to forward logs to upstream by a transport.
+// The hook buffers logs and send them in background - it requires logger.Exit() at app exit.
+type UpstreamBufferedHook struct {
+	name       string // name of transport for internal errors
+	transport  UpstreamTransport
+	connected  bool
+	options    UpstreamBufferOptions
+	logChannel chan upstreamLog
+	dropped    atomic.Int64 // logs dropped by overflow since the last report
+	closing    chan void    // close() to signal "closing": prepare to end worker and no more retry
+	closeOnce  sync.Once    // for closing, which may be signaled by both panic and exit
+	closed     chan void    // close() to signal "closed": fully stopped
+}
+
+// NewUpstreamBufferedHook creates a hook to be added to an instance of logger, to send logs by the transport
+//
+// The name is printed with internal errors to stderr, e.g. "upstreamtcpbuf".
+func NewUpstreamBufferedHook(name string, transport UpstreamTransport, opts UpstreamBufferOptions) *UpstreamBufferedHook {
+	opts = opts.withDefaults()
+	hook := &UpstreamBufferedHook{
+		name:       name,
+		transport:  transport,
+		options:    opts,
+		logChannel: make(chan upstreamLog, opts.QueueSize),
+		closing:    make(chan void),
+		closed:     make(chan void),
+	}
+	go hook.run()
+	logrus.RegisterExitHandler(hook.onExit)
+	return hook
+}
+
+// Fire is called to forward a logrus Entry / log record
+func (hook *UpstreamBufferedHook) Fire(entry *logrus.Entry) error {
+	data, err := SharedJSONFormatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	line := strings.TrimSuffix(string(data), "\n")
+	if len(line) == 0 {
+		return nil
+	}
+	hook.enqueue(upstreamLog{
+		level: entry.Level,
+		line:  line,
+	})
+	if entry.Level <= logrus.PanicLevel {
+		hook.signalClosing()
+		select {
+		case <-hook.closed:
+			break
+		case <-time.After(bufferedPanicTimeout):
+			break
+		}
+	}
+	return nil
+}
+
+// Levels defines the levels of logs to be sent to this hook
+func (hook *UpstreamBufferedHook) Levels() []logrus.Level {
+	return upstreamLogLevels
+}
+
+// enqueue adds the log to queue by the overflow policy, except that panic logs always block
+func (hook *UpstreamBufferedHook) enqueue(log upstreamLog) {
+	if hook.options.OverflowPolicy == OverflowBlock || log.level <= logrus.PanicLevel {
+		hook.logChannel <- log
+		return
+	}
+	for {
+		select {
+		case hook.logChannel <- log:
+			return
+		default:
+		}
+		if hook.options.OverflowPolicy == OverflowDropNewest {
+			hook.countOverflow()
+			return
+		}
+		select {
+		case <-hook.logChannel:
+			hook.countOverflow()
+		default:
+		}
+	}
+}
+
+func (hook *UpstreamBufferedHook) countOverflow() {
+	hook.dropped.Add(1)
+	upstreamDroppedByOverflow.Inc()
+}
+
+func (hook *UpstreamBufferedHook) run() {
+	defer close(hook.closed)
+	defer hook.drop()
+	defer hook.reportDropped()
+	lastReport := time.Now()
+	for {
+		select {
+		case <-time.After(hook.options.FlushInterval):
+			queued := hook.drainLogChannel()
+			if cont := hook.flushLogs(queued, true); !cont {
+				return
+			}
+		case <-hook.closing:
+			hook.flushRemainingLogs()
+			return
+		}
+		if time.Since(lastReport) >= DropReportInterval {
+			hook.reportDropped()
+			lastReport = time.Now()
+		}
+	}
+}
+
+// reportDropped prints the number of logs dropped by overflow since the last report, if any
+func (hook *UpstreamBufferedHook) reportDropped() {
+	if dropped := hook.dropped.Swap(0); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "%s: dropped %d logs due to full queue (%s)\n", hook.name, dropped, hook.options.OverflowPolicy)
+	}
+}
+
+func (hook *UpstreamBufferedHook) signalClosing() {
+	hook.closeOnce.Do(func() { close(hook.closing) })
+}
+
+func (hook *UpstreamBufferedHook) onExit() {
+	hook.signalClosing()
+	select {
+	case <-hook.closed:
+		break
+	case <-time.After(bufferedExitTimeout):
+		break
+	}
+}
+
+func (hook *UpstreamBufferedHook) flushRemainingLogs() {
+	remaining := hook.drainLogChannel()
+	hook.flushLogs(remaining, false)
+}
+
+func (hook *UpstreamBufferedHook) flushLogs(logs []upstreamLog, retry bool) bool {
+IterateBatches:
+	for i := 0; i < len(logs); i += hook.options.BatchSize {
+		batch := make([]string, 0, hook.options.BatchSize)
+		for _, log := range logs[i:min(i+hook.options.BatchSize, len(logs))] {
+			batch = append(batch, log.line)
+		}
+		for {
+			if !hook.connect(retry) {
+				fmt.Fprintf(os.Stderr, "%s: dropped %d remaining logs\n", hook.name, len(logs)-i)
+				upstreamDroppedBySendFail.Add(uint64(len(logs) - i))
+				return false
+			}
+			err := hook.transport.Send(batch)
+			if err == nil {
+				continue IterateBatches
+			}
+			fmt.Fprintf(os.Stderr, "%s: failed to send: %v\n", hook.name, err)
+			hook.drop()
+			select {
+			case <-hook.closing:
+				retry = false // allow one more reconnect attempt to send all logs
+			case <-time.After(RetryInterval):
+			}
+		}
+	}
+	return true
+}
+
+func (hook *UpstreamBufferedHook) drainLogChannel() []upstreamLog {
+	list := make([]upstreamLog, 0, len(hook.logChannel))
+	for {
+		select {
+		case log, ok := <-hook.logChannel:
+			if !ok {
+				return list
+			}
+			list = append(list, log)
+		default:
+			return list
+		}
+	}
+}
+
+func (hook *UpstreamBufferedHook) connect(keepRetrying bool) bool {
+	if hook.connected {
+		return true
+	}
+	policy := retry.NoRetry
+	if keepRetrying {
+		policy = retry.Policy{MaxAttempts: math.MaxInt, InitialBackoff: RetryInterval, Multiplier: 1}
+	}
+	ctx, cancel := hook.newClosingContext()
+	defer cancel()
+
+	err := retry.Do(ctx, policy, func() error {
+		if err := hook.transport.Connect(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: failed to connect: %v\n", hook.name, err)
+			return err
+		}
+		hook.connected = true
+		return nil
+	})
+	return err == nil
+}
+
+// newClosingContext creates a context which is cancelled when the hook is closing
+func (hook *UpstreamBufferedHook) newClosingContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-hook.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (hook *UpstreamBufferedHook) drop() {
+	if !hook.connected {
+		return
+	}
+	hook.transport.Close()
+	hook.connected = false
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package priv
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBufferedHook(queueSize int, policy OverflowPolicy) *UpstreamBufferedHook {
+	opts := UpstreamBufferOptions{QueueSize: queueSize, OverflowPolicy: policy, BatchSize: 2}.withDefaults()
+	return &UpstreamBufferedHook{
+		options:    opts,
+		logChannel: make(chan upstreamLog, opts.QueueSize),
+		closing:    make(chan void),
+	}
+}
+
+func TestBufferedHookOverflow(t *testing.T) {
+	dropOldest := newTestBufferedHook(2, OverflowDropOldest)
+	dropNewest := newTestBufferedHook(2, OverflowDropNewest)
+	for _, line := range []string{"1", "2", "3"} {
+		dropOldest.enqueue(upstreamLog{logrus.InfoLevel, line})
+		dropNewest.enqueue(upstreamLog{logrus.InfoLevel, line})
+	}
+	assert.Equal(t, []upstreamLog{{logrus.InfoLevel, "2"}, {logrus.InfoLevel, "3"}}, dropOldest.drainLogChannel())
+	assert.Equal(t, []upstreamLog{{logrus.InfoLevel, "1"}, {logrus.InfoLevel, "2"}}, dropNewest.drainLogChannel())
+	assert.EqualValues(t, 1, dropOldest.dropped.Load())
+	assert.EqualValues(t, 1, dropNewest.dropped.Load())
+	dropOldest.reportDropped()
+	assert.EqualValues(t, 0, dropOldest.dropped.Load())
+}
+
+type testTransport struct {
+	batches  [][]string
+	failures int
+	closed   int
+}
+
+func (t *testTransport) Connect() error {
+	return nil
+}
+
+func (t *testTransport) Send(lines []string) error {
+	if t.failures > 0 {
+		t.failures--
+		return errors.New("broken pipe")
+	}
+	t.batches = append(t.batches, lines)
+	return nil
+}
+
+func (t *testTransport) Close() error {
+	t.closed++
+	return nil
+}
+
+func TestBufferedHookBatch(t *testing.T) {
+	RetryInterval = time.Millisecond
+	transport := &testTransport{failures: 1}
+	hook := newTestBufferedHook(10, OverflowBlock)
+	hook.name = "test"
+	hook.transport = transport
+
+	assert.True(t, hook.flushLogs([]upstreamLog{{logrus.InfoLevel, "a"}, {logrus.InfoLevel, "b"}, {logrus.InfoLevel, "c"}}, false))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, transport.batches)
+	assert.Equal(t, 1, transport.closed)
+}
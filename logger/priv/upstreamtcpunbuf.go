@@ -47,7 +47,7 @@ func NewUpstreamTCPUnbufferedHook(endpoint string) *UpstreamTCPUnbufferedHook {
 
 // Fire is called to forward a logrus Entry / log record
 func (hook *UpstreamTCPUnbufferedHook) Fire(entry *logrus.Entry) error {
-	data, err := JSONFormatter.Format(entry)
+	data, err := SharedJSONFormatter.Format(entry)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,90 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Headers of W3C trace context and request ID, see ForRequest
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+	requestIDHeader   = "X-Request-ID"
+)
+
+// ForRequest creates a request-scoped sub-logger with fields from the W3C trace context and request ID headers of
+// the HTTP request:
+//
+//   - "traceID" and "parentSpanID" from "traceparent", e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+//   - "traceState" from "tracestate", only if "traceparent" is valid
+//   - "requestID" from "X-Request-ID"
+//
+// Missing or invalid headers are skipped.
+func (logger Logger) ForRequest(r *http.Request) Logger {
+	fields := Fields{}
+	if traceID, parentSpanID, ok := parseTraceParent(r.Header.Get(traceParentHeader)); ok {
+		fields["traceID"] = traceID
+		fields["parentSpanID"] = parentSpanID
+		if traceState := strings.Join(r.Header.Values(traceStateHeader), ","); traceState != "" {
+			fields["traceState"] = traceState
+		}
+	}
+	if requestID := r.Header.Get(requestIDHeader); requestID != "" {
+		fields["requestID"] = requestID
+	}
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.WithFields(fields)
+}
+
+// ForRequest creates a request-scoped sub-logger of the root logger, see Logger.ForRequest
+func ForRequest(r *http.Request) Logger {
+	return root.ForRequest(r)
+}
+
+// parseTraceParent parses the "traceparent" header of W3C trace context, in the format of
+// "{version}-{trace-id}-{parent-id}-{trace-flags}"
+//
+// Future versions may append more fields, which are ignored.
+func parseTraceParent(header string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	version, traceID, parentSpanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", false
+	}
+	if !isLowerHex(traceID, 32) || !isLowerHex(parentSpanID, 16) || !isLowerHex(flags, 2) {
+		return "", "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentSpanID, "0") == "" {
+		return "", "", false
+	}
+	return traceID, parentSpanID, true
+}
+
+func isLowerHex(str string, length int) bool {
+	if len(str) != length {
+		return false
+	}
+	for _, c := range str {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/relex/gotils/envutil"
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// SentryOptions defines the environment and release tags and the rate limit of events sent to Sentry
+type SentryOptions = priv.SentryOptions
+
+var (
+	sentryLock            sync.Mutex
+	sentryOutput          = &sentryHookRef{}
+	sentryHookSet         sync.Once
+	unregisterSentryFlush func()
+)
+
+// sentryHookRef forwards entries to the current Sentry hook, which can be replaced by SetSentry
+type sentryHookRef struct {
+	hook atomic.Pointer[priv.SentryHook]
+}
+
+func (ref *sentryHookRef) Levels() []logrus.Level {
+	return priv.SentryLogLevels
+}
+
+func (ref *sentryHookRef) Fire(entry *logrus.Entry) error {
+	hook := ref.hook.Load()
+	if hook == nil {
+		return nil
+	}
+	return hook.Fire(entry)
+}
+
+func setDefaultSentry() {
+	dsn := envutil.Get("SENTRY_DSN", "")
+	if dsn == "" {
+		return
+	}
+	opts := SentryOptions{
+		Environment: envutil.Get("SENTRY_ENVIRONMENT", ""),
+		Release:     envutil.Get("SENTRY_RELEASE", ""),
+	}
+	if limit, found, err := envutil.Lookup[int]("LOG_SENTRY_RATE_LIMIT"); err != nil || (found && limit <= 0) {
+		ownLogger.Errorf("Invalid LOG_SENTRY_RATE_LIMIT value: '%s', select default", os.Getenv("LOG_SENTRY_RATE_LIMIT"))
+	} else {
+		opts.RateLimit = limit
+	}
+	if err := SetSentry(dsn, opts); err != nil {
+		ownLogger.Errorf("Unable to set Sentry: %v", err)
+	}
+}
+
+// SetSentry configures the root logger to send logs at error level or above to the Sentry DSN as events, e.g.
+// "https://public_key@sentry.example.com/42"
+//
+// Fields of logs including those from StructuredError are sent as extra data, along with the stack trace of caller.
+// Events are sent in background and flushed at exit by RegisterFlusher.
+//
+// Calling it again replaces the previous Sentry hook, which is closed after sending its pending events.
+func SetSentry(dsn string, opts SentryOptions) error {
+	hook, err := priv.NewSentryHook(dsn, opts)
+	if err != nil {
+		return err
+	}
+
+	sentryLock.Lock()
+	defer sentryLock.Unlock()
+	sentryHookSet.Do(func() {
+		root.entry.Logger.AddHook(sentryOutput)
+	})
+	if previous := sentryOutput.hook.Swap(hook); previous != nil {
+		unregisterSentryFlush()
+		previous.Close()
+	}
+	unregisterSentryFlush = RegisterFlusher(hook.Flush)
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSubprocessLineLength is the max length of a line from subprocesses, longer lines are split
+const maxSubprocessLineLength = 64 * 1024
+
+// textLevelRegex matches the level in text logs of subprocesses, e.g. "level=warn", "[ERROR]" or "WARN:" at beginning
+var textLevelRegex = regexp.MustCompile(`(?i)(?:\blevel=|\blvl=|^\W*)(trace|debug|info|warn|warning|error|fatal|crit|critical|panic)\b`)
+
+var (
+	jsonLevelKeys   = []string{"level", "lvl", "severity"}
+	jsonMessageKeys = []string{"message", "msg"}
+	jsonIgnoredKeys = []string{"time", "timestamp", "ts"}
+)
+
+// CommandOutputOptions configures ForwardCommandOutput
+type CommandOutputOptions struct {
+	Name        string   // component name of logs from the subprocess, default to the base name of command path
+	ParseJSON   bool     // parse each line as JSON and take the level, message and other fields from it if possible
+	StdoutLevel LogLevel // level of stdout lines without recognizable level, default to info
+	StderrLevel LogLevel // level of stderr lines without recognizable level, default to warn
+}
+
+// ForwardCommandOutput forwards the output of a command through the root logger, see Logger.ForwardCommandOutput
+func ForwardCommandOutput(cmd *exec.Cmd, opts CommandOutputOptions) func() {
+	return root.ForwardCommandOutput(cmd, opts)
+}
+
+// ForwardCommandOutput attaches to the stdout and stderr of a command and re-emits each line through this logger, with
+// the subprocess name as component and levels recognized from the lines, e.g. "level=warn" or "[ERROR]" in text, or
+// the "level" field in JSON. Fatal and panic levels from the subprocess are logged as errors.
+//
+// Must be called before cmd.Start. The returned function flushes incomplete last lines and should be called after
+// cmd.Wait, e.g.:
+//
+//	flush := logger.ForwardCommandOutput(cmd, logger.CommandOutputOptions{ParseJSON: true})
+//	err := cmd.Run()
+//	flush()
+func (logger Logger) ForwardCommandOutput(cmd *exec.Cmd, opts CommandOutputOptions) func() {
+	name := opts.Name
+	if name == "" {
+		name = filepath.Base(cmd.Path)
+	}
+	if opts.StdoutLevel == "" {
+		opts.StdoutLevel = InfoLevel
+	}
+	if opts.StderrLevel == "" {
+		opts.StderrLevel = WarnLevel
+	}
+	cmdLogger := logger.WithField(priv.LabelComponent, name)
+	stdout := &commandOutputWriter{logger: cmdLogger, parseJSON: opts.ParseJSON, defaultLevel: opts.StdoutLevel}
+	stderr := &commandOutputWriter{logger: cmdLogger, parseJSON: opts.ParseJSON, defaultLevel: opts.StderrLevel}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+// commandOutputWriter splits output of subprocess into lines and logs each of them
+type commandOutputWriter struct {
+	logger       Logger
+	parseJSON    bool
+	defaultLevel LogLevel
+
+	lock   sync.Mutex
+	buffer []byte
+}
+
+func (w *commandOutputWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buffer = append(w.buffer, p...)
+	for {
+		end := bytes.IndexByte(w.buffer, '\n')
+		if end < 0 {
+			if len(w.buffer) >= maxSubprocessLineLength {
+				w.logLine(string(w.buffer))
+				w.buffer = w.buffer[:0]
+			}
+			break
+		}
+		w.logLine(string(w.buffer[:end]))
+		w.buffer = w.buffer[end+1:]
+	}
+	return len(p), nil
+}
+
+func (w *commandOutputWriter) flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.buffer) > 0 {
+		w.logLine(string(w.buffer))
+		w.buffer = nil
+	}
+}
+
+func (w *commandOutputWriter) logLine(line string) {
+	line = strings.TrimRight(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if w.parseJSON && strings.HasPrefix(line, "{") {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &fields); err == nil {
+			w.logJSONFields(fields, line)
+			return
+		}
+	}
+	level := w.defaultLevel
+	if match := textLevelRegex.FindStringSubmatch(line); match != nil {
+		level = LogLevel(strings.ToLower(match[1]))
+	}
+	logSubprocessLine(w.logger, level, line)
+}
+
+func (w *commandOutputWriter) logJSONFields(fields map[string]interface{}, line string) {
+	level := w.defaultLevel
+	if levelValue, found := popJSONField(fields, jsonLevelKeys); found {
+		childLevel := LogLevel(strings.ToLower(fmt.Sprint(levelValue)))
+		if _, known := levelMap[childLevel]; known {
+			level = childLevel
+		}
+	}
+	message := line
+	if messageValue, found := popJSONField(fields, jsonMessageKeys); found {
+		message = fmt.Sprint(messageValue)
+	}
+	popJSONField(fields, jsonIgnoredKeys)
+	if component, found := fields[priv.LabelComponent]; found {
+		delete(fields, priv.LabelComponent)
+		fields["subcomponent"] = component
+	}
+
+	entryLogger := w.logger
+	if len(fields) > 0 {
+		entryLogger = entryLogger.WithFields(fields)
+	}
+	logSubprocessLine(entryLogger, level, message)
+}
+
+// popJSONField removes the given keys from fields and returns the value of the first key found
+func popJSONField(fields map[string]interface{}, keys []string) (interface{}, bool) {
+	var value interface{}
+	found := false
+	for _, key := range keys {
+		if v, exists := fields[key]; exists {
+			if !found {
+				value = v
+				found = true
+			}
+			delete(fields, key)
+		}
+	}
+	return value, found
+}
+
+// logSubprocessLine logs a line at the given level, or error if the level is fatal or panic
+func logSubprocessLine(logger Logger, level LogLevel, message string) {
+	switch levelMap[level] {
+	case logrus.WarnLevel:
+		logger.Warn(message)
+	case logrus.InfoLevel:
+		logger.Info(message)
+	case logrus.DebugLevel:
+		logger.Debug(message)
+	case logrus.TraceLevel:
+		logger.Trace(message)
+	default:
+		logger.Error(message)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/relex/gotils/envutil"
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// SystemOutput represents a log output of the operating system
+type SystemOutput string
+
+// System outputs
+const (
+	AutoSystemOutput SystemOutput = "auto"     // event log for Windows services, or journal if stderr is not collected by systemd
+	JournalOutput    SystemOutput = "journal"  // systemd journal
+	EventLogOutput   SystemOutput = "eventlog" // Windows Event Log, with the executable name as event source
+)
+
+func setDefaultSystemOutput() {
+	if output := envutil.Get("LOG_SYSTEM_OUTPUT", ""); output != "" {
+		if err := SetSystemOutput(SystemOutput(strings.ToLower(output))); err != nil {
+			ownLogger.Errorf("Unable to set system log output '%s': %v", output, err)
+		}
+	}
+}
+
+// SetSystemOutput configures the root logger to write logs of the primary output to the system output as well, e.g.
+// for system services whose stdout and stderr are not collected
+//
+// AutoSystemOutput selects nothing if no system output is available or stderr is already collected by systemd.
+// This function should be called at most once.
+func SetSystemOutput(output SystemOutput) error {
+	if output == AutoSystemOutput {
+		switch {
+		case priv.IsEventLogAvailable():
+			output = EventLogOutput
+		case priv.IsJournalAvailable() && os.Getenv("JOURNAL_STREAM") == "":
+			output = JournalOutput
+		default:
+			ownLogger.Debug("No system log output selected")
+			return nil
+		}
+	}
+
+	var hook logrus.Hook
+	var err error
+	switch output {
+	case JournalOutput:
+		hook, err = priv.NewJournalHook()
+	case EventLogOutput:
+		hook, err = priv.NewEventLogHook(strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"))
+	default:
+		return fmt.Errorf("invalid system log output: '%s'", output)
+	}
+	if err != nil {
+		return err
+	}
+	root.entry.Logger.Hooks.Add(primaryLevelHook{hook})
+	return nil
+}
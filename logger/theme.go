@@ -0,0 +1,105 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package logger
+
+import (
+	"fmt"
+
+	"github.com/relex/gotils/logger/priv"
+	"github.com/sirupsen/logrus"
+)
+
+// ConsoleColor is an ANSI escape sequence of foreground color for console format
+type ConsoleColor string
+
+// Basic console colors supported by all color terminals
+const (
+	ColorBlack   ConsoleColor = "\x1b[30m"
+	ColorRed     ConsoleColor = "\x1b[31m"
+	ColorGreen   ConsoleColor = "\x1b[32m"
+	ColorYellow  ConsoleColor = "\x1b[33m"
+	ColorBlue    ConsoleColor = "\x1b[34m"
+	ColorMagenta ConsoleColor = "\x1b[35m"
+	ColorCyan    ConsoleColor = "\x1b[36m"
+	ColorWhite   ConsoleColor = "\x1b[37m"
+	ColorGray    ConsoleColor = "\x1b[90m"
+)
+
+// Color256 returns the color in the 256-color palette, e.g. 208 for orange
+func Color256(index uint8) ConsoleColor {
+	return ConsoleColor(fmt.Sprintf("\x1b[38;5;%dm", index))
+}
+
+// TrueColor returns the 24-bit RGB color, which is only supported by some terminals
+func TrueColor(r, g, b uint8) ConsoleColor {
+	return ConsoleColor(fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b))
+}
+
+// ConsoleTheme defines the colors and styles of console format, which is selected by SetAutoFormat for terminals
+type ConsoleTheme struct {
+	LevelColors          map[LogLevel]ConsoleColor // colors by level; missing levels use colors of DefaultConsoleTheme
+	NoComponentUnderline bool                      // don't underline component names
+}
+
+// DefaultConsoleTheme is the default theme for dark terminal backgrounds
+var DefaultConsoleTheme = ConsoleTheme{
+	LevelColors: map[LogLevel]ConsoleColor{
+		TraceLevel: ColorWhite,
+		DebugLevel: ColorWhite,
+		InfoLevel:  ColorYellow,
+		WarnLevel:  ColorMagenta,
+		ErrorLevel: ColorRed,
+		FatalLevel: ColorRed,
+		PanicLevel: ColorRed,
+	},
+}
+
+// LightConsoleTheme is the theme for light terminal backgrounds
+var LightConsoleTheme = ConsoleTheme{
+	LevelColors: map[LogLevel]ConsoleColor{
+		TraceLevel: ColorGray,
+		DebugLevel: ColorGray,
+		InfoLevel:  ColorBlue,
+		WarnLevel:  Color256(130), // dark orange
+		ErrorLevel: ColorRed,
+		FatalLevel: ColorRed,
+		PanicLevel: ColorRed,
+	},
+}
+
+// SetConsoleTheme sets the colors and styles of console format, taking effect immediately
+//
+// Colors are never used if the environment variable "NO_COLOR" is set, unless forced by "LOG_COLOR".
+func SetConsoleTheme(theme ConsoleTheme) {
+	levelColors := make(map[logrus.Level]string, len(levelMap))
+	for level, color := range DefaultConsoleTheme.LevelColors {
+		levelColors[levelMap[level]] = string(color)
+	}
+	for level, color := range theme.LevelColors {
+		logrusLevel, exists := levelMap[level]
+		if !exists {
+			ownLogger.Fatalf("Invalid log level: '%s'", level)
+		}
+		levelColors[logrusLevel] = string(color)
+	}
+
+	componentFormat := priv.DefaultConsoleTheme.ComponentFormat
+	if theme.NoComponentUnderline {
+		componentFormat = ""
+	}
+	priv.SetConsoleTheme(&priv.ConsoleTheme{
+		LevelColors:     levelColors,
+		DefaultColor:    priv.DefaultConsoleTheme.DefaultColor,
+		ComponentFormat: componentFormat,
+	})
+}
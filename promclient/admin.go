@@ -0,0 +1,128 @@
+package promclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const deletePushGroupTimeout = 20 * time.Second
+
+// adminResponse defines the structure of Prometheus admin API responses, which may have no content on success
+type adminResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+// DeletePushGroup deletes all metrics of the group identified by job and grouping labels from Pushgateway
+//
+// The URL should contain no path for the official pushgateway, same as promexporter.PushMetrics
+func DeletePushGroup(url string, job string, groupingLabels map[string]string) error {
+	client := &http.Client{Timeout: deletePushGroupTimeout} // default is no timeout
+	pusher := push.New(url, job).Client(client)
+	for name, value := range groupingLabels {
+		pusher = pusher.Grouping(name, value)
+	}
+	if err := pusher.Delete(); err != nil {
+		return fmt.Errorf("failed to delete push group '%s' %v: %w", job, groupingLabels, err)
+	}
+	return nil
+}
+
+// DeleteSeries deletes data of series matching any of the selectors within the time range from Prometheus
+//
+// Zero start or end means no limit on that side. The admin API must be enabled by "--web.enable-admin-api", and the
+// space is only freed after compaction or CleanTombstones.
+func DeleteSeries(baseURL string, timeout time.Duration, selectors []string, start time.Time, end time.Time) error {
+	if len(selectors) == 0 {
+		return fmt.Errorf("no series selectors for deletion")
+	}
+	parameters := map[string][]string{"match[]": selectors}
+	if !start.IsZero() {
+		parameters["start"] = []string{start.Format(time.RFC3339)}
+	}
+	if !end.IsZero() {
+		parameters["end"] = []string{end.Format(time.RFC3339)}
+	}
+	return callAdminAPI(baseURL, "/api/v1/admin/tsdb/delete_series", parameters, timeout, nil)
+}
+
+// CleanTombstones removes deleted data from disk in Prometheus, see DeleteSeries
+func CleanTombstones(baseURL string, timeout time.Duration) error {
+	return callAdminAPI(baseURL, "/api/v1/admin/tsdb/clean_tombstones", nil, timeout, nil)
+}
+
+// Snapshot creates a snapshot of all current data in Prometheus, optionally skipping data in the head block
+//
+// Returns the name of snapshot directory under "<data-dir>/snapshots"
+func Snapshot(baseURL string, timeout time.Duration, skipHead bool) (string, error) {
+	parameters := map[string][]string{"skip_head": {strconv.FormatBool(skipHead)}}
+	var data struct {
+		Name string `json:"name"`
+	}
+	if err := callAdminAPI(baseURL, "/api/v1/admin/tsdb/snapshot", parameters, timeout, &data); err != nil {
+		return "", err
+	}
+	if data.Name == "" {
+		return "", fmt.Errorf("failed to parse Prometheus snapshot response: missing name")
+	}
+	return data.Name, nil
+}
+
+// callAdminAPI sends a POST request to the admin API and parses the "data" field into output if not nil
+func callAdminAPI(baseURL string, path string, parameters map[string][]string, timeout time.Duration, output interface{}) error {
+	apiURL, urlErr := buildURLWithValues(baseURL, path, parameters)
+	if urlErr != nil {
+		return urlErr
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, reqErr := http.NewRequestWithContext(ctx, "POST", apiURL, nil)
+	if reqErr != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", reqErr)
+	}
+
+	resp, respErr := http.DefaultClient.Do(req)
+	if respErr != nil {
+		return fmt.Errorf("failed to get HTTP response: %w", respErr)
+	}
+
+	defer resp.Body.Close()
+	body, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return fmt.Errorf("failed to read HTTP response: %w", readErr)
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	var parsedBody adminResponse
+	if err := json.Unmarshal(body, &parsedBody); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("failed to call Prometheus admin API %s: %s: %s", path, resp.Status, string(body))
+		}
+		return fmt.Errorf("failed to parse HTTP response: %w\n%s", err, string(body))
+	}
+
+	if resp.StatusCode/100 != 2 || parsedBody.Status != "success" {
+		return fmt.Errorf("failed to call Prometheus admin API %s: %s: %s: %s", path, resp.Status, parsedBody.ErrorType, parsedBody.Error)
+	}
+
+	if output != nil {
+		if err := json.Unmarshal(parsedBody.Data, output); err != nil {
+			return fmt.Errorf("failed to parse Prometheus admin API result: %w\n%s", err, string(parsedBody.Data))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package promclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeletePushGroup(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/metrics/job/missing" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, DeletePushGroup(server.URL, "batch", map[string]string{"instance": "host1"}))
+	assert.ErrorContains(t, DeletePushGroup(server.URL, "missing", nil), "failed to delete push group 'missing'")
+	assert.Equal(t, []string{"DELETE /metrics/job/batch/instance/host1", "DELETE /metrics/job/missing"}, requests)
+}
+
+func TestAdminAPI(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/prom/api/v1/admin/tsdb/delete_series", "/prom/api/v1/admin/tsdb/clean_tombstones":
+			w.WriteHeader(http.StatusNoContent)
+		case "/prom/api/v1/admin/tsdb/snapshot":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"name":"20210601T000000Z-2be650b6d019eb54"}}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"unavailable","error":"admin APIs disabled"}`))
+		}
+	}))
+	defer server.Close()
+	baseURL := server.URL + "/prom/"
+
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, DeleteSeries(baseURL, time.Second, []string{`up{job="a"}`, `up{job="b"}`}, start, time.Time{}))
+	assert.ErrorContains(t, DeleteSeries(baseURL, time.Second, nil, start, time.Time{}), "no series selectors")
+	assert.NoError(t, CleanTombstones(baseURL, time.Second))
+	name, err := Snapshot(baseURL, time.Second, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "20210601T000000Z-2be650b6d019eb54", name)
+	assert.Equal(t, []string{
+		"POST /prom/api/v1/admin/tsdb/delete_series?match%5B%5D=up%7Bjob%3D%22a%22%7D&match%5B%5D=up%7Bjob%3D%22b%22%7D&start=2021-06-01T00%3A00%3A00Z",
+		"POST /prom/api/v1/admin/tsdb/clean_tombstones",
+		"POST /prom/api/v1/admin/tsdb/snapshot?skip_head=true",
+	}, requests)
+
+	assert.EqualError(t, CleanTombstones(server.URL, time.Second),
+		"failed to call Prometheus admin API /api/v1/admin/tsdb/clean_tombstones: 503 Service Unavailable: unavailable: admin APIs disabled")
+}
@@ -0,0 +1,165 @@
+package promclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iancoleman/strcase"
+)
+
+// labelFieldsCache caches label fields by struct types
+var labelFieldsCache sync.Map // reflect.Type => []labelField
+
+type labelField struct {
+	index    int
+	name     string
+	required bool
+}
+
+// LabeledInstantVector is a vector returned by Prometheus instant queries with labels decoded into struct L
+//
+// A reference of LabeledInstantVector can be used directly as the "outVector" argument to the QueryInstant function.
+// See DecodeLabels for the fields of L.
+type LabeledInstantVector[L any] []LabeledInstantSample[L]
+
+// LabeledInstantSample is a sample returned by Prometheus instant queries with labels decoded into struct L
+type LabeledInstantSample[L any] struct {
+	Labels L         // Labels contains the labels of this sample decoded by DecodeLabels
+	Value  DataPoint // Value is the sampled value from instant queries
+}
+
+// LabeledRangedMatrix is a matrix returned by Prometheus ranged queries with labels decoded into struct L
+//
+// A reference of LabeledRangedMatrix can be used directly as the "outMatrix" argument to the QueryRanged function.
+// See DecodeLabels for the fields of L.
+type LabeledRangedMatrix[L any] []LabeledRangedSampleStream[L]
+
+// LabeledRangedSampleStream is a sample stream returned by Prometheus ranged queries with labels decoded into struct L
+type LabeledRangedSampleStream[L any] struct {
+	Labels L           // Labels contains the labels of this sample stream decoded by DecodeLabels
+	Values []DataPoint // Values contains a stream of sampled values from ranged queries
+}
+
+// UnmarshalJSON provides custom JSON unmarshalling
+func (sample *LabeledInstantSample[L]) UnmarshalJSON(data []byte) error {
+	var raw SimpleInstantSample
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := DecodeLabels(raw.Metric, &sample.Labels); err != nil {
+		return err
+	}
+	sample.Value = raw.Value
+	return nil
+}
+
+// UnmarshalJSON provides custom JSON unmarshalling
+func (stream *LabeledRangedSampleStream[L]) UnmarshalJSON(data []byte) error {
+	var raw SimpleRangedSampleStream
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := DecodeLabels(raw.Metric, &stream.Labels); err != nil {
+		return err
+	}
+	stream.Values = raw.Values
+	return nil
+}
+
+// DecodeLabels decodes labels into the struct pointed by labelStruct, the reverse of promexporter.GetLabelNames
+//
+// Fields are mapped to labels by the tag `label:"name"`, then `json:"name"`, or the field name in snake case if
+// untagged. Fields tagged by `label:"-"` are ignored. Missing labels are left as zero values, unless the fields are
+// tagged as required, e.g. `label:"job,required"`.
+//
+// Fields can be string, bool, integers or floats, which are parsed from label values.
+func DecodeLabels(labels map[string]string, labelStruct interface{}) error {
+	structValue := reflect.ValueOf(labelStruct)
+	if structValue.Kind() != reflect.Pointer || structValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("invalid label struct type: %T", labelStruct)
+	}
+	structValue = structValue.Elem()
+
+	for _, field := range getLabelFields(structValue.Type()) {
+		value, found := labels[field.name]
+		if !found {
+			if field.required {
+				return fmt.Errorf("missing required label '%s'", field.name)
+			}
+			continue
+		}
+		if err := setLabelField(structValue.Field(field.index), value); err != nil {
+			return fmt.Errorf("failed to decode label '%s': %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+// getLabelFields returns the label fields of the struct type
+func getLabelFields(t reflect.Type) []labelField {
+	if cached, ok := labelFieldsCache.Load(t); ok {
+		return cached.([]labelField)
+	}
+	fields := make([]labelField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(f.Tag.Get("label"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name, _, _ = strings.Cut(f.Tag.Get("json"), ",")
+		}
+		if name == "" {
+			name = strcase.ToSnake(f.Name)
+		}
+		fields = append(fields, labelField{
+			index:    i,
+			name:     name,
+			required: options == "required",
+		})
+	}
+	labelFieldsCache.Store(t, fields)
+	return fields
+}
+
+func setLabelField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package promclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTargetLabels struct {
+	Job      string `label:"job,required"`
+	Port     uint16
+	Replica  int     `label:"replica_index"`
+	Weight   float64 `label:"weight"`
+	Canary   bool    `json:"is_canary"`
+	Ignored  string  `label:"-"`
+	internal string
+}
+
+func TestDecodeLabels(t *testing.T) {
+	var labels testTargetLabels
+	assert.NoError(t, DecodeLabels(map[string]string{
+		"job": "api", "port": "8080", "replica_index": "-1", "weight": "0.5", "is_canary": "true", "ignored": "x", "other": "y",
+	}, &labels))
+	assert.Equal(t, testTargetLabels{Job: "api", Port: 8080, Replica: -1, Weight: 0.5, Canary: true}, labels)
+
+	assert.EqualError(t, DecodeLabels(map[string]string{"port": "80"}, &testTargetLabels{}), "missing required label 'job'")
+	assert.ErrorContains(t, DecodeLabels(map[string]string{"job": "api", "port": "http"}, &testTargetLabels{}),
+		"failed to decode label 'port': strconv.ParseUint: parsing \"http\": invalid syntax")
+	assert.ErrorContains(t, DecodeLabels(nil, testTargetLabels{}), "invalid label struct type")
+}
+
+func TestDecodeVectorAndMatrix(t *testing.T) {
+	var vector LabeledInstantVector[testTargetLabels]
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"metric": {"job": "api", "port": "8080"}, "value": [1622505600, "1"]},
+		{"metric": {"job": "db"}, "value": [1622505600, "0"]}
+	]`), &vector))
+	assert.Len(t, vector, 2)
+	assert.Equal(t, testTargetLabels{Job: "api", Port: 8080}, vector[0].Labels)
+	assert.Equal(t, 1.0, vector[0].Value.Value)
+	assert.Equal(t, "db", vector[1].Labels.Job)
+
+	var matrix LabeledRangedMatrix[testTargetLabels]
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"metric": {"job": "api"}, "values": [[1622505600, "1"], [1622505660, "2"]]}
+	]`), &matrix))
+	assert.Len(t, matrix, 1)
+	assert.Equal(t, "api", matrix[0].Labels.Job)
+	assert.Len(t, matrix[0].Values, 2)
+
+	assert.ErrorContains(t, json.Unmarshal([]byte(`[{"metric": {}, "value": [1622505600, "1"]}]`), &vector),
+		"missing required label 'job'")
+}
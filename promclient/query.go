@@ -47,8 +47,12 @@ func QueryInstant(baseURL string, timeout time.Duration, expression string, ts t
 
 // QueryRanged queries Prometheus for a time range and returns a matrix.
 //
-// The outMatrix argument may be a reference to a slice of custom struct or a SimpleRangedMatrix
+// The outMatrix argument may be a reference to a slice of custom struct or a SimpleRangedMatrix.
+// The step is in seconds. Queries resulting in more than MaxQueryPoints per series are rejected without being sent.
 func QueryRanged(baseURL string, timeout time.Duration, expression string, start time.Time, end time.Time, step int, outMatrix interface{}) error {
+	if err := checkQueryPoints(start, end, step); err != nil {
+		return err
+	}
 	return queryAPI(baseURL, "/api/v1/query_range",
 		map[string]string{
 			"query": expression,
@@ -108,6 +112,15 @@ func queryAPI(baseURL string, path string, parameters map[string]string, timeout
 }
 
 func buildURL(baseURL string, addPath string, addQuery map[string]string) (string, error) {
+	values := make(map[string][]string, len(addQuery))
+	for key, val := range addQuery {
+		values[key] = []string{val}
+	}
+	return buildURLWithValues(baseURL, addPath, values)
+}
+
+// buildURLWithValues builds an URL like buildURL, with parameters which may have multiple values, e.g. "match[]"
+func buildURLWithValues(baseURL string, addPath string, addQuery map[string][]string) (string, error) {
 	urlObj, parseErr := url.Parse(baseURL)
 	if parseErr != nil {
 		return "", fmt.Errorf("failed to parse URL: %w", parseErr)
@@ -116,7 +129,7 @@ func buildURL(baseURL string, addPath string, addQuery map[string]string) (strin
 	urlObj.Path = strings.TrimRight(urlObj.Path, "/") + addPath
 	q := urlObj.Query()
 	for key, val := range addQuery {
-		q.Set(key, val)
+		q[key] = val
 	}
 	urlObj.RawQuery = q.Encode()
 
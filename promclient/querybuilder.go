@@ -0,0 +1,136 @@
+package promclient
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/relex/gotils/logger"
+)
+
+var (
+	metricNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegex  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Query builds a PromQL expression from a metric selector, with label values escaped properly, e.g.:
+//
+//	promclient.NewQuery("http_requests_total").Match("job", userInput).Rate(5 * time.Minute).Sum("code").String()
+//
+// Label matchers must be added before functions or aggregations. Invalid metric or label names cause panic.
+//
+// Query values are immutable and can be shared as bases of different queries.
+type Query struct {
+	metric   string
+	matchers []string
+	expr     string // expression with functions or aggregations applied, empty for plain selector
+}
+
+// NewQuery creates a Query for the metric name, which may be empty if label matchers are added
+func NewQuery(metric string) Query {
+	if metric != "" && !metricNameRegex.MatchString(metric) {
+		logger.Panicf("invalid metric name: '%s'", metric)
+	}
+	return Query{metric: metric}
+}
+
+// Match adds a label matcher for equality: label="value"
+func (q Query) Match(label string, value string) Query {
+	return q.addMatcher(label, "=", value)
+}
+
+// NotMatch adds a label matcher for inequality: label!="value"
+func (q Query) NotMatch(label string, value string) Query {
+	return q.addMatcher(label, "!=", value)
+}
+
+// MatchRe adds a label matcher for regular expression: label=~"regex"
+//
+// Prometheus anchors the expression, so it must match the whole label value. Use regexp.QuoteMeta for literal parts.
+func (q Query) MatchRe(label string, re *regexp.Regexp) Query {
+	return q.addMatcher(label, "=~", re.String())
+}
+
+// NotMatchRe adds a label matcher for negative regular expression: label!~"regex", see MatchRe
+func (q Query) NotMatchRe(label string, re *regexp.Regexp) Query {
+	return q.addMatcher(label, "!~", re.String())
+}
+
+// Rate applies rate() over the range of window to the selector
+func (q Query) Rate(window time.Duration) Query {
+	return q.applyRangeFunction("rate", window)
+}
+
+// Increase applies increase() over the range of window to the selector
+func (q Query) Increase(window time.Duration) Query {
+	return q.applyRangeFunction("increase", window)
+}
+
+// Sum applies sum() to the current expression, by the given labels if any
+func (q Query) Sum(by ...string) Query {
+	return q.Aggregate("sum", by...)
+}
+
+// Aggregate applies an aggregation operator like "sum", "avg", "max" or "count" to the current expression, by the
+// given labels if any
+func (q Query) Aggregate(operator string, by ...string) Query {
+	if !labelNameRegex.MatchString(operator) {
+		logger.Panicf("invalid aggregation operator: '%s'", operator)
+	}
+	for _, label := range by {
+		checkLabelName(label)
+	}
+	var builder strings.Builder
+	builder.WriteString(operator)
+	if len(by) > 0 {
+		builder.WriteString(" by (" + strings.Join(by, ", ") + ")")
+	}
+	builder.WriteString(" (" + q.String() + ")")
+	q.expr = builder.String()
+	return q
+}
+
+// String returns the PromQL expression
+func (q Query) String() string {
+	if q.expr != "" {
+		return q.expr
+	}
+	return q.selector()
+}
+
+func (q Query) selector() string {
+	if len(q.matchers) == 0 {
+		return q.metric
+	}
+	return q.metric + "{" + strings.Join(q.matchers, ", ") + "}"
+}
+
+func (q Query) addMatcher(label string, operator string, value string) Query {
+	if q.expr != "" {
+		logger.Panicf("label matcher of '%s' added after functions: %s", label, q.expr)
+	}
+	checkLabelName(label)
+	// PromQL strings follow the same escaping rules as Go
+	matcher := label + operator + strconv.Quote(value)
+	q.matchers = append(q.matchers[:len(q.matchers):len(q.matchers)], matcher)
+	return q
+}
+
+func (q Query) applyRangeFunction(function string, window time.Duration) Query {
+	if q.expr != "" {
+		logger.Panicf("%s() can only be applied to selectors: %s", function, q.expr)
+	}
+	if window <= 0 {
+		logger.Panicf("invalid range of %s(): %s", function, window)
+	}
+	q.expr = function + "(" + q.selector() + "[" + model.Duration(window).String() + "])"
+	return q
+}
+
+func checkLabelName(label string) {
+	if !labelNameRegex.MatchString(label) {
+		logger.Panicf("invalid label name: '%s'", label)
+	}
+}
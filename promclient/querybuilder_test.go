@@ -0,0 +1,27 @@
+package promclient
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	base := NewQuery("http_requests_total").Match("job", "api")
+	assert.Equal(t, `http_requests_total{job="api"}`, base.String())
+
+	query := base.MatchRe("instance", regexp.MustCompile(`web-\d+`)).Rate(5 * time.Minute)
+	assert.Equal(t, `rate(http_requests_total{job="api", instance=~"web-\\d+"}[5m])`, query.String())
+	assert.Equal(t, `sum by (code, method) (rate(http_requests_total{job="api", instance=~"web-\\d+"}[5m]))`, query.Sum("code", "method").String())
+	assert.Equal(t, `max (increase(http_requests_total{job="api", path!="/a\"} or vector(1) # \n"}[1h30m]))`,
+		base.NotMatch("path", "/a\"} or vector(1) # \n").Increase(90*time.Minute).Aggregate("max").String())
+	assert.Equal(t, `{__name__!~"go_.*"}`, NewQuery("").NotMatchRe("__name__", regexp.MustCompile("go_.*")).String())
+	assert.Equal(t, `http_requests_total{job="api"}`, base.String(), "base query should not be changed")
+
+	assert.Panics(t, func() { NewQuery("up{job=\"x\"}") })
+	assert.Panics(t, func() { base.Match("job\"", "api") })
+	assert.Panics(t, func() { query.Match("code", "200") })
+	assert.Panics(t, func() { query.Rate(time.Minute) })
+}
@@ -0,0 +1,153 @@
+package promclient
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxQueryPoints is the maximum number of points per series accepted by Prometheus in ranged queries
+const MaxQueryPoints = 11000
+
+// niceSteps are the steps chosen by AutoStep, longer steps are multiples of a day
+var niceSteps = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	15 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// TimeRange is a time range for ranged queries, inclusive on both ends like Prometheus
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the time range
+func (r TimeRange) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Points returns the number of points per series for a ranged query with the given step
+func (r TimeRange) Points(step time.Duration) int {
+	if step <= 0 || r.End.Before(r.Start) {
+		return 0
+	}
+	return int(r.Duration()/step) + 1
+}
+
+// String returns the range in RFC3339 format
+func (r TimeRange) String() string {
+	return r.Start.Format(time.RFC3339) + " - " + r.End.Format(time.RFC3339)
+}
+
+// LastHoursAligned returns the range of the last N hours until now, with both ends aligned to the step
+//
+// Aligned ranges give the same data points on repeated queries, e.g. from scripts running periodically.
+func LastHoursAligned(now time.Time, hours int, step time.Duration) TimeRange {
+	end := now.Truncate(step)
+	return TimeRange{
+		Start: end.Add(-time.Duration(hours) * time.Hour).Truncate(step),
+		End:   end,
+	}
+}
+
+// BusinessDayRanges returns the business hours of each weekday (Monday to Friday) between from and to
+//
+// Business hours are from openHour to closeHour in the given location, e.g. 8 to 17 in Europe/Helsinki. The first and
+// last ranges are clipped by from and to.
+func BusinessDayRanges(from time.Time, to time.Time, loc *time.Location, openHour int, closeHour int) []TimeRange {
+	ranges := make([]TimeRange, 0, 10)
+
+	localFrom := from.In(loc)
+	day := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day(), 0, 0, 0, 0, loc)
+	for !day.After(to) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			openTime := time.Date(day.Year(), day.Month(), day.Day(), openHour, 0, 0, 0, loc)
+			closeTime := time.Date(day.Year(), day.Month(), day.Day(), closeHour, 0, 0, 0, loc)
+			if openTime.Before(from) {
+				openTime = from.In(loc)
+			}
+			if closeTime.After(to) {
+				closeTime = to.In(loc)
+			}
+			if openTime.Before(closeTime) {
+				ranges = append(ranges, TimeRange{openTime, closeTime})
+			}
+		}
+		day = day.AddDate(0, 0, 1) // not Add(24h) because of DST
+	}
+	return ranges
+}
+
+// AutoStep selects a step for the time range to get close to but not more than the target number of points
+//
+// The step is rounded up to a "nice" duration like 15s, 5m or 1h, and it never results in more than MaxQueryPoints.
+func AutoStep(r TimeRange, targetPoints int) time.Duration {
+	if targetPoints <= 0 || targetPoints > MaxQueryPoints {
+		targetPoints = MaxQueryPoints
+	}
+	if r.Duration() <= 0 {
+		return niceSteps[0]
+	}
+
+	minStep := r.Duration() / time.Duration(targetPoints)
+	for _, step := range niceSteps {
+		if step >= minStep && r.Points(step) <= targetPoints {
+			return step
+		}
+	}
+
+	day := 24 * time.Hour
+	step := (minStep + day - 1) / day * day
+	for r.Points(step) > targetPoints {
+		step += day
+	}
+	return step
+}
+
+// QueryRangedAuto queries Prometheus for a time range like QueryRanged, with the step selected by AutoStep
+//
+// The start of range is aligned to the step, see AlignAutoStep.
+func QueryRangedAuto(baseURL string, timeout time.Duration, expression string, r TimeRange, targetPoints int, outMatrix interface{}) error {
+	aligned, step := AlignAutoStep(r, targetPoints)
+	return QueryRanged(baseURL, timeout, expression, aligned.Start, aligned.End, int(step/time.Second), outMatrix)
+}
+
+// AlignAutoStep selects a step like AutoStep and truncates the start of range to it
+//
+// The step is selected for the truncated range, which is up to one step longer, so that it still doesn't result in
+// more than the target number of points.
+func AlignAutoStep(r TimeRange, targetPoints int) (TimeRange, time.Duration) {
+	step := AutoStep(r, targetPoints)
+	for {
+		aligned := TimeRange{r.Start.Truncate(step), r.End}
+		alignedStep := AutoStep(aligned, targetPoints)
+		if alignedStep <= step {
+			return aligned, step
+		}
+		step = alignedStep
+	}
+}
+
+func checkQueryPoints(start time.Time, end time.Time, step int) error {
+	if step <= 0 {
+		return fmt.Errorf("invalid step: %d", step)
+	}
+	if points := (TimeRange{start, end}).Points(time.Duration(step) * time.Second); points > MaxQueryPoints {
+		return fmt.Errorf("too many points per series: %d, exceeding %d", points, MaxQueryPoints)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package promclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastHoursAligned(t *testing.T) {
+	now := time.Date(2021, 6, 1, 10, 7, 31, 0, time.UTC)
+	r := LastHoursAligned(now, 3, 5*time.Minute)
+	assert.Equal(t, time.Date(2021, 6, 1, 10, 5, 0, 0, time.UTC), r.End)
+	assert.Equal(t, time.Date(2021, 6, 1, 7, 5, 0, 0, time.UTC), r.Start)
+	assert.Equal(t, 37, r.Points(5*time.Minute))
+}
+
+func TestBusinessDayRanges(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Helsinki")
+	assert.NoError(t, err)
+
+	// Friday 12:00 UTC to next Tuesday 08:00 UTC
+	ranges := BusinessDayRanges(time.Date(2021, 6, 4, 12, 0, 0, 0, time.UTC), time.Date(2021, 6, 8, 8, 0, 0, 0, time.UTC), loc, 8, 17)
+	assert.Equal(t, 3, len(ranges))
+	assert.Equal(t, "2021-06-04T15:00:00+03:00 - 2021-06-04T17:00:00+03:00", ranges[0].String())
+	assert.Equal(t, "2021-06-07T08:00:00+03:00 - 2021-06-07T17:00:00+03:00", ranges[1].String())
+	assert.Equal(t, "2021-06-08T08:00:00+03:00 - 2021-06-08T11:00:00+03:00", ranges[2].String())
+}
+
+func TestAutoStep(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 15*time.Second, AutoStep(TimeRange{start, start.Add(time.Hour)}, 300))
+	assert.Equal(t, 5*time.Minute, AutoStep(TimeRange{start, start.Add(24 * time.Hour)}, 300))
+	assert.Equal(t, 48*time.Hour, AutoStep(TimeRange{start, start.AddDate(1, 0, 0)}, 200))
+	assert.Equal(t, time.Hour, AutoStep(TimeRange{start, start.AddDate(1, 0, 0)}, 0), "should be limited by MaxQueryPoints")
+
+	assert.ErrorContains(t, QueryRanged("http://localhost:1", time.Second, "up", start, start.AddDate(1, 0, 0), 1, nil), "too many points")
+}
+
+func TestAlignAutoStep(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 500*int(time.Millisecond), time.UTC)
+	r := TimeRange{start, start.Add((MaxQueryPoints-1)*time.Second + 500*time.Millisecond)}
+	assert.Equal(t, time.Second, AutoStep(r, 0))
+	assert.Equal(t, MaxQueryPoints, r.Points(time.Second))
+	assert.Equal(t, MaxQueryPoints+1, TimeRange{start.Truncate(time.Second), r.End}.Points(time.Second), "truncated start should add one point")
+
+	aligned, step := AlignAutoStep(r, 0)
+	assert.Equal(t, 5*time.Second, step)
+	assert.Equal(t, start.Truncate(5*time.Second), aligned.Start)
+	assert.Equal(t, r.End, aligned.End)
+	assert.NoError(t, checkQueryPoints(aligned.Start, aligned.End, int(step/time.Second)))
+
+	start = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	aligned, step = AlignAutoStep(TimeRange{start, start.Add((MaxQueryPoints - 1) * time.Second)}, 0)
+	assert.Equal(t, time.Second, step, "aligned range at exactly MaxQueryPoints should keep the step")
+	assert.Equal(t, MaxQueryPoints, aligned.Points(step))
+}
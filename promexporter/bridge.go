@@ -0,0 +1,331 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promexporter
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/relex/gotils/channels"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// BridgeProtocol is the protocol used by MetricsBridge to export metrics
+type BridgeProtocol string
+
+// Bridge protocols
+const (
+	GraphiteProtocol  BridgeProtocol = "graphite"  // Graphite plaintext over TCP, with tags in Graphite 1.1 format
+	StatsDProtocol    BridgeProtocol = "statsd"    // StatsD gauges over UDP, with labels in metric names
+	DogStatsDProtocol BridgeProtocol = "dogstatsd" // Datadog StatsD gauges over UDP, with tags
+)
+
+const (
+	bridgeDialTimeout  = 10 * time.Second
+	bridgeWriteTimeout = 30 * time.Second
+	maxStatsDPacket    = 1432 // to fit in the Ethernet MTU, see StatsD docs
+)
+
+var invalidBridgeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.+\-]`)
+
+// NameRule replaces matches of Pattern in Prometheus metric names by Replacement, as in regexp.ReplaceAllString
+type NameRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// BridgeOptions defines the destination and conversion of metrics exported by MetricsBridge
+type BridgeOptions struct {
+	Protocol     BridgeProtocol
+	Address      string              // host:port of Graphite carbon or StatsD server
+	Gatherer     prometheus.Gatherer // source of metrics, default to prometheus.DefaultGatherer
+	Prefix       string              // prefix of exported names after mangling, e.g. "myapp."
+	NameRules    []NameRule          // rules to mangle metric names, applied in order
+	LabelTags    map[string]string   // label names to tag names, or to empty string to drop labels
+	LabelsInPath bool                // for Graphite: append labels to metric paths instead of tags, for servers before 1.1
+}
+
+// MetricsBridge exports metrics from a Prometheus Gatherer to Graphite or StatsD, for legacy monitoring systems
+//
+// Summaries and histograms are exported as series of "_sum", "_count" and quantiles or buckets, where quantiles and
+// bucket bounds are tags of "quantile" and "le". Since Prometheus counters are cumulative, all values are exported as
+// gauges to StatsD.
+type MetricsBridge struct {
+	options BridgeOptions
+	logger  logger.Logger
+
+	exportsTotal promext.RWCounter
+	errorsTotal  promext.RWCounter
+	lastSuccess  promext.RWGauge
+}
+
+// NewMetricsBridge creates a MetricsBridge
+//
+// Metrics are created from the given creator with the labels "protocol" and "address"
+func NewMetricsBridge(options BridgeOptions, creator promreg.MetricCreator) *MetricsBridge {
+	switch options.Protocol {
+	case GraphiteProtocol, StatsDProtocol, DogStatsDProtocol:
+	default:
+		logger.Panicf("unsupported bridge protocol: '%s'", options.Protocol)
+	}
+	if options.Gatherer == nil {
+		options.Gatherer = prometheus.DefaultGatherer
+	}
+
+	metricCreator := creator.AddOrGetPrefix("bridge_", []string{"protocol", "address"}, []string{string(options.Protocol), options.Address})
+	return &MetricsBridge{
+		options: options,
+		logger:  logger.WithField("component", "MetricsBridge").WithField("address", options.Address),
+
+		exportsTotal: metricCreator.AddOrGetCounter("exports_total", "Numbers of metric exports to bridged monitoring systems", nil, nil),
+		errorsTotal:  metricCreator.AddOrGetCounter("errors_total", "Numbers of failures to export metrics to bridged monitoring systems", nil, nil),
+		lastSuccess:  metricCreator.AddOrGetGauge("last_success", "Whether the last export of metrics succeeded (1) or not (0)", nil, nil),
+	}
+}
+
+// Export gathers and sends all metrics once
+func (b *MetricsBridge) Export() error {
+	lines, err := b.gatherLines(time.Now())
+	if err == nil {
+		err = b.send(lines)
+	}
+	if err != nil {
+		b.errorsTotal.Inc()
+		b.lastSuccess.Set(0)
+		return err
+	}
+	b.exportsTotal.Inc()
+	b.lastSuccess.Set(1)
+	return nil
+}
+
+// RunPeriodically exports metrics on the given interval until stopped
+//
+// The first export is made immediately. Errors are logged and retried next time.
+// Returns an Awaitable to be signaled after the loop ends.
+func (b *MetricsBridge) RunPeriodically(interval time.Duration, stop channels.Awaitable) channels.Awaitable {
+	ended := channels.NewSignalAwaitable()
+	go func() {
+		defer ended.Signal()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := b.Export(); err != nil {
+				b.logger.Error(err)
+			}
+			if stop.WaitTimer(ticker.C) {
+				return
+			}
+		}
+	}()
+	return ended
+}
+
+func (b *MetricsBridge) gatherLines(now time.Time) ([]string, error) {
+	families, err := b.options.Gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	var lines []string
+	for _, family := range families {
+		for _, m := range family.Metric {
+			forEachBridgeSample(family.GetName(), m, func(name string, labels map[string]string, value float64) {
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					return // not supported by Graphite or StatsD
+				}
+				lines = append(lines, b.formatLine(name, labels, value, now))
+			})
+		}
+	}
+	return lines, nil
+}
+
+// formatLine formats a sample in the protocol, after name mangling and label mapping
+func (b *MetricsBridge) formatLine(name string, labels map[string]string, value float64, now time.Time) string {
+	for _, rule := range b.options.NameRules {
+		name = rule.Pattern.ReplaceAllString(name, rule.Replacement)
+	}
+	path := b.options.Prefix + sanitizeBridgeName(name)
+	tags := b.mapTags(labels)
+	valueStr := strconv.FormatFloat(value, 'f', -1, 64)
+
+	var builder strings.Builder
+	builder.WriteString(path)
+	switch b.options.Protocol {
+	case DogStatsDProtocol:
+		builder.WriteString(":" + valueStr + "|g")
+		for i, tag := range tags {
+			if i == 0 {
+				builder.WriteString("|#")
+			} else {
+				builder.WriteString(",")
+			}
+			builder.WriteString(tag[0] + ":" + tag[1])
+		}
+	case StatsDProtocol:
+		writeTagsInPath(&builder, tags)
+		builder.WriteString(":" + valueStr + "|g")
+	default:
+		if b.options.LabelsInPath {
+			writeTagsInPath(&builder, tags)
+		} else {
+			for _, tag := range tags {
+				builder.WriteString(";" + tag[0] + "=" + tag[1])
+			}
+		}
+		builder.WriteString(" " + valueStr + " " + strconv.FormatInt(now.Unix(), 10))
+	}
+	return builder.String()
+}
+
+// writeTagsInPath appends tags as path components, e.g. "name.tag1.value1.tag2.value2"
+func writeTagsInPath(builder *strings.Builder, tags [][2]string) {
+	for _, tag := range tags {
+		builder.WriteString("." + tag[0] + "." + strings.ReplaceAll(tag[1], ".", "_"))
+	}
+}
+
+// mapTags converts labels to tag name-value pairs sorted by tag names, skipping empty values and dropped labels
+func (b *MetricsBridge) mapTags(labels map[string]string) [][2]string {
+	tags := make([][2]string, 0, len(labels))
+	for label, value := range labels {
+		tagName := label
+		if mapped, found := b.options.LabelTags[label]; found {
+			tagName = mapped
+		}
+		if tagName == "" || value == "" {
+			continue
+		}
+		tags = append(tags, [2]string{sanitizeBridgeName(tagName), sanitizeBridgeName(value)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i][0] < tags[j][0] })
+	return tags
+}
+
+func (b *MetricsBridge) send(lines []string) error {
+	if b.options.Protocol == GraphiteProtocol {
+		return b.sendTCP(lines)
+	}
+	return b.sendUDP(lines)
+}
+
+func (b *MetricsBridge) sendTCP(lines []string) error {
+	conn, err := net.DialTimeout("tcp", b.options.Address, bridgeDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to '%s': %w", b.options.Address, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(bridgeWriteTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	var content strings.Builder
+	for _, line := range lines {
+		content.WriteString(line + "\n")
+	}
+	if _, err := conn.Write([]byte(content.String())); err != nil {
+		return fmt.Errorf("failed to send metrics to '%s': %w", b.options.Address, err)
+	}
+	return nil
+}
+
+// sendUDP sends lines in packets up to maxStatsDPacket, or one line per packet if longer
+func (b *MetricsBridge) sendUDP(lines []string) error {
+	conn, err := net.DialTimeout("udp", b.options.Address, bridgeDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to '%s': %w", b.options.Address, err)
+	}
+	defer conn.Close()
+
+	var packet []byte
+	flush := func() error {
+		if len(packet) == 0 {
+			return nil
+		}
+		if _, err := conn.Write(packet); err != nil {
+			return fmt.Errorf("failed to send metrics to '%s': %w", b.options.Address, err)
+		}
+		packet = packet[:0]
+		return nil
+	}
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > maxStatsDPacket {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	return flush()
+}
+
+// forEachBridgeSample calls fn for each value of the metric, with summaries and histograms flattened
+func forEachBridgeSample(name string, m *dto.Metric, fn func(name string, labels map[string]string, value float64)) {
+	labels := make(map[string]string, len(m.Label))
+	for _, pair := range m.Label {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	withLabel := func(name, value string) map[string]string {
+		newLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			newLabels[k] = v
+		}
+		newLabels[name] = value
+		return newLabels
+	}
+
+	switch {
+	case m.Counter != nil:
+		fn(name, labels, m.Counter.GetValue())
+	case m.Gauge != nil:
+		fn(name, labels, m.Gauge.GetValue())
+	case m.Untyped != nil:
+		fn(name, labels, m.Untyped.GetValue())
+	case m.Summary != nil:
+		for _, q := range m.Summary.Quantile {
+			fn(name, withLabel("quantile", strconv.FormatFloat(q.GetQuantile(), 'f', -1, 64)), q.GetValue())
+		}
+		fn(name+"_sum", labels, m.Summary.GetSampleSum())
+		fn(name+"_count", labels, float64(m.Summary.GetSampleCount()))
+	case m.Histogram != nil:
+		for _, bucket := range m.Histogram.Bucket {
+			fn(name+"_bucket", withLabel("le", strconv.FormatFloat(bucket.GetUpperBound(), 'f', -1, 64)), float64(bucket.GetCumulativeCount()))
+		}
+		if n := len(m.Histogram.Bucket); n == 0 || !math.IsInf(m.Histogram.Bucket[n-1].GetUpperBound(), +1) {
+			fn(name+"_bucket", withLabel("le", "+Inf"), float64(m.Histogram.GetSampleCount()))
+		}
+		fn(name+"_sum", labels, m.Histogram.GetSampleSum())
+		fn(name+"_count", labels, float64(m.Histogram.GetSampleCount()))
+	}
+}
+
+// sanitizeBridgeName replaces characters reserved by Graphite or StatsD in names and tags
+func sanitizeBridgeName(name string) string {
+	return invalidBridgeNameChars.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promexporter
+
+import (
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBridgeTestRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total"}, []string{"method", "path"})
+	requests.WithLabelValues("GET", "/api/v1").Add(3)
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "http_latency_seconds", Buckets: []float64{0.5}})
+	latency.Observe(0.25)
+	registry.MustRegister(requests, latency)
+	return registry
+}
+
+func TestMetricsBridgeFormats(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	factory := promreg.NewMetricFactory("test_", nil, nil)
+	gatherLines := func(options BridgeOptions) []string {
+		options.Gatherer = newBridgeTestRegistry()
+		lines, err := NewMetricsBridge(options, factory).gatherLines(now)
+		assert.NoError(t, err)
+		return lines
+	}
+
+	assert.Equal(t, []string{
+		"app.http.latency.seconds.bucket;le=0.5 1 1600000000",
+		"app.http.latency.seconds.bucket;le=+Inf 1 1600000000",
+		"app.http.latency.seconds.sum 0.25 1600000000",
+		"app.http.latency.seconds.count 1 1600000000",
+		"app.http.requests;method=GET 3 1600000000",
+	}, gatherLines(BridgeOptions{
+		Protocol:  GraphiteProtocol,
+		Prefix:    "app.",
+		NameRules: []NameRule{{regexp.MustCompile(`_total$`), ""}, {regexp.MustCompile(`_`), "."}},
+		LabelTags: map[string]string{"path": ""},
+	}))
+
+	assert.Equal(t, []string{
+		"http_latency_seconds_bucket.le.0_5 1 1600000000",
+		"http_latency_seconds_bucket.le.+Inf 1 1600000000",
+		"http_latency_seconds_sum 0.25 1600000000",
+		"http_latency_seconds_count 1 1600000000",
+		"http_requests_total.method.GET.path._api_v1 3 1600000000",
+	}, gatherLines(BridgeOptions{Protocol: GraphiteProtocol, LabelsInPath: true}))
+
+	assert.Equal(t, []string{
+		"http_latency_seconds_bucket.le.0_5:1|g",
+		"http_latency_seconds_bucket.le.+Inf:1|g",
+		"http_latency_seconds_sum:0.25|g",
+		"http_latency_seconds_count:1|g",
+		"http_requests_total.verb.GET:3|g",
+	}, gatherLines(BridgeOptions{Protocol: StatsDProtocol, LabelTags: map[string]string{"method": "verb", "path": ""}}))
+
+	assert.Equal(t, []string{
+		"http_latency_seconds_bucket:1|g|#le:0.5",
+		"http_latency_seconds_bucket:1|g|#le:+Inf",
+		"http_latency_seconds_sum:0.25|g",
+		"http_latency_seconds_count:1|g",
+		"http_requests_total:3|g|#method:GET,path:_api_v1",
+	}, gatherLines(BridgeOptions{Protocol: DogStatsDProtocol}))
+}
+
+func TestMetricsBridgeExportGraphite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, aerr := listener.Accept()
+		if aerr != nil {
+			received <- aerr.Error()
+			return
+		}
+		defer conn.Close()
+		content, _ := io.ReadAll(conn)
+		received <- string(content)
+	}()
+
+	bridge := NewMetricsBridge(BridgeOptions{
+		Protocol: GraphiteProtocol,
+		Address:  listener.Addr().String(),
+		Gatherer: newBridgeTestRegistry(),
+	}, promreg.NewMetricFactory("test_", nil, nil))
+	assert.NoError(t, bridge.Export())
+	content := <-received
+	assert.Equal(t, 5, strings.Count(content, "\n"))
+	assert.Contains(t, content, "http_requests_total;method=GET;path=_api_v1 3 ")
+}
+
+func TestMetricsBridgeExportStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	bridge := NewMetricsBridge(BridgeOptions{
+		Protocol: DogStatsDProtocol,
+		Address:  conn.LocalAddr().String(),
+		Gatherer: newBridgeTestRegistry(),
+	}, promreg.NewMetricFactory("test_", nil, nil))
+	assert.NoError(t, bridge.Export())
+
+	buf := make([]byte, maxStatsDPacket)
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, rerr := conn.ReadFrom(buf)
+	assert.NoError(t, rerr)
+	assert.Equal(t, "http_latency_seconds_bucket:1|g|#le:0.5\n"+
+		"http_latency_seconds_bucket:1|g|#le:+Inf\n"+
+		"http_latency_seconds_sum:0.25|g\n"+
+		"http_latency_seconds_count:1|g\n"+
+		"http_requests_total:3|g|#method:GET,path:_api_v1", string(buf[:n]))
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promexporter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/relex/gotils/channels"
+	"github.com/relex/gotils/fsutil"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// FileSDWriter writes target groups to a Prometheus file_sd file
+//
+// The format is decided by file extension: ".json" for JSON, ".yml" or ".yaml" for YAML. Files are replaced
+// atomically and only when the content changes, so that Prometheus doesn't reload unchanged files.
+type FileSDWriter[L comparable] struct {
+	path        string
+	yamlFormat  bool
+	logger      logger.Logger
+	mutex       sync.Mutex
+	lastContent []byte
+
+	writesTotal        promext.RWCounter
+	errorsTotal        promext.RWCounter
+	lastSuccess        promext.RWGauge
+	lastWriteTimestamp promext.RWGauge
+}
+
+// NewFileSDWriter creates a FileSDWriter for the given output path
+//
+// Metrics are created from the given creator with the label "path"
+func NewFileSDWriter[L comparable](path string, creator promreg.MetricCreator) *FileSDWriter[L] {
+	var yamlFormat bool
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		yamlFormat = false
+	case ".yml", ".yaml":
+		yamlFormat = true
+	default:
+		logger.Panicf("unsupported file_sd extension in '%s': must be .json, .yml or .yaml", path)
+	}
+
+	metricCreator := creator.AddOrGetPrefix("filesd_", []string{"path"}, []string{path})
+	return &FileSDWriter[L]{
+		path:       path,
+		yamlFormat: yamlFormat,
+		logger:     logger.WithField("component", "FileSDWriter").WithField("path", path),
+
+		writesTotal:        metricCreator.AddOrGetCounter("writes_total", "Numbers of file_sd files written with changes", nil, nil),
+		errorsTotal:        metricCreator.AddOrGetCounter("errors_total", "Numbers of failures to write file_sd files", nil, nil),
+		lastSuccess:        metricCreator.AddOrGetGauge("last_success", "Whether the last attempt to update file_sd file succeeded (1) or not (0)", nil, nil),
+		lastWriteTimestamp: metricCreator.AddOrGetGauge("last_write_timestamp_seconds", "Unix time when the file_sd file was last written", nil, nil),
+	}
+}
+
+// Write validates, serializes and writes the target groups if they're different from the current file content
+//
+// Invalid groups are never written, see ValidateTargetGroups. Returns true if the file has been written
+func (w *FileSDWriter[L]) Write(groups []TargetGroup[L]) (bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	written, err := w.write(groups)
+	if err != nil {
+		w.errorsTotal.Inc()
+		w.lastSuccess.Set(0)
+		return false, err
+	}
+	w.lastSuccess.Set(1)
+	return written, nil
+}
+
+// RunPeriodically refreshes the file from getGroups on the given interval until stopped
+//
+// The first refresh is made immediately. Errors from getGroups or writing are logged and retried next time.
+// Returns an Awaitable to be signaled after the loop ends.
+func (w *FileSDWriter[L]) RunPeriodically(getGroups func() ([]TargetGroup[L], error), interval time.Duration, stop channels.Awaitable) channels.Awaitable {
+	ended := channels.NewSignalAwaitable()
+	go func() {
+		defer ended.Signal()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			w.refresh(getGroups)
+			if stop.WaitTimer(ticker.C) {
+				return
+			}
+		}
+	}()
+	return ended
+}
+
+func (w *FileSDWriter[L]) refresh(getGroups func() ([]TargetGroup[L], error)) {
+	groups, gerr := getGroups()
+	if gerr != nil {
+		w.errorsTotal.Inc()
+		w.lastSuccess.Set(0)
+		w.logger.Errorf("failed to get target groups: %v", gerr)
+		return
+	}
+	written, werr := w.Write(groups)
+	if werr != nil {
+		w.logger.Error(werr)
+		return
+	}
+	if written {
+		w.logger.Infof("updated with %d target groups", len(groups))
+	}
+}
+
+func (w *FileSDWriter[L]) write(groups []TargetGroup[L]) (bool, error) {
+	if err := ValidateTargetGroups(groups); err != nil {
+		return false, err
+	}
+	content, err := w.serialize(groups)
+	if err != nil {
+		return false, err
+	}
+
+	if w.lastContent == nil {
+		// compare with the existing file only at the beginning, to avoid touching it after restarts
+		if existing, rerr := os.ReadFile(w.path); rerr == nil {
+			w.lastContent = existing
+		}
+	}
+	if bytes.Equal(content, w.lastContent) {
+		return false, nil
+	}
+
+	if err := fsutil.AtomicWriteFile(w.path, content, fsutil.AtomicWriteOptions{}); err != nil {
+		return false, err
+	}
+	w.lastContent = content
+	w.writesTotal.Inc()
+	w.lastWriteTimestamp.Set(time.Now().Unix())
+	return true, nil
+}
+
+func (w *FileSDWriter[L]) serialize(groups []TargetGroup[L]) ([]byte, error) {
+	if w.yamlFormat {
+		return MarshalTargetGroupsYAML(groups)
+	}
+	return MarshalTargetGroupsJSON(groups)
+}
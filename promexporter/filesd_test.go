@@ -0,0 +1,135 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promexporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/channels"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+type sdLabelSet struct {
+	Job  string `json:"job"`
+	Zone string `json:"zone"`
+}
+
+var sdGroups = []TargetGroup[sdLabelSet]{
+	{Targets: []string{"host1:9100", "host2:9100"}, Labels: sdLabelSet{"node", "a"}},
+	{Targets: []string{"host3:9100"}, Labels: sdLabelSet{"node", "b"}},
+}
+
+func TestFileSDWriterJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	factory := promreg.NewMetricFactory("test_", nil, nil)
+	writer := NewFileSDWriter[sdLabelSet](path, factory)
+
+	written, err := writer.Write(nil)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assertFileContent(t, path, "[]\n")
+
+	written, err = writer.Write(sdGroups)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assertFileContent(t, path, `[
+  {
+    "targets": [
+      "host1:9100",
+      "host2:9100"
+    ],
+    "labels": {
+      "job": "node",
+      "zone": "a"
+    }
+  },
+  {
+    "targets": [
+      "host3:9100"
+    ],
+    "labels": {
+      "job": "node",
+      "zone": "b"
+    }
+  }
+]
+`)
+
+	written, err = writer.Write(sdGroups)
+	assert.NoError(t, err)
+	assert.False(t, written, "unchanged groups should not be written again")
+
+	// a new writer should detect the existing file
+	written, err = NewFileSDWriter[sdLabelSet](path, factory).Write(sdGroups)
+	assert.NoError(t, err)
+	assert.False(t, written, "unchanged groups should not be written again by a new writer")
+
+	assert.Equal(t, 1.0, promext.SumMetricValues(factory.LookupMetricFamily("filesd_last_success")))
+	assert.Equal(t, 2.0, promext.SumMetricValues(factory.LookupMetricFamily("filesd_writes_total")))
+	assert.Equal(t, 0.0, promext.SumMetricValues(factory.LookupMetricFamily("filesd_errors_total")))
+}
+
+func TestFileSDWriterYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.yml")
+	writer := NewFileSDWriter[sdLabelSet](path, promreg.NewMetricFactory("test_", nil, nil))
+
+	written, err := writer.Write(sdGroups[1:])
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assertFileContent(t, path, `- labels:
+    job: node
+    zone: b
+  targets:
+    - host3:9100
+`)
+
+	written, err = writer.Write([]TargetGroup[sdLabelSet]{{Labels: sdLabelSet{"node", "c"}}})
+	assert.NoError(t, err, "groups without targets should be accepted")
+	assert.True(t, written)
+	assertFileContent(t, path, `- labels:
+    job: node
+    zone: c
+  targets: []
+`)
+}
+
+func TestFileSDWriterRunPeriodically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.json")
+	writer := NewFileSDWriter[sdLabelSet](path, promreg.NewMetricFactory("test_", nil, nil))
+
+	stop := channels.NewSignalAwaitable()
+	calls := 0
+	ended := writer.RunPeriodically(func() ([]TargetGroup[sdLabelSet], error) {
+		calls++
+		return sdGroups, nil
+	}, 10*time.Millisecond, stop)
+
+	time.Sleep(35 * time.Millisecond)
+	stop.Signal()
+	assert.True(t, ended.Wait(time.Second))
+	assert.GreaterOrEqual(t, calls, 2)
+	_, serr := os.Stat(path)
+	assert.NoError(t, serr)
+}
+
+func assertFileContent(t *testing.T, path string, expected string) {
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpsd serves scrape targets in the Prometheus HTTP service discovery format
+//
+// See https://prometheus.io/docs/prometheus/latest/http_sd/
+package httpsd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// TargetGroupProvider provides the current target groups for each HTTP SD request
+type TargetGroupProvider[L comparable] func(ctx context.Context) ([]promexporter.TargetGroup[L], error)
+
+type handler[L comparable] struct {
+	provider         TargetGroupProvider[L]
+	logger           logger.Logger
+	requestsByResult *promext.RWCounterVec
+	targetGroups     promext.RWGauge
+}
+
+// NewHandler creates a HTTP handler to serve target groups from the provider
+//
+// Metrics are created from the given creator with the prefix "httpsd_"
+func NewHandler[L comparable](provider TargetGroupProvider[L], creator promreg.MetricCreator) http.Handler {
+	metricCreator := creator.AddOrGetPrefix("httpsd_", nil, nil)
+	return &handler[L]{
+		provider:         provider,
+		logger:           logger.WithField("component", "HTTPSD"),
+		requestsByResult: metricCreator.AddOrGetCounterVec("requests_total", "Numbers of HTTP SD requests by result", []string{"result"}, nil),
+		targetGroups:     metricCreator.AddOrGetGauge("target_groups", "Numbers of target groups in the last successful response", nil, nil),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *handler[L]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.requestsByResult.WithLabelValues("bad_method").Inc()
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	groups, gerr := h.provider(r.Context())
+	if gerr != nil {
+		h.requestsByResult.WithLabelValues("error").Inc()
+		h.logger.Errorf("failed to get target groups for %s: %v", r.RemoteAddr, gerr)
+		// Prometheus keeps the previous targets on non-200 responses
+		http.Error(w, "failed to get target groups", http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []promexporter.TargetGroup[L]{} // HTTP SD requires an empty list instead of null
+	}
+
+	body, merr := json.Marshal(groups)
+	if merr != nil {
+		h.requestsByResult.WithLabelValues("error").Inc()
+		h.logger.Errorf("failed to marshal target groups: %v", merr)
+		http.Error(w, "failed to marshal target groups", http.StatusInternalServerError)
+		return
+	}
+
+	h.requestsByResult.WithLabelValues("success").Inc()
+	h.targetGroups.Set(int64(len(groups)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
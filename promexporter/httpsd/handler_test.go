@@ -0,0 +1,63 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpsd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/relex/gotils/promexporter"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+type labelSet struct {
+	Job string `json:"job"`
+}
+
+func TestHandler(t *testing.T) {
+	var groups []promexporter.TargetGroup[labelSet]
+	var failure error
+	factory := promreg.NewMetricFactory("test_", nil, nil)
+	h := NewHandler(func(ctx context.Context) ([]promexporter.TargetGroup[labelSet], error) {
+		return groups, failure
+	}, factory)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "[]", rec.Body.String())
+
+	groups = []promexporter.TargetGroup[labelSet]{{Targets: []string{"host1:9100"}, Labels: labelSet{"node"}}}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `[{"targets":["host1:9100"],"labels":{"job":"node"}}]`, rec.Body.String())
+
+	failure = errors.New("upstream down")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	assert.Equal(t, `test_httpsd_requests_total{result="error"} 1
+test_httpsd_requests_total{result="success"} 2
+test_httpsd_target_groups 1
+`, promext.DumpMetrics("", true, false, factory))
+}
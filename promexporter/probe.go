@@ -0,0 +1,158 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promexporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// DefaultProbeTimeout is the timeout of probes if neither ProbeOptions.Timeout nor the scrape timeout header is set
+const DefaultProbeTimeout = 10 * time.Second
+
+// ProbeFunc probes the target with the module and returns a factory containing metrics of this probe only, e.g.
+// created by promreg.NewMetricFactory on each call
+//
+// The context is canceled when the probe times out. The returned factory is still exposed on error, if not nil.
+type ProbeFunc func(ctx context.Context, target string, module string) (*promreg.MetricFactory, error)
+
+// ProbeOptions defines options of probe handler
+type ProbeOptions struct {
+	Modules       []string      // allowed modules, or empty to allow any
+	DefaultModule string        // module to use if not specified in query
+	Timeout       time.Duration // max duration of probes, default DefaultProbeTimeout
+	TimeoutOffset time.Duration // duration subtracted from the scrape timeout set by Prometheus, default 500ms
+}
+
+// NewProbeHandler creates a HTTP handler for the multi-target exporter pattern, e.g. "/probe?target=host:port&module=tcp"
+//
+// Besides metrics from the probe, "probe_success" and "probe_duration_seconds" are always exposed. Probes are limited
+// by the shorter of ProbeOptions.Timeout and the scrape timeout in header "X-Prometheus-Scrape-Timeout-Seconds",
+// and exposed as failed when they exceed it.
+func NewProbeHandler(probe ProbeFunc, options ProbeOptions) http.Handler {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultProbeTimeout
+	}
+	if options.TimeoutOffset <= 0 {
+		options.TimeoutOffset = 500 * time.Millisecond
+	}
+	return &probeHandler{
+		probe:   probe,
+		options: options,
+		logger:  logger.WithField("component", "ProbeHandler"),
+	}
+}
+
+type probeHandler struct {
+	probe   ProbeFunc
+	options ProbeOptions
+	logger  logger.Logger
+}
+
+type probeResult struct {
+	factory *promreg.MetricFactory
+	err     error
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	target := query.Get("target")
+	if target == "" {
+		http.Error(w, "missing parameter 'target'", http.StatusBadRequest)
+		return
+	}
+	module := query.Get("module")
+	if module == "" {
+		module = h.options.DefaultModule
+	}
+	if !h.isModuleAllowed(module) {
+		http.Error(w, fmt.Sprintf("unknown module '%s'", module), http.StatusBadRequest)
+		return
+	}
+
+	timeout := h.getTimeout(req)
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	plogger := h.logger.WithFields(logger.Fields{"target": target, "module": module})
+	start := time.Now()
+	resultChan := make(chan probeResult, 1)
+	go func() {
+		factory, err := h.probe(ctx, target, module)
+		resultChan <- probeResult{factory, err}
+	}()
+
+	var result probeResult
+	select {
+	case result = <-resultChan:
+		if result.err != nil {
+			plogger.Warn("probe failed: ", result.err)
+		}
+	case <-ctx.Done():
+		result.err = ctx.Err()
+		plogger.Warnf("probe timed out after %s", timeout)
+	}
+
+	successGauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_success", Help: "Whether the probe succeeded"})
+	durationGauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_duration_seconds", Help: "Duration of the probe"})
+	if result.err == nil {
+		successGauge.Set(1)
+	}
+	durationGauge.Set(time.Since(start).Seconds())
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(successGauge, durationGauge)
+	gatherers := prometheus.Gatherers{registry}
+	if result.factory != nil {
+		gatherers = append(gatherers, result.factory)
+	}
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{ErrorLog: plogger.NewStdLogger(logger.WarnLevel)}).ServeHTTP(w, req)
+}
+
+func (h *probeHandler) isModuleAllowed(module string) bool {
+	if len(h.options.Modules) == 0 {
+		return true
+	}
+	for _, m := range h.options.Modules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// getTimeout returns the timeout of probe, limited by the scrape timeout of Prometheus minus offset
+func (h *probeHandler) getTimeout(req *http.Request) time.Duration {
+	timeout := h.options.Timeout
+	header := req.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return timeout
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil {
+		h.logger.Warnf("invalid X-Prometheus-Scrape-Timeout-Seconds: '%s'", header)
+		return timeout
+	}
+	scrapeTimeout := time.Duration(seconds*float64(time.Second)) - h.options.TimeoutOffset
+	if scrapeTimeout > 0 && scrapeTimeout < timeout {
+		return scrapeTimeout
+	}
+	return timeout
+}
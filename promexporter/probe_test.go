@@ -0,0 +1,73 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promexporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeHandler(t *testing.T) {
+	handler := NewProbeHandler(func(ctx context.Context, target string, module string) (*promreg.MetricFactory, error) {
+		factory := promreg.NewMetricFactory("probe_", []string{"module"}, []string{module})
+		if target == "slow" {
+			<-ctx.Done()
+			return factory, ctx.Err()
+		}
+		factory.AddOrGetGauge("size_bytes", "Size", nil, nil).Set(int64(len(target)))
+		return factory, nil
+	}, ProbeOptions{Modules: []string{"http", "tcp"}, DefaultModule: "http"})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(query string, scrapeTimeout string) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/probe?"+query, nil)
+		if scrapeTimeout != "" {
+			req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", scrapeTimeout)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("target=example.com", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "\nprobe_size_bytes{module=\"http\"} 11\n")
+	assert.Contains(t, body, "\nprobe_success 1\n")
+	assert.Contains(t, body, "\nprobe_duration_seconds ")
+
+	start := time.Now()
+	status, body = get("target=slow&module=tcp", "0.6")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, body, "\nprobe_success 0\n")
+
+	status, body = get("module=tcp", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "missing parameter 'target'\n", body)
+
+	status, body = get("target=example.com&module=icmp", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "unknown module 'icmp'\n", body)
+}
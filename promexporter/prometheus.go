@@ -45,7 +45,14 @@ func Serve(getMetricsFn func(), port uint16, timer *Timer) error {
 
 // GetHandler returns "/metrics" handler. Use this if you want to set up more handlers
 // If the timer is nil, `getMetricsFn` will be called on each request
+// If `getMetricsFn` is nil, nothing is called and the timer is ignored
+//
+// Only one `getMetricsFn` can be set for the whole process. To update metrics from multiple components on each scrape,
+// register refresh callbacks on promreg.MetricFactory instead, see promreg.MetricCreator's AddRefreshCallback
 func GetHandler(getMetricsFn func(), timer *Timer) http.Handler {
+	if getMetricsFn == nil {
+		return promhttp.Handler()
+	}
 	if timer == nil {
 		oldGatherer := prometheus.DefaultGatherer
 		prometheus.DefaultGatherer = customGatherer{getMetricsFn: getMetricsFn, oldGatherer: oldGatherer}
@@ -79,6 +86,8 @@ func CreateTimerFromTicker(ticker *time.Ticker) Timer {
 }
 
 // CreateTimerFromCron creates a timer from a cron exptession, e.g "* * * * *"
+//
+// See the scheduler package for jobs with timeout, overlap policy, panic recovery and metrics
 func CreateTimerFromCron(cron string) Timer {
 	timer := make(Timer)
 	ctab := crontab.New()
@@ -78,3 +78,33 @@ test_gauge{brand="V",class="Car",extra="",group="Vehicle"} 17
 		assert.Equal(t, dumpResult, loggerMetricLines.String())
 	})
 }
+
+func TestDumpMetricsForTest(t *testing.T) {
+	cv := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testdump_requests_total", Help: "Requests"}, []string{"status", "host"})
+	cv.WithLabelValues("200", "host-b").Add(3)
+	cv.WithLabelValues("200", "host-a").Add(2)
+	cv.WithLabelValues("503", "host-a").Add(1)
+	cv.WithLabelValues("404", "host-a")
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testdump_queue_length", Help: "Queue length"})
+	g.Set(7)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(cv, g)
+
+	assert.Equal(t, `# HELP testdump_requests_total Requests
+# TYPE testdump_requests_total counter
+testdump_requests_total{status="200"} 5
+testdump_requests_total{status="503"} 1
+`, DumpMetricsForTest(DumpOptions{
+		Prefix:         "testdump_",
+		MatchLabels:    map[string]string{"status": "[25].."},
+		StripLabels:    []string{"host"},
+		SkipZeroValues: true,
+	}, reg))
+
+	assert.Equal(t, map[string]float64{
+		`testdump_queue_length`:                               7,
+		`testdump_requests_total{host="host-a",status="200"}`: 2,
+		`testdump_requests_total{host="host-a",status="404"}`: 0,
+		`testdump_requests_total{host="host-a",status="503"}`: 1,
+	}, DumpMetricsMapForTest(DumpOptions{MatchLabels: map[string]string{"host": "host-a|"}}, reg))
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promext
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DumpOptions defines how to filter and normalize metrics for DumpMetricsForTest
+type DumpOptions struct {
+	Prefix         string            // prefix of metric names, empty to include all metrics
+	MatchLabels    map[string]string // regular expressions to fully match label values like "=~" in PromQL, with missing labels as ""
+	StripLabels    []string          // volatile labels to remove, e.g. hostnames, merging series which become identical by sum
+	SkipComments   bool              // skip HELP and TYPE comments
+	SkipZeroValues bool              // skip samples of zero values
+}
+
+// DumpMetricsForTest dumps metrics from the given gatherer(s) into the .prom text format for comparison in tests, with
+// series filtered and normalized by the options and sorted by names and labels. Timestamps are always removed.
+//
+// If no gatherers is provided, the DefaultGatherer is used
+func DumpMetricsForTest(opts DumpOptions, gatherers ...prometheus.Gatherer) string {
+	families := gatherForTest(opts, gatherers)
+	writer := &bytes.Buffer{}
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(writer, mf); err != nil {
+			panic(fmt.Sprintf("failed to export '%s': %v", mf.GetName(), err))
+		}
+	}
+
+	lines := strings.Split(writer.String(), "\n")
+	linesFiltered := make([]string, 0, len(lines))
+	for _, ln := range lines {
+		if opts.SkipComments && strings.HasPrefix(ln, "#") {
+			continue
+		}
+		if opts.SkipZeroValues && strings.HasSuffix(ln, " 0") {
+			continue
+		}
+		linesFiltered = append(linesFiltered, ln)
+	}
+	return strings.Join(linesFiltered, "\n")
+}
+
+// DumpMetricsMapForTest dumps metrics like DumpMetricsForTest, into values by series keys, e.g.
+// `jobs_total{queue="a",status="done"}` => 2
+//
+// Summaries and histograms are flattened into series of "_sum", "_count", "_bucket" and quantiles as in text format.
+// SkipComments is implied.
+func DumpMetricsMapForTest(opts DumpOptions, gatherers ...prometheus.Gatherer) map[string]float64 {
+	opts.SkipComments = true
+	values := make(map[string]float64)
+	for _, ln := range strings.Split(DumpMetricsForTest(opts, gatherers...), "\n") {
+		if ln == "" {
+			continue
+		}
+		sep := strings.LastIndexByte(ln, ' ')
+		value, err := strconv.ParseFloat(ln[sep+1:], 64)
+		if err != nil {
+			panic(fmt.Sprintf("failed to parse dumped line '%s': %v", ln, err)) // dumped by ourselves
+		}
+		values[ln[:sep]] = value
+	}
+	return values
+}
+
+// gatherForTest gathers metric families and applies filters and normalization
+func gatherForTest(opts DumpOptions, gatherers []prometheus.Gatherer) []*dto.MetricFamily {
+	matchers := make(map[string]*regexp.Regexp, len(opts.MatchLabels))
+	for name, pattern := range opts.MatchLabels {
+		matchers[name] = regexp.MustCompile("^(?:" + pattern + ")$")
+	}
+	stripped := make(map[string]bool, len(opts.StripLabels))
+	for _, name := range opts.StripLabels {
+		stripped[name] = true
+	}
+
+	var compositeGatherer prometheus.Gatherer = prometheus.Gatherers(gatherers)
+	if len(gatherers) == 0 {
+		compositeGatherer = prometheus.DefaultGatherer
+	}
+	metricFamilies, err := compositeGatherer.Gather()
+	if err != nil {
+		panic(fmt.Sprintf("failed to gather metrics: %v", err))
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(metricFamilies))
+	for _, mf := range metricFamilies {
+		if !strings.HasPrefix(mf.GetName(), opts.Prefix) {
+			continue
+		}
+		metrics := make([]*dto.Metric, 0, len(mf.Metric))
+		for _, m := range mf.Metric {
+			if !matchLabelsForTest(m, matchers) {
+				continue
+			}
+			m.TimestampMs = nil
+			m.Label = stripLabelsForTest(m.Label, stripped)
+			metrics = append(metrics, m)
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		mf.Metric = mergeMetricsForTest(metrics)
+		result = append(result, mf)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+	return result
+}
+
+func matchLabelsForTest(m *dto.Metric, matchers map[string]*regexp.Regexp) bool {
+	for name, matcher := range matchers {
+		if !matcher.MatchString(GetLabelValue(m, name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func stripLabelsForTest(labels []*dto.LabelPair, stripped map[string]bool) []*dto.LabelPair {
+	if len(stripped) == 0 {
+		return labels
+	}
+	kept := make([]*dto.LabelPair, 0, len(labels))
+	for _, lbl := range labels {
+		if !stripped[lbl.GetName()] {
+			kept = append(kept, lbl)
+		}
+	}
+	return kept
+}
+
+// mergeMetricsForTest sorts metrics by labels and merges those with identical labels by sum
+//
+// Quantiles of summaries can't be merged and are kept from the first one.
+func mergeMetricsForTest(metrics []*dto.Metric) []*dto.Metric {
+	keys := make(map[*dto.Metric]string, len(metrics))
+	for _, m := range metrics {
+		sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+		parts := make([]string, len(m.Label))
+		for i, lbl := range m.Label {
+			parts[i] = lbl.GetName() + "=" + strconv.Quote(lbl.GetValue())
+		}
+		keys[m] = strings.Join(parts, ",")
+	}
+	sort.SliceStable(metrics, func(i, j int) bool { return keys[metrics[i]] < keys[metrics[j]] })
+
+	merged := make([]*dto.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if len(merged) > 0 {
+			if last := merged[len(merged)-1]; keys[last] == keys[m] {
+				addMetricForTest(last, m)
+				continue
+			}
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// addMetricForTest adds values of src to dst of the same type
+func addMetricForTest(dst *dto.Metric, src *dto.Metric) {
+	switch {
+	case dst.Counter != nil:
+		*dst.Counter.Value += src.Counter.GetValue()
+	case dst.Gauge != nil:
+		*dst.Gauge.Value += src.Gauge.GetValue()
+	case dst.Untyped != nil:
+		*dst.Untyped.Value += src.Untyped.GetValue()
+	case dst.Summary != nil:
+		*dst.Summary.SampleCount += src.Summary.GetSampleCount()
+		*dst.Summary.SampleSum += src.Summary.GetSampleSum()
+	case dst.Histogram != nil:
+		*dst.Histogram.SampleCount += src.Histogram.GetSampleCount()
+		*dst.Histogram.SampleSum += src.Histogram.GetSampleSum()
+		for i, bucket := range dst.Histogram.Bucket {
+			if i < len(src.Histogram.Bucket) {
+				*bucket.CumulativeCount += src.Histogram.Bucket[i].GetCumulativeCount()
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promext
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LastUpdateSuffix is the suffix of the companion gauges of metric vectors created with last-update timestamps
+const LastUpdateSuffix = "_last_update_timestamp_seconds"
+
+// NewRWCounterVecWithLastUpdate creates a RWCounterVec like NewRWCounterVec, with the collector of its companion gauges
+// "<name>_last_update_timestamp_seconds" which are set to the current time whenever the counters are updated
+//
+// Both must be registered. Companion gauges are omitted from collection until their counters are updated.
+func NewRWCounterVecWithLastUpdate(opts prometheus.CounterOpts, labelNames []string) (*RWCounterVec, prometheus.Collector) {
+	lastUpdates := newLastUpdateVec(opts.Namespace, opts.Subsystem, opts.Name, labelNames, opts.ConstLabels)
+	return newRWCounterVec(opts, labelNames, lastUpdates), &lastUpdateVec{lastUpdates}
+}
+
+// NewLazyRWCounterVecWithLastUpdate creates a LazyRWCounterVec with the collector of its companion gauges, see
+// NewRWCounterVecWithLastUpdate
+func NewLazyRWCounterVecWithLastUpdate(opts prometheus.CounterOpts, labelNames []string) (*LazyRWCounterVec, prometheus.Collector) {
+	lastUpdates := newLastUpdateVec(opts.Namespace, opts.Subsystem, opts.Name, labelNames, opts.ConstLabels)
+	return newLazyRWCounterVec(opts, labelNames, lastUpdates), &lastUpdateVec{lastUpdates}
+}
+
+// NewRWGaugeVecWithLastUpdate creates a RWGaugeVec with the collector of its companion gauges, see
+// NewRWCounterVecWithLastUpdate
+func NewRWGaugeVecWithLastUpdate(opts prometheus.GaugeOpts, labelNames []string) (*RWGaugeVec, prometheus.Collector) {
+	lastUpdates := newLastUpdateVec(opts.Namespace, opts.Subsystem, opts.Name, labelNames, opts.ConstLabels)
+	return newRWGaugeVec(opts, labelNames, lastUpdates), &lastUpdateVec{lastUpdates}
+}
+
+// NewLazyRWGaugeVecWithLastUpdate creates a LazyRWGaugeVec with the collector of its companion gauges, see
+// NewRWCounterVecWithLastUpdate
+func NewLazyRWGaugeVecWithLastUpdate(opts prometheus.GaugeOpts, labelNames []string) (*LazyRWGaugeVec, prometheus.Collector) {
+	lastUpdates := newLastUpdateVec(opts.Namespace, opts.Subsystem, opts.Name, labelNames, opts.ConstLabels)
+	return newLazyRWGaugeVec(opts, labelNames, lastUpdates), &lastUpdateVec{lastUpdates}
+}
+
+func newLastUpdateVec(namespace, subsystem, name string, labelNames []string, constLabels prometheus.Labels) *RWGaugeFVec {
+	fqName := prometheus.BuildFQName(namespace, subsystem, name)
+	return NewRWGaugeFVec(prometheus.GaugeOpts{
+		Name:        fqName + LastUpdateSuffix,
+		Help:        "Unix time of the last update of " + fqName,
+		ConstLabels: constLabels,
+	}, labelNames)
+}
+
+// lastUpdateVec collects the companion gauges of last-update timestamps, except those never updated
+type lastUpdateVec struct {
+	*RWGaugeFVec
+}
+
+func (v *lastUpdateVec) Collect(ch chan<- prometheus.Metric) {
+	collectNonZero(v.MetricVec, ch, func(m prometheus.Metric) bool {
+		return m.(RWGaugeF).Get() == 0
+	})
+}
+
+// getLastUpdateGauge returns the companion gauge for the metric of given label values, or nil if not enabled
+func getLastUpdateGauge(lastUpdates *RWGaugeFVec, lvs []string) RWGaugeF {
+	if lastUpdates == nil {
+		return nil
+	}
+	return lastUpdates.WithLabelValues(lvs...)
+}
+
+func markUpdated(lastUpdate RWGaugeF) {
+	if lastUpdate != nil {
+		lastUpdate.SetToCurrentTime()
+	}
+}
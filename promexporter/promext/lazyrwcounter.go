@@ -47,6 +47,10 @@ type LazyRWCounterVec struct {
 //
 // Unlike the normal counter-vector, all zero-valued counters are omitted from metric collection / dump
 func NewLazyRWCounterVec(opts prometheus.CounterOpts, labelNames []string) *LazyRWCounterVec {
+	return newLazyRWCounterVec(opts, labelNames, nil)
+}
+
+func newLazyRWCounterVec(opts prometheus.CounterOpts, labelNames []string, lastUpdates *RWGaugeFVec) *LazyRWCounterVec {
 	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
 	desc := prometheus.NewDesc(
 		fqName,
@@ -61,6 +65,7 @@ func NewLazyRWCounterVec(opts prometheus.CounterOpts, labelNames []string) *Lazy
 			}
 			result := &lazyRWCounter{rwCounter{
 				valBits:    0,
+				lastUpdate: getLastUpdateGauge(lastUpdates, lvs),
 				desc:       desc,
 				labelPairs: prometheus.MakeLabelPairs(desc, lvs),
 			}}
@@ -110,13 +115,20 @@ func (v *LazyRWCounterVec) CurryWith(labels prometheus.Labels) (*LazyRWCounterVe
 
 // Collect implements prometheus.Collector, putting all non-zero counters to the given output channel
 func (v *LazyRWCounterVec) Collect(ch chan<- prometheus.Metric) {
+	collectNonZero(v.MetricVec, ch, func(m prometheus.Metric) bool {
+		return m.(*lazyRWCounter).Get() == 0
+	})
+}
+
+// collectNonZero collects metrics from the vector except those checked as zero
+func collectNonZero(vec *prometheus.MetricVec, ch chan<- prometheus.Metric, isZero func(m prometheus.Metric) bool) {
 	tmp := make(chan prometheus.Metric, cap(ch))
 	go func() {
-		v.MetricVec.Collect(tmp)
+		vec.Collect(tmp)
 		close(tmp)
 	}()
 	for m := range tmp {
-		if m.(*lazyRWCounter).Get() == 0 {
+		if isZero(m) {
 			continue
 		}
 		ch <- m
@@ -0,0 +1,117 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promext
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LazyRWGauge is prometheus.Gauge with signed int64 type and getter, and only collected when not zero
+type LazyRWGauge RWGauge
+
+type lazyRWGauge struct {
+	rwGauge
+}
+
+// Collect implements prometheus.Collector, putting this gauge to the given output channel if not zero
+// The function is never called when the gauge is under a vector
+func (g *lazyRWGauge) Collect(ch chan<- prometheus.Metric) {
+	if g.Get() == 0 {
+		return
+	}
+	ch <- g
+}
+
+// LazyRWGaugeVec is a lazy version of prometheus.GaugeVec with signed int64 type and getter
+// Unlike the normal RWGaugeVec, gauges inside this vector are omitted from output collection if their values are zero
+type LazyRWGaugeVec struct {
+	RWGaugeVec
+}
+
+// NewLazyRWGaugeVec creates a lazy RWGaugeVec based on the provided GaugeOpts and label names
+// Unlike the normal gauge-vector, all zero-valued gauges are omitted from metric collection / dump
+func NewLazyRWGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *LazyRWGaugeVec {
+	return newLazyRWGaugeVec(opts, labelNames, nil)
+}
+
+func newLazyRWGaugeVec(opts prometheus.GaugeOpts, labelNames []string, lastUpdates *RWGaugeFVec) *LazyRWGaugeVec {
+	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	desc := prometheus.NewDesc(
+		fqName,
+		opts.Help,
+		labelNames,
+		opts.ConstLabels,
+	)
+	return &LazyRWGaugeVec{RWGaugeVec{
+		MetricVec: prometheus.NewMetricVec(desc, func(lvs ...string) prometheus.Metric {
+			if len(lvs) != len(labelNames) {
+				panic(makeInconsistentCardinalityError(fqName, labelNames, lvs))
+			}
+			result := &lazyRWGauge{rwGauge{
+				valBits:    0,
+				lastUpdate: getLastUpdateGauge(lastUpdates, lvs),
+				desc:       desc,
+				labelPairs: prometheus.MakeLabelPairs(desc, lvs),
+			}}
+			return result
+		}),
+		fqName: fqName,
+	}}
+}
+
+// WithLabelValues returns the Gauge for the given slice of label values or panic
+// (same order as the variable labels in Desc).
+func (v *LazyRWGaugeVec) WithLabelValues(lvs ...string) LazyRWGauge {
+	g, err := v.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(fmt.Sprintf("LazyRWGaugeVec %s{%v}: %v", v.fqName, lvs, err))
+	}
+	return g
+}
+
+// GetMetricWithLabelValues returns the Gauge for the given slice of label values
+// (same order as the variable labels in Desc).
+func (v *LazyRWGaugeVec) GetMetricWithLabelValues(lvs ...string) (LazyRWGauge, error) {
+	metric, err := v.MetricVec.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(RWGauge), nil
+}
+
+// MustCurryWith returns a vector curried with the provided labels or panic
+func (v *LazyRWGaugeVec) MustCurryWith(labels prometheus.Labels) *LazyRWGaugeVec {
+	vec, err := v.MetricVec.CurryWith(labels)
+	if err != nil {
+		panic(fmt.Sprintf("LazyRWGaugeVec %s{%v}: %v", v.fqName, labels, err))
+	}
+	return &LazyRWGaugeVec{RWGaugeVec{vec, v.fqName}}
+}
+
+// CurryWith returns a vector curried with the provided labels
+func (v *LazyRWGaugeVec) CurryWith(labels prometheus.Labels) (*LazyRWGaugeVec, error) {
+	vec, err := v.MetricVec.CurryWith(labels)
+	if vec != nil {
+		return &LazyRWGaugeVec{RWGaugeVec{vec, v.fqName}}, err
+	}
+	return nil, err
+}
+
+// Collect implements prometheus.Collector, putting all non-zero gauges to the given output channel
+func (v *LazyRWGaugeVec) Collect(ch chan<- prometheus.Metric) {
+	collectNonZero(v.MetricVec, ch, func(m prometheus.Metric) bool {
+		return m.(*lazyRWGauge).Get() == 0
+	})
+}
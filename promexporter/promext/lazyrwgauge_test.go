@@ -0,0 +1,47 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promext
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLazyRWGauge(t *testing.T) {
+	gv := NewLazyRWGaugeVec(prometheus.GaugeOpts{Name: "testrw_lazygauge"}, []string{"color"})
+	gv.WithLabelValues("red").Add(3)
+	gv.WithLabelValues("green")
+	gv.WithLabelValues("blue").Sub(1)
+	gv.WithLabelValues("yellow").Inc()
+	gv.WithLabelValues("yellow").Dec()
+	assert.EqualValues(t, 2, SumMetricValues(gv))
+
+	prometheus.MustRegister(gv)
+	assert.Equal(t, `testrw_lazygauge{color="blue"} -1
+testrw_lazygauge{color="red"} 3
+`, DumpMetrics("testrw_lazygauge", true, false))
+}
+
+func TestRWGaugeVecWithLastUpdate(t *testing.T) {
+	gv, lastUpdates := NewRWGaugeVecWithLastUpdate(prometheus.GaugeOpts{Name: "testrw_lastupdategauge"}, []string{"color"})
+	gv.MustCurryWith(prometheus.Labels{"color": "red"}).WithLabelValues().Set(3)
+	gv.WithLabelValues("green")
+
+	prometheus.MustRegister(gv, lastUpdates)
+	assert.Regexp(t, `^testrw_lastupdategauge\{color="green"\} 0
+testrw_lastupdategauge\{color="red"\} 3
+testrw_lastupdategauge_last_update_timestamp_seconds\{color="red"\} [0-9.e+]+
+$`, DumpMetrics("testrw_lastupdategauge", true, false))
+}
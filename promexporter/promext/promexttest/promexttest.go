@@ -0,0 +1,81 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package promexttest provides assertions on RW metrics and vectors from promext, for tests which check values
+// directly instead of comparing dumps
+package promexttest
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// CollectAsMap collects all series from the collector into values by series keys, e.g.
+// `jobs_total{queue="a",status="done"}` => 2
+//
+// Summaries and histograms are flattened as in promtest.Snapshot. Curried vectors give all series of the full vector.
+func CollectAsMap(collector prometheus.Collector) map[string]float64 {
+	snapshot, err := promtest.ParseSnapshot(promext.DumpMetricsFrom("", false, false, collector))
+	if err != nil {
+		panic(err) // dumped by ourselves
+	}
+	values := make(map[string]float64, len(snapshot))
+	for key, sample := range snapshot {
+		values[key] = sample.Value
+	}
+	return values
+}
+
+// AssertCounterValue asserts the sum of counters matching the labels in the vector or counter
+//
+// The labels may be a subset of series labels, or nil to match all. No matched counters are treated as zero, e.g.
+// lazy counters which are not collected.
+func AssertCounterValue(t assert.TestingT, vec prometheus.Collector, labels prometheus.Labels, expected float64) bool {
+	value, _, err := sumMatched(vec, labels)
+	if err != nil {
+		return assert.Fail(t, err.Error())
+	}
+	return assert.InDelta(t, expected, value, 1e-9, "value of counter %v", labels)
+}
+
+// AssertGaugeWithin asserts the sum of gauges matching the labels in the vector or gauge to be within [min, max]
+//
+// The labels may be a subset of series labels, or nil to match all. It fails if no gauges are matched.
+func AssertGaugeWithin(t assert.TestingT, vec prometheus.Collector, labels prometheus.Labels, min float64, max float64) bool {
+	value, count, err := sumMatched(vec, labels)
+	if err != nil {
+		return assert.Fail(t, err.Error())
+	}
+	if count == 0 {
+		return assert.Fail(t, fmt.Sprintf("no gauge matching %v", labels))
+	}
+	if value < min || value > max {
+		return assert.Fail(t, fmt.Sprintf("value of gauge %v is %v, not within [%v, %v]", labels, value, min, max))
+	}
+	return true
+}
+
+func sumMatched(c prometheus.Collector, labels prometheus.Labels) (float64, int, error) {
+	metrics, err := promext.CollectMetrics(c, labels)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	sum := 0.0
+	for _, m := range metrics {
+		sum += promext.GetExportedMetricValue(m)
+	}
+	return sum, len(metrics), nil
+}
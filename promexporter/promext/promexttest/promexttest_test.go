@@ -0,0 +1,59 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promexttest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	jobs := promext.NewRWCounterVec(prometheus.CounterOpts{Name: "testpromexttest_jobs_total"}, []string{"status", "queue"})
+	jobs.WithLabelValues("done", "a").Add(2)
+	jobs.WithLabelValues("done", "b").Add(3)
+	jobs.WithLabelValues("failed", "a").Inc()
+	pending := promext.NewLazyRWGaugeVec(prometheus.GaugeOpts{Name: "testpromexttest_pending"}, []string{"queue"})
+	pending.WithLabelValues("a").Set(5)
+
+	assert.Equal(t, map[string]float64{
+		`testpromexttest_jobs_total{queue="a",status="done"}`:   2,
+		`testpromexttest_jobs_total{queue="b",status="done"}`:   3,
+		`testpromexttest_jobs_total{queue="a",status="failed"}`: 1,
+	}, CollectAsMap(jobs))
+
+	assert.True(t, AssertCounterValue(t, jobs, prometheus.Labels{"status": "done"}, 5))
+	assert.True(t, AssertCounterValue(t, jobs.MustCurryWith(prometheus.Labels{"queue": "a"}), nil, 6))
+	assert.True(t, AssertCounterValue(t, jobs, prometheus.Labels{"status": "unknown"}, 0))
+	assert.True(t, AssertGaugeWithin(t, pending, nil, 4, 6))
+	assert.True(t, AssertGaugeWithin(t, pending.WithLabelValues("a"), nil, 5, 5))
+
+	rt := &recordingT{}
+	assert.False(t, AssertCounterValue(rt, jobs, prometheus.Labels{"queue": "b"}, 2))
+	assert.False(t, AssertGaugeWithin(rt, pending, prometheus.Labels{"queue": "a"}, 0, 1))
+	assert.False(t, AssertGaugeWithin(rt, pending, prometheus.Labels{"queue": "b"}, 0, 1))
+	assert.Len(t, rt.errors, 3)
+	assert.Contains(t, rt.errors[1], "value of gauge map[queue:a] is 5, not within [0, 1]")
+	assert.Contains(t, rt.errors[2], "no gauge matching map[queue:b]")
+}
@@ -16,11 +16,16 @@ package promext
 
 import (
 	"fmt"
+	"sort"
 	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // RWCounter is prometheus.Counter with unsigned int64 type and getter
@@ -33,10 +38,17 @@ type RWCounter interface {
 	Get() uint64
 	Inc() uint64
 	Add(uint64) uint64
+
+	// AddWithExemplar adds the value and attaches an exemplar (e.g. trace ID) to be shown in OpenMetrics exposition
+	//
+	// Panics if the exemplar labels are invalid or longer than prometheus.ExemplarMaxRunes in total
+	AddWithExemplar(val uint64, exemplar prometheus.Labels) uint64
 }
 
 type rwCounter struct {
-	valBits uint64
+	valBits    uint64
+	exemplar   atomic.Pointer[dto.Exemplar]
+	lastUpdate RWGaugeF // optional timestamp of last update
 
 	desc       *prometheus.Desc
 	labelPairs []*dto.LabelPair
@@ -51,18 +63,28 @@ func (c *rwCounter) Get() uint64 {
 }
 
 func (c *rwCounter) Inc() uint64 {
+	markUpdated(c.lastUpdate)
 	return atomic.AddUint64(&c.valBits, 1)
 }
 
 func (c *rwCounter) Add(val uint64) uint64 {
+	markUpdated(c.lastUpdate)
 	return atomic.AddUint64(&c.valBits, val)
 }
 
+func (c *rwCounter) AddWithExemplar(val uint64, exemplar prometheus.Labels) uint64 {
+	markUpdated(c.lastUpdate)
+	result := atomic.AddUint64(&c.valBits, val)
+	c.exemplar.Store(newExemplar(float64(val), exemplar))
+	return result
+}
+
 // Write implements prometheus.Metric
 func (c *rwCounter) Write(out *dto.Metric) error {
 	val := atomic.LoadUint64(&c.valBits)
 	oc := &dto.Counter{}
 	oc.Value = proto.Float64(float64(val))
+	oc.Exemplar = c.exemplar.Load()
 	out.Label = c.labelPairs
 	out.Counter = oc
 	return nil
@@ -92,6 +114,10 @@ type RWCounterVec struct {
 
 // NewRWCounterVec creates a new RWCounterVec based on the provided CounterOpts and label names
 func NewRWCounterVec(opts prometheus.CounterOpts, labelNames []string) *RWCounterVec {
+	return newRWCounterVec(opts, labelNames, nil)
+}
+
+func newRWCounterVec(opts prometheus.CounterOpts, labelNames []string, lastUpdates *RWGaugeFVec) *RWCounterVec {
 	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
 	desc := prometheus.NewDesc(
 		fqName,
@@ -106,6 +132,7 @@ func NewRWCounterVec(opts prometheus.CounterOpts, labelNames []string) *RWCounte
 			}
 			result := &rwCounter{
 				valBits:    0,
+				lastUpdate: getLastUpdateGauge(lastUpdates, lvs),
 				desc:       desc,
 				labelPairs: prometheus.MakeLabelPairs(desc, lvs),
 			}
@@ -152,3 +179,28 @@ func (v *RWCounterVec) CurryWith(labels prometheus.Labels) (*RWCounterVec, error
 	}
 	return nil, err
 }
+
+// newExemplar creates an exemplar with the current time, the same way as prometheus.Counter.AddWithExemplar
+func newExemplar(val float64, labels prometheus.Labels) *dto.Exemplar {
+	labelPairs := make([]*dto.LabelPair, 0, len(labels))
+	runes := 0
+	for name, value := range labels {
+		if !model.LabelName(name).IsValid() {
+			panic(fmt.Sprintf("exemplar label name %q is invalid", name))
+		}
+		if !utf8.ValidString(value) {
+			panic(fmt.Sprintf("exemplar label value %q is not valid UTF-8", value))
+		}
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+		labelPairs = append(labelPairs, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	sort.Slice(labelPairs, func(i, j int) bool { return labelPairs[i].GetName() < labelPairs[j].GetName() })
+	if runes > prometheus.ExemplarMaxRunes {
+		panic(fmt.Sprintf("exemplar labels have %d runes, exceeding the limit of %d", runes, prometheus.ExemplarMaxRunes))
+	}
+	return &dto.Exemplar{
+		Label:     labelPairs,
+		Value:     proto.Float64(val),
+		Timestamp: timestamppb.New(time.Now()),
+	}
+}
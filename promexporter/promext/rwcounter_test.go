@@ -17,6 +17,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,3 +38,19 @@ testrw_counter_norm{category="Book",name="Foo",part="part"} 3
 testrw_counter_norm{category="PC",name="Mac",part="Disk"} 100
 `, DumpMetrics("testrw_counter_norm", true, false))
 }
+
+func TestRWCounterExemplar(t *testing.T) {
+	cv := NewRWCounterVec(prometheus.CounterOpts{Name: "testrw_counter_exemplar"}, []string{"name"})
+	c := cv.WithLabelValues("Foo")
+	c.Add(3)
+	assert.EqualValues(t, 5, c.AddWithExemplar(2, prometheus.Labels{"trace_id": "abc123"}))
+
+	out := &dto.Metric{}
+	assert.NoError(t, c.Write(out))
+	assert.EqualValues(t, 5, out.GetCounter().GetValue())
+	assert.EqualValues(t, 2, out.GetCounter().GetExemplar().GetValue())
+	assert.Equal(t, "trace_id", out.GetCounter().GetExemplar().GetLabel()[0].GetName())
+	assert.Equal(t, "abc123", out.GetCounter().GetExemplar().GetLabel()[0].GetValue())
+
+	assert.Panics(t, func() { c.AddWithExemplar(1, prometheus.Labels{"bad-name": "x"}) })
+}
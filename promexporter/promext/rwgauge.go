@@ -41,7 +41,8 @@ type RWGauge interface {
 }
 
 type rwGauge struct {
-	valBits int64
+	valBits    int64
+	lastUpdate RWGaugeF // optional timestamp of last update
 
 	desc       *prometheus.Desc
 	labelPairs []*dto.LabelPair
@@ -56,22 +57,27 @@ func (g *rwGauge) Get() int64 {
 }
 
 func (g *rwGauge) Set(val int64) {
+	markUpdated(g.lastUpdate)
 	atomic.StoreInt64(&g.valBits, val)
 }
 
 func (g *rwGauge) Inc() int64 {
+	markUpdated(g.lastUpdate)
 	return atomic.AddInt64(&g.valBits, 1)
 }
 
 func (g *rwGauge) Dec() int64 {
+	markUpdated(g.lastUpdate)
 	return atomic.AddInt64(&g.valBits, -1)
 }
 
 func (g *rwGauge) Add(val int64) int64 {
+	markUpdated(g.lastUpdate)
 	return atomic.AddInt64(&g.valBits, val)
 }
 
 func (g *rwGauge) Sub(val int64) int64 {
+	markUpdated(g.lastUpdate)
 	return atomic.AddInt64(&g.valBits, -val)
 }
 
@@ -122,6 +128,10 @@ type RWGaugeVec struct {
 
 // NewRWGaugeVec creates a new RWGaugeVec based on the provided GaugeOpts and label names
 func NewRWGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *RWGaugeVec {
+	return newRWGaugeVec(opts, labelNames, nil)
+}
+
+func newRWGaugeVec(opts prometheus.GaugeOpts, labelNames []string, lastUpdates *RWGaugeFVec) *RWGaugeVec {
 	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
 	desc := prometheus.NewDesc(
 		fqName,
@@ -136,6 +146,7 @@ func NewRWGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *RWGaugeVec {
 			}
 			result := &rwGauge{
 				valBits:    0,
+				lastUpdate: getLastUpdateGauge(lastUpdates, lvs),
 				desc:       desc,
 				labelPairs: prometheus.MakeLabelPairs(desc, lvs),
 			}
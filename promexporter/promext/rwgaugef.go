@@ -0,0 +1,169 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promext
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// RWGaugeF is prometheus.Gauge with float64 type and getter, for ratios and timestamps
+//
+// The value is stored as bits of float64 and updated atomically, same as prometheus.Gauge.
+type RWGaugeF interface {
+	prometheus.Metric
+	prometheus.Collector
+
+	Get() float64
+	Set(float64)
+	Add(float64) float64
+	Sub(float64) float64
+	SetToCurrentTime()
+}
+
+type rwGaugeF struct {
+	valBits uint64
+
+	desc       *prometheus.Desc
+	labelPairs []*dto.LabelPair
+}
+
+func (g *rwGaugeF) Desc() *prometheus.Desc {
+	return g.desc
+}
+
+func (g *rwGaugeF) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.valBits))
+}
+
+func (g *rwGaugeF) Set(val float64) {
+	atomic.StoreUint64(&g.valBits, math.Float64bits(val))
+}
+
+func (g *rwGaugeF) Add(val float64) float64 {
+	for {
+		oldBits := atomic.LoadUint64(&g.valBits)
+		newVal := math.Float64frombits(oldBits) + val
+		if atomic.CompareAndSwapUint64(&g.valBits, oldBits, math.Float64bits(newVal)) {
+			return newVal
+		}
+	}
+}
+
+func (g *rwGaugeF) Sub(val float64) float64 {
+	return g.Add(-val)
+}
+
+// SetToCurrentTime sets the gauge to the current Unix time in seconds
+func (g *rwGaugeF) SetToCurrentTime() {
+	g.Set(float64(time.Now().UnixNano()) / 1e9)
+}
+
+// Write implements prometheus.Metric
+func (g *rwGaugeF) Write(out *dto.Metric) error {
+	og := &dto.Gauge{}
+	og.Value = proto.Float64(g.Get())
+	out.Label = g.labelPairs
+	out.Gauge = og
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+//
+// The function is never called when the gauge is under a vector
+func (g *rwGaugeF) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.Desc()
+}
+
+// Collect implements prometheus.Collector.
+//
+// The function is never called when the gauge is under a vector
+func (g *rwGaugeF) Collect(ch chan<- prometheus.Metric) {
+	ch <- g
+}
+
+// RWGaugeFVec is prometheus.GaugeVec with float64 type and getter
+type RWGaugeFVec struct {
+	*prometheus.MetricVec
+	fqName string
+}
+
+// NewRWGaugeFVec creates a new RWGaugeFVec based on the provided GaugeOpts and label names
+func NewRWGaugeFVec(opts prometheus.GaugeOpts, labelNames []string) *RWGaugeFVec {
+	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	desc := prometheus.NewDesc(
+		fqName,
+		opts.Help,
+		labelNames,
+		opts.ConstLabels,
+	)
+	return &RWGaugeFVec{
+		MetricVec: prometheus.NewMetricVec(desc, func(lvs ...string) prometheus.Metric {
+			if len(lvs) != len(labelNames) {
+				panic(makeInconsistentCardinalityError(fqName, labelNames, lvs))
+			}
+			result := &rwGaugeF{
+				valBits:    0,
+				desc:       desc,
+				labelPairs: prometheus.MakeLabelPairs(desc, lvs),
+			}
+			return result
+		}),
+		fqName: fqName,
+	}
+}
+
+// WithLabelValues returns the Gauge for the given slice of label values or panic
+// (same order as the variable labels in Desc).
+func (v *RWGaugeFVec) WithLabelValues(lvs ...string) RWGaugeF {
+	g, err := v.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(fmt.Sprintf("RWGaugeFVec %s{%v}: %v", v.fqName, lvs, err))
+	}
+	return g
+}
+
+// GetMetricWithLabelValues returns the Gauge for the given slice of label values
+// (same order as the variable labels in Desc).
+func (v *RWGaugeFVec) GetMetricWithLabelValues(lvs ...string) (RWGaugeF, error) {
+	metric, err := v.MetricVec.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		return nil, err
+	}
+	return metric.(RWGaugeF), err
+}
+
+// MustCurryWith returns a vector curried with the provided labels or panic
+func (v *RWGaugeFVec) MustCurryWith(labels prometheus.Labels) *RWGaugeFVec {
+	vec, err := v.MetricVec.CurryWith(labels)
+	if err != nil {
+		panic(fmt.Sprintf("RWGaugeFVec %s{%v}: %v", v.fqName, labels, err))
+	}
+	return &RWGaugeFVec{vec, v.fqName}
+}
+
+// CurryWith returns a vector curried with the provided labels
+func (v *RWGaugeFVec) CurryWith(labels prometheus.Labels) (*RWGaugeFVec, error) {
+	vec, err := v.MetricVec.CurryWith(labels)
+	if vec != nil {
+		return &RWGaugeFVec{vec, v.fqName}, err
+	}
+	return nil, err
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promext
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRWGaugeF(t *testing.T) {
+	gv := NewRWGaugeFVec(prometheus.GaugeOpts{Name: "testrw_gaugef"}, []string{"group", "class"})
+	gv.WithLabelValues("Vehicle", "Car").Add(0.25)
+	g := gv.MustCurryWith(map[string]string{"group": "Vehicle"})
+	assert.EqualValues(t, 0.75, g.WithLabelValues("Car").Add(0.5))
+	assert.EqualValues(t, 0.625, g.WithLabelValues("Car").Sub(0.125))
+	g.WithLabelValues("Boat").Set(1.5)
+	assert.EqualValues(t, 2.125, SumMetricValues(gv))
+
+	prometheus.MustRegister(gv)
+	assert.Equal(t, `testrw_gaugef{class="Boat",group="Vehicle"} 1.5
+testrw_gaugef{class="Car",group="Vehicle"} 0.625
+`, DumpMetrics("testrw_gaugef", true, false))
+
+	ts := gv.WithLabelValues("Time", "Now")
+	ts.SetToCurrentTime()
+	assert.InDelta(t, float64(time.Now().UnixNano())/1e9, ts.Get(), 1.0)
+}
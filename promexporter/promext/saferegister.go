@@ -0,0 +1,57 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promext
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var registrationErrors struct {
+	sync.Mutex
+	errs []error
+}
+
+// SafeRegister registers collectors to the default registerer without panicking
+//
+// It's meant for init() of library packages, where a conflict caused by a certain combination of imported packages
+// should not crash the application. Errors are collected and can be retrieved later by RegistrationErrors.
+//
+// Registering the same collector twice is not an error.
+func SafeRegister(collectors ...prometheus.Collector) {
+	for _, c := range collectors {
+		err := prometheus.DefaultRegisterer.Register(c)
+		if err == nil {
+			continue
+		}
+		var areErr prometheus.AlreadyRegisteredError
+		if errors.As(err, &areErr) && areErr.ExistingCollector == c {
+			continue
+		}
+
+		registrationErrors.Lock()
+		registrationErrors.errs = append(registrationErrors.errs, fmt.Errorf("failed to register collector %T: %w", c, err))
+		registrationErrors.Unlock()
+	}
+}
+
+// RegistrationErrors returns all errors collected by SafeRegister so far
+func RegistrationErrors() []error {
+	registrationErrors.Lock()
+	defer registrationErrors.Unlock()
+	return append([]error(nil), registrationErrors.errs...)
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promext
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeRegister(t *testing.T) {
+	numErrors := len(RegistrationErrors())
+
+	cv := NewRWCounterVec(prometheus.CounterOpts{Name: "testsafe_counter"}, []string{"name"})
+	SafeRegister(cv)
+	SafeRegister(cv)
+	assert.Len(t, RegistrationErrors(), numErrors, "registering the same collector twice should not be an error")
+
+	conflict := NewRWCounterVec(prometheus.CounterOpts{Name: "testsafe_counter"}, []string{"name"})
+	assert.NotPanics(t, func() { SafeRegister(conflict) })
+	assert.Len(t, RegistrationErrors(), numErrors+1, "conflicting collector should be recorded")
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promreg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/logger"
+)
+
+// funcMetricVec is a metric family of values computed by functions at collection time, added by AddGaugeFunc or
+// AddCounterFunc of any creators
+type funcMetricVec struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	lock      sync.RWMutex
+	metrics   map[string]*funcMetric // by label values joined by "\xff"
+}
+
+type funcMetric struct {
+	labelValues []string
+	function    func() float64
+	logger      logger.Logger
+}
+
+// AddGaugeFunc adds a gauge whose value is computed by the function at collection time, e.g. from the current size
+// of a queue, instead of being updated in background
+//
+// The function must be safe for concurrent calls and should be lightweight. A panic in the function is logged and
+// the gauge is omitted from that scrape. Adding the same gauge with the same label values twice is a fatal error.
+func (creator *metricCreatorBase) AddGaugeFunc(name string, help string, labelNames []string, labelValues []string, function func() float64) {
+	creator.addFuncMetric(prometheus.GaugeValue, name, help, labelNames, labelValues, function)
+}
+
+// AddCounterFunc adds a counter whose value is computed by the function at collection time, e.g. from the counter
+// of a third-party library, see AddGaugeFunc
+//
+// The returned values must never decrease.
+func (creator *metricCreatorBase) AddCounterFunc(name string, help string, labelNames []string, labelValues []string, function func() float64) {
+	creator.addFuncMetric(prometheus.CounterValue, name, help, labelNames, labelValues, function)
+}
+
+func (creator *metricCreatorBase) addFuncMetric(valueType prometheus.ValueType, name string, help string, labelNames []string, labelValues []string, function func() float64) {
+	kind := getFuncMetricKind(valueType)
+	if len(labelNames) != len(labelValues) {
+		creator.logger.Panicf("failed to add %s '%s': different lengths of labelNames (%s) and labelValues (%s)",
+			kind, name, strings.Join(labelNames, ","), strings.Join(labelValues, ","))
+	}
+	fullName, allLabelNames, allLabelValues := creator.concatNameAndLabels(name, labelNames, labelValues)
+
+	creator.root.mapLock.Lock()
+	defer creator.root.mapLock.Unlock()
+
+	var vec *funcMetricVec
+	if oldCollector, ok := creator.root.byName[fullName]; ok {
+		oldVec, isFuncVec := oldCollector.(*funcMetricVec)
+		if !isFuncVec || oldVec.valueType != valueType {
+			creator.logger.Panicf("failed to add %s '%s': already exists as %T", kind, fullName, oldCollector)
+		}
+		vec = oldVec
+	} else {
+		vec = &funcMetricVec{
+			desc:      prometheus.NewDesc(fullName, help, allLabelNames, nil),
+			valueType: valueType,
+			metrics:   make(map[string]*funcMetric),
+		}
+		creator.registerVec(kind+"Vec", fullName, allLabelNames, vec, nil)
+	}
+
+	key := strings.Join(allLabelValues, "\xff")
+	vec.lock.Lock()
+	defer vec.lock.Unlock()
+	if _, exists := vec.metrics[key]; exists {
+		creator.logger.Panicf("failed to add %s '%s': already exists", kind, formatMetricDesc(fullName, allLabelNames, allLabelValues))
+	}
+	vec.metrics[key] = &funcMetric{
+		labelValues: allLabelValues,
+		function:    function,
+		logger:      creator.logger.WithField("metric", fullName),
+	}
+}
+
+// Describe implements prometheus.Collector's Describe function
+func (vec *funcMetricVec) Describe(output chan<- *prometheus.Desc) {
+	output <- vec.desc
+}
+
+// Collect implements prometheus.Collector's Collect function
+func (vec *funcMetricVec) Collect(output chan<- prometheus.Metric) {
+	vec.lock.RLock()
+	metrics := make([]*funcMetric, 0, len(vec.metrics))
+	for _, m := range vec.metrics {
+		metrics = append(metrics, m)
+	}
+	vec.lock.RUnlock()
+
+	for _, m := range metrics {
+		if value, ok := m.evaluate(); ok {
+			output <- prometheus.MustNewConstMetric(vec.desc, vec.valueType, value, m.labelValues...)
+		}
+	}
+}
+
+func (m *funcMetric) evaluate() (value float64, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Errorf("panic in metric function: %s", fmt.Sprint(r))
+			ok = false
+		}
+	}()
+	return m.function(), true
+}
+
+func getFuncMetricKind(valueType prometheus.ValueType) string {
+	if valueType == prometheus.CounterValue {
+		return "CounterFunc"
+	}
+	return "GaugeFunc"
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promreg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// mergedGatherer gathers metric families from multiple gatherers, see MergeGatherers
+type mergedGatherer []prometheus.Gatherer
+
+// MergeGatherers creates a Gatherer of metric families from all the given gatherers, e.g. MetricFactory(s) from
+// different libraries
+//
+// Metric families found in more than one gatherer are reported by Gather in an error listing their names and sources,
+// along with the other families and the first of duplicates, instead of the obscure failures of prometheus.Gatherers.
+func MergeGatherers(gatherers ...prometheus.Gatherer) prometheus.Gatherer {
+	return mergedGatherer(gatherers)
+}
+
+// Gather implements prometheus.Gatherer's Gather function, collecting all metric families
+func (merged mergedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families := make(map[string]*dto.MetricFamily)
+	sources := make(map[string][]string) // family name => descriptions of gatherers
+	var errs []string
+	for i, gatherer := range merged {
+		source := describeGatherer(i, gatherer)
+		gathered, err := gatherer.Gather()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to gather from %s: %v", source, err))
+		}
+		for _, family := range gathered {
+			name := family.GetName()
+			sources[name] = append(sources[name], source)
+			if _, exists := families[name]; !exists {
+				families[name] = family
+			}
+		}
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(families))
+	for name, family := range families {
+		result = append(result, family)
+		if len(sources[name]) > 1 {
+			errs = append(errs, fmt.Sprintf("duplicate metric family '%s' from %s", name, strings.Join(sources[name], ", ")))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return result, fmt.Errorf("failed to merge gatherers: %s", strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// describeGatherer describes the gatherer by index and String() if available, e.g. `#1 myprefix_{test="foo"}`
+func describeGatherer(index int, gatherer prometheus.Gatherer) string {
+	if stringer, ok := gatherer.(fmt.Stringer); ok {
+		return fmt.Sprintf("#%d %s", index, stringer.String())
+	}
+	return fmt.Sprintf("#%d %T", index, gatherer)
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promreg
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeGatherers(t *testing.T) {
+	factory1 := NewMetricFactory("testmerge_", nil, nil)
+	factory1.AddOrGetCounter("requests_total", "Help requests", nil, nil).Inc()
+	factory1.AddOrGetGauge("shared", "Help shared", nil, nil).Set(1)
+	factory2 := NewMetricFactory("testmerge_", []string{"lib"}, []string{"other"})
+	factory2.AddOrGetGauge("shared", "Help shared", nil, nil).Set(2)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "testmerge_registry_total", Help: "Help registry"}))
+
+	families, err := MergeGatherers(factory1, registry).Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testmerge_registry_total", "testmerge_requests_total", "testmerge_shared"}, getFamilyNames(families))
+
+	families, err = MergeGatherers(factory1, factory2, registry).Gather()
+	assert.EqualError(t, err, `failed to merge gatherers: duplicate metric family 'testmerge_shared' from #0 testmerge_{}, #1 testmerge_{lib="other"}`)
+	assert.Equal(t, []string{"testmerge_registry_total", "testmerge_requests_total", "testmerge_shared"}, getFamilyNames(families))
+	assert.Equal(t, 1.0, families[2].Metric[0].GetGauge().GetValue(), "the first of duplicates should be kept")
+}
+
+func getFamilyNames(families []*dto.MetricFamily) []string {
+	names := make([]string, len(families))
+	for i, family := range families {
+		names[i] = family.GetName()
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promreg
+
+import (
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+)
+
+// EnableGaugeOwnership makes gauges settable only by their owners, for this factory and all its sub-creators
+//
+// The owner of a gauge is the creator which first creates it by AddOrGetGauge. Gauges got from other creators panic
+// on Set and must be updated by Add/Sub, because there could be multiple updaters.
+//
+// Gauges from AddOrGetGaugeVec are not covered. Ownership applies to gauges created after this call.
+func (factory *MetricFactory) EnableGaugeOwnership() {
+	factory.root.mapLock.Lock()
+	defer factory.root.mapLock.Unlock()
+
+	if factory.root.gaugeOwners == nil {
+		factory.root.gaugeOwners = make(map[string]*metricCreatorBase)
+	}
+}
+
+// applyGaugeOwnership wraps the gauge to enforce ownership if enabled
+func (creator *metricCreatorBase) applyGaugeOwnership(gauge promext.RWGauge, name string, labelNames []string, labelValues []string) promext.RWGauge {
+	fullName, allLabelNames, allLabelValues := creator.concatNameAndLabels(name, labelNames, labelValues)
+	desc := formatMetricDesc(fullName, allLabelNames, allLabelValues)
+
+	creator.root.mapLock.Lock()
+	defer creator.root.mapLock.Unlock()
+
+	if creator.root.gaugeOwners == nil {
+		return gauge
+	}
+	owner, exists := creator.root.gaugeOwners[desc]
+	if !exists {
+		creator.root.gaugeOwners[desc] = creator
+		return gauge
+	}
+	if owner == creator {
+		return gauge
+	}
+	return &sharedGauge{
+		RWGauge: gauge,
+		logger:  creator.logger.WithField("gauge", desc),
+		owner:   owner.String(),
+	}
+}
+
+// sharedGauge is a gauge got by a creator other than the owner, which cannot be Set
+type sharedGauge struct {
+	promext.RWGauge
+	logger logger.Logger
+	owner  string
+}
+
+func (g *sharedGauge) Set(val int64) {
+	g.logger.Panicf("gauge can only be Set by its owner '%s', use Add/Sub instead", g.owner)
+}
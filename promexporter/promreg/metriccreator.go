@@ -14,6 +14,7 @@
 package promreg
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/relex/gotils/promexporter/promext"
@@ -51,7 +52,8 @@ type MetricCreator interface {
 
 	// AddOrGetGauge adds or gets a gauge
 	//
-	// Gauges must be updated by Add/Sub not Set, because there could be multiple updaters
+	// Gauges must be updated by Add/Sub not Set, because there could be multiple updaters. See EnableGaugeOwnership
+	// to enforce it.
 	AddOrGetGauge(name string, help string, labelNames []string, labelValues []string) promext.RWGauge
 
 	// AddOrGetGaugeVec adds or gets a gauge-vec with leftmost label values
@@ -69,5 +71,36 @@ type MetricCreator interface {
 	// Lazy counters are not listed in output if the value is zero
 	AddOrGetLazyCounterVec(name string, help string, labelNames []string, leftmostLabelValues []string) *promext.LazyRWCounterVec
 
+	// AddOrGetLazyGauge adds or gets a lazy gauge
+	//
+	// Lazy gauges are not listed in output if the value is zero
+	AddOrGetLazyGauge(name string, help string, labelNames []string, labelValues []string) promext.LazyRWGauge
+
+	// AddOrGetLazyGaugeVec adds or gets a lazy gauge-vec with leftmost label values
+	//
+	// Lazy gauges are not listed in output if the value is zero
+	AddOrGetLazyGaugeVec(name string, help string, labelNames []string, leftmostLabelValues []string) *promext.LazyRWGaugeVec
+
+	// AddGaugeFunc adds a gauge whose value is computed by the function at collection time, e.g. from the current
+	// size of a queue, instead of being updated in background
+	AddGaugeFunc(name string, help string, labelNames []string, labelValues []string, function func() float64)
+
+	// AddCounterFunc adds a counter whose value is computed by the function at collection time
+	//
+	// The returned values must never decrease.
+	AddCounterFunc(name string, help string, labelNames []string, labelValues []string, function func() float64)
+
+	// WithLastUpdateTimestamps creates a sub-creator with the same prefix and fixed labels, whose new metrics are
+	// accompanied by gauges "<name>_last_update_timestamp_seconds" set to the current time on every update, e.g. to
+	// alert on stale pipelines
+	//
+	// The timestamps are omitted from output until the first update. Metrics already created without timestamps by
+	// other creators are returned as they are.
+	WithLastUpdateTimestamps() MetricCreator
+
+	// AddRefreshCallback registers a callback to update metrics right before they're gathered or collected from the
+	// root MetricFactory, with a time budget for all callbacks
+	AddRefreshCallback(name string, callback func(ctx context.Context))
+
 	fmt.Stringer
 }
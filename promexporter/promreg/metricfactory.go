@@ -17,6 +17,7 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/relex/gotils/logger"
 )
@@ -61,6 +62,28 @@ func (factory *MetricFactory) LookupMetricFamily(name string) prometheus.Collect
 	return mf
 }
 
+// AddGoAndProcessCollectors adds Go runtime and process collectors with this factory's prefix and fixed labels,
+// e.g. "myprefix_go_goroutines{mylabel="value"}"
+//
+// The collectors are normally only available in the global default registry, which isn't exposed by factory-based
+// metric listeners.
+func (factory *MetricFactory) AddGoAndProcessCollectors() {
+	var registerer prometheus.Registerer = &extraCollectorRegisterer{factory.root}
+	if len(factory.fixedLabelNames) > 0 {
+		registerer = prometheus.WrapRegistererWith(buildLabels(factory.fixedLabelNames, factory.fixedLabelValues), registerer)
+	}
+	if factory.fullPrefix != "" {
+		registerer = prometheus.WrapRegistererWithPrefix(factory.fullPrefix, registerer)
+	}
+
+	if err := registerer.Register(collectors.NewGoCollector()); err != nil {
+		factory.logger.Panicf("failed to register Go collector: %s", err.Error())
+	}
+	if err := registerer.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		factory.logger.Panicf("failed to register process collector: %s", err.Error())
+	}
+}
+
 // Describe implements prometheus.Collector's Describe function, storing metric descriptions in the output channel
 func (factory *MetricFactory) Describe(output chan<- *prometheus.Desc) {
 	token := factory.root.mapLock.RLock()
@@ -69,19 +92,66 @@ func (factory *MetricFactory) Describe(output chan<- *prometheus.Desc) {
 	for _, vec := range factory.root.byName {
 		vec.Describe(output)
 	}
+	for _, c := range factory.root.extras {
+		c.Describe(output)
+	}
 }
 
 // Collect implements prometheus.Collector's Collect function, storing metrics in the output channel
 func (factory *MetricFactory) Collect(output chan<- prometheus.Metric) {
+	factory.root.refresh.run()
+
 	token := factory.root.mapLock.RLock()
 	defer factory.root.mapLock.RUnlock(token)
 
 	for _, vec := range factory.root.byName {
 		vec.Collect(output)
 	}
+	for _, c := range factory.root.extras {
+		c.Collect(output)
+	}
 }
 
 // Gather implements prometheus.Gatherer's Gather function, collecting all metric families
 func (factory *MetricFactory) Gather() ([]*dto.MetricFamily, error) {
+	factory.root.refresh.run()
 	return factory.root.registry.Gather()
 }
+
+// extraCollectorRegisterer registers collectors wrapped by prometheus.WrapRegisterer* to the root, so that they're
+// included in both Gather and Collect
+type extraCollectorRegisterer struct {
+	root *metricCreatorRoot
+}
+
+func (r *extraCollectorRegisterer) Register(c prometheus.Collector) error {
+	r.root.mapLock.Lock()
+	defer r.root.mapLock.Unlock()
+
+	if err := r.root.registry.Register(c); err != nil {
+		return err
+	}
+	r.root.extras = append(r.root.extras, c)
+	return nil
+}
+
+func (r *extraCollectorRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *extraCollectorRegisterer) Unregister(c prometheus.Collector) bool {
+	r.root.mapLock.Lock()
+	defer r.root.mapLock.Unlock()
+
+	for i, existing := range r.root.extras {
+		if existing == c {
+			r.root.extras = append(r.root.extras[:i:i], r.root.extras[i+1:]...)
+			break
+		}
+	}
+	return r.root.registry.Unregister(c)
+}
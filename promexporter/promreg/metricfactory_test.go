@@ -14,8 +14,12 @@
 package promreg
 
 import (
+	"context"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/relex/gotils/promexporter/promext"
 	"github.com/stretchr/testify/assert"
 )
@@ -39,3 +43,125 @@ testmetricfactory_mycounter{name="foo",test="TestMetricFactory"} 7
 testmetricfactory_mycountervec{category="book",test="TestMetricFactory"} 5
 `, promext.DumpMetrics("", true, false, mfactory))
 }
+
+func TestMetricFactoryGoAndProcessCollectors(t *testing.T) {
+	mfactory := NewMetricFactory("testruntime_", []string{"test"}, []string{"TestMetricFactoryGoAndProcessCollectors"})
+	mfactory.AddGoAndProcessCollectors()
+
+	gathered := promext.DumpMetrics("testruntime_go_goroutines", true, false, mfactory)
+	assert.Regexp(t, `^testruntime_go_goroutines\{test="TestMetricFactoryGoAndProcessCollectors"\} \d+\n$`, gathered)
+
+	collected := promext.DumpMetricsFrom("testruntime_process_start_time_seconds", true, false, mfactory)
+	assert.Regexp(t, `^testruntime_process_start_time_seconds\{test="TestMetricFactoryGoAndProcessCollectors"\} `, collected)
+}
+
+func TestMetricFactoryGaugeOwnership(t *testing.T) {
+	mfactory := NewMetricFactory("testgaugeownership_", nil, nil)
+	mfactory.EnableGaugeOwnership()
+
+	owner := mfactory.AddOrGetPrefix("pool_", nil, nil)
+	other := mfactory.AddOrGetPrefix("pool_", nil, nil)
+	owner.AddOrGetGauge("size", "Help size", []string{"name"}, []string{"a"}).Set(10)
+	owner.AddOrGetGauge("size", "Help size", []string{"name"}, []string{"a"}).Set(20)
+
+	shared := other.AddOrGetGauge("size", "Help size", []string{"name"}, []string{"a"})
+	shared.Add(5)
+	assert.Equal(t, int64(25), shared.Get())
+	assert.Panics(t, func() { shared.Set(1) })
+
+	other.AddOrGetGauge("size", "Help size", []string{"name"}, []string{"b"}).Set(3)
+	assert.Panics(t, func() { owner.AddOrGetGauge("size", "Help size", []string{"name"}, []string{"b"}).Set(4) })
+}
+
+func TestMetricFactoryLazyGaugesAndLastUpdates(t *testing.T) {
+	mfactory := NewMetricFactory("testlastupdate_", nil, nil)
+	mfactory.AddOrGetLazyGauge("idle", "Help idle", []string{"name"}, []string{"a"}).Set(0)
+	mfactory.AddOrGetLazyGaugeVec("pending", "Help pending", []string{"name"}, nil).WithLabelValues("b").Add(2)
+
+	creator := mfactory.WithLastUpdateTimestamps().AddOrGetPrefix("pipeline_", nil, nil)
+	creator.AddOrGetCounter("processed_total", "Help processed", []string{"name"}, []string{"a"}).Inc()
+	creator.AddOrGetCounter("processed_total", "Help processed", []string{"name"}, []string{"b"})
+	creator.AddOrGetLazyGaugeVec("lag", "Help lag", []string{"name"}, nil).WithLabelValues("a")
+
+	assert.Regexp(t, `^testlastupdate_pending\{name="b"\} 2
+testlastupdate_pipeline_processed_total\{name="a"\} 1
+testlastupdate_pipeline_processed_total\{name="b"\} 0
+testlastupdate_pipeline_processed_total_last_update_timestamp_seconds\{name="a"\} [0-9.e+]+
+$`, promext.DumpMetrics("", true, false, mfactory))
+
+	timestamp := mfactory.LookupMetricFamily("pipeline_processed_total_last_update_timestamp_seconds")
+	assert.InDelta(t, float64(time.Now().Unix()), promext.SumMetricValues(timestamp), 5)
+}
+
+func TestMetricFactoryRefreshCallbacks(t *testing.T) {
+	mfactory := NewMetricFactory("testrefresh_", nil, nil)
+	mfactory.SetRefreshTimeout(100 * time.Millisecond)
+
+	creator := mfactory.AddOrGetPrefix("queue_", nil, nil)
+	length := creator.AddOrGetGauge("length", "Help length", nil, nil)
+	refreshCount := 0
+	creator.AddRefreshCallback("length", func(ctx context.Context) {
+		refreshCount++
+		length.Set(int64(refreshCount * 10))
+	})
+	mfactory.AddRefreshCallback("panic", func(ctx context.Context) {
+		panic("test")
+	})
+	slowCount := atomic.Int32{}
+	mfactory.AddRefreshCallback("slow", func(ctx context.Context) {
+		slowCount.Add(1)
+		time.Sleep(300 * time.Millisecond)
+	})
+
+	assert.Equal(t, "testrefresh_queue_length 10\n", promext.DumpMetrics("", true, false, mfactory))
+	assert.Equal(t, "testrefresh_queue_length 20\n", promext.DumpMetricsFrom("", true, false, mfactory))
+	assert.Equal(t, int32(1), slowCount.Load()) // skipped in the 2nd scrape because the 1st one is still running
+}
+
+func TestMetricFactoryFuncMetrics(t *testing.T) {
+	mfactory := NewMetricFactory("testfunc_", []string{"app"}, []string{"test"})
+	queueSize := 3
+	mfactory.AddOrGetPrefix("", []string{"prot"}, []string{"tcp"}).AddGaugeFunc("queue_size", "Help queue_size", nil, nil, func() float64 {
+		return float64(queueSize)
+	})
+	mfactory.AddOrGetPrefix("", []string{"prot"}, []string{"udp"}).AddGaugeFunc("queue_size", "Help queue_size", nil, nil, func() float64 {
+		panic("test")
+	})
+	mfactory.AddCounterFunc("files_total", "Help files_total", []string{"dir"}, []string{"in"}, func() float64 { return 42 })
+
+	assert.Equal(t, `testfunc_files_total{app="test",dir="in"} 42
+testfunc_queue_size{app="test",prot="tcp"} 3
+`, promext.DumpMetrics("", true, false, mfactory))
+	queueSize = 5
+	assert.Contains(t, promext.DumpMetrics("", true, false, mfactory), `testfunc_queue_size{app="test",prot="tcp"} 5`)
+
+	assert.Panics(t, func() {
+		mfactory.AddCounterFunc("files_total", "Help files_total", []string{"dir"}, []string{"in"}, nil)
+	})
+	assert.Panics(t, func() {
+		mfactory.AddCounterFunc("queue_size", "Help queue_size", []string{"prot"}, []string{"sctp"}, nil)
+	})
+	assert.Panics(t, func() { mfactory.AddOrGetGauge("files_total", "Help files_total", []string{"dir"}, []string{"out"}) })
+}
+
+func TestScrapeHarness(t *testing.T) {
+	mfactory := NewMetricFactory("testscrape_", []string{"test"}, []string{"TestScrapeHarness"})
+	counter := mfactory.AddOrGetCounter("jobs_total", "Help jobs_total", []string{"status"}, []string{"done"})
+	harness := StartScrapeHarness(mfactory)
+	defer harness.Close()
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+			counter.Inc()
+		}
+	}()
+	families, err := harness.ScrapeUntil(5*time.Second, func(families map[string]*dto.MetricFamily) bool {
+		return families["testscrape_jobs_total"] != nil && promext.SumExportedMetrics(families["testscrape_jobs_total"], nil) == 3
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, promext.SumExportedMetrics(families["testscrape_jobs_total"], map[string]string{"status": "done", "test": "TestScrapeHarness"}))
+
+	_, err = harness.ScrapeUntil(100*time.Millisecond, func(families map[string]*dto.MetricFamily) bool { return false })
+	assert.ErrorContains(t, err, "condition not met")
+}
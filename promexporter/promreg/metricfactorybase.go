@@ -28,6 +28,10 @@ type metricCreatorRoot struct {
 	registry *prometheus.Registry
 	mapLock  *xsync.RBMutex                  // access lock to byName
 	byName   map[string]prometheus.Collector // keep all metric families by full name, including sub-creators'
+	extras   []prometheus.Collector          // collectors not created by the factory, e.g. Go runtime collector
+
+	gaugeOwners map[string]*metricCreatorBase // owners of gauges by name and labels, nil unless EnableGaugeOwnership
+	refresh     refreshCallbacks              // callbacks to run before gathering or collecting metrics
 }
 
 func newMetricCreatorRoot() *metricCreatorRoot {
@@ -43,6 +47,7 @@ type metricCreatorBase struct {
 	fullPrefix       string
 	fixedLabelNames  []string
 	fixedLabelValues []string
+	lastUpdates      bool // whether to create metrics with last-update timestamps
 	logger           logger.Logger
 	root             *metricCreatorRoot
 }
@@ -64,7 +69,21 @@ func (creator *metricCreatorBase) AddOrGetPrefix(prefix string, labelNames []str
 			"labelNames":  labelNames,
 			"labelValues": labelValues,
 		}),
-		root: creator.root,
+		lastUpdates: creator.lastUpdates,
+		root:        creator.root,
+	}
+}
+
+// WithLastUpdateTimestamps creates a sub-creator with the same prefix and fixed labels, whose new metrics are
+// accompanied by gauges "<name>_last_update_timestamp_seconds" set to the current time on every update
+func (creator *metricCreatorBase) WithLastUpdateTimestamps() MetricCreator {
+	return &metricCreatorBase{
+		fullPrefix:       creator.fullPrefix,
+		fixedLabelNames:  creator.fixedLabelNames,
+		fixedLabelValues: creator.fixedLabelValues,
+		lastUpdates:      true,
+		logger:           creator.logger,
+		root:             creator.root,
 	}
 }
 
@@ -92,11 +111,14 @@ func (creator *metricCreatorBase) AddOrGetCounterVec(name string, help string, l
 		opts := prometheus.CounterOpts{}
 		opts.Name = fullName
 		opts.Help = help
-		newVec := promext.NewRWCounterVec(opts, allLabelNames)
-		if err := creator.root.registry.Register(newVec); err != nil {
-			creator.logger.Panicf("failed to register CounterVec '%s' with %s: %s", fullName, allLabelNames, err.Error())
+		var newVec *promext.RWCounterVec
+		var lastUpdateVec prometheus.Collector
+		if creator.lastUpdates {
+			newVec, lastUpdateVec = promext.NewRWCounterVecWithLastUpdate(opts, allLabelNames)
+		} else {
+			newVec = promext.NewRWCounterVec(opts, allLabelNames)
 		}
-		creator.root.byName[fullName] = newVec
+		creator.registerVec("CounterVec", fullName, allLabelNames, newVec, lastUpdateVec)
 		return newVec
 	}()
 
@@ -116,7 +138,8 @@ func (creator *metricCreatorBase) AddOrGetGauge(name string, help string, labelN
 		creator.logger.Panicf("failed to add or get Gauge '%s': different lengths of labelNames (%s) and labelValues (%s)",
 			name, strings.Join(labelNames, ","), strings.Join(labelValues, ","))
 	}
-	return creator.AddOrGetGaugeVec(name, help, labelNames, labelValues).WithLabelValues()
+	gauge := creator.AddOrGetGaugeVec(name, help, labelNames, labelValues).WithLabelValues()
+	return creator.applyGaugeOwnership(gauge, name, labelNames, labelValues)
 }
 
 // AddOrGetGaugeVec adds or gets a gauge-vec with leftmost label values
@@ -136,11 +159,14 @@ func (creator *metricCreatorBase) AddOrGetGaugeVec(name string, help string, lab
 		opts := prometheus.GaugeOpts{}
 		opts.Name = fullName
 		opts.Help = help
-		newVec := promext.NewRWGaugeVec(opts, allLabelNames)
-		if err := creator.root.registry.Register(newVec); err != nil {
-			creator.logger.Panicf("failed to register GaugeVec '%s' with %s: %s", fullName, allLabelNames, err.Error())
+		var newVec *promext.RWGaugeVec
+		var lastUpdateVec prometheus.Collector
+		if creator.lastUpdates {
+			newVec, lastUpdateVec = promext.NewRWGaugeVecWithLastUpdate(opts, allLabelNames)
+		} else {
+			newVec = promext.NewRWGaugeVec(opts, allLabelNames)
 		}
-		creator.root.byName[fullName] = newVec
+		creator.registerVec("GaugeVec", fullName, allLabelNames, newVec, lastUpdateVec)
 		return newVec
 	}()
 
@@ -152,16 +178,16 @@ func (creator *metricCreatorBase) AddOrGetGaugeVec(name string, help string, lab
 	return curriedGaugeVec
 }
 
-// AddOrGetCounter adds or gets a counter
+// AddOrGetLazyCounter adds or gets a lazy counter
 func (creator *metricCreatorBase) AddOrGetLazyCounter(name string, help string, labelNames []string, labelValues []string) promext.LazyRWCounter {
 	if len(labelNames) != len(labelValues) {
 		logger.Panicf("failed to add or get LazyCounter '%s' from creator '%s': different lengths of labelNames (%s) and labelValues (%s)",
 			name, creator.fullPrefix, strings.Join(labelNames, ","), strings.Join(labelValues, ","))
 	}
-	return creator.AddOrGetCounterVec(name, help, labelNames, labelValues).WithLabelValues()
+	return creator.AddOrGetLazyCounterVec(name, help, labelNames, labelValues).WithLabelValues()
 }
 
-// AddOrGetCounterVec adds or gets a counter-vec with leftmost label values
+// AddOrGetLazyCounterVec adds or gets a lazy counter-vec with leftmost label values
 func (creator *metricCreatorBase) AddOrGetLazyCounterVec(name string, help string, labelNames []string, leftmostLabelValues []string) *promext.LazyRWCounterVec {
 	fullName, allLabelNames, allLeftmostLabelValues := creator.concatNameAndLabels(name, labelNames, leftmostLabelValues)
 
@@ -176,11 +202,14 @@ func (creator *metricCreatorBase) AddOrGetLazyCounterVec(name string, help strin
 		opts := prometheus.CounterOpts{}
 		opts.Name = fullName
 		opts.Help = help
-		newVec := promext.NewLazyRWCounterVec(opts, allLabelNames)
-		if err := creator.root.registry.Register(newVec); err != nil {
-			creator.logger.Panicf("failed to register LazyCounterVec '%s' with %s: %s", fullName, allLabelNames, err.Error())
+		var newVec *promext.LazyRWCounterVec
+		var lastUpdateVec prometheus.Collector
+		if creator.lastUpdates {
+			newVec, lastUpdateVec = promext.NewLazyRWCounterVecWithLastUpdate(opts, allLabelNames)
+		} else {
+			newVec = promext.NewLazyRWCounterVec(opts, allLabelNames)
 		}
-		creator.root.byName[fullName] = newVec
+		creator.registerVec("LazyCounterVec", fullName, allLabelNames, newVec, lastUpdateVec)
 		return newVec
 	}()
 
@@ -192,6 +221,67 @@ func (creator *metricCreatorBase) AddOrGetLazyCounterVec(name string, help strin
 	return curriedCounterVec
 }
 
+// AddOrGetLazyGauge adds or gets a lazy gauge
+func (creator *metricCreatorBase) AddOrGetLazyGauge(name string, help string, labelNames []string, labelValues []string) promext.LazyRWGauge {
+	if len(labelNames) != len(labelValues) {
+		creator.logger.Panicf("failed to add or get LazyGauge '%s': different lengths of labelNames (%s) and labelValues (%s)",
+			name, strings.Join(labelNames, ","), strings.Join(labelValues, ","))
+	}
+	gauge := creator.AddOrGetLazyGaugeVec(name, help, labelNames, labelValues).WithLabelValues()
+	return creator.applyGaugeOwnership(gauge, name, labelNames, labelValues)
+}
+
+// AddOrGetLazyGaugeVec adds or gets a lazy gauge-vec with leftmost label values
+func (creator *metricCreatorBase) AddOrGetLazyGaugeVec(name string, help string, labelNames []string, leftmostLabelValues []string) *promext.LazyRWGaugeVec {
+	fullName, allLabelNames, allLeftmostLabelValues := creator.concatNameAndLabels(name, labelNames, leftmostLabelValues)
+
+	gaugeVec := func() *promext.LazyRWGaugeVec {
+		creator.root.mapLock.Lock()
+		defer creator.root.mapLock.Unlock()
+
+		if oldVec, ok := creator.root.byName[fullName]; ok {
+			return oldVec.(*promext.LazyRWGaugeVec)
+		}
+
+		opts := prometheus.GaugeOpts{}
+		opts.Name = fullName
+		opts.Help = help
+		var newVec *promext.LazyRWGaugeVec
+		var lastUpdateVec prometheus.Collector
+		if creator.lastUpdates {
+			newVec, lastUpdateVec = promext.NewLazyRWGaugeVecWithLastUpdate(opts, allLabelNames)
+		} else {
+			newVec = promext.NewLazyRWGaugeVec(opts, allLabelNames)
+		}
+		creator.registerVec("LazyGaugeVec", fullName, allLabelNames, newVec, lastUpdateVec)
+		return newVec
+	}()
+
+	curryLabels := buildLabels(allLabelNames, allLeftmostLabelValues)
+	curriedGaugeVec, cerr := gaugeVec.CurryWith(curryLabels)
+	if cerr != nil {
+		creator.logger.Panicf("failed to curry LazyGaugeVec '%s' with %s: %s", fullName, curryLabels, cerr.Error())
+	}
+	return curriedGaugeVec
+}
+
+// registerVec registers a new vector and its companion vector of last-update timestamps if not nil
+//
+// The caller must hold the write lock of root
+func (creator *metricCreatorBase) registerVec(kind string, fullName string, allLabelNames []string, vec prometheus.Collector, lastUpdateVec prometheus.Collector) {
+	if err := creator.root.registry.Register(vec); err != nil {
+		creator.logger.Panicf("failed to register %s '%s' with %s: %s", kind, fullName, allLabelNames, err.Error())
+	}
+	creator.root.byName[fullName] = vec
+	if lastUpdateVec == nil {
+		return
+	}
+	if err := creator.root.registry.Register(lastUpdateVec); err != nil {
+		creator.logger.Panicf("failed to register %s '%s' with %s: %s", kind, fullName+promext.LastUpdateSuffix, allLabelNames, err.Error())
+	}
+	creator.root.byName[fullName+promext.LastUpdateSuffix] = lastUpdateVec
+}
+
 // String implements fmt.Stringer's String function
 func (creator *metricCreatorBase) String() string {
 	return formatMetricDesc(creator.fullPrefix, creator.fixedLabelNames, creator.fixedLabelValues)
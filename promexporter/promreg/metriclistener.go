@@ -21,10 +21,12 @@ import (
 	"net/http/pprof"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
 )
 
 const metricListenerIndexPage = `<html>
@@ -34,17 +36,43 @@ const metricListenerIndexPage = `<html>
 <body>
 	<h1>Metric listener for %s</h1>
 	<ul>
-		<li><a href='/debug/pprof'>/debug/pprof</a></li>
+%s		<li><a href='/debug/pprof'>/debug/pprof</a></li>
 		<li><a href='/metrics'>/metrics</a></li>
 	</ul>
 </body>
 </html>
 `
 
+var reportRegistrationErrorsOnce sync.Once
+
+// ListenerOptions configures optional features of metric listener, all disabled by default
+type ListenerOptions struct {
+	// EnablePprof serves /debug/pprof
+	EnablePprof bool
+
+	// EnableOpenMetrics serves the OpenMetrics format to scrapers requesting it, which is required for exemplars
+	EnableOpenMetrics bool
+
+	// EnableLoggerComponents serves /debug/logger/components, the names of logger components seen so far
+	EnableLoggerComponents bool
+
+	// ShutdownAtExit shuts down the server gracefully by logger.RegisterFlusher, letting in-flight scrapes finish
+	// before the program exits by logger.Exit or Fatal
+	ShutdownAtExit bool
+}
+
 // LaunchMetricListener starts a HTTP server for Prometheus metrics and optionally /debug/pprof
 //
 // If the address contains unspecified port (":0"), a random port is assigned and set to server.Addr
+//
+// To let in-flight scrapes finish at exit, use LaunchMetricListenerWithOptions with ShutdownAtExit
 func LaunchMetricListener(address string, gatherer prometheus.Gatherer, enablePprof bool) *http.Server {
+	return LaunchMetricListenerWithOptions(address, gatherer, ListenerOptions{EnablePprof: enablePprof})
+}
+
+// LaunchMetricListenerWithOptions starts a HTTP server for Prometheus metrics like LaunchMetricListener, with
+// optional features
+func LaunchMetricListenerWithOptions(address string, gatherer prometheus.Gatherer, opts ListenerOptions) *http.Server {
 	mlogger := logger.WithField("component", "MetricListener")
 
 	lsnr, lsnrErr := net.Listen("tcp", address)
@@ -52,15 +80,25 @@ func LaunchMetricListener(address string, gatherer prometheus.Gatherer, enablePp
 		mlogger.Fatal("failed to listen for metrics: ", lsnrErr)
 	}
 	mlogger.Infof("listening on %s for metrics...", lsnr.Addr())
+	reportRegistrationErrorsOnce.Do(func() {
+		for _, err := range promext.RegistrationErrors() {
+			mlogger.Warn("metric registration error during init: ", err)
+		}
+	})
 
-	mux := createServerMux(gatherer)
-	if enablePprof {
+	mux := createServerMux(gatherer, opts)
+	if opts.EnablePprof {
 		registerPprocHandlers(mux)
 	}
 
 	srv := &http.Server{}
 	srv.Addr = lsnr.Addr().String()
 	srv.Handler = mux
+	srv.ErrorLog = mlogger.NewStdLogger(logger.WarnLevel)
+	if opts.ShutdownAtExit {
+		unregister := logger.RegisterFlusher(srv.Shutdown)
+		srv.RegisterOnShutdown(unregister) // if shut down by the caller before exit
+	}
 
 	go func() {
 		if err := srv.Serve(lsnr); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -71,18 +109,23 @@ func LaunchMetricListener(address string, gatherer prometheus.Gatherer, enablePp
 	return srv
 }
 
-func createServerMux(gatherer prometheus.Gatherer) *http.ServeMux {
+func createServerMux(gatherer prometheus.Gatherer, opts ListenerOptions) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mhandler := promhttp.InstrumentMetricHandler(
-		prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+		prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: opts.EnableOpenMetrics}),
 	)
 	mux.Handle("/metrics", mhandler)
 	mux.Handle("/api/v1/metrics/prometheus", mhandler) // for fluent-bit compatibility
+	extraLinks := ""
+	if opts.EnableLoggerComponents {
+		mux.Handle("/debug/logger/components", logger.ComponentsHandler())
+		extraLinks = "\t\t<li><a href='/debug/logger/components'>/debug/logger/components</a></li>\n"
+	}
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
 		appName := filepath.Base(os.Args[0])
-		fmt.Fprintf(w, metricListenerIndexPage, appName, appName)
+		fmt.Fprintf(w, metricListenerIndexPage, appName, appName, extraLinks)
 	})
 
 	return mux
@@ -0,0 +1,60 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promreg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricListenerOpenMetrics(t *testing.T) {
+	factory := NewMetricFactory("testlistener_", nil, nil)
+	factory.AddOrGetCounter("requests_total", "Help requests", nil, nil).Inc()
+
+	scrape := func(opts ListenerOptions) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		rec := httptest.NewRecorder()
+		createServerMux(factory, opts).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := scrape(ListenerOptions{})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain", "OpenMetrics should be disabled by default")
+
+	rec = scrape(ListenerOptions{EnableOpenMetrics: true})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+	assert.Contains(t, rec.Body.String(), "# EOF")
+}
+
+func TestMetricListenerLoggerComponents(t *testing.T) {
+	get := func(opts ListenerOptions, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		createServerMux(NewMetricFactory("testlistener_", nil, nil), opts).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get(ListenerOptions{}, "/debug/logger/components")
+	assert.NotContains(t, rec.Header().Get("Content-Type"), "application/json", "components should not be served by default")
+	assert.NotContains(t, get(ListenerOptions{}, "/").Body.String(), "/debug/logger/components")
+
+	rec = get(ListenerOptions{EnableLoggerComponents: true}, "/debug/logger/components")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, get(ListenerOptions{EnableLoggerComponents: true}, "/").Body.String(), "<li><a href='/debug/logger/components'>")
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promreg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/relex/gotils/logger"
+)
+
+// DefaultRefreshTimeout is the default time budget for all refresh callbacks in a single Gather or Collect call
+const DefaultRefreshTimeout = 5 * time.Second
+
+// refreshCallback is a function registered by AddRefreshCallback to update metrics before they're collected
+type refreshCallback struct {
+	callback func(ctx context.Context)
+	logger   logger.Logger
+	running  atomic.Bool // true while the callback is running, maybe from a previous scrape which timed out
+}
+
+// refreshCallbacks keeps all callbacks of a MetricFactory
+type refreshCallbacks struct {
+	lock      sync.Mutex
+	callbacks []*refreshCallback
+	timeout   time.Duration
+}
+
+// AddRefreshCallback registers a callback to update metrics of this creator right before they're gathered or
+// collected from the MetricFactory, e.g. to set gauges from the current sizes of queues
+//
+// Callbacks run in parallel and should be lightweight. The context is cancelled when the time budget runs out (see
+// SetRefreshTimeout), after which metrics are collected without waiting for late callbacks. A callback still running
+// from a previous scrape is skipped, and panics in callbacks are logged instead of crashing the process.
+func (creator *metricCreatorBase) AddRefreshCallback(name string, callback func(ctx context.Context)) {
+	refresh := &creator.root.refresh
+	refresh.lock.Lock()
+	defer refresh.lock.Unlock()
+
+	refresh.callbacks = append(refresh.callbacks, &refreshCallback{
+		callback: callback,
+		logger:   creator.logger.WithField("refreshCallback", name),
+	})
+}
+
+// SetRefreshTimeout sets the time budget for all refresh callbacks in a single Gather or Collect call, default to
+// DefaultRefreshTimeout
+func (factory *MetricFactory) SetRefreshTimeout(timeout time.Duration) {
+	refresh := &factory.root.refresh
+	refresh.lock.Lock()
+	defer refresh.lock.Unlock()
+
+	refresh.timeout = timeout
+}
+
+// run invokes all callbacks in parallel and waits until they finish or the time budget runs out
+func (refresh *refreshCallbacks) run() {
+	refresh.lock.Lock()
+	callbacks := refresh.callbacks
+	timeout := refresh.timeout
+	refresh.lock.Unlock()
+
+	if len(callbacks) == 0 {
+		return
+	}
+	if timeout <= 0 {
+		timeout = DefaultRefreshTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	for _, cb := range callbacks {
+		if !cb.running.CompareAndSwap(false, true) {
+			cb.logger.Warn("skipped refresh callback still running from previous scrape")
+			continue
+		}
+		wg.Add(1)
+		go func(cb *refreshCallback) {
+			defer wg.Done()
+			defer cb.running.Store(false)
+			defer func() {
+				if r := recover(); r != nil {
+					cb.logger.Errorf("panic in refresh callback: %s", fmt.Sprint(r))
+				}
+			}()
+			cb.callback(ctx)
+		}(cb)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.WithField("component", "MetricFactory").Warnf("refresh callbacks didn't finish in %s", timeout)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promreg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// scrapeRetryInterval is the interval between scrapes in ScrapeHarness.ScrapeUntil
+const scrapeRetryInterval = 50 * time.Millisecond
+
+// ScrapeHarness runs a metric listener on a random local port and scrapes it like Prometheus, for integration tests
+// to verify the actual HTTP exposition instead of internal dumps, e.g.:
+//
+//	harness := promreg.StartScrapeHarness(factory)
+//	defer harness.Close()
+//	families, err := harness.ScrapeUntil(5*time.Second, func(families map[string]*dto.MetricFamily) bool {
+//		return families["myapp_jobs_total"] != nil
+//	})
+//	assert.Equal(t, 3.0, promext.SumExportedMetrics(families["myapp_jobs_total"], map[string]string{"status": "done"}))
+type ScrapeHarness struct {
+	server *http.Server
+	url    string
+	client *http.Client
+}
+
+// StartScrapeHarness starts a metric listener for the gatherer on a random port of localhost
+func StartScrapeHarness(gatherer prometheus.Gatherer) *ScrapeHarness {
+	server := LaunchMetricListener("127.0.0.1:0", gatherer, false)
+	return &ScrapeHarness{
+		server: server,
+		url:    "http://" + server.Addr + "/metrics",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// URL returns the URL of metrics endpoint
+func (h *ScrapeHarness) URL() string {
+	return h.url
+}
+
+// Scrape fetches metrics from the listener and returns the parsed metric families by name
+func (h *ScrapeHarness) Scrape() (map[string]*dto.MetricFamily, error) {
+	resp, err := h.client.Get(h.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape: %s", resp.Status)
+	}
+	var parser expfmt.TextParser
+	families, parseErr := parser.TextToMetricFamilies(resp.Body)
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse scraped metrics: %w", parseErr)
+	}
+	return families, nil
+}
+
+// ScrapeUntil scrapes repeatedly until the condition is met or the timeout is reached
+//
+// Returns the last scraped metric families, with an error if the condition is never met.
+func (h *ScrapeHarness) ScrapeUntil(timeout time.Duration, condition func(families map[string]*dto.MetricFamily) bool) (map[string]*dto.MetricFamily, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		families, err := h.Scrape()
+		if err == nil && condition(families) {
+			return families, nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return families, err
+			}
+			return families, fmt.Errorf("condition not met after %s", timeout)
+		}
+		time.Sleep(scrapeRetryInterval)
+	}
+}
+
+// Close shuts down the metric listener
+func (h *ScrapeHarness) Close() {
+	h.server.Shutdown(context.Background())
+}
@@ -0,0 +1,231 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promtest provides snapshots of metrics and their differences for tests, to assert changes of specific
+// series without comparing full dumps
+package promtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/stretchr/testify/assert"
+)
+
+// Series is a time series identified by metric name and labels
+//
+// Summaries and histograms are flattened into series of "_sum", "_count" and quantiles or "_bucket".
+type Series struct {
+	Name   string
+	Labels map[string]string
+}
+
+// Key returns the series in exposition format without value, e.g. `http_requests_total{code="200",method="GET"}`
+func (s Series) Key() string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, s.Labels[name]))
+	}
+	return s.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// matches checks whether the series has the name and all the given labels
+func (s Series) matches(name string, labels prometheus.Labels) bool {
+	if s.Name != name {
+		return false
+	}
+	for k, v := range labels {
+		if s.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Snapshot is the values of series at a point of time, by series keys
+type Snapshot map[string]Sample
+
+// Sample is a series with value
+type Sample struct {
+	Series
+	Value float64
+}
+
+// TakeSnapshot gathers metrics with the name prefix from the gatherers, or from the DefaultGatherer if none
+func TakeSnapshot(prefix string, gatherers ...prometheus.Gatherer) Snapshot {
+	snapshot, err := ParseSnapshot(promext.DumpMetrics(prefix, false, false, gatherers...))
+	if err != nil {
+		panic(err) // dumped by ourselves
+	}
+	return snapshot
+}
+
+// ParseSnapshot parses a dump from promext.DumpMetrics, with or without comments
+func ParseSnapshot(dump string) (Snapshot, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(dump + "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics dump: %w", err)
+	}
+	snapshot := make(Snapshot)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			addSamples(snapshot, family.GetName(), m)
+		}
+	}
+	return snapshot, nil
+}
+
+func addSamples(snapshot Snapshot, name string, m *dto.Metric) {
+	labels := make(map[string]string, len(m.Label)+1)
+	for _, pair := range m.Label {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	add := func(name string, extraLabel string, extraValue string, value float64) {
+		sampleLabels := labels
+		if extraLabel != "" {
+			sampleLabels = make(map[string]string, len(labels)+1)
+			for k, v := range labels {
+				sampleLabels[k] = v
+			}
+			sampleLabels[extraLabel] = extraValue
+		}
+		series := Series{name, sampleLabels}
+		snapshot[series.Key()] = Sample{series, value}
+	}
+
+	switch {
+	case m.Counter != nil:
+		add(name, "", "", m.Counter.GetValue())
+	case m.Gauge != nil:
+		add(name, "", "", m.Gauge.GetValue())
+	case m.Untyped != nil:
+		add(name, "", "", m.Untyped.GetValue())
+	case m.Summary != nil:
+		add(name+"_sum", "", "", m.Summary.GetSampleSum())
+		add(name+"_count", "", "", float64(m.Summary.GetSampleCount()))
+		for _, q := range m.Summary.Quantile {
+			add(name, "quantile", fmt.Sprint(q.GetQuantile()), q.GetValue())
+		}
+	case m.Histogram != nil:
+		add(name+"_sum", "", "", m.Histogram.GetSampleSum())
+		add(name+"_count", "", "", float64(m.Histogram.GetSampleCount()))
+		for _, b := range m.Histogram.Bucket {
+			add(name+"_bucket", "le", fmt.Sprint(b.GetUpperBound()), float64(b.GetCumulativeCount()))
+		}
+	}
+}
+
+// Sum returns the sum of values of series with the name and labels, which may be a subset of series labels
+func (s Snapshot) Sum(name string, labels prometheus.Labels) float64 {
+	sum := 0.0
+	for _, sample := range s {
+		if sample.matches(name, labels) {
+			sum += sample.Value
+		}
+	}
+	return sum
+}
+
+// Diff is the difference between two snapshots, by series keys
+type Diff struct {
+	Added   map[string]Sample  // series only in the later snapshot
+	Removed map[string]Sample  // series only in the earlier snapshot
+	Changed map[string]float64 // deltas of values of series in both snapshots
+}
+
+// Compare returns the difference from the snapshot "before" to "after"
+func Compare(before, after Snapshot) Diff {
+	diff := Diff{
+		Added:   make(map[string]Sample),
+		Removed: make(map[string]Sample),
+		Changed: make(map[string]float64),
+	}
+	for key, sample := range after {
+		prev, found := before[key]
+		switch {
+		case !found:
+			diff.Added[key] = sample
+		case prev.Value != sample.Value:
+			diff.Changed[key] = sample.Value - prev.Value
+		}
+	}
+	for key, sample := range before {
+		if _, found := after[key]; !found {
+			diff.Removed[key] = sample
+		}
+	}
+	return diff
+}
+
+// IsEmpty checks whether there is no difference
+func (d Diff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns the difference in lines sorted by series keys, e.g. "+ new_total 1", "- old_total 2" and
+// "~ jobs_total +3"
+func (d Diff) String() string {
+	lines := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for key, sample := range d.Added {
+		lines = append(lines, fmt.Sprintf("+ %s %v", key, sample.Value))
+	}
+	for key, sample := range d.Removed {
+		lines = append(lines, fmt.Sprintf("- %s %v", key, sample.Value))
+	}
+	for key, delta := range d.Changed {
+		lines = append(lines, fmt.Sprintf("~ %s %+g", key, delta))
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	return strings.Join(lines, "\n")
+}
+
+// AssertCounterDelta asserts the change of the sum of series with the name and labels from "before" to "after"
+//
+// The labels may be a subset of series labels. Series absent in "before" are counted from zero.
+func AssertCounterDelta(t assert.TestingT, before, after Snapshot, name string, labels prometheus.Labels, expected float64) bool {
+	delta := after.Sum(name, labels) - before.Sum(name, labels)
+	return assert.InDelta(t, expected, delta, 1e-9, "delta of %s%v", name, labels)
+}
+
+// AssertUnchanged asserts no difference of series with the name prefix from "before" to "after"
+func AssertUnchanged(t assert.TestingT, before, after Snapshot, prefix string) bool {
+	diff := Compare(filterSnapshot(before, prefix), filterSnapshot(after, prefix))
+	if diff.IsEmpty() {
+		return true
+	}
+	return assert.Fail(t, fmt.Sprintf("metrics with prefix '%s' changed:\n%s", prefix, diff))
+}
+
+func filterSnapshot(snapshot Snapshot, prefix string) Snapshot {
+	filtered := make(Snapshot, len(snapshot))
+	for key, sample := range snapshot {
+		if strings.HasPrefix(sample.Name, prefix) {
+			filtered[key] = sample
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestCompare(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	jobs := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testpromtest_jobs_total", Help: "jobs"}, []string{"status", "queue"})
+	pending := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "testpromtest_pending", Help: "pending"}, []string{"queue"})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "testpromtest_latency_seconds", Help: "latency", Buckets: []float64{1}})
+	registry.MustRegister(jobs, pending, latency)
+
+	jobs.WithLabelValues("done", "a").Add(2)
+	pending.WithLabelValues("old").Set(5)
+	before := TakeSnapshot("testpromtest_", registry)
+
+	jobs.WithLabelValues("done", "a").Add(3)
+	jobs.WithLabelValues("done", "b").Inc()
+	jobs.WithLabelValues("failed", "a").Inc()
+	pending.DeleteLabelValues("old")
+	latency.Observe(0.5)
+	after := TakeSnapshot("testpromtest_", registry)
+
+	diff := Compare(before, after)
+	assert.Equal(t, `~ testpromtest_jobs_total{queue="a",status="done"} +3
++ testpromtest_jobs_total{queue="a",status="failed"} 1
++ testpromtest_jobs_total{queue="b",status="done"} 1
+~ testpromtest_latency_seconds_bucket{le="+Inf"} +1
+~ testpromtest_latency_seconds_bucket{le="1"} +1
+~ testpromtest_latency_seconds_count +1
+~ testpromtest_latency_seconds_sum +0.5
+- testpromtest_pending{queue="old"} 5`, diff.String())
+
+	AssertCounterDelta(t, before, after, "testpromtest_jobs_total", prometheus.Labels{"status": "done"}, 4)
+	AssertCounterDelta(t, before, after, "testpromtest_jobs_total", nil, 5)
+	AssertUnchanged(t, before, after, "testpromtest_unrelated")
+	failedT := &recordingT{}
+	assert.False(t, AssertUnchanged(failedT, before, after, "testpromtest_pending"))
+	assert.Contains(t, failedT.errors[0], "metrics with prefix 'testpromtest_pending' changed:")
+	assert.Contains(t, failedT.errors[0], "- testpromtest_pending{queue=\"old\"} 5")
+}
+
+func TestParseSnapshot(t *testing.T) {
+	counter := promext.NewRWCounterVec(prometheus.CounterOpts{Name: "testpromtest_parsed_total", Help: "parsed"}, []string{"kind"})
+	counter.WithLabelValues("x").Add(7)
+
+	snapshot, err := ParseSnapshot(promext.DumpMetricsFrom("testpromtest_parsed_total", true, false, counter))
+	assert.NoError(t, err)
+	assert.Equal(t, 7.0, snapshot.Sum("testpromtest_parsed_total", prometheus.Labels{"kind": "x"}))
+
+	_, parseErr := ParseSnapshot("invalid{")
+	assert.Error(t, parseErr)
+}
@@ -14,6 +14,8 @@
 package promexporter
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -28,9 +30,7 @@ const pushMetricsTimeout = 20 * time.Second
 //
 // The URL should contain no path for the official pushgateway
 func PushMetrics(url string, job string) {
-	client := &http.Client{}
-	client.Timeout = pushMetricsTimeout // default is no timeout
-	err := push.New(url, job).Gatherer(prometheus.DefaultGatherer).Client(client).Push()
+	err := newPusher(url, job, nil).Push()
 	if err != nil {
 		logger.Error("failed to push metrics: ", err)
 	}
@@ -41,10 +41,43 @@ func PushMetrics(url string, job string) {
 //
 // The URL should contain no path for the official pushgateway
 func AddMetrics(url string, job string) {
-	client := &http.Client{}
-	client.Timeout = pushMetricsTimeout // default is no timeout
-	err := push.New(url, job).Gatherer(prometheus.DefaultGatherer).Client(client).Add()
+	err := newPusher(url, job, nil).Add()
 	if err != nil {
 		logger.Error("failed to push metrics: ", err)
 	}
 }
+
+// PushMetricsAtExit registers to push all metrics from the gatherer like PushMetrics before the program exits by
+// logger.Exit or Fatal, so that the final metrics of batch jobs are not lost, and returns a function to unregister it
+//
+// The gatherer is prometheus.DefaultGatherer if nil
+func PushMetricsAtExit(url string, job string, gatherer prometheus.Gatherer) func() {
+	return logger.RegisterFlusher(func(ctx context.Context) error {
+		if err := newPusher(url, job, gatherer).PushContext(ctx); err != nil {
+			return fmt.Errorf("failed to push metrics: %w", err)
+		}
+		return nil
+	})
+}
+
+// AddMetricsAtExit registers to push all metrics from the gatherer like AddMetrics before the program exits by
+// logger.Exit or Fatal, and returns a function to unregister it
+//
+// The gatherer is prometheus.DefaultGatherer if nil
+func AddMetricsAtExit(url string, job string, gatherer prometheus.Gatherer) func() {
+	return logger.RegisterFlusher(func(ctx context.Context) error {
+		if err := newPusher(url, job, gatherer).AddContext(ctx); err != nil {
+			return fmt.Errorf("failed to push metrics: %w", err)
+		}
+		return nil
+	})
+}
+
+func newPusher(url string, job string, gatherer prometheus.Gatherer) *push.Pusher {
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	client := &http.Client{}
+	client.Timeout = pushMetricsTimeout // default is no timeout
+	return push.New(url, job).Gatherer(gatherer).Client(client)
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package promexporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPusherWithGatherer(t *testing.T) {
+	var pushed []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushed = append(pushed, r.Method+" "+r.URL.Path+" "+string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	factory := promreg.NewMetricFactory("testpush_", nil, nil)
+	factory.AddOrGetCounter("jobs_total", "Help jobs", nil, nil).Add(3)
+
+	assert.NoError(t, newPusher(gateway.URL, "batch", factory).PushContext(context.Background()))
+	if assert.Len(t, pushed, 1) {
+		assert.Regexp(t, "^PUT /metrics/job/batch ", pushed[0])
+		assert.Contains(t, pushed[0], "testpush_jobs_total", "metrics should be gathered from the given factory")
+	}
+}
@@ -14,11 +14,18 @@
 package promexporter
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/prometheus/common/model"
 	"github.com/samber/lo"
 	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
 )
 
 // Target is a prometheus scrape target.
@@ -64,3 +71,110 @@ func GroupTargets[L comparable](targetList []Target[L]) []TargetGroup[L] {
 
 	return targetGroups
 }
+
+// MarshalTargetGroupsJSON marshals target groups to indented JSON for Prometheus file_sd, with a trailing newline
+//
+// Labels are named by json tags as for encoding/json, and nil groups or targets are marshalled as empty list.
+func MarshalTargetGroupsJSON[L comparable](groups []TargetGroup[L]) ([]byte, error) {
+	if groups == nil {
+		groups = []TargetGroup[L]{} // Prometheus accepts "[]" but not "null"
+	}
+	if slices.ContainsFunc(groups, func(g TargetGroup[L]) bool { return g.Targets == nil }) {
+		groups = slices.Clone(groups)
+		for i := range groups {
+			if groups[i].Targets == nil {
+				groups[i].Targets = []string{}
+			}
+		}
+	}
+	content, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal target groups to JSON: %w", err)
+	}
+	return append(content, '\n'), nil
+}
+
+// MarshalTargetGroupsYAML marshals target groups to YAML for Prometheus file_sd
+//
+// Labels are named by json tags like MarshalTargetGroupsJSON.
+func MarshalTargetGroupsYAML[L comparable](groups []TargetGroup[L]) ([]byte, error) {
+	jsonContent, jerr := MarshalTargetGroupsJSON(groups)
+	if jerr != nil {
+		return nil, jerr
+	}
+
+	// go through JSON to keep the field names from json tags of labels
+	var doc interface{}
+	if err := json.Unmarshal(jsonContent, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal target groups from JSON: %w", err)
+	}
+	yamlContent, yerr := yaml.Marshal(doc)
+	if yerr != nil {
+		return nil, fmt.Errorf("failed to marshal target groups to YAML: %w", yerr)
+	}
+	return yamlContent, nil
+}
+
+// ValidateTargetGroups checks target groups would be accepted by Prometheus file_sd, returning errors of all
+// problems found
+//
+// Labels must be a struct whose label names from GetLabelNames and names in marshalled files (json tags) are legal
+// Prometheus label names, and whose values are strings of valid UTF-8. Groups without targets are valid, as accepted by
+// Prometheus.
+func ValidateTargetGroups[L comparable](groups []TargetGroup[L]) error {
+	var labels L
+	labelType := reflect.TypeOf(labels)
+	if labelType == nil || labelType.Kind() != reflect.Struct {
+		return fmt.Errorf("invalid labels type %v: must be a struct", labelType)
+	}
+
+	var errs []error
+	for _, name := range GetLabelNames(labels) {
+		if !model.LabelName(name).IsValid() {
+			errs = append(errs, fmt.Errorf("invalid label name '%s' in %v: must match [a-zA-Z_][a-zA-Z0-9_]*, set by `label` or `json` tag", name, labelType))
+		}
+	}
+
+	for i, group := range groups {
+		for _, err := range validateLabels(group.Labels) {
+			errs = append(errs, fmt.Errorf("target group #%d %+v: %w", i, group.Labels, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid target groups: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateLabels checks the values of labels and the labels as they're marshalled in file_sd files
+func validateLabels(labels interface{}) []error {
+	var errs []error
+	labelValue := reflect.ValueOf(labels)
+	for i := 0; i < labelValue.NumField(); i++ {
+		field := labelValue.Field(i)
+		if field.Kind() == reflect.String && !utf8.ValidString(field.String()) {
+			errs = append(errs, fmt.Errorf("invalid value of field %s: %q is not valid UTF-8", labelValue.Type().Field(i).Name, field.String()))
+		}
+	}
+
+	content, err := json.Marshal(labels)
+	if err != nil {
+		return append(errs, fmt.Errorf("failed to marshal labels: %w", err))
+	}
+	var labelMap map[string]interface{}
+	if err := json.Unmarshal(content, &labelMap); err != nil {
+		return append(errs, fmt.Errorf("labels are not marshalled as object: %s", content))
+	}
+
+	names := lo.Keys(labelMap)
+	sort.Strings(names)
+	for _, name := range names {
+		if !model.LabelName(name).IsValid() {
+			errs = append(errs, fmt.Errorf("invalid label name '%s' in JSON: must match [a-zA-Z_][a-zA-Z0-9_]*, set by `json` tag", name))
+		}
+		if _, isString := labelMap[name].(string); !isString {
+			errs = append(errs, fmt.Errorf("invalid value of label '%s': %v is not a string", name, labelMap[name]))
+		}
+	}
+	return errs
+}
@@ -51,3 +51,42 @@ func TestGrouping(t *testing.T) {
 		}),
 	)
 }
+
+type invalidLabelSet struct {
+	Job      string `json:"job"`
+	HostName string `json:"host-name"`
+	Port     int    `json:"port"`
+}
+
+func TestMarshalTargetGroups(t *testing.T) {
+	groups := []TargetGroup[labelSet]{{Targets: []string{"host1"}, Labels: labelSet{"1", "red"}}}
+
+	content, err := MarshalTargetGroupsJSON[labelSet](nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", string(content))
+
+	content, err = MarshalTargetGroupsYAML(groups)
+	assert.NoError(t, err)
+	assert.Equal(t, `- labels:
+    Color: red
+    Name: "1"
+  targets:
+    - host1
+`, string(content))
+}
+
+func TestValidateTargetGroups(t *testing.T) {
+	assert.NoError(t, ValidateTargetGroups([]TargetGroup[labelSet]{{Targets: []string{"host1"}, Labels: labelSet{"1", "red"}}}))
+	assert.EqualError(t, ValidateTargetGroups([]TargetGroup[string]{}), "invalid labels type string: must be a struct")
+
+	err := ValidateTargetGroups([]TargetGroup[invalidLabelSet]{
+		{Targets: []string{"host1"}, Labels: invalidLabelSet{"node", "a", 9100}},
+		{Targets: nil, Labels: invalidLabelSet{"node", "\xff", 9100}},
+	})
+	assert.EqualError(t, err, "invalid target groups: invalid label name 'host-name' in promexporter.invalidLabelSet: must match [a-zA-Z_][a-zA-Z0-9_]*, set by `label` or `json` tag\n"+
+		"target group #0 {Job:node HostName:a Port:9100}: invalid label name 'host-name' in JSON: must match [a-zA-Z_][a-zA-Z0-9_]*, set by `json` tag\n"+
+		"target group #0 {Job:node HostName:a Port:9100}: invalid value of label 'port': 9100 is not a string\n"+
+		"target group #1 {Job:node HostName:\xff Port:9100}: invalid value of field HostName: \"\\xff\" is not valid UTF-8\n"+
+		"target group #1 {Job:node HostName:\xff Port:9100}: invalid label name 'host-name' in JSON: must match [a-zA-Z_][a-zA-Z0-9_]*, set by `json` tag\n"+
+		"target group #1 {Job:node HostName:\xff Port:9100}: invalid value of label 'port': 9100 is not a string")
+}
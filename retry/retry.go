@@ -0,0 +1,129 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry provides retry loops with exponential backoff and jitter
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy defines how operations are retried on errors, with exponential backoff and jitter
+type Policy struct {
+	MaxAttempts    int                                                 // max attempts including the first one, no retry if less than 2
+	InitialBackoff time.Duration                                       // wait before the first retry, zero to retry immediately
+	MaxBackoff     time.Duration                                       // max wait between attempts, zero for unlimited
+	Multiplier     float64                                             // growth of backoff after each retry, 2 if zero
+	Jitter         float64                                             // randomization of each backoff from 0 to 1, e.g. 0.2 for +/- 20%
+	MaxElapsedTime time.Duration                                       // max time from the first attempt to a retry, zero for unlimited
+	IsRetryable    func(err error) bool                                // classifier of retryable errors, all errors are retryable if nil
+	OnRetry        func(attempt int, backoff time.Duration, err error) // hook before waiting for each retry, e.g. for logging
+}
+
+// NoRetry is the Policy which never retries
+var NoRetry = Policy{MaxAttempts: 1}
+
+// Delayer is implemented by errors which request a minimum wait before retry, e.g. from the "Retry-After" header
+//
+// The delay overrides shorter backoff, but is still limited by MaxBackoff.
+type Delayer interface {
+	RetryAfter() time.Duration
+}
+
+// Permanent wraps the error to stop retry regardless of IsRetryable, and Do returns the original error
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Do calls fn until it succeeds, the error is not retryable, attempts or elapsed time are exhausted or the context is
+// done
+//
+// The last error is returned as it is, or wrapped with the context error if the context is done.
+func Do(ctx context.Context, policy Policy, fn func() error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if attempt >= policy.MaxAttempts || (policy.IsRetryable != nil && !policy.IsRetryable(err)) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w (after %w)", ctx.Err(), err)
+		}
+
+		backoff := policy.Backoff(attempt)
+		var delayer Delayer
+		if errors.As(err, &delayer) && delayer.RetryAfter() > backoff {
+			backoff = delayer.RetryAfter()
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+		if policy.MaxElapsedTime > 0 && backoff > policy.MaxElapsedTime-time.Since(start) {
+			return err
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, backoff, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (after %w)", ctx.Err(), err)
+		}
+	}
+}
+
+// Backoff returns the wait after the given attempt (1 for the first attempt), with jitter applied
+func (policy Policy) Backoff(attempt int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	backoff := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		backoff += backoff * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	if backoff >= float64(math.MaxInt64) { // unlimited MaxBackoff after many attempts, would overflow on conversion
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(backoff)
+}
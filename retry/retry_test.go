@@ -0,0 +1,140 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type delayedError struct {
+	delay time.Duration
+}
+
+func (e delayedError) Error() string {
+	return "throttled"
+}
+
+func (e delayedError) RetryAfter() time.Duration {
+	return e.delay
+}
+
+func TestDo(t *testing.T) {
+	var retries []string
+	policy := Policy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		IsRetryable:    func(err error) bool { return err.Error() != "fatal" },
+		OnRetry: func(attempt int, backoff time.Duration, err error) {
+			retries = append(retries, backoff.String()+" after "+err.Error())
+		},
+	}
+
+	calls := 0
+	err := Do(context.Background(), policy, func() error {
+		calls++
+		if calls < 3 {
+			return delayedError{time.Duration(calls) * 5 * time.Millisecond}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5ms after throttled", "10ms after throttled"}, retries)
+
+	calls = 0
+	err = Do(context.Background(), policy, func() error {
+		calls++
+		return errors.New("temporary")
+	})
+	assert.EqualError(t, err, "temporary")
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Do(context.Background(), policy, func() error {
+		calls++
+		return errors.New("fatal")
+	})
+	assert.EqualError(t, err, "fatal")
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = Do(context.Background(), policy, func() error {
+		calls++
+		return Permanent(errors.New("bad input"))
+	})
+	assert.EqualError(t, err, "bad input")
+	assert.Equal(t, 1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Do(ctx, policy, func() error {
+		return errors.New("temporary")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.EqualError(t, err, "context canceled (after temporary)")
+}
+
+func TestDoMaxElapsedTime(t *testing.T) {
+	policy := Policy{MaxAttempts: 100, InitialBackoff: 20 * time.Millisecond, Multiplier: 1, MaxElapsedTime: 50 * time.Millisecond}
+	calls := 0
+	start := time.Now()
+	err := Do(context.Background(), policy, func() error {
+		calls++
+		return errors.New("temporary")
+	})
+	assert.EqualError(t, err, "temporary")
+	assert.Equal(t, 3, calls)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 300*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 900*time.Millisecond, policy.Backoff(3))
+	assert.Equal(t, time.Second, policy.Backoff(4))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(1)
+		assert.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 150*time.Millisecond)
+	}
+}
+
+func TestBackoffOverflow(t *testing.T) {
+	policy := Policy{InitialBackoff: time.Second}
+	assert.Equal(t, time.Duration(1<<62), Policy{InitialBackoff: 1}.Backoff(63))
+	for _, attempt := range []int{35, 64, 100, 2000} {
+		assert.Equal(t, time.Duration(math.MaxInt64), policy.Backoff(attempt), "attempt %d", attempt)
+	}
+
+	policy.Jitter = 0.5
+	assert.Greater(t, policy.Backoff(100), time.Duration(0), "jitter shouldn't overflow")
+
+	policy = Policy{MaxAttempts: 3, InitialBackoff: time.Duration(math.MaxInt64), MaxElapsedTime: time.Hour}
+	calls := 0
+	err := Do(context.Background(), policy, func() error {
+		calls++
+		return errors.New("temporary")
+	})
+	assert.EqualError(t, err, "temporary")
+	assert.Equal(t, 1, calls, "huge backoff should exceed MaxElapsedTime instead of overflowing")
+}
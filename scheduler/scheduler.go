@@ -0,0 +1,328 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler runs jobs periodically by cron expressions or intervals
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/mileusna/crontab"
+	"github.com/relex/gotils/logger"
+	"github.com/relex/gotils/promexporter/promext"
+	"github.com/relex/gotils/promexporter/promreg"
+)
+
+// DefaultStopTimeout is the max wait for running jobs to finish at exit
+const DefaultStopTimeout = 30 * time.Second
+
+// OverlapPolicy decides what to do when a job is triggered while its previous run is still in progress
+type OverlapPolicy string
+
+// Overlap policies
+const (
+	OverlapSkip  OverlapPolicy = "skip"  // skip the new run (default)
+	OverlapQueue OverlapPolicy = "queue" // start the new run after the current one finishes, at most one queued
+)
+
+// Outcomes of job runs in metrics
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusSkipped = "skipped"
+)
+
+// JobOptions configures a job
+type JobOptions struct {
+	Timeout    time.Duration // max duration of each run, after which the context of run is cancelled, zero for unlimited
+	Overlap    OverlapPolicy // policy of overlapping runs, OverlapSkip if empty
+	RunAtStart bool          // run once immediately when added
+}
+
+// Scheduler runs jobs by cron expressions or intervals, with panic recovery, logging and metrics with the prefix of
+// its creator:
+//
+//   - scheduler_job_runs_total{job,status}
+//   - scheduler_job_last_success{job}: 1 if the last run succeeded or 0
+//   - scheduler_job_last_duration_milliseconds{job}
+//   - scheduler_job_last_run_timestamp_seconds{job}
+//
+// Jobs receive a context cancelled on timeout or when Stop gives up waiting.
+type Scheduler struct {
+	lock     sync.Mutex
+	jobs     map[string]*job
+	cron     *crontab.Crontab
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	stopped  bool
+	running  sync.WaitGroup
+	logger   logger.Logger
+	metrics  schedulerMetrics
+}
+
+type schedulerMetrics struct {
+	runs         *promext.RWCounterVec
+	lastSuccess  *promext.RWGaugeVec
+	lastDuration *promext.RWGaugeVec
+	lastRun      *promext.RWGaugeVec
+}
+
+type job struct {
+	name    string
+	fn      func(ctx context.Context) error
+	opts    JobOptions
+	running bool
+	queued  bool
+	logger  logger.Logger
+}
+
+// New creates a Scheduler, which is stopped on logger.Exit with DefaultStopTimeout
+//
+// Metrics are created from the given creator with the prefix "scheduler_", or not exported if the creator is nil.
+// Schedulers sharing a creator need different job names.
+func New(creator promreg.MetricCreator) *Scheduler {
+	if creator == nil {
+		creator = promreg.NewMetricFactory("", nil, nil)
+	}
+	metricCreator := creator.AddOrGetPrefix("scheduler_", nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		jobs:     make(map[string]*job),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopping: make(chan struct{}),
+		logger:   logger.WithField("component", "Scheduler"),
+		metrics: schedulerMetrics{
+			runs:         metricCreator.AddOrGetCounterVec("job_runs_total", "Numbers of job runs by status", []string{"job", "status"}, nil),
+			lastSuccess:  metricCreator.AddOrGetGaugeVec("job_last_success", "Whether the last run of job succeeded", []string{"job"}, nil),
+			lastDuration: metricCreator.AddOrGetGaugeVec("job_last_duration_milliseconds", "Duration of the last run of job in milliseconds", []string{"job"}, nil),
+			lastRun:      metricCreator.AddOrGetGaugeVec("job_last_run_timestamp_seconds", "Time of the last finished run of job", []string{"job"}, nil),
+		},
+	}
+	logger.AtExit(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultStopTimeout)
+		defer cancel()
+		if err := s.Stop(ctx); err != nil {
+			s.logger.Warn("failed to stop gracefully: ", err)
+		}
+	})
+	return s
+}
+
+// AddCronJob adds a job to run by the cron expression in local time, e.g. "*/5 * * * *" for every 5 minutes
+//
+// The name must be unique and is used in logs and metrics.
+func (s *Scheduler) AddCronJob(name string, cron string, fn func(ctx context.Context) error, opts JobOptions) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	j, err := s.newJob(name, fn, opts)
+	if err != nil {
+		return err
+	}
+	if s.cron == nil {
+		s.cron = crontab.New()
+	}
+	if err := s.cron.AddJob(cron, s.trigger, j); err != nil {
+		return fmt.Errorf("failed to add job '%s' by cron '%s': %w", name, cron, err)
+	}
+	s.addJob(j)
+	return nil
+}
+
+// AddIntervalJob adds a job to run at the fixed interval, starting one interval after added
+//
+// The name must be unique and is used in logs and metrics.
+func (s *Scheduler) AddIntervalJob(name string, interval time.Duration, fn func(ctx context.Context) error, opts JobOptions) error {
+	if interval <= 0 {
+		return fmt.Errorf("failed to add job '%s': invalid interval %s", name, interval)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	j, err := s.newJob(name, fn, opts)
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.trigger(j)
+			case <-s.stopping:
+				return
+			}
+		}
+	}()
+	s.addJob(j)
+	return nil
+}
+
+// Trigger runs the job immediately, subject to its overlap policy
+//
+// Returns false if the job is not found or the scheduler is stopped.
+func (s *Scheduler) Trigger(name string) bool {
+	s.lock.Lock()
+	j, found := s.jobs[name]
+	s.lock.Unlock()
+	if !found {
+		return false
+	}
+	return s.trigger(j)
+}
+
+// Stop stops triggering jobs and waits for running ones to finish, until the context is done
+//
+// If the context is done first, contexts of running jobs are cancelled and the error of context is returned.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.lock.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stopping)
+		if s.cron != nil {
+			s.cron.Shutdown()
+		}
+	}
+	s.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	defer s.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("aborted running jobs: %w", ctx.Err())
+	}
+}
+
+// newJob creates a job after validation, must be called with the lock held
+func (s *Scheduler) newJob(name string, fn func(ctx context.Context) error, opts JobOptions) (*job, error) {
+	if s.stopped {
+		return nil, fmt.Errorf("failed to add job '%s': scheduler stopped", name)
+	}
+	if _, exists := s.jobs[name]; exists {
+		return nil, fmt.Errorf("failed to add job '%s': duplicate name", name)
+	}
+	switch opts.Overlap {
+	case "":
+		opts.Overlap = OverlapSkip
+	case OverlapSkip, OverlapQueue:
+	default:
+		return nil, fmt.Errorf("failed to add job '%s': invalid overlap policy '%s'", name, opts.Overlap)
+	}
+	return &job{
+		name:   name,
+		fn:     fn,
+		opts:   opts,
+		logger: s.logger.WithField("job", name),
+	}, nil
+}
+
+// addJob registers the job and runs it if RunAtStart, must be called with the lock held
+func (s *Scheduler) addJob(j *job) {
+	s.jobs[j.name] = j
+	if j.opts.RunAtStart {
+		s.start(j)
+	}
+	j.logger.Info("added job")
+}
+
+// trigger runs the job in background if not running, or else skips or queues it by the overlap policy
+func (s *Scheduler) trigger(j *job) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return false
+	}
+	if !j.running {
+		s.start(j)
+		return true
+	}
+	if j.opts.Overlap == OverlapQueue {
+		j.queued = true
+		return true
+	}
+	j.logger.Warn("skipped run as the previous one is still running")
+	s.metrics.runs.WithLabelValues(j.name, StatusSkipped).Inc()
+	return true
+}
+
+// start runs the job and any queued run in background, must be called with the lock held
+func (s *Scheduler) start(j *job) {
+	j.running = true
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		for {
+			s.runOnce(j)
+
+			s.lock.Lock()
+			if !j.queued || s.stopped {
+				j.running = false
+				j.queued = false
+				s.lock.Unlock()
+				return
+			}
+			j.queued = false
+			s.lock.Unlock()
+		}
+	}()
+}
+
+func (s *Scheduler) runOnce(j *job) {
+	ctx := s.ctx
+	if j.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.opts.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := runWithRecovery(ctx, j)
+	duration := time.Since(start)
+
+	s.metrics.lastDuration.WithLabelValues(j.name).Set(duration.Milliseconds())
+	s.metrics.lastRun.WithLabelValues(j.name).Set(time.Now().Unix())
+	if err != nil {
+		j.logger.WithField("duration", duration.String()).Errorf("job failed: %v", err)
+		s.metrics.runs.WithLabelValues(j.name, StatusFailure).Inc()
+		s.metrics.lastSuccess.WithLabelValues(j.name).Set(0)
+		return
+	}
+	j.logger.WithField("duration", duration.String()).Debug("job succeeded")
+	s.metrics.runs.WithLabelValues(j.name, StatusSuccess).Inc()
+	s.metrics.lastSuccess.WithLabelValues(j.name).Set(1)
+}
+
+func runWithRecovery(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			j.logger.WithField("stack", string(debug.Stack())).Errorf("panic in job: %v", rec)
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return j.fn(ctx)
+}
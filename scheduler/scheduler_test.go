@@ -0,0 +1,101 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/relex/gotils/promexporter/promext/promexttest"
+	"github.com/relex/gotils/promexporter/promreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	factory := promreg.NewMetricFactory("test_", nil, nil)
+	s := New(factory)
+	never := time.Hour
+	noop := func(ctx context.Context) error { return nil }
+
+	assert.ErrorContains(t, s.AddCronJob("badcron", "* * *", noop, JobOptions{}), "failed to add job 'badcron' by cron '* * *'")
+	assert.NoError(t, s.AddCronJob("cron", "*/5 * * * *", noop, JobOptions{}))
+	assert.EqualError(t, s.AddIntervalJob("cron", never, noop, JobOptions{}), "failed to add job 'cron': duplicate name")
+	assert.False(t, s.Trigger("unknown"))
+
+	var ticks atomic.Int32
+	assert.NoError(t, s.AddIntervalJob("interval", 10*time.Millisecond, func(ctx context.Context) error {
+		ticks.Add(1)
+		return nil
+	}, JobOptions{}))
+	assert.Eventually(t, func() bool { return ticks.Load() >= 2 }, time.Second, 5*time.Millisecond)
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	blocking := func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	assert.NoError(t, s.AddIntervalJob("skipped", never, blocking, JobOptions{RunAtStart: true}))
+	<-started
+	assert.True(t, s.Trigger("skipped"))
+	assert.EqualValues(t, 1, s.metrics.runs.WithLabelValues("skipped", StatusSkipped).Get())
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		return s.metrics.runs.WithLabelValues("skipped", StatusSuccess).Get() == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, s.AddIntervalJob("queued", never, blocking, JobOptions{Overlap: OverlapQueue}))
+	s.Trigger("queued")
+	<-started
+	s.Trigger("queued")
+	s.Trigger("queued")
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		return s.metrics.runs.WithLabelValues("queued", StatusSuccess).Get() == 2
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, s.AddIntervalJob("panic", never, func(ctx context.Context) error { panic("oops") }, JobOptions{}))
+	assert.NoError(t, s.AddIntervalJob("timeout", never, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, JobOptions{Timeout: 10 * time.Millisecond}))
+	s.Trigger("panic")
+	s.Trigger("timeout")
+	assert.Eventually(t, func() bool {
+		return s.metrics.runs.WithLabelValues("panic", StatusFailure).Get() == 1 &&
+			s.metrics.runs.WithLabelValues("timeout", StatusFailure).Get() == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.EqualValues(t, 0, s.metrics.lastSuccess.WithLabelValues("timeout").Get())
+	assert.EqualValues(t, 1, s.metrics.lastSuccess.WithLabelValues("queued").Get())
+	assert.Equal(t, 2.0, promexttest.CollectAsMap(factory)[`test_scheduler_job_runs_total{job="queued",status="success"}`])
+
+	var cancelled atomic.Bool
+	assert.NoError(t, s.AddIntervalJob("stuck", never, func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled.Store(true)
+		return ctx.Err()
+	}, JobOptions{RunAtStart: true}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(s.Stop(ctx), context.DeadlineExceeded))
+	assert.Eventually(t, cancelled.Load, time.Second, 5*time.Millisecond)
+	assert.False(t, s.Trigger("interval"))
+	assert.ErrorContains(t, s.AddIntervalJob("late", never, noop, JobOptions{}), "scheduler stopped")
+}
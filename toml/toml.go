@@ -0,0 +1,62 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package toml provides TOML file helpers with the same API as the json package
+package toml
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/relex/gotils/io"
+)
+
+// MarshalTOMLWithSorting marshals TOML with keys sorted by alphabet, including fields of structs
+//
+// Tables are always placed after plain keys in the same table, as required by TOML.
+func MarshalTOMLWithSorting(input interface{}) ([]byte, error) {
+	data, err := toml.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling intermediate input: %v: %w", input, err)
+	}
+	// remarshal from map to sort keys
+	fieldMap := make(map[string]interface{})
+	if err := toml.Unmarshal(data, &fieldMap); err != nil {
+		return nil, fmt.Errorf("error unmarshalling intermediate field map: %v: %w", fieldMap, err)
+	}
+	sortedTOML, sErr := toml.Marshal(fieldMap)
+	if sErr != nil {
+		return nil, fmt.Errorf("error marshalling intermediate field map: %v: %w", fieldMap, sErr)
+	}
+	return sortedTOML, nil
+}
+
+// MarshalToTOMLFile marshals structure to a TOML file at the specified path, with keys sorted and the file replaced
+// atomically
+func MarshalToTOMLFile(filepath string, input interface{}) error {
+	data, err := MarshalTOMLWithSorting(input)
+	if err != nil {
+		return err
+	}
+	return io.TryWriteFileAtomically(filepath, data)
+}
+
+// UnmarshalFromTOMLFile unmarshals TOML file at the specified path
+func UnmarshalFromTOMLFile(filepath string, outputPtr interface{}) error {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	return toml.Unmarshal(data, outputPtr)
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	Name   string            `toml:"name"`
+	Ports  []int             `toml:"ports"`
+	Labels map[string]string `toml:"labels"`
+}
+
+func TestTOMLFile(t *testing.T) {
+	server := testServer{Name: "web", Ports: []int{80, 443}, Labels: map[string]string{"zone": "a", "env": "prod"}}
+
+	path := filepath.Join(t.TempDir(), "server.toml")
+	assert.NoError(t, MarshalToTOMLFile(path, server))
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, `name = 'web'
+ports = [80, 443]
+
+[labels]
+env = 'prod'
+zone = 'a'
+`, string(data))
+
+	loaded := testServer{}
+	assert.NoError(t, UnmarshalFromTOMLFile(path, &loaded))
+	assert.Equal(t, server, loaded)
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/relex/gotils/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/relex/gotils/tracing"
+
+type loggerKey struct{}
+
+// StartSpan starts a span by the global tracer provider, as a child of the span in ctx if any
+//
+// The returned context carries the span and the logger from ctx with fields "traceID" and "spanID", which can be
+// retrieved by Logger. The span must be ended by EndSpan.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+	spanContext := span.SpanContext()
+	if !spanContext.IsValid() {
+		return ctx, span
+	}
+	spanLogger := Logger(ctx).WithFields(map[string]interface{}{
+		"traceID": spanContext.TraceID().String(),
+		"spanID":  spanContext.SpanID().String(),
+	})
+	return ContextWithLogger(ctx, spanLogger), span
+}
+
+// EndSpan ends the span and records the error if not nil
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ContextWithLogger returns a new context carrying the logger, for Logger to retrieve
+func ContextWithLogger(ctx context.Context, contextLogger logger.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, contextLogger)
+}
+
+// Logger returns the logger in ctx set by StartSpan or ContextWithLogger, or the root logger if not found
+func Logger(ctx context.Context) logger.Logger {
+	if contextLogger, found := ctx.Value(loggerKey{}).(logger.Logger); found {
+		return contextLogger
+	}
+	return logger.Root()
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing sets up OpenTelemetry tracing for services from the standard env vars
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/relex/gotils/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ShutdownTimeout is the max time to flush remaining spans at exit
+const ShutdownTimeout = 5 * time.Second
+
+var tracingLogger = logger.WithField("component", "Tracing")
+
+// Setup initializes the global tracer provider and propagators, exporting spans by OTLP over HTTP
+//
+// Configuration is read from the standard OpenTelemetry env vars, mainly:
+//
+//   - OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, e.g. "http://otel-collector:4318"
+//   - OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG, e.g. "parentbased_traceidratio" and "0.1"
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES, e.g. "deployment.environment=prod"
+//
+// Tracing stays disabled (no-op) if no endpoint is set or OTEL_SDK_DISABLED is "true".
+//
+// Remaining spans are flushed on logger.Exit. The returned provider can be passed to other packages, e.g.
+// dbutil.EnableTracing.
+func Setup(serviceName string) (trace.TracerProvider, error) {
+	provider, shutdown, err := setup(context.Background(), serviceName)
+	if err != nil {
+		return nil, err
+	}
+	logger.AtExit(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			tracingLogger.Warnf("failed to flush spans: %v", err)
+		}
+	})
+	return provider, nil
+}
+
+func setup(ctx context.Context, serviceName string) (trace.TracerProvider, func(ctx context.Context) error, error) {
+	if !isEnabledByEnv() {
+		tracingLogger.Debug("tracing disabled: no OTLP endpoint configured")
+		return otel.GetTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, resErr := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME overrides the given name
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if resErr != nil {
+		return nil, nil, fmt.Errorf("failed to create resource: %w", resErr)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		tracingLogger.Warn(err)
+	}))
+
+	tracingLogger.Infof("tracing enabled for service '%s'", serviceName)
+	return provider, provider.Shutdown, nil
+}
+
+func isEnabledByEnv() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
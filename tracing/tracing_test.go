@@ -0,0 +1,67 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/relex/gotils/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetup(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	_, _, err := setup(context.Background(), "test")
+	assert.NoError(t, err)
+
+	ctx, span := StartSpan(context.Background(), "noop")
+	assert.False(t, span.SpanContext().IsValid())
+	assert.Equal(t, logger.Root(), Logger(ctx))
+
+	var exported atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		exported.Add(1)
+	}))
+	defer collector.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+
+	_, shutdown, err := setup(context.Background(), "test")
+	assert.NoError(t, err)
+
+	logOutput := &bytes.Buffer{}
+	logger.SetJSONFormat()
+	logger.SetOutput(logOutput)
+	defer logger.SetOutput(os.Stderr)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	ctx, child := StartSpan(ctx, "child")
+	assert.Equal(t, parent.SpanContext().TraceID(), child.SpanContext().TraceID())
+	Logger(ctx).Info("in child")
+	assert.Contains(t, logOutput.String(), `"traceID":"`+child.SpanContext().TraceID().String()+`"`)
+	assert.Contains(t, logOutput.String(), `"spanID":"`+child.SpanContext().SpanID().String()+`"`)
+	EndSpan(child, errors.New("failed"))
+	EndSpan(parent, nil)
+
+	assert.NoError(t, shutdown(context.Background()))
+	assert.EqualValues(t, 1, exported.Load())
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 RELEX Oy
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package yaml provides YAML file helpers with the same API as the json package
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/relex/gotils/io"
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalYAMLWithSorting marshals YAML with keys sorted by alphabet, including fields of structs
+func MarshalYAMLWithSorting(input interface{}) ([]byte, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(input); err != nil {
+		return nil, fmt.Errorf("error marshalling intermediate input: %v: %w", input, err)
+	}
+	sortMappingKeys(node)
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, fmt.Errorf("error marshalling intermediate node: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("error marshalling intermediate node: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalToYAMLFile marshals structure to a YAML file at the specified path, with keys sorted and the file replaced
+// atomically
+func MarshalToYAMLFile(filepath string, input interface{}) error {
+	data, err := MarshalYAMLWithSorting(input)
+	if err != nil {
+		return err
+	}
+	return io.TryWriteFileAtomically(filepath, data)
+}
+
+// UnmarshalFromYAMLFile unmarshals YAML file at the specified path
+func UnmarshalFromYAMLFile(filepath string, outputPtr interface{}) error {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, outputPtr)
+}
+
+// sortMappingKeys sorts keys of all mappings in the node tree, keeping each value after its key
+func sortMappingKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i][0].Value < pairs[j][0].Value
+		})
+		for i, pair := range pairs {
+			node.Content[2*i] = pair[0]
+			node.Content[2*i+1] = pair[1]
+		}
+	}
+	for _, child := range node.Content {
+		sortMappingKeys(child)
+	}
+}